@@ -35,7 +35,20 @@ func main() {
 		cmd.SetupCommand(),
 		cmd.CreateCommand(),
 		cmd.FetchCommand(),
+		cmd.CopyCommand(),
+		cmd.LabelCommand(),
 		cmd.RevokeCommand(),
+		cmd.RestoreCommand(),
+		cmd.DeleteCommand(),
+		cmd.UndeleteCommand(),
+		cmd.GCCommand(),
+		cmd.RetentionCommand(),
+		cmd.PruneCommand(),
+		cmd.ListCommand(),
+		cmd.ImportCommand(),
+		cmd.ExportCommand(),
+		cmd.BackupCommand(),
+		cmd.HistoryCommand(),
 		cmd.DaemonCommand(),
 	}
 
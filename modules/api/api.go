@@ -13,16 +13,123 @@
 
 package api
 
+import "time"
+
 // SecretRecord abstracts the secret record to store and retrieve
 type SecretRecord struct {
-	Name    string `json:"name"`
-	Serial  int64  `json:"serial"`
-	Payload string `json:"payload"`
-	Active  bool   `json:"active"`
+	Name          string            `json:"name"`
+	Serial        int64             `json:"serial"`
+	Payload       string            `json:"payload"`
+	Active        bool              `json:"active"`
+	CreatedAt     *time.Time        `json:"createdAt,omitempty"`
+	CreatedBy     string            `json:"createdBy,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	RestoredAt    *time.Time        `json:"restoredAt,omitempty"`
+	RestoredBy    string            `json:"restoredBy,omitempty"`
+	RestoreReason string            `json:"restoreReason,omitempty"`
+	ExpiresAt     *time.Time        `json:"expiresAt,omitempty"`
+	ContentType   string            `json:"contentType,omitempty"`
+	// Encoding is set to Base64Encoding when the payload has been encoded
+	// to be transported as JSON
+	Encoding string `json:"encoding,omitempty"`
+	// ExpectedSerial is the serial that the caller saving the secret expects
+	// its latest version to have, if any. Zero means that the secret is not
+	// expected to exist
+	ExpectedSerial *int64 `json:"-"`
+}
+
+// SecretRequest identifies a secret to be fetched along with others. The
+// latest active version of the secret is fetched unless a serial or a label
+// is specified
+type SecretRequest struct {
+	Name   string `json:"name"`
+	Serial int64  `json:"serial,omitempty"`
+	Label  string `json:"label,omitempty"`
+}
+
+// BatchGetRequest defines the api structure to be used by remote clients to
+// fetch several secrets at once
+type BatchGetRequest struct {
+	Secrets []*SecretRequest `json:"secrets"`
+}
+
+// SecretBatch maps the names of secrets fetched at once to the secrets.
+// Errors maps the names of the secrets that could not be fetched to the
+// reasons why
+type SecretBatch struct {
+	Secrets map[string]*SecretRecord `json:"secrets"`
+	Errors  map[string]string        `json:"errors,omitempty"`
+}
+
+// SecretSummary describes a secret without its payload
+type SecretSummary struct {
+	Name          string            `json:"name"`
+	Serial        int64             `json:"serial"`
+	Active        bool              `json:"active"`
+	CreatedAt     *time.Time        `json:"createdAt,omitempty"`
+	CreatedBy     string            `json:"createdBy,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	RestoredAt    *time.Time        `json:"restoredAt,omitempty"`
+	RestoredBy    string            `json:"restoredBy,omitempty"`
+	RestoreReason string            `json:"restoreReason,omitempty"`
+	ExpiresAt     *time.Time        `json:"expiresAt,omitempty"`
+	ContentType   string            `json:"contentType,omitempty"`
+	DeletedAt     *time.Time        `json:"deletedAt,omitempty"`
+	DeletedBy     string            `json:"deletedBy,omitempty"`
+	PurgeAt       *time.Time        `json:"purgeAt,omitempty"`
+}
+
+// SecretList defines a page of secrets returned when listing secrets.
+// NextToken is set when there are more secrets to be listed
+type SecretList struct {
+	Secrets   []*SecretSummary `json:"secrets"`
+	NextToken string           `json:"nextToken,omitempty"`
+}
+
+// SecretHistory lists every version of a secret along with its labels
+type SecretHistory struct {
+	Name     string           `json:"name"`
+	Versions []*SecretSummary `json:"versions"`
+	Labels   map[string]int64 `json:"labels,omitempty"`
+}
+
+// LabelRequest defines the api structure to be used by remote clients to
+// point a label of a secret at one of its versions
+type LabelRequest struct {
+	Serial int64 `json:"serial"`
 }
 
 // SecretPayload defines the api structure to be used by remote
 // clients to post secrets
 type SecretPayload struct {
-	Payload string `json:"payload"`
+	Payload     string            `json:"payload"`
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	ExpiresAt   *time.Time        `json:"expiresAt,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	// Encoding is set to Base64Encoding when the payload is base64 encoded,
+	// which is required for binary payloads
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// RestoreRequest defines the api structure to be used by remote clients to
+// restore revoked versions of secrets
+type RestoreRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// DeleteResponse defines the api structure returned to remote clients when a
+// secret is scheduled for deletion
+type DeleteResponse struct {
+	PurgeAt *time.Time `json:"purgeAt"`
+}
+
+// RetentionPolicy defines which versions of a secret are kept. Versions that
+// are not among the last MaxVersions versions and are older than MaxAge fall
+// outside the policy. A zero value for either disables that limit
+type RetentionPolicy struct {
+	MaxVersions int64         `json:"maxVersions,omitempty"`
+	MaxAge      time.Duration `json:"maxAge,omitempty"`
 }
@@ -0,0 +1,61 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StructuredContentType is the content type of structured secrets, whose
+// payload is a JSON object mapping the names of fields to their values
+const StructuredContentType = "application/vnd.ecs-secrets.fields+json"
+
+// ParseFields parses the payload of a structured secret into its fields
+func ParseFields(payload string) (map[string]string, error) {
+	var fields map[string]string
+	err := json.Unmarshal([]byte(payload), &fields)
+	if err != nil || fields == nil {
+		return nil, fmt.Errorf("Payload of a structured secret should be a JSON object with string values")
+	}
+	return fields, nil
+}
+
+// EncodeFields encodes the fields of a structured secret as its payload
+func EncodeFields(fields map[string]string) (string, error) {
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("Error encoding fields: %v", err)
+	}
+	return string(payload), nil
+}
+
+// Field returns the value of a field of the structured secret record
+func (record *SecretRecord) Field(name string) (string, error) {
+	if record.ContentType != StructuredContentType {
+		return "", fmt.Errorf("Secret '%s' is not a structured secret", record.Name)
+	}
+	if !record.Active {
+		return "", fmt.Errorf("Version %d of secret '%s' is not active", record.Serial, record.Name)
+	}
+	fields, err := ParseFields(record.Payload)
+	if err != nil {
+		return "", err
+	}
+	value, ok := fields[name]
+	if !ok {
+		return "", fmt.Errorf("Field '%s' not found in secret '%s'", name, record.Name)
+	}
+	return value, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(`{"host":"db.example.com","password":"hunter2"}`)
+	if err != nil {
+		t.Fatalf("Error parsing fields: %v", err)
+	}
+	expectedFields := map[string]string{"host": "db.example.com", "password": "hunter2"}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("Incorrect fields parsed: %v", fields)
+	}
+}
+
+func TestParseFieldsInvalid(t *testing.T) {
+	for _, payload := range []string{"", "hunter2", "null", `["a"]`, `{"port":5432}`} {
+		_, err := ParseFields(payload)
+		if err == nil {
+			t.Errorf("Expected error parsing fields of %q", payload)
+		}
+	}
+}
+
+func TestField(t *testing.T) {
+	record := &SecretRecord{
+		Name:        "db",
+		Payload:     `{"password":"hunter2"}`,
+		Active:      true,
+		ContentType: StructuredContentType,
+	}
+	value, err := record.Field("password")
+	if err != nil {
+		t.Fatalf("Error getting field: %v", err)
+	}
+	if value != "hunter2" {
+		t.Errorf("Incorrect value of field: %s", value)
+	}
+
+	_, err = record.Field("user")
+	if err == nil {
+		t.Error("Expected error getting field that is not set")
+	}
+}
+
+func TestFieldNotStructured(t *testing.T) {
+	record := &SecretRecord{
+		Name:    "db",
+		Payload: `{"password":"hunter2"}`,
+		Active:  true,
+	}
+	_, err := record.Field("password")
+	if err == nil {
+		t.Error("Expected error getting field of secret that is not structured")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Base64Encoding is the encoding of payloads that are base64 encoded
+	Base64Encoding = "base64"
+	// OctetStreamContentType is the content type of binary payloads
+	OctetStreamContentType = "application/octet-stream"
+)
+
+// IsBinary returns true if payloads of the content type cannot be transported
+// as text. Secrets saved without a content type are text
+func IsBinary(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-yaml", "application/yaml":
+		return false
+	}
+	return true
+}
+
+// EncodePayload base64 encodes the payload of the secret record if it is
+// binary, so that it can be transported as JSON
+func (record *SecretRecord) EncodePayload() {
+	if record.Encoding != "" {
+		return
+	}
+	if !IsBinary(record.ContentType) && utf8.ValidString(record.Payload) {
+		return
+	}
+	record.Payload = base64.StdEncoding.EncodeToString([]byte(record.Payload))
+	record.Encoding = Base64Encoding
+}
+
+// DecodePayload returns the payload of the request, decoding it if it has
+// been encoded
+func (payload *SecretPayload) DecodePayload() (string, error) {
+	switch payload.Encoding {
+	case "":
+		return payload.Payload, nil
+	case Base64Encoding:
+		decoded, err := base64.StdEncoding.DecodeString(payload.Payload)
+		if err != nil {
+			return "", fmt.Errorf("Error decoding payload: %v", err)
+		}
+		return string(decoded), nil
+	}
+	return "", fmt.Errorf("Unsupported payload encoding: %s", payload.Encoding)
+}
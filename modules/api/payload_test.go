@@ -0,0 +1,82 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import "testing"
+
+func TestIsBinary(t *testing.T) {
+	for contentType, binary := range map[string]bool{
+		"":                            false,
+		"text/plain; charset=utf-8":   false,
+		"application/json":            false,
+		"application/vnd.api+json":    false,
+		"application/octet-stream":    true,
+		"application/x-java-keystore": true,
+		"application/x-pkcs12":        true,
+		"not a content type":          true,
+	} {
+		if IsBinary(contentType) != binary {
+			t.Errorf("Expected IsBinary(%q) to be %v", contentType, binary)
+		}
+	}
+}
+
+func TestEncodePayload(t *testing.T) {
+	record := &SecretRecord{Payload: "\xfe\xed\xfe\xed", ContentType: "application/x-java-keystore"}
+	record.EncodePayload()
+	if record.Payload != "/u3+7Q==" || record.Encoding != Base64Encoding {
+		t.Errorf("Incorrect encoding of binary payload: %s, %s", record.Payload, record.Encoding)
+	}
+
+	// Encoding is idempotent
+	record.EncodePayload()
+	if record.Payload != "/u3+7Q==" {
+		t.Errorf("Payload encoded twice: %s", record.Payload)
+	}
+}
+
+func TestEncodePayloadText(t *testing.T) {
+	record := &SecretRecord{Payload: "hunter2"}
+	record.EncodePayload()
+	if record.Payload != "hunter2" || record.Encoding != "" {
+		t.Errorf("Text payload should not be encoded: %s, %s", record.Payload, record.Encoding)
+	}
+}
+
+func TestEncodePayloadInvalidUTF8(t *testing.T) {
+	record := &SecretRecord{Payload: "\xff"}
+	record.EncodePayload()
+	if record.Payload != "/w==" || record.Encoding != Base64Encoding {
+		t.Errorf("Payload that is not valid UTF-8 should be encoded: %s, %s", record.Payload, record.Encoding)
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	payload := &SecretPayload{Payload: "/u3+7Q==", Encoding: Base64Encoding}
+	decoded, err := payload.DecodePayload()
+	if err != nil {
+		t.Fatalf("Error decoding payload: %v", err)
+	}
+	if decoded != "\xfe\xed\xfe\xed" {
+		t.Errorf("Incorrect payload decoded: %q", decoded)
+	}
+}
+
+func TestDecodePayloadUnsupportedEncoding(t *testing.T) {
+	payload := &SecretPayload{Payload: "foo", Encoding: "rot13"}
+	_, err := payload.DecodePayload()
+	if err == nil {
+		t.Error("Expected error decoding payload with unsupported encoding")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package backend
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	ssmclient "github.com/awslabs/ecs-secrets/modules/ssm/client"
+	vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Names of the backends that secrets can be stored in
+const (
+	DynamoDB = "dynamodb"
+	SSM      = "ssm"
+	S3       = "s3"
+	File     = "file"
+	Vault    = "vault"
+	// Default is the backend used when none is selected
+	Default = DynamoDB
+)
+
+// Options of the ssm backend
+const (
+	// ParameterTypeOption selects whether secrets are stored in String or
+	// SecureString parameters. Defaults to SecureString
+	ParameterTypeOption = "parameter-type"
+	// KeyIDOption specifies the KMS key that SecureString parameters are
+	// encrypted with. Defaults to the default key of the account
+	KeyIDOption = "key-id"
+)
+
+// Options of the s3 backend
+const (
+	// BucketOption specifies the versioned bucket that secrets are stored in
+	BucketOption = "bucket"
+	// KeyPrefixOption specifies the prefix of the keys of the objects of
+	// every application. Defaults to ecs-secrets/
+	KeyPrefixOption = "key-prefix"
+)
+
+// Options of the file backend
+const (
+	// DataFileOption specifies the local file that secrets are stored in
+	DataFileOption = "data-file"
+)
+
+// Options of the vault backend. The address, token and namespace default to
+// the VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE environment variables used
+// by Vault's own tools. KeyPrefixOption is supported as well, and defaults to
+// ecs-secrets/
+const (
+	// AddressOption specifies the address of the Vault server
+	AddressOption = "address"
+	// MountOption specifies the path the KV version 2 secrets engine is
+	// mounted at. Defaults to secret
+	MountOption = "mount"
+	// NamespaceOption specifies the Vault Enterprise namespace to use
+	NamespaceOption = "namespace"
+	// TokenOption specifies the token to authenticate with
+	TokenOption = "token"
+	// RoleIDOption and SecretIDOption specify the AppRole credentials to log
+	// in with when there is no token
+	RoleIDOption   = "role-id"
+	SecretIDOption = "secret-id"
+	// AppRoleMountOption specifies the path the AppRole auth method is
+	// mounted at. Defaults to approle
+	AppRoleMountOption = "approle-mount"
+	// TransitMountOption specifies the path the transit secrets engine that
+	// encrypts the data keys of secrets is mounted at. Defaults to transit
+	TransitMountOption = "transit-mount"
+	// TransitKeyOption specifies the transit key that encrypts the data keys
+	// of secrets. Defaults to ecs-secrets-<application name>
+	TransitKeyOption = "transit-key"
+)
+
+// transitKeyPrefix is prepended to the application name to name its default
+// transit key
+const transitKeyPrefix = "ecs-secrets-"
+
+// Factory creates the data access layer for the secrets of the application,
+// configured with the options of the backend
+type Factory func(appName string, options map[string]string) (dao.DAO, error)
+
+var factories = map[string]Factory{
+	DynamoDB: newDynamoDBDAO,
+	SSM:      newSSMDAO,
+	S3:       newS3DAO,
+	File:     newFileDAO,
+	Vault:    newVaultDAO,
+}
+
+// Register makes a backend available under the name. It panics if a backend
+// has already been registered under the same name
+func Register(name string, factory Factory) {
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("Backend '%s' is already registered", name))
+	}
+	factories[name] = factory
+}
+
+// Names returns the names of every backend available, sorted
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the data access layer for the secrets of the application in
+// the backend with the name
+func New(name string, appName string, options map[string]string) (dao.DAO, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown backend '%s', should be one of: %s", name, strings.Join(Names(), ", "))
+	}
+	return factory(appName, options)
+}
+
+// checkOptions returns an error if any of the options is not one of those
+// supported by the backend
+func checkOptions(name string, options map[string]string, supported ...string) error {
+	for option := range options {
+		found := false
+		for _, supportedOption := range supported {
+			if option == supportedOption {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Unknown option '%s' for backend '%s'", option, name)
+		}
+	}
+	return nil
+}
+
+func newDynamoDBDAO(appName string, options map[string]string) (dao.DAO, error) {
+	err := checkOptions(DynamoDB, options)
+	if err != nil {
+		return nil, err
+	}
+	return dao.NewDAO(appName, dynamodb.New(session.New())), nil
+}
+
+func newSSMDAO(appName string, options map[string]string) (dao.DAO, error) {
+	err := checkOptions(SSM, options, ParameterTypeOption, KeyIDOption)
+	if err != nil {
+		return nil, err
+	}
+	parameterType := options[ParameterTypeOption]
+	if parameterType == "" {
+		parameterType = ssmclient.SecureStringParameterType
+	}
+	return dao.NewSSMDAO(appName, ssmclient.NewClient(session.New()), parameterType, options[KeyIDOption])
+}
+
+func newS3DAO(appName string, options map[string]string) (dao.DAO, error) {
+	err := checkOptions(S3, options, BucketOption, KeyPrefixOption)
+	if err != nil {
+		return nil, err
+	}
+	bucket := options[BucketOption]
+	if bucket == "" {
+		return nil, fmt.Errorf("Missing required option '%s' for backend '%s'", BucketOption, S3)
+	}
+	keyPrefix, ok := options[KeyPrefixOption]
+	if !ok {
+		keyPrefix = dao.DefaultS3KeyPrefix
+	}
+	return dao.NewS3DAO(appName, s3.New(session.New()), bucket, keyPrefix), nil
+}
+
+func newFileDAO(appName string, options map[string]string) (dao.DAO, error) {
+	err := checkOptions(File, options, DataFileOption)
+	if err != nil {
+		return nil, err
+	}
+	dataFile := options[DataFileOption]
+	if dataFile == "" {
+		return nil, fmt.Errorf("Missing required option '%s' for backend '%s'", DataFileOption, File)
+	}
+	return dao.NewFileDAO(appName, dataFile)
+}
+
+func newVaultDAO(appName string, options map[string]string) (dao.DAO, error) {
+	vaultClient, err := NewVaultClient(options)
+	if err != nil {
+		return nil, err
+	}
+	keyPrefix, ok := options[KeyPrefixOption]
+	if !ok {
+		keyPrefix = dao.DefaultVaultKeyPrefix
+	}
+	return dao.NewVaultDAO(appName, vaultClient, keyPrefix), nil
+}
+
+// NewVaultClient creates a Vault client configured with the options of the
+// vault backend. It is used to store secrets as well as to encrypt their data
+// keys and identify the caller, so that the backend needs no AWS service
+func NewVaultClient(options map[string]string) (vaultclient.Client, error) {
+	err := checkOptions(Vault, options, AddressOption, MountOption, NamespaceOption, TokenOption,
+		RoleIDOption, SecretIDOption, AppRoleMountOption, KeyPrefixOption, TransitMountOption, TransitKeyOption)
+	if err != nil {
+		return nil, err
+	}
+	config := vaultclient.Config{
+		Address:      optionOrEnv(options, AddressOption, "VAULT_ADDR"),
+		Mount:        options[MountOption],
+		Namespace:    optionOrEnv(options, NamespaceOption, "VAULT_NAMESPACE"),
+		RoleID:       options[RoleIDOption],
+		SecretID:     options[SecretIDOption],
+		AppRoleMount: options[AppRoleMountOption],
+		TransitMount: options[TransitMountOption],
+	}
+	// A token in the environment must not take precedence over AppRole
+	// credentials that are set explicitly
+	if config.RoleID == "" {
+		config.Token = optionOrEnv(options, TokenOption, "VAULT_TOKEN")
+	} else {
+		config.Token = options[TokenOption]
+	}
+	return vaultclient.NewClient(config)
+}
+
+// TransitKeyName returns the name of the transit key that encrypts the data
+// keys of the secrets of the application in the vault backend
+func TransitKeyName(appName string, options map[string]string) string {
+	if keyName := options[TransitKeyOption]; keyName != "" {
+		return keyName
+	}
+	return transitKeyPrefix + appName
+}
+
+// optionOrEnv returns the option if it is set, the environment variable
+// otherwise
+func optionOrEnv(options map[string]string, option string, envVar string) string {
+	if value := options[option]; value != "" {
+		return value
+	}
+	return os.Getenv(envVar)
+}
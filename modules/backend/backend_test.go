@@ -0,0 +1,95 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package backend
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/dao"
+)
+
+func TestNewUnknownBackend(t *testing.T) {
+	_, err := New("etcd", "myapp", nil)
+	if err == nil {
+		t.Error("Expected error creating an unknown backend")
+	}
+}
+
+func TestNewUnknownOption(t *testing.T) {
+	_, err := New(DynamoDB, "myapp", map[string]string{KeyIDOption: "alias/key"})
+	if err == nil {
+		t.Error("Expected error creating a backend with an unknown option")
+	}
+}
+
+func TestNewSSMInvalidParameterType(t *testing.T) {
+	_, err := New(SSM, "myapp", map[string]string{ParameterTypeOption: "StringList"})
+	if err == nil {
+		t.Error("Expected error creating the ssm backend with an unknown parameter type")
+	}
+}
+
+func TestNewS3MissingBucket(t *testing.T) {
+	_, err := New(S3, "myapp", map[string]string{KeyPrefixOption: "secrets/"})
+	if err == nil {
+		t.Error("Expected error creating the s3 backend without a bucket")
+	}
+}
+
+func TestNewFileMissingDataFile(t *testing.T) {
+	_, err := New(File, "myapp", map[string]string{})
+	if err == nil {
+		t.Error("Expected error creating the file backend without a data file")
+	}
+}
+
+func TestNewVaultMissingCredentials(t *testing.T) {
+	defer os.Setenv("VAULT_TOKEN", os.Getenv("VAULT_TOKEN"))
+	os.Unsetenv("VAULT_TOKEN")
+	_, err := New(Vault, "myapp", map[string]string{AddressOption: "http://127.0.0.1:8200"})
+	if err == nil {
+		t.Error("Expected error creating the vault backend without a token or role ID")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var createdFor string
+	Register("test", func(appName string, options map[string]string) (dao.DAO, error) {
+		createdFor = appName
+		return nil, nil
+	})
+	defer delete(factories, "test")
+
+	_, err := New("test", "myapp", nil)
+	if err != nil {
+		t.Fatalf("Error creating registered backend: %v", err)
+	}
+	if createdFor != "myapp" {
+		t.Errorf("Expected backend to be created for myapp, got '%s'", createdFor)
+	}
+	if !reflect.DeepEqual(Names(), []string{DynamoDB, File, S3, SSM, "test", Vault}) {
+		t.Errorf("Unexpected backend names: %v", Names())
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a duplicate backend to panic")
+		}
+	}()
+	Register(DynamoDB, newDynamoDBDAO)
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package backup creates archives of the secrets of an application and
+// restores them into a fresh data store. Archives hold the encrypted data of
+// every version along with its data key, which is still wrapped by KMS, so an
+// archive reveals no more than the DynamoDB table it was created from.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/awslabs/ecs-secrets/modules/crypt"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+)
+
+// ArchiveVersion is the version of the archive format. It is incremented
+// whenever the format changes in a way that older releases can't read
+const ArchiveVersion = 1
+
+// listPageSize is the number of secrets listed per page when reading the names
+// of every secret of the application
+const listPageSize = int64(100)
+
+// Archive defines the contents of a backup of the secrets of an application.
+// Records holds every version of every secret, including revoked ones, and
+// Metadata holds the labels and retention policies of the secrets and of the
+// application
+type Archive struct {
+	Version     int                 `json:"version"`
+	Application string              `json:"application"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	Records     []*dao.SecretRecord `json:"records"`
+	Metadata    []*dao.SecretLabels `json:"metadata,omitempty"`
+}
+
+// Create reads every version of every secret of the application, along with
+// their metadata, into an archive. Nothing is decrypted
+func Create(appName string, secretDAO dao.DAO) (*Archive, error) {
+	names, err := listNames(secretDAO)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []dao.SecretKey
+	var metadata []*dao.SecretLabels
+	for _, name := range append(names, dao.ApplicationMetadataName) {
+		secretMetadata, err := secretDAO.GetSecretLabels(name)
+		if err != nil {
+			return nil, err
+		}
+		// Metadata that has never been put has no revision
+		if secretMetadata.Revision != 0 {
+			metadata = append(metadata, secretMetadata)
+		}
+		if name == dao.ApplicationMetadataName {
+			continue
+		}
+
+		versions, err := secretDAO.ListVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			keys = append(keys, dao.SecretKey{Name: name, Serial: version.Serial})
+		}
+	}
+
+	records, err := secretDAO.GetSecretRecords(keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != len(keys) {
+		return nil, fmt.Errorf("Versions of secrets were deleted while the backup was being created, found %d of %d versions",
+			len(records), len(keys))
+	}
+	for _, record := range records {
+		// The archive holds the encrypted data of a version in one piece,
+		// however it is stored
+		record.Chunks = 0
+		record.EncryptedDataChecksum = ""
+	}
+	sort.Sort(recordsByKey(records))
+
+	return &Archive{
+		Version:     ArchiveVersion,
+		Application: appName,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Records:     records,
+		Metadata:    metadata,
+	}, nil
+}
+
+// Verify checks that the archive can be restored for the application by
+// decrypting every version in it
+func Verify(archive *Archive, appName string, crypter crypt.Crypter) error {
+	if archive.Application != appName {
+		return fmt.Errorf("Backup of application '%s' cannot be restored for application '%s'",
+			archive.Application, appName)
+	}
+	for _, record := range archive.Records {
+		_, err := crypter.DecryptSecret(record)
+		if err != nil {
+			return fmt.Errorf("Error decrypting secret '%s' with serial %d: %v", record.Name, record.Serial, err)
+		}
+	}
+	return nil
+}
+
+// Restore verifies the archive and then writes all of its versions and
+// metadata to the data store of the application, which must not have any
+// secrets yet. Serials, labels and revocations are kept as they were when
+// the archive was created
+func Restore(archive *Archive, appName string, secretDAO dao.DAO, crypter crypt.Crypter) error {
+	err := Verify(archive, appName, crypter)
+	if err != nil {
+		return err
+	}
+	names, err := listNames(secretDAO)
+	if err != nil {
+		return err
+	}
+	if len(names) != 0 {
+		return fmt.Errorf("Backup can only be restored for an application without secrets, found %d secrets", len(names))
+	}
+
+	for _, record := range archive.Records {
+		err = secretDAO.PutSecretRecord(record)
+		if err != nil {
+			return fmt.Errorf("Error restoring secret '%s' with serial %d: %v", record.Name, record.Serial, err)
+		}
+	}
+	for _, metadata := range archive.Metadata {
+		// The metadata is new to the data store it is restored to
+		newMetadata := *metadata
+		newMetadata.Revision = 0
+		err = secretDAO.PutSecretLabels(&newMetadata)
+		if err != nil {
+			return fmt.Errorf("Error restoring metadata of secret '%s': %v", metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+// Encode encodes the archive as JSON
+func Encode(archive *Archive) ([]byte, error) {
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding backup: %v", err)
+	}
+	return data, nil
+}
+
+// Decode decodes an archive from JSON. An error is returned if the archive
+// was written in a version of the format that is not supported
+func Decode(data []byte) (*Archive, error) {
+	archive := &Archive{}
+	err := json.Unmarshal(data, archive)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding backup: %v", err)
+	}
+	if archive.Version != ArchiveVersion {
+		return nil, fmt.Errorf("Unsupported backup version %d, expected %d", archive.Version, ArchiveVersion)
+	}
+	return archive, nil
+}
+
+// listNames lists the names of all of the secrets in the data store
+func listNames(secretDAO dao.DAO) ([]string, error) {
+	var names []string
+	nextToken := ""
+	for {
+		records, token, err := secretDAO.ListSecrets("", nextToken, listPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			names = append(names, record.Name)
+		}
+		nextToken = token
+		if nextToken == "" {
+			return names, nil
+		}
+	}
+}
+
+// recordsByKey sorts secret records by name and serial
+type recordsByKey []*dao.SecretRecord
+
+func (records recordsByKey) Len() int {
+	return len(records)
+}
+
+func (records recordsByKey) Less(i, j int) bool {
+	if records[i].Name != records[j].Name {
+		return records[i].Name < records[j].Name
+	}
+	return records[i].Serial < records[j].Serial
+}
+
+func (records recordsByKey) Swap(i, j int) {
+	records[i], records[j] = records[j], records[i]
+}
@@ -0,0 +1,235 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package backup
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/crypt/mock"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/dao/mock"
+	"github.com/awslabs/ecs-secrets/modules/dynamodb/client/mock"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	fooLabels := &dao.SecretLabels{Name: "foo", Labels: map[string]int64{"current": 2}, Revision: 3}
+	gomock.InOrder(
+		mockDAO.EXPECT().ListSecrets("", "", listPageSize).Return([]*dao.SecretRecord{{Name: "foo"}}, "foo", nil),
+		mockDAO.EXPECT().ListSecrets("", "foo", listPageSize).Return([]*dao.SecretRecord{{Name: "bar"}}, "", nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(fooLabels, nil),
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{{Name: "foo", Serial: 1}, {Name: "foo", Serial: 2}}, nil),
+		mockDAO.EXPECT().GetSecretLabels("bar").Return(&dao.SecretLabels{Name: "bar"}, nil),
+		mockDAO.EXPECT().ListVersions("bar").Return([]*dao.SecretRecord{{Name: "bar", Serial: 1}}, nil),
+		mockDAO.EXPECT().GetSecretLabels(dao.ApplicationMetadataName).Return(&dao.SecretLabels{Name: dao.ApplicationMetadataName}, nil),
+		mockDAO.EXPECT().GetSecretRecords([]dao.SecretKey{{Name: "foo", Serial: 1}, {Name: "foo", Serial: 2}, {Name: "bar", Serial: 1}}).Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 2, EncryptedData: "c2", Active: true, Chunks: 2, EncryptedDataChecksum: "abc"},
+			{Name: "bar", Serial: 1, EncryptedData: "c3", Active: true},
+			{Name: "foo", Serial: 1, EncryptedData: "c1"},
+		}, nil),
+	)
+
+	archive, err := Create("myapp", mockDAO)
+	if err != nil {
+		t.Fatalf("Error creating backup: %v", err)
+	}
+	if archive.Version != ArchiveVersion || archive.Application != "myapp" {
+		t.Errorf("Unexpected archive version %d or application %s", archive.Version, archive.Application)
+	}
+	expectedRecords := []*dao.SecretRecord{
+		{Name: "bar", Serial: 1, EncryptedData: "c3", Active: true},
+		{Name: "foo", Serial: 1, EncryptedData: "c1"},
+		{Name: "foo", Serial: 2, EncryptedData: "c2", Active: true},
+	}
+	if !reflect.DeepEqual(archive.Records, expectedRecords) {
+		t.Errorf("Mismatch between expected and archived records: %v != %v", archive.Records, expectedRecords)
+	}
+	if !reflect.DeepEqual(archive.Metadata, []*dao.SecretLabels{fooLabels}) {
+		t.Errorf("Unexpected archived metadata: %v", archive.Metadata)
+	}
+}
+
+func TestCreateDynamoDB(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+	gomock.InOrder(
+		ddbClient.EXPECT().Scan(gomock.Any()).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("0")}, "LatestSerial": {N: aws.String("1")}},
+			},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("1")}}},
+			},
+		}, nil),
+		ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil),
+		ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("1")}},
+			},
+		}, nil),
+		ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {{
+					"Name":          {S: aws.String("foo")},
+					"Serial":        {N: aws.String("1")},
+					"EncryptedData": {S: aws.String("c1")},
+					"Active":        {BOOL: aws.Bool(true)},
+				}},
+			},
+		}, nil),
+	)
+
+	archive, err := Create("myapp", dao.NewDAO("myapp", ddbClient))
+	if err != nil {
+		t.Fatalf("Error creating backup: %v", err)
+	}
+	expectedRecords := []*dao.SecretRecord{{Name: "foo", Serial: 1, EncryptedData: "c1", Active: true}}
+	if !reflect.DeepEqual(archive.Records, expectedRecords) {
+		t.Errorf("Mismatch between expected and archived records: %v != %v", archive.Records, expectedRecords)
+	}
+}
+
+func TestCreateVersionDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	gomock.InOrder(
+		mockDAO.EXPECT().ListSecrets("", "", listPageSize).Return([]*dao.SecretRecord{{Name: "foo"}}, "", nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{Name: "foo"}, nil),
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{{Name: "foo", Serial: 1}, {Name: "foo", Serial: 2}}, nil),
+		mockDAO.EXPECT().GetSecretLabels(dao.ApplicationMetadataName).Return(&dao.SecretLabels{Name: dao.ApplicationMetadataName}, nil),
+		mockDAO.EXPECT().GetSecretRecords(gomock.Any()).Return([]*dao.SecretRecord{{Name: "foo", Serial: 2}}, nil),
+	)
+
+	_, err := Create("myapp", mockDAO)
+	if err == nil {
+		t.Error("Expected error when a version is deleted during the backup")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	record1 := &dao.SecretRecord{Name: "foo", Serial: 1, EncryptedData: "c1"}
+	record2 := &dao.SecretRecord{Name: "foo", Serial: 2, EncryptedData: "c2", Active: true}
+	archive := &Archive{
+		Version:     ArchiveVersion,
+		Application: "myapp",
+		Records:     []*dao.SecretRecord{record1, record2},
+		Metadata:    []*dao.SecretLabels{{Name: "foo", Labels: map[string]int64{"current": 2}, Revision: 3}},
+	}
+	gomock.InOrder(
+		crypter.EXPECT().DecryptSecret(record1).Return([]byte("old"), nil),
+		crypter.EXPECT().DecryptSecret(record2).Return([]byte("new"), nil),
+		mockDAO.EXPECT().ListSecrets("", "", listPageSize).Return(nil, "", nil),
+		mockDAO.EXPECT().PutSecretRecord(record1).Return(nil),
+		mockDAO.EXPECT().PutSecretRecord(record2).Return(nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{Name: "foo", Labels: map[string]int64{"current": 2}}).Return(nil),
+	)
+
+	err := Restore(archive, "myapp", mockDAO, crypter)
+	if err != nil {
+		t.Fatalf("Error restoring backup: %v", err)
+	}
+}
+
+func TestRestoreDecryptionFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	record1 := &dao.SecretRecord{Name: "foo", Serial: 1, EncryptedData: "c1"}
+	record2 := &dao.SecretRecord{Name: "foo", Serial: 2, EncryptedData: "c2"}
+	archive := &Archive{
+		Version:     ArchiveVersion,
+		Application: "myapp",
+		Records:     []*dao.SecretRecord{record1, record2},
+	}
+	gomock.InOrder(
+		crypter.EXPECT().DecryptSecret(record1).Return([]byte("old"), nil),
+		crypter.EXPECT().DecryptSecret(record2).Return(nil, fmt.Errorf("AccessDenied")),
+	)
+
+	err := Restore(archive, "myapp", mockDAO, crypter)
+	if err == nil {
+		t.Error("Expected error when a version in the backup cannot be decrypted")
+	}
+}
+
+func TestRestoreNotEmpty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	archive := &Archive{Version: ArchiveVersion, Application: "myapp"}
+	mockDAO.EXPECT().ListSecrets("", "", listPageSize).Return([]*dao.SecretRecord{{Name: "foo"}}, "", nil)
+
+	err := Restore(archive, "myapp", mockDAO, crypter)
+	if err == nil {
+		t.Error("Expected error when restoring a backup for an application with secrets")
+	}
+}
+
+func TestVerifyOtherApplication(t *testing.T) {
+	archive := &Archive{Version: ArchiveVersion, Application: "otherapp"}
+	err := Verify(archive, "myapp", nil)
+	if err == nil {
+		t.Error("Expected error when verifying a backup of another application")
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	archive := &Archive{
+		Version:     ArchiveVersion,
+		Application: "myapp",
+		Records:     []*dao.SecretRecord{{Name: "foo", Serial: 1, EncryptedData: "c1", EncryptedDataKey: "k1", Active: true}},
+	}
+	data, err := Encode(archive)
+	if err != nil {
+		t.Fatalf("Error encoding backup: %v", err)
+	}
+	decodedArchive, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Error decoding backup: %v", err)
+	}
+	if !reflect.DeepEqual(decodedArchive, archive) {
+		t.Errorf("Mismatch between encoded and decoded backup: %v != %v", decodedArchive, archive)
+	}
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	_, err := Decode([]byte(`{"version":2,"application":"myapp","records":[]}`))
+	if err == nil {
+		t.Error("Expected error decoding a backup with an unsupported version")
+	}
+}
@@ -64,6 +64,10 @@ var template = `
           "ReadCapacityUnits" : "5",
           "WriteCapacityUnits" : "5"
         },
+        "TimeToLiveSpecification" : {
+          "AttributeName" : "ExpiresAt",
+          "Enabled" : true
+        },
         "TableName" : {"Ref": "ECSSecretsTableName"}
       }
     },
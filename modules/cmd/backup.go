@@ -0,0 +1,119 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/backup"
+	"github.com/awslabs/ecs-secrets/modules/crypt"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/urfave/cli"
+)
+
+// backupFileMode is the mode of backup files, which are only readable by
+// their owner
+const backupFileMode = 0600
+
+func backupCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretDAO, err := createSecretDAO(context, appName)
+	if err != nil {
+		return err
+	}
+	return doBackup(context, appName, secretDAO, &ioutilFileWriter{})
+}
+
+func doBackup(context *cli.Context, appName string, secretDAO dao.DAO, writer fileWriter) error {
+	// Validate that the backup file has been specified
+	fileName, err := getRequiredArgumentFromFlag(context, fileFlag)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Creating backup of application: %s", appName)
+	archive, err := backup.Create(appName, secretDAO)
+	if err != nil {
+		return err
+	}
+	data, err := backup.Encode(archive)
+	if err != nil {
+		return err
+	}
+	err = writer.WriteFile(fileName, data, backupFileMode)
+	if err != nil {
+		return fmt.Errorf("Error writing to %s: %v", fileName, err)
+	}
+
+	log.Infof("Backed up %d versions of secrets of application: %s to %s", len(archive.Records), appName, fileName)
+	return nil
+}
+
+func restoreBackupCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretDAO, err := createSecretDAO(context, appName)
+	if err != nil {
+		return err
+	}
+	crypter, err := createCrypter(context, appName)
+	if err != nil {
+		return err
+	}
+	return doRestoreBackup(context, appName, secretDAO, crypter, &ioutilFileReader{})
+}
+
+func doRestoreBackup(context *cli.Context, appName string, secretDAO dao.DAO, crypter crypt.Crypter, reader fileReader) error {
+	// Validate that the backup file has been specified
+	fileName, err := getRequiredArgumentFromFlag(context, fileFlag)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Reading from %s", fileName)
+	data, err := reader.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Error reading from %s: %v", fileName, err)
+	}
+	archive, err := backup.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	if context.Bool(dryRunFlag) {
+		err = backup.Verify(archive, appName, crypter)
+		if err != nil {
+			return err
+		}
+		log.Infof("Dry run: all %d versions of secrets in the backup created at %v can be restored",
+			len(archive.Records), archive.CreatedAt)
+		return nil
+	}
+
+	err = backup.Restore(archive, appName, secretDAO, crypter)
+	if err != nil {
+		return err
+	}
+	log.Infof("Restored %d versions of secrets of application: %s from the backup created at %v",
+		len(archive.Records), appName, archive.CreatedAt)
+	return nil
+}
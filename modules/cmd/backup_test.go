@@ -0,0 +1,99 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/backup"
+	"github.com/awslabs/ecs-secrets/modules/crypt/mock"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/dao/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestBackupCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(fileFlag, "backup.json", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := backupCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoBackup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(fileFlag, "backup.json", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	gomock.InOrder(
+		mockDAO.EXPECT().ListSecrets("", "", int64(100)).Return([]*dao.SecretRecord{{Name: "foo"}}, "", nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{Name: "foo"}, nil),
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{{Name: "foo", Serial: 1}}, nil),
+		mockDAO.EXPECT().GetSecretLabels(dao.ApplicationMetadataName).Return(&dao.SecretLabels{Name: dao.ApplicationMetadataName}, nil),
+		mockDAO.EXPECT().GetSecretRecords([]dao.SecretKey{{Name: "foo", Serial: 1}}).Return([]*dao.SecretRecord{{Name: "foo", Serial: 1, EncryptedData: "c1"}}, nil),
+	)
+	writer := &mockWriter{}
+	err := doBackup(context, "myapp", mockDAO, writer)
+	if err != nil {
+		t.Fatalf("Error creating backup: %v", err)
+	}
+	if writer.filename != "backup.json" || writer.perm != backupFileMode {
+		t.Errorf("Backup written to %s with mode %v", writer.filename, writer.perm)
+	}
+	archive, err := backup.Decode(writer.data)
+	if err != nil {
+		t.Fatalf("Error decoding backup: %v", err)
+	}
+	if len(archive.Records) != 1 {
+		t.Errorf("Unexpected records in backup: %v", archive.Records)
+	}
+}
+
+func TestDoRestoreBackupDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(fileFlag, "backup.json", "")
+	flagSet.Bool(dryRunFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	crypter.EXPECT().DecryptSecret(&dao.SecretRecord{Name: "foo", Serial: 1, EncryptedData: "c1"}).Return([]byte("bar"), nil)
+	reader := &mockReader{payload: []byte(`{"version":1,"application":"myapp","records":[{"Name":"foo","Serial":1,"EncryptedData":"c1"}]}`)}
+	err := doRestoreBackup(context, "myapp", mockDAO, crypter, reader)
+	if err != nil {
+		t.Errorf("Error verifying backup: %v", err)
+	}
+}
+
+func TestDoRestoreBackupFileNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doRestoreBackup(context, "myapp", nil, nil, &mockReader{})
+	if err == nil {
+		t.Error("Expected error when backup file is not specified")
+	}
+}
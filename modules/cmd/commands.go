@@ -13,7 +13,15 @@
 
 package cmd
 
-import "github.com/urfave/cli"
+import (
+	"strings"
+
+	"github.com/awslabs/ecs-secrets/modules/backend"
+	"github.com/awslabs/ecs-secrets/modules/format"
+	"github.com/awslabs/ecs-secrets/modules/server"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
 
 const (
 	applicationNameFlag = "application-name"
@@ -25,6 +33,34 @@ const (
 	payloadFlag                = "payload"
 	payloadLocationFlag        = "payload-location"
 	serialFlag                 = "serial"
+	descriptionFlag            = "description"
+	tagFlag                    = "tag"
+	allFlag                    = "all"
+	purgeFlag                  = "purge"
+	confirmFlag                = "confirm"
+	reasonFlag                 = "reason"
+	labelFlag                  = "label"
+	maxVersionsFlag            = "max-versions"
+	maxAgeFlag                 = "max-age"
+	expiresInFlag              = "expires-in"
+	contentTypeFlag            = "content-type"
+	rawFlag                    = "raw"
+	fieldFlag                  = "field"
+	setFlag                    = "set"
+	prefixFlag                 = "prefix"
+	expectedSerialFlag         = "expected-serial"
+	fromAppFlag                = "from-app"
+	toAppFlag                  = "to-app"
+	formatFlag                 = "format"
+	fileFlag                   = "file"
+	dryRunFlag                 = "dry-run"
+	recoveryWindowFlag         = "recovery-window"
+	backendFlag                = "backend"
+	backendOptionFlag          = "backend-option"
+	dataFileFlag               = "data-file"
+	devFlag                    = "dev"
+	seedFileFlag               = "seed-file"
+	addressFlag                = "address"
 )
 
 // appendCommonCLIFlags returns a modified list of flags by appending the
@@ -48,6 +84,34 @@ func appendCommonCLIFlags(flags []cli.Flag) []cli.Flag {
 	}...)
 }
 
+// appendStoreCLIFlags returns a modified list of flags by appending the flags
+// that select the backend secrets are stored in, along with the common CLI
+// flags, to the list provided in function arguments
+func appendStoreCLIFlags(flags []cli.Flag) []cli.Flag {
+	return appendCommonCLIFlags(append(flags, backendCLIFlags()...))
+}
+
+func backendCLIFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   backendFlag,
+			Value:  backend.Default,
+			EnvVar: "ECS_SECRETS_BACKEND",
+			Usage:  "Specifies the backend secrets are stored in, one of: " + strings.Join(backend.Names(), ", ") + ".",
+		},
+		cli.StringSliceFlag{
+			Name:   backendOptionFlag,
+			EnvVar: "ECS_SECRETS_BACKEND_OPTIONS",
+			Usage:  "Specifies an option of the backend, as key=value. Can be repeated.",
+		},
+		cli.StringFlag{
+			Name:   dataFileFlag,
+			EnvVar: "ECS_SECRETS_DATA_FILE",
+			Usage:  "Specifies the file secrets are stored in by the file backend. Same as --backend-option data-file=<file>.",
+		},
+	}
+}
+
 func SetupCommand() cli.Command {
 	return cli.Command{
 		Name:   "setup",
@@ -74,7 +138,7 @@ func CreateCommand() cli.Command {
 		Usage:   "Creates a secret.",
 		Before:  beforeCommand,
 		Action:  createCommand,
-		Flags: appendCommonCLIFlags([]cli.Flag{
+		Flags: appendStoreCLIFlags([]cli.Flag{
 			cli.StringFlag{
 				Name:  nameFlag,
 				Usage: "Specifies the name of the secret.",
@@ -87,6 +151,30 @@ func CreateCommand() cli.Command {
 				Name:  payloadLocationFlag,
 				Usage: "Specifies the file path containing the secrets payload.",
 			},
+			cli.StringFlag{
+				Name:  descriptionFlag,
+				Usage: "Specifies a description for this version of the secret.",
+			},
+			cli.StringSliceFlag{
+				Name:  tagFlag,
+				Usage: "Specifies a tag for this version of the secret, as key=value. Can be repeated.",
+			},
+			cli.DurationFlag{
+				Name:  expiresInFlag,
+				Usage: "Specifies how long this version of the secret is valid for, such as 720h.",
+			},
+			cli.StringFlag{
+				Name:  contentTypeFlag,
+				Usage: "Specifies the content type of the payload, such as application/x-pkcs12. Defaults to application/octet-stream for binary files.",
+			},
+			cli.StringSliceFlag{
+				Name:  setFlag,
+				Usage: "Sets a field of a structured secret, as key=value, keeping the other fields of its latest version. Can be repeated.",
+			},
+			cli.Int64Flag{
+				Name:  expectedSerialFlag,
+				Usage: "Specifies the serial of the latest version of the secret. The secret is not created if its latest version has a different serial. Use 0 if the secret should not exist yet.",
+			},
 		}),
 	}
 }
@@ -98,11 +186,77 @@ func FetchCommand() cli.Command {
 		Usage:   "Gets a secret.",
 		Before:  beforeCommand,
 		Action:  fetchCommand,
-		Flags: appendCommonCLIFlags([]cli.Flag{
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringSliceFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret. Can be repeated to fetch several secrets at once.",
+			},
+			cli.StringFlag{
+				Name:  serialFlag,
+				Usage: "Specifies the verison of the secret. Use 'latest' to get the latest version even if it is inactive.",
+			},
+			cli.StringFlag{
+				Name:  labelFlag,
+				Usage: "Specifies the label of the version of the secret.",
+			},
+			cli.BoolFlag{
+				Name:  rawFlag,
+				Usage: "Writes only the payload of the secret, byte for byte, instead of the secret as JSON.",
+			},
+			cli.StringFlag{
+				Name:  fieldFlag,
+				Usage: "Writes only the value of the field of a structured secret.",
+			},
+		}),
+	}
+}
+
+func CopyCommand() cli.Command {
+	return cli.Command{
+		Name:   "copy",
+		Usage:  "Copies a secret from one application to another.",
+		Before: beforeCommand,
+		Action: copyCommand,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  fromAppFlag,
+				Usage: "Specifies the name of the application to copy the secret from.",
+			},
+			cli.StringFlag{
+				Name:  toAppFlag,
+				Usage: "Specifies the name of the application to copy the secret to.",
+			},
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret.",
+			},
+			cli.StringFlag{
+				Name:  serialFlag,
+				Usage: "Specifies the version of the secret to copy. Defaults to the latest active version.",
+			},
+			cli.BoolFlag{
+				Name:  debugFlag,
+				Usage: "Run in debug mode.",
+			},
+		}, backendCLIFlags()...),
+	}
+}
+
+func LabelCommand() cli.Command {
+	return cli.Command{
+		Name:   "label",
+		Usage:  "Points a label of a secret at a version of the secret.",
+		Before: beforeCommand,
+		Action: labelCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
 			cli.StringFlag{
 				Name:  nameFlag,
 				Usage: "Specifies the name of the secret.",
 			},
+			cli.StringFlag{
+				Name:  labelFlag,
+				Usage: "Specifies the label, such as current, pending or previous.",
+			},
 			cli.StringFlag{
 				Name:  serialFlag,
 				Usage: "Specifies the verison of the secret.",
@@ -117,7 +271,7 @@ func RevokeCommand() cli.Command {
 		Usage:  "Revokes a secret.",
 		Before: beforeCommand,
 		Action: revokeCommand,
-		Flags: appendCommonCLIFlags([]cli.Flag{
+		Flags: appendStoreCLIFlags([]cli.Flag{
 			cli.StringFlag{
 				Name:  nameFlag,
 				Usage: "Specifies the name of the secret.",
@@ -130,12 +284,270 @@ func RevokeCommand() cli.Command {
 	}
 }
 
+func RestoreCommand() cli.Command {
+	return cli.Command{
+		Name:   "restore",
+		Usage:  "Restores a revoked secret.",
+		Before: beforeCommand,
+		Action: restoreCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret.",
+			},
+			cli.StringFlag{
+				Name:  serialFlag,
+				Usage: "Specifies the verison of the secret.",
+			},
+			cli.StringFlag{
+				Name:  reasonFlag,
+				Usage: "Specifies why the secret is being restored.",
+			},
+		}),
+	}
+}
+
+func DeleteCommand() cli.Command {
+	return cli.Command{
+		Name:   "delete",
+		Usage:  "Schedules a secret for deletion, or permanently deletes versions of it with --purge.",
+		Before: beforeCommand,
+		Action: deleteCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret.",
+			},
+			cli.StringFlag{
+				Name:  serialFlag,
+				Usage: "Specifies the verison of the secret.",
+			},
+			cli.BoolFlag{
+				Name:  allFlag,
+				Usage: "Deletes every version of the secret.",
+			},
+			cli.BoolFlag{
+				Name:  purgeFlag,
+				Usage: "Permanently deletes the secret, including its encrypted payload.",
+			},
+			cli.BoolFlag{
+				Name:  confirmFlag,
+				Usage: "Confirms that the secret should be permanently deleted.",
+			},
+			cli.IntFlag{
+				Name:  recoveryWindowFlag,
+				Value: int(store.DefaultRecoveryWindow.Hours() / 24),
+				Usage: "Specifies the number of days, from 7 to 30, during which a secret scheduled for deletion can be undeleted.",
+			},
+		}),
+	}
+}
+
+func UndeleteCommand() cli.Command {
+	return cli.Command{
+		Name:   "undelete",
+		Usage:  "Cancels the scheduled deletion of a secret.",
+		Before: beforeCommand,
+		Action: undeleteCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret.",
+			},
+		}),
+	}
+}
+
+func GCCommand() cli.Command {
+	return cli.Command{
+		Name:   "gc",
+		Usage:  "Permanently deletes secrets whose recovery window has passed since they were scheduled for deletion.",
+		Before: beforeCommand,
+		Action: gcCommand,
+		Flags:  appendStoreCLIFlags([]cli.Flag{}),
+	}
+}
+
+func RetentionCommand() cli.Command {
+	return cli.Command{
+		Name:   "retention",
+		Usage:  "Sets the retention policy of a secret, or the default retention policy of the application.",
+		Before: beforeCommand,
+		Action: retentionCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret. The default retention policy of the application is set if not specified.",
+			},
+			cli.Int64Flag{
+				Name:  maxVersionsFlag,
+				Usage: "Specifies the number of latest versions to keep.",
+			},
+			cli.DurationFlag{
+				Name:  maxAgeFlag,
+				Usage: "Specifies the age of versions to keep, such as 720h.",
+			},
+		}),
+	}
+}
+
+func PruneCommand() cli.Command {
+	return cli.Command{
+		Name:   "prune",
+		Usage:  "Revokes versions of secrets that fall outside their retention policy.",
+		Before: beforeCommand,
+		Action: pruneCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret. All secrets are pruned if not specified.",
+			},
+			cli.BoolFlag{
+				Name:  purgeFlag,
+				Usage: "Permanently deletes the versions instead of revoking them.",
+			},
+			cli.BoolFlag{
+				Name:  confirmFlag,
+				Usage: "Confirms that the versions should be permanently deleted.",
+			},
+		}),
+	}
+}
+
+func ListCommand() cli.Command {
+	return cli.Command{
+		Name:   "list",
+		Usage:  "Lists secrets along with their latest versions.",
+		Before: beforeCommand,
+		Action: listCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  prefixFlag,
+				Usage: "Lists only the secrets whose names start with the prefix, such as prod/payments/.",
+			},
+		}),
+	}
+}
+
+func ImportCommand() cli.Command {
+	return cli.Command{
+		Name:   "import",
+		Usage:  "Creates a secret for each key in a dotenv, JSON or YAML file.",
+		Before: beforeCommand,
+		Action: importCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  formatFlag,
+				Value: format.Dotenv,
+				Usage: "Specifies the format of the file, one of dotenv, json or yaml.",
+			},
+			cli.StringFlag{
+				Name:  fileFlag,
+				Usage: "Specifies the file path containing the secrets to import.",
+			},
+			cli.BoolFlag{
+				Name:  dryRunFlag,
+				Usage: "Reports the secrets that would be created or updated without saving them.",
+			},
+		}),
+	}
+}
+
+func ExportCommand() cli.Command {
+	return cli.Command{
+		Name:   "export",
+		Usage:  "Writes the latest active versions of secrets in dotenv, JSON or YAML format.",
+		Before: beforeCommand,
+		Action: exportCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  formatFlag,
+				Value: format.Dotenv,
+				Usage: "Specifies the format to write, one of dotenv, json or yaml.",
+			},
+			cli.StringFlag{
+				Name:  fileFlag,
+				Usage: "Specifies the file path to write the secrets to. Defaults to stdout.",
+			},
+			cli.StringFlag{
+				Name:  prefixFlag,
+				Usage: "Exports only the secrets whose names start with the prefix, such as prod/payments/.",
+			},
+			cli.BoolFlag{
+				Name:  dryRunFlag,
+				Usage: "Reports the secrets that would be exported without writing them.",
+			},
+		}),
+	}
+}
+
+func BackupCommand() cli.Command {
+	return cli.Command{
+		Name:   "backup",
+		Usage:  "Writes every version of every secret, still encrypted, to a backup file.",
+		Before: beforeCommand,
+		Action: backupCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  fileFlag,
+				Usage: "Specifies the file path to write the backup to.",
+			},
+		}),
+		Subcommands: []cli.Command{
+			{
+				Name:   "restore",
+				Usage:  "Restores secrets from a backup file into an application without secrets.",
+				Before: beforeCommand,
+				Action: restoreBackupCommand,
+				Flags: appendStoreCLIFlags([]cli.Flag{
+					cli.StringFlag{
+						Name:  fileFlag,
+						Usage: "Specifies the file path containing the backup.",
+					},
+					cli.BoolFlag{
+						Name:  dryRunFlag,
+						Usage: "Verifies that every version in the backup can be decrypted without restoring it.",
+					},
+				}),
+			},
+		},
+	}
+}
+
+func HistoryCommand() cli.Command {
+	return cli.Command{
+		Name:   "history",
+		Usage:  "Lists all versions of a secret.",
+		Before: beforeCommand,
+		Action: historyCommand,
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.StringFlag{
+				Name:  nameFlag,
+				Usage: "Specifies the name of the secret.",
+			},
+		}),
+	}
+}
+
 func DaemonCommand() cli.Command {
 	return cli.Command{
 		Name:   "daemon",
 		Usage:  "Starts ECS Secrets daemon.",
 		Before: beforeCommand,
 		Action: daemonCommand,
-		Flags:  appendCommonCLIFlags([]cli.Flag{}),
+		Flags: appendStoreCLIFlags([]cli.Flag{
+			cli.BoolFlag{
+				Name:  devFlag,
+				Usage: "Run in development mode. Secrets are kept in memory and encrypted with a throwaway key. INSECURE, never use in production.",
+			},
+			cli.StringFlag{
+				Name:  seedFileFlag,
+				Usage: "Specifies a JSON file of secret names and payloads to create when running in development mode.",
+			},
+			cli.StringFlag{
+				Name:  addressFlag,
+				Usage: "Specifies the address to listen on, as host:port. Defaults to " + server.DefaultAddress + ", or to " + server.LoopbackAddress + " in development mode.",
+			},
+		}),
 	}
 }
@@ -0,0 +1,68 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func copyCommand(context *cli.Context) error {
+	// Validate that the source and target applications have been specified
+	fromApp, err := getRequiredArgumentFromFlag(context, fromAppFlag)
+	if err != nil {
+		return err
+	}
+	toApp, err := getRequiredArgumentFromFlag(context, toAppFlag)
+	if err != nil {
+		return err
+	}
+	if fromApp == toApp {
+		return fmt.Errorf("Incorrect usage. '%s' and '%s' should be different applications", fromAppFlag, toAppFlag)
+	}
+	fromStore, err := createSecretStore(context, fromApp)
+	if err != nil {
+		return err
+	}
+	toStore, err := createSecretStore(context, toApp)
+	if err != nil {
+		return err
+	}
+	return doCopy(context, fromStore, toStore)
+}
+
+func doCopy(context *cli.Context, fromStore store.Store, toStore store.Store) error {
+	// Validate that secrets name has been specified
+	name, err := getRequiredArgumentFromFlag(context, nameFlag)
+	if err != nil {
+		return err
+	}
+
+	serial := context.String(serialFlag)
+	log.Debugf("Copying secret name: %s with version: %s from %s to %s",
+		name, serial, context.String(fromAppFlag), context.String(toAppFlag))
+	secret, err := store.Copy(fromStore, toStore, name, serial)
+	if err != nil {
+		if _, ok := err.(*store.ConflictError); ok {
+			return cli.NewExitError(err.Error(), conflictExitCode)
+		}
+		return err
+	}
+
+	log.Infof("Copied secret with name: %s to %s as version: %d", secret.Name, context.String(toAppFlag), secret.Serial)
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestCopyCommandFromAppNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(toAppFlag, "otherapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := copyCommand(context)
+	if err == nil {
+		t.Error("Expected error when source application is not specified")
+	}
+}
+
+func TestCopyCommandSameApplication(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(fromAppFlag, "myapp", "")
+	flagSet.String(toAppFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := copyCommand(context)
+	if err == nil {
+		t.Error("Expected error when source and target applications are the same")
+	}
+}
+
+func TestDoCopyNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(fromAppFlag, "myapp", "")
+	flagSet.String(toAppFlag, "otherapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doCopy(context, nil, nil)
+	if err == nil {
+		t.Error("Expected error when name is not specified")
+	}
+}
+
+func TestDoCopy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(fromAppFlag, "myapp", "")
+	flagSet.String(toAppFlag, "otherapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "2", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	fromStore := mock_store.NewMockStore(ctrl)
+	toStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		fromStore.EXPECT().Get("foo", "2").Return(&api.SecretRecord{
+			Name:        "foo",
+			Serial:      2,
+			Active:      true,
+			Payload:     "bar",
+			Description: "db password",
+		}, nil),
+		toStore.EXPECT().Save(&api.SecretRecord{
+			Name:        "foo",
+			Serial:      1,
+			Active:      true,
+			Payload:     "bar",
+			Description: "db password",
+		}).Return(&api.SecretRecord{Name: "foo", Serial: 1}, nil),
+	)
+	err := doCopy(context, fromStore, toStore)
+	if err != nil {
+		t.Errorf("Error copying secret: %v", err)
+	}
+}
+
+func TestDoCopyGetError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(fromAppFlag, "myapp", "")
+	flagSet.String(toAppFlag, "otherapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	fromStore := mock_store.NewMockStore(ctrl)
+	toStore := mock_store.NewMockStore(ctrl)
+	fromStore.EXPECT().Get("foo", "").Return(nil, fmt.Errorf("no such secret"))
+	err := doCopy(context, fromStore, toStore)
+	if err == nil {
+		t.Error("Expected error when secret cannot be read from the source application")
+	}
+}
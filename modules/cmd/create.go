@@ -16,6 +16,9 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/awslabs/ecs-secrets/modules/api"
 	"github.com/awslabs/ecs-secrets/modules/store"
@@ -31,13 +34,21 @@ type fileReader interface {
 
 type ioutilFileReader struct{}
 
+// maxSetFieldsAttempts is the number of times the fields of a secret are read
+// and set again when the secret is changed by someone else at the same time
+const maxSetFieldsAttempts = 3
+
 func createCommand(context *cli.Context) error {
 	// Validate that application name has been specified
 	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
 	if err != nil {
 		return err
 	}
-	return doCreate(context, createSecretStore(appName), &ioutilFileReader{})
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doCreate(context, secretStore, &ioutilFileReader{})
 }
 
 func doCreate(context *cli.Context, secretStore store.Store, reader fileReader) error {
@@ -46,40 +57,179 @@ func doCreate(context *cli.Context, secretStore store.Store, reader fileReader)
 	if err != nil {
 		return err
 	}
+	err = store.ValidateName(name)
+	if err != nil {
+		return err
+	}
+
+	var payload, contentType string
+	fieldArgs := context.StringSlice(setFlag)
+	if len(fieldArgs) != 0 {
+		if context.String(payloadFlag) != "" || context.String(payloadLocationFlag) != "" || context.String(contentTypeFlag) != "" {
+			return fmt.Errorf("Incorrect usage. '%s' cannot be combined with '%s', '%s' or '%s'",
+				setFlag, payloadFlag, payloadLocationFlag, contentTypeFlag)
+		}
+		contentType = api.StructuredContentType
+	} else {
+		payload, err = readPayload(context, reader)
+		if err != nil {
+			return err
+		}
+		contentType = context.String(contentTypeFlag)
+		if contentType == "" && !utf8.ValidString(payload) {
+			contentType = api.OctetStreamContentType
+		}
+	}
 
+	tags, err := parseTags(context.StringSlice(tagFlag))
+	if err != nil {
+		return err
+	}
+
+	secret := &api.SecretRecord{
+		Name:        name,
+		Serial:      int64(1),
+		Payload:     payload,
+		Active:      true,
+		Description: context.String(descriptionFlag),
+		Tags:        tags,
+		ContentType: contentType,
+	}
+	if context.IsSet(expectedSerialFlag) {
+		expectedSerial := context.Int64(expectedSerialFlag)
+		if expectedSerial < 0 {
+			return fmt.Errorf("Incorrect usage. '%s' cannot be negative", expectedSerialFlag)
+		}
+		secret.ExpectedSerial = &expectedSerial
+	}
+	if expiresIn := context.Duration(expiresInFlag); expiresIn != 0 {
+		if expiresIn < 0 {
+			return fmt.Errorf("Incorrect usage. '%s' should be a positive duration", expiresInFlag)
+		}
+		expiresAt := time.Now().Add(expiresIn)
+		secret.ExpiresAt = &expiresAt
+	}
+
+	log.Debugf("Creating secret with name: %s", name)
+	var editedSecret *api.SecretRecord
+	if len(fieldArgs) != 0 {
+		editedSecret, err = saveFields(secret, fieldArgs, secretStore)
+	} else {
+		editedSecret, err = secretStore.Save(secret)
+	}
+	if err != nil {
+		if _, ok := err.(*store.ConflictError); ok {
+			return cli.NewExitError(err.Error(), conflictExitCode)
+		}
+		return err
+	}
+
+	log.Infof("Created application secret with name: %s and version: %d", editedSecret.Name, editedSecret.Serial)
+	return nil
+}
+
+// readPayload returns the payload specified either inline or in a file
+func readPayload(context *cli.Context, reader fileReader) (string, error) {
 	// Validate that either payload or payload location are specifiec
 	payload, payloadErr := getRequiredArgumentFromFlag(context, payloadFlag)
 	payloadLocation, payloadLocationErr := getRequiredArgumentFromFlag(context, payloadLocationFlag)
 
 	if payloadErr == nil && payloadLocationErr == nil {
-		return fmt.Errorf("Incorrect usage. Only one of '%s' or '%s' should be specified", payloadFlag, payloadLocationFlag)
+		return "", fmt.Errorf("Incorrect usage. Only one of '%s' or '%s' should be specified", payloadFlag, payloadLocationFlag)
 	}
 
 	if payloadErr != nil {
 		if payloadLocationErr != nil {
-			return fmt.Errorf("Incorrect usage. One of '%s' or '%s' should be specified", payloadFlag, payloadLocationFlag)
+			return "", fmt.Errorf("Incorrect usage. One of '%s', '%s' or '%s' should be specified", payloadFlag, payloadLocationFlag, setFlag)
 		}
 		log.Debugf("Reading from %s", payloadLocation)
 		readBytes, err := reader.ReadFile(payloadLocation)
 		if err != nil {
-			return fmt.Errorf("Error reading from %s: %v", payloadLocation, err)
+			return "", fmt.Errorf("Error reading from %s: %v", payloadLocation, err)
 		}
 		payload = string(readBytes)
 	}
+	return payload, nil
+}
 
-	log.Debugf("Creating secret with name: %s", name)
-	editedSecret, err := secretStore.Save(&api.SecretRecord{
-		Name:    name,
-		Serial:  int64(1),
-		Payload: payload,
-		Active:  true,
-	})
-	if err != nil {
-		return err
+// saveFields saves the secret with the fields set to the values in fieldArgs.
+// The serial of the latest version read by setFields is expected when saving,
+// so that fields set by someone else in the meantime are not lost. The fields
+// are read and set again if the secret changed, unless the caller expects a
+// serial of their own
+func saveFields(secret *api.SecretRecord, fieldArgs []string, secretStore store.Store) (*api.SecretRecord, error) {
+	callerExpectsSerial := secret.ExpectedSerial != nil
+	for attempt := 1; ; attempt++ {
+		payload, latestSerial, err := setFields(secret.Name, fieldArgs, secretStore)
+		if err != nil {
+			return nil, err
+		}
+		secret.Payload = payload
+		if !callerExpectsSerial {
+			secret.ExpectedSerial = &latestSerial
+		}
+		editedSecret, err := secretStore.Save(secret)
+		if _, ok := err.(*store.ConflictError); !ok || callerExpectsSerial || attempt == maxSetFieldsAttempts {
+			return editedSecret, err
+		}
+		log.Infof("Secret %s changed while setting its fields, attempt %d of %d", secret.Name, attempt, maxSetFieldsAttempts)
 	}
+}
 
-	log.Infof("Created application secret with name: %s and version: %d", editedSecret.Name, editedSecret.Serial)
-	return nil
+// setFields returns the payload of a structured secret with the fields set
+// to the values in fieldArgs, along with the other fields of the latest active
+// version of the secret if there is one. The serial of the latest version of
+// the secret is returned as well, which is 0 if the secret does not exist
+func setFields(name string, fieldArgs []string, secretStore store.Store) (string, int64, error) {
+	latestSerial := int64(0)
+	latestSecret, err := secretStore.Get(name, store.LatestVersion)
+	if err == nil {
+		latestSerial = latestSecret.Serial
+		expired := latestSecret.ExpiresAt != nil && !latestSecret.ExpiresAt.After(time.Now())
+		if !latestSecret.Active || expired {
+			latestSecret, err = secretStore.Get(name, "")
+		}
+	}
+
+	fields := make(map[string]string)
+	if err == nil {
+		if latestSecret.ContentType != api.StructuredContentType {
+			return "", 0, fmt.Errorf("Secret '%s' is not a structured secret", name)
+		}
+		fields, err = api.ParseFields(latestSecret.Payload)
+		if err != nil {
+			return "", 0, err
+		}
+	} else if _, ok := err.(*store.NotFoundError); !ok {
+		return "", 0, err
+	}
+
+	for _, fieldArg := range fieldArgs {
+		parts := strings.SplitN(fieldArg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return "", 0, fmt.Errorf("Incorrect usage. Fields should be specified as key=value, got '%s'", fieldArg)
+		}
+		fields[parts[0]] = parts[1]
+	}
+	payload, err := api.EncodeFields(fields)
+	return payload, latestSerial, err
+}
+
+// parseTags parses tags specified as key=value pairs
+func parseTags(tagArgs []string) (map[string]string, error) {
+	if len(tagArgs) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string)
+	for _, tagArg := range tagArgs {
+		parts := strings.SplitN(tagArg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Incorrect usage. Tags should be specified as key=value, got '%s'", tagArg)
+		}
+		tags[parts[0]] = parts[1]
+	}
+	return tags, nil
 }
 
 func (reader *ioutilFileReader) ReadFile(filename string) ([]byte, error) {
@@ -17,9 +17,11 @@ import (
 	"flag"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/awslabs/ecs-secrets/modules/api"
 
+	"github.com/awslabs/ecs-secrets/modules/store"
 	"github.com/awslabs/ecs-secrets/modules/store/mock"
 	"github.com/golang/mock/gomock"
 	"github.com/urfave/cli"
@@ -57,6 +59,18 @@ func TestDoCreateSecretNameNotSet(t *testing.T) {
 	}
 }
 
+func TestDoCreateInvalidSecretName(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "prod/db/1", "")
+	flagSet.String(payloadFlag, "value", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doCreate(context, nil, nil)
+	if err == nil {
+		t.Error("Expected error when the name of the secret is invalid")
+	}
+}
+
 func TestDoCreateSecretPayloadAndLocationNotSet(t *testing.T) {
 	flagSet := flag.NewFlagSet("ecs-secrets", 0)
 	flagSet.String(applicationNameFlag, "myapp", "")
@@ -129,6 +143,311 @@ func TestDoCreatePayloadLocationSet(t *testing.T) {
 	}
 }
 
+func TestDoCreateBinaryPayloadLocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadLocationFlag, "keystore.jks", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	apiSecret := &api.SecretRecord{
+		Name:        "name",
+		Serial:      int64(1),
+		Payload:     "\xfe\xed\xfe\xed",
+		Active:      true,
+		ContentType: "application/octet-stream",
+	}
+	secretStore.EXPECT().Save(apiSecret).Return(apiSecret, nil)
+	err := doCreate(context, secretStore, &mockReader{[]byte{0xfe, 0xed, 0xfe, 0xed}, nil})
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateWithContentType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadLocationFlag, "keystore.p12", "")
+	flagSet.String(contentTypeFlag, "application/x-pkcs12", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	apiSecret := &api.SecretRecord{
+		Name:        "name",
+		Serial:      int64(1),
+		Payload:     "\x30\x82",
+		Active:      true,
+		ContentType: "application/x-pkcs12",
+	}
+	secretStore.EXPECT().Save(apiSecret).Return(apiSecret, nil)
+	err := doCreate(context, secretStore, &mockReader{[]byte{0x30, 0x82}, nil})
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateSetFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	fields := &cli.StringSlice{"password=hunter3", "port=5432"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	apiSecret := &api.SecretRecord{
+		Name:        "db",
+		Serial:      int64(1),
+		Payload:     `{"host":"db.example.com","password":"hunter3","port":"5432"}`,
+		Active:      true,
+		ContentType: api.StructuredContentType,
+	}
+	expectedSerial := int64(4)
+	apiSecret.ExpectedSerial = &expectedSerial
+	gomock.InOrder(
+		secretStore.EXPECT().Get("db", store.LatestVersion).Return(&api.SecretRecord{
+			Name:        "db",
+			Serial:      int64(4),
+			Payload:     `{"host":"db.example.com","password":"hunter2"}`,
+			Active:      true,
+			ContentType: api.StructuredContentType,
+		}, nil),
+		secretStore.EXPECT().Save(apiSecret).Return(apiSecret, nil),
+	)
+	err := doCreate(context, secretStore, nil)
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateSetFieldsNewSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	fields := &cli.StringSlice{"password=hunter2"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	apiSecret := &api.SecretRecord{
+		Name:        "db",
+		Serial:      int64(1),
+		Payload:     `{"password":"hunter2"}`,
+		Active:      true,
+		ContentType: api.StructuredContentType,
+	}
+	expectedSerial := int64(0)
+	apiSecret.ExpectedSerial = &expectedSerial
+	gomock.InOrder(
+		secretStore.EXPECT().Get("db", store.LatestVersion).Return(nil, &store.NotFoundError{Name: "db"}),
+		secretStore.EXPECT().Save(apiSecret).Return(apiSecret, nil),
+	)
+	err := doCreate(context, secretStore, nil)
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateSetFieldsNotStructured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	fields := &cli.StringSlice{"password=hunter2"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Get("db", store.LatestVersion).Return(&api.SecretRecord{
+		Name:    "db",
+		Payload: "hunter1",
+		Active:  true,
+	}, nil)
+	err := doCreate(context, secretStore, nil)
+	if err == nil {
+		t.Error("Expected error setting fields of a secret that is not structured")
+	}
+}
+
+func TestDoCreateSetFieldsLatestVersionRevoked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	fields := &cli.StringSlice{"password=hunter3"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().Get("db", store.LatestVersion).Return(&api.SecretRecord{
+			Name:        "db",
+			Serial:      int64(5),
+			Payload:     `{"host":"db.example.com","password":"hunter2"}`,
+			ContentType: api.StructuredContentType,
+		}, nil),
+		secretStore.EXPECT().Get("db", "").Return(&api.SecretRecord{
+			Name:        "db",
+			Serial:      int64(4),
+			Payload:     `{"host":"db.example.com","password":"hunter1"}`,
+			Active:      true,
+			ContentType: api.StructuredContentType,
+		}, nil),
+		secretStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+			if secret.Payload != `{"host":"db.example.com","password":"hunter3"}` {
+				t.Errorf("Expected fields of the latest active version to be set, got: %s", secret.Payload)
+			}
+			if secret.ExpectedSerial == nil || *secret.ExpectedSerial != 5 {
+				t.Errorf("Expected serial of the latest version, got: %v", secret.ExpectedSerial)
+			}
+		}).Return(&api.SecretRecord{Name: "db", Serial: int64(6)}, nil),
+	)
+	err := doCreate(context, secretStore, nil)
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateSetFieldsRetriesOnConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	fields := &cli.StringSlice{"password=hunter3"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().Get("db", store.LatestVersion).Return(&api.SecretRecord{
+			Name:        "db",
+			Serial:      int64(4),
+			Payload:     `{"password":"hunter2"}`,
+			Active:      true,
+			ContentType: api.StructuredContentType,
+		}, nil),
+		secretStore.EXPECT().Save(gomock.Any()).Return(nil, &store.ConflictError{Name: "db", Reason: "expected latest serial 4, found 5"}),
+		secretStore.EXPECT().Get("db", store.LatestVersion).Return(&api.SecretRecord{
+			Name:        "db",
+			Serial:      int64(5),
+			Payload:     `{"password":"hunter2","port":"5432"}`,
+			Active:      true,
+			ContentType: api.StructuredContentType,
+		}, nil),
+		secretStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+			if secret.Payload != `{"password":"hunter3","port":"5432"}` {
+				t.Errorf("Expected fields set concurrently to be kept, got: %s", secret.Payload)
+			}
+			if secret.ExpectedSerial == nil || *secret.ExpectedSerial != 5 {
+				t.Errorf("Expected serial 5, got: %v", secret.ExpectedSerial)
+			}
+		}).Return(&api.SecretRecord{Name: "db", Serial: int64(6)}, nil),
+	)
+	err := doCreate(context, secretStore, nil)
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateSetFieldsWithExpectedSerialConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	flagSet.Int64(expectedSerialFlag, 0, "")
+	flagSet.Set(expectedSerialFlag, "3")
+	fields := &cli.StringSlice{"password=hunter3"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().Get("db", store.LatestVersion).Return(&api.SecretRecord{
+			Name:        "db",
+			Serial:      int64(4),
+			Payload:     `{"password":"hunter2"}`,
+			Active:      true,
+			ContentType: api.StructuredContentType,
+		}, nil),
+		secretStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+			if secret.ExpectedSerial == nil || *secret.ExpectedSerial != 3 {
+				t.Errorf("Expected serial given by the caller, got: %v", secret.ExpectedSerial)
+			}
+		}).Return(nil, &store.ConflictError{Name: "db", Reason: "expected latest serial 3, found 4"}),
+	)
+	err := doCreate(context, secretStore, nil)
+	exitErr, ok := err.(cli.ExitCoder)
+	if !ok || exitErr.ExitCode() != conflictExitCode {
+		t.Errorf("Expected exit code %d, got: %v", conflictExitCode, err)
+	}
+}
+
+func TestDoCreateSetFieldsAndPayload(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "db", "")
+	flagSet.String(payloadFlag, "value", "")
+	fields := &cli.StringSlice{"password=hunter2"}
+	flagSet.Var(fields, setFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	err := doCreate(context, nil, nil)
+	if err == nil {
+		t.Error("Expected error when both fields and payload are specified")
+	}
+}
+
+func TestDoCreateWithExpectedSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadFlag, "value", "")
+	flagSet.Int64(expectedSerialFlag, 0, "")
+	flagSet.Set(expectedSerialFlag, "4")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	expectedSerial := int64(4)
+	apiSecret := &api.SecretRecord{
+		Name:           "name",
+		Serial:         int64(1),
+		Payload:        "value",
+		Active:         true,
+		ExpectedSerial: &expectedSerial,
+	}
+	secretStore.EXPECT().Save(apiSecret).Return(nil, &store.ConflictError{Name: "name", Reason: "expected latest serial 4, found 5"})
+	err := doCreate(context, secretStore, nil)
+	exitErr, ok := err.(cli.ExitCoder)
+	if !ok || exitErr.ExitCode() != conflictExitCode {
+		t.Errorf("Expected exit code %d, got: %v", conflictExitCode, err)
+	}
+}
+
 func TestDoCreateSaveError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -152,3 +471,110 @@ func TestDoCreateSaveError(t *testing.T) {
 		t.Errorf("Expected error creating secret")
 	}
 }
+
+func TestDoCreateSaveConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadFlag, "value", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Save(gomock.Any()).Return(nil, &store.ConflictError{Name: "name", Reason: "too busy"})
+	err := doCreate(context, secretStore, &mockReader{nil, nil})
+	exitErr, ok := err.(cli.ExitCoder)
+	if !ok {
+		t.Fatalf("Expected exit error creating secret, got: %v", err)
+	}
+	if exitErr.ExitCode() != conflictExitCode {
+		t.Errorf("Incorrect exit code: %d", exitErr.ExitCode())
+	}
+}
+
+func TestDoCreateWithDescriptionAndTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadFlag, "value", "")
+	flagSet.String(descriptionFlag, "first", "")
+	tags := &cli.StringSlice{"team=payments", "env=prod"}
+	flagSet.Var(tags, tagFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	apiSecret := &api.SecretRecord{
+		Name:        "name",
+		Serial:      int64(1),
+		Payload:     "value",
+		Active:      true,
+		Description: "first",
+		Tags:        map[string]string{"team": "payments", "env": "prod"},
+	}
+	secretStore.EXPECT().Save(apiSecret).Return(apiSecret, nil)
+	err := doCreate(context, secretStore, &mockReader{nil, nil})
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateWithExpiresIn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadFlag, "value", "")
+	flagSet.Duration(expiresInFlag, 720*time.Hour, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	before := time.Now()
+	secretStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+		if secret.ExpiresAt == nil {
+			t.Fatal("Expected expiry time to be set")
+		}
+		expiresIn := secret.ExpiresAt.Sub(before)
+		if expiresIn < 720*time.Hour || expiresIn > 721*time.Hour {
+			t.Errorf("Incorrect expiry time: %v", secret.ExpiresAt)
+		}
+	}).Return(&api.SecretRecord{Name: "name", Serial: 1}, nil)
+	err := doCreate(context, secretStore, &mockReader{nil, nil})
+	if err != nil {
+		t.Errorf("Error creating secret: %v", err)
+	}
+}
+
+func TestDoCreateNegativeExpiresIn(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadFlag, "value", "")
+	flagSet.Duration(expiresInFlag, -time.Hour, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doCreate(context, nil, &mockReader{nil, nil})
+	if err == nil {
+		t.Error("Expected error when expires in is negative")
+	}
+}
+
+func TestDoCreateInvalidTag(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(payloadFlag, "value", "")
+	tags := &cli.StringSlice{"team"}
+	flagSet.Var(tags, tagFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	err := doCreate(context, nil, &mockReader{nil, nil})
+	if err == nil {
+		t.Error("Expected error creating secret with an invalid tag")
+	}
+}
@@ -14,11 +14,35 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/crypt"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/format"
+	"github.com/awslabs/ecs-secrets/modules/identity"
 	"github.com/awslabs/ecs-secrets/modules/server"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	log "github.com/cihub/seelog"
 
 	"github.com/urfave/cli"
 )
 
+const (
+	// environmentEnvVar names the environment the daemon runs in. The daemon
+	// refuses to run in development mode when it is set to production
+	environmentEnvVar     = "ECS_SECRETS_ENVIRONMENT"
+	productionEnvironment = "production"
+)
+
+// productionEnvVars are the environment variables that select where secrets
+// are stored. Setting any of them means that real secrets are expected, so
+// the daemon refuses to run in development mode
+var productionEnvVars = []string{"ECS_SECRETS_BACKEND", "ECS_SECRETS_BACKEND_OPTIONS", "ECS_SECRETS_DATA_FILE"}
+
 func daemonCommand(context *cli.Context) error {
 	// Validate that application name has been specified
 	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
@@ -26,5 +50,112 @@ func daemonCommand(context *cli.Context) error {
 		return err
 	}
 
-	return server.NewServer(createSecretStore(appName)).Serve()
+	var secretStore store.Store
+	if context.Bool(devFlag) {
+		secretStore, err = createDevSecretStore(context, appName, &ioutilFileReader{})
+	} else {
+		secretStore, err = createSecretStore(context, appName)
+	}
+	if err != nil {
+		return err
+	}
+	return server.NewServer(secretStore).Serve(listenAddress(context))
+}
+
+// listenAddress returns the address the daemon listens on. In development
+// mode, the daemon is only reachable from the same host unless an address is
+// set explicitly
+func listenAddress(context *cli.Context) string {
+	if context.IsSet(addressFlag) {
+		return context.String(addressFlag)
+	}
+	if context.Bool(devFlag) {
+		return server.LoopbackAddress
+	}
+	return server.DefaultAddress
+}
+
+// createDevSecretStore creates a store that keeps secrets in memory and
+// encrypts them with a master key generated at startup, so that the daemon can
+// run without AWS. The store is seeded with the secrets in the seed file, if
+// one is specified
+func createDevSecretStore(context *cli.Context, appName string, reader fileReader) (store.Store, error) {
+	err := checkDevMode(context)
+	if err != nil {
+		return nil, err
+	}
+	log.Warn("Running in development mode. Secrets are stored in memory and encrypted with a throwaway key. THIS IS INSECURE, do not use it in production")
+
+	crypter, err := crypt.NewLocalCrypter(crypt.GenerateMasterKey())
+	if err != nil {
+		return nil, err
+	}
+	secretStore := store.NewStore(appName, dao.NewMemoryDAO(appName), crypter, identity.NewLocalProvider())
+
+	seedFile := context.String(seedFileFlag)
+	if seedFile == "" {
+		return secretStore, nil
+	}
+	err = seedSecretStore(secretStore, seedFile, reader)
+	if err != nil {
+		return nil, err
+	}
+	return secretStore, nil
+}
+
+// checkDevMode returns an error if the daemon has been configured in a way
+// that suggests it is meant to serve real secrets
+func checkDevMode(context *cli.Context) error {
+	if strings.EqualFold(os.Getenv(environmentEnvVar), productionEnvironment) {
+		return fmt.Errorf("Refusing to run in development mode: %s is set to %s", environmentEnvVar, productionEnvironment)
+	}
+	for _, flagName := range []string{backendFlag, backendOptionFlag, dataFileFlag} {
+		if context.IsSet(flagName) {
+			return fmt.Errorf("Refusing to run in development mode: '%s' is set", flagName)
+		}
+	}
+	for _, envVar := range productionEnvVars {
+		if os.Getenv(envVar) != "" {
+			return fmt.Errorf("Refusing to run in development mode: %s is set", envVar)
+		}
+	}
+	return nil
+}
+
+// seedSecretStore creates the secrets in the seed file, a JSON object mapping
+// the names of secrets to their payloads
+func seedSecretStore(secretStore store.Store, seedFile string, reader fileReader) error {
+	log.Debugf("Reading seed secrets from %s", seedFile)
+	data, err := reader.ReadFile(seedFile)
+	if err != nil {
+		return fmt.Errorf("Error reading from %s: %v", seedFile, err)
+	}
+	secrets, err := format.Read(format.JSON, data)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		err = store.ValidateName(name)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, err = secretStore.Save(&api.SecretRecord{
+			Name:    name,
+			Serial:  int64(1),
+			Payload: secrets[name],
+			Active:  true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("Seeded %d secrets from %s", len(names), seedFile)
+	return nil
 }
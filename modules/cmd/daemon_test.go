@@ -15,8 +15,10 @@ package cmd
 
 import (
 	"flag"
+	"os"
 	"testing"
 
+	"github.com/awslabs/ecs-secrets/modules/server"
 	"github.com/urfave/cli"
 )
 
@@ -28,3 +30,78 @@ func TestDaemonCommandApplicationNameNotSet(t *testing.T) {
 		t.Error("Expected error when application name is not specified")
 	}
 }
+
+func TestCreateDevSecretStoreSeeded(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.Bool(devFlag, true, "")
+	flagSet.String(seedFileFlag, "seed.json", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	reader := &mockReader{payload: []byte(`{"db/password":"hunter2","api-key":"abc"}`)}
+	secretStore, err := createDevSecretStore(context, "myapp", reader)
+	if err != nil {
+		t.Fatalf("Error creating development store: %v", err)
+	}
+
+	secret, err := secretStore.Get("db/password", "")
+	if err != nil {
+		t.Fatalf("Error getting seeded secret: %v", err)
+	}
+	if secret.Payload != "hunter2" || secret.Serial != 1 {
+		t.Errorf("Unexpected seeded secret: %v", secret)
+	}
+}
+
+func TestCreateDevSecretStoreInvalidSeedFile(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(seedFileFlag, "seed.json", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	_, err := createDevSecretStore(context, "myapp", &mockReader{payload: []byte(`["hunter2"]`)})
+	if err == nil {
+		t.Error("Expected error seeding from a file that is not a JSON object")
+	}
+}
+
+func TestCreateDevSecretStoreBackendFlagSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(backendFlag, "", "")
+	flagSet.Parse([]string{"--" + backendFlag, "s3"})
+	context := cli.NewContext(nil, flagSet, nil)
+	_, err := createDevSecretStore(context, "myapp", &mockReader{})
+	if err == nil {
+		t.Error("Expected error running in development mode with a backend selected")
+	}
+}
+
+func TestCreateDevSecretStoreProductionEnvironment(t *testing.T) {
+	os.Setenv(environmentEnvVar, "Production")
+	defer os.Unsetenv(environmentEnvVar)
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	context := cli.NewContext(nil, flagSet, nil)
+	_, err := createDevSecretStore(context, "myapp", &mockReader{})
+	if err == nil {
+		t.Error("Expected error running in development mode in production")
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{nil, server.DefaultAddress},
+		{[]string{"--" + devFlag}, server.LoopbackAddress},
+		{[]string{"--" + devFlag, "--" + addressFlag, ":9090"}, ":9090"},
+		{[]string{"--" + addressFlag, "10.0.0.1:8080"}, "10.0.0.1:8080"},
+	} {
+		flagSet := flag.NewFlagSet("ecs-secrets", 0)
+		flagSet.Bool(devFlag, false, "")
+		flagSet.String(addressFlag, "", "")
+		flagSet.Parse(test.args)
+		context := cli.NewContext(nil, flagSet, nil)
+		address := listenAddress(context)
+		if address != test.expected {
+			t.Errorf("Unexpected address for %v: %s != %s", test.args, address, test.expected)
+		}
+	}
+}
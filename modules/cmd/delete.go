@@ -0,0 +1,93 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func deleteCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doDelete(context, secretStore)
+}
+
+func doDelete(context *cli.Context, secretStore store.Store) error {
+	// Validate that secrets name has been specified
+	name, err := getRequiredArgumentFromFlag(context, nameFlag)
+	if err != nil {
+		return err
+	}
+
+	// Without purge, the whole secret is scheduled for deletion and can be
+	// undeleted until its recovery window has passed
+	serial := context.String(serialFlag)
+	if !context.Bool(purgeFlag) {
+		if serial != "" {
+			return fmt.Errorf("Incorrect usage. Versions can only be permanently deleted with '%s'. Use 'revoke' to disable a version instead", purgeFlag)
+		}
+		return scheduleDeletion(context, name, secretStore)
+	}
+
+	// Validate that either a version or all versions have been specified
+	all := context.Bool(allFlag)
+	if serial != "" && all {
+		return fmt.Errorf("Incorrect usage. Only one of '%s' or '%s' should be specified", serialFlag, allFlag)
+	}
+	if serial == "" && !all {
+		return fmt.Errorf("Incorrect usage. One of '%s' or '%s' should be specified", serialFlag, allFlag)
+	}
+	if all {
+		serial = store.AllVersions
+	}
+
+	if !context.Bool(confirmFlag) {
+		return fmt.Errorf("Permanently deleting secret '%s' cannot be undone. Specify '%s' to continue", name, confirmFlag)
+	}
+
+	log.Debugf("Purging secret name: %s with version: %s", name, serial)
+	err = secretStore.Purge(name, serial)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Permanently deleted secret: %s, version: %s", name, serial)
+	return nil
+}
+
+// scheduleDeletion schedules every version of the secret for deletion once the
+// recovery window has passed
+func scheduleDeletion(context *cli.Context, name string, secretStore store.Store) error {
+	recoveryWindow := time.Duration(context.Int(recoveryWindowFlag)) * 24 * time.Hour
+	log.Debugf("Scheduling deletion of secret name: %s with recovery window: %v", name, recoveryWindow)
+	purgeAt, err := secretStore.Delete(name, recoveryWindow)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Scheduled deletion of secret: %s. It can be undeleted until %v", name, purgeAt)
+	return nil
+}
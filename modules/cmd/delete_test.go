@@ -0,0 +1,199 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestDeleteCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := deleteCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoDeleteSecretNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doDelete(context, nil)
+	if err == nil {
+		t.Error("Expected error when name is not specified for the secret")
+	}
+}
+
+func TestDoDeleteSerialAndAllNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Bool(purgeFlag, true, "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doDelete(context, nil)
+	if err == nil {
+		t.Error("Expected error when neither serial nor all are specified")
+	}
+}
+
+func TestDoDeleteSerialAndAllSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.Bool(allFlag, true, "")
+	flagSet.Bool(purgeFlag, true, "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doDelete(context, nil)
+	if err == nil {
+		t.Error("Expected error when both serial and all are specified")
+	}
+}
+
+func TestDoDeletePurgeNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doDelete(context, nil)
+	if err == nil {
+		t.Error("Expected error when a version is deleted without purge")
+	}
+}
+
+func TestDoDeleteConfirmNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.Bool(purgeFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doDelete(context, nil)
+	if err == nil {
+		t.Error("Expected error when confirm is not specified")
+	}
+}
+
+func TestDoDeletePurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.Bool(purgeFlag, true, "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Purge("foo", "1").Return(nil)
+	err := doDelete(context, secretStore)
+	if err != nil {
+		t.Errorf("Error deleting secret: %v", err)
+	}
+}
+
+func TestDoDeletePurgeAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Bool(allFlag, true, "")
+	flagSet.Bool(purgeFlag, true, "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Purge("foo", store.AllVersions).Return(nil)
+	err := doDelete(context, secretStore)
+	if err != nil {
+		t.Errorf("Error deleting secret: %v", err)
+	}
+}
+
+func TestDoDeleteOnPurgeError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.Bool(purgeFlag, true, "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Purge("foo", "1").Return(fmt.Errorf("it will not go away"))
+	err := doDelete(context, secretStore)
+	if err == nil {
+		t.Error("Expected error deleting secret")
+	}
+}
+
+func TestDoDeleteSchedulesDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Int(recoveryWindowFlag, 7, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	purgeAt := time.Now().Add(7 * 24 * time.Hour)
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Delete("foo", 7*24*time.Hour).Return(&purgeAt, nil)
+	err := doDelete(context, secretStore)
+	if err != nil {
+		t.Errorf("Error scheduling deletion of secret: %v", err)
+	}
+}
+
+func TestDoDeleteOnScheduleDeletionError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Bool(allFlag, true, "")
+	flagSet.Int(recoveryWindowFlag, 30, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Delete("foo", 30*24*time.Hour).Return(nil, &store.NotFoundError{Name: "foo"})
+	err := doDelete(context, secretStore)
+	if err == nil {
+		t.Error("Expected error scheduling deletion of secret")
+	}
+}
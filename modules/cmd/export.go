@@ -0,0 +1,127 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/format"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+// exportFileMode is the mode of files that secrets are exported to, which
+// are only readable by their owner
+const exportFileMode = 0600
+
+// fileWriter interface wraps the ioutil.WriteFile method. This makes it easier
+// to mock and test the write-secrets-to-file functionality.
+type fileWriter interface {
+	WriteFile(filename string, data []byte, perm os.FileMode) error
+}
+
+type ioutilFileWriter struct{}
+
+func exportCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doExport(context, secretStore, &ioutilFileWriter{})
+}
+
+func doExport(context *cli.Context, secretStore store.Store, writer fileWriter) error {
+	// Validate the format before reading any secrets
+	exportFormat := context.String(formatFlag)
+	_, err := format.Write(exportFormat, nil)
+	if err != nil {
+		return err
+	}
+
+	// Page through all of the secrets in the store
+	names := []string{}
+	prefix := context.String(prefixFlag)
+	nextToken := ""
+	for {
+		log.Debugf("Listing secrets, prefix: %s, nextToken: %s", prefix, nextToken)
+		secretList, err := secretStore.List(prefix, nextToken, 0)
+		if err != nil {
+			return err
+		}
+		for _, summary := range secretList.Secrets {
+			names = append(names, summary.Name)
+		}
+		nextToken = secretList.NextToken
+		if nextToken == "" {
+			break
+		}
+	}
+
+	dryRun := context.Bool(dryRunFlag)
+	secrets := make(map[string]string)
+	for _, name := range names {
+		secret, err := secretStore.Get(name, "")
+		if err != nil {
+			if _, ok := err.(*store.NotFoundError); ok {
+				log.Debugf("Skipping secret with name: %s as it has no active versions", name)
+				continue
+			}
+			return err
+		}
+		if api.IsBinary(secret.ContentType) {
+			log.Warnf("Skipping secret with name: %s as it has binary content type: %s", name, secret.ContentType)
+			continue
+		}
+		if dryRun {
+			log.Infof("Would export secret with name: %s and version: %d", name, secret.Serial)
+		}
+		secrets[name] = secret.Payload
+	}
+
+	if dryRun {
+		log.Infof("Dry run: %d secrets would be exported", len(secrets))
+		return nil
+	}
+
+	data, err := format.Write(exportFormat, secrets)
+	if err != nil {
+		return err
+	}
+	fileName := context.String(fileFlag)
+	if fileName == "" {
+		// Print secrets to stdout
+		fmt.Print(string(data))
+		return nil
+	}
+	log.Debugf("Writing %d secrets to %s", len(secrets), fileName)
+	err = writer.WriteFile(fileName, data, exportFileMode)
+	if err != nil {
+		return fmt.Errorf("Error writing to %s: %v", fileName, err)
+	}
+	log.Infof("Exported %d secrets to %s", len(secrets), fileName)
+	return nil
+}
+
+func (writer *ioutilFileWriter) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filename, data, perm)
+}
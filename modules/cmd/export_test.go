@@ -0,0 +1,127 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+// mockWriter implements a mock file writer that records what was written
+type mockWriter struct {
+	filename string
+	data     []byte
+	perm     os.FileMode
+}
+
+func (writer *mockWriter) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	writer.filename = filename
+	writer.data = data
+	writer.perm = perm
+	return nil
+}
+
+func TestExportCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(formatFlag, "dotenv", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := exportCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoExportUnknownFormat(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "toml", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doExport(context, nil, &mockWriter{})
+	if err == nil {
+		t.Error("Expected error when format is unknown")
+	}
+}
+
+func TestDoExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "dotenv", "")
+	flagSet.String(fileFlag, ".env", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().List("", "", int64(0)).Return(&api.SecretList{
+			Secrets:   []*api.SecretSummary{{Name: "DB_HOST"}, {Name: "DB_PASSWORD"}},
+			NextToken: "DB_PASSWORD",
+		}, nil),
+		secretStore.EXPECT().List("", "DB_PASSWORD", int64(0)).Return(&api.SecretList{
+			Secrets: []*api.SecretSummary{{Name: "OLD"}, {Name: "TLS_KEY"}},
+		}, nil),
+		secretStore.EXPECT().Get("DB_HOST", "").Return(&api.SecretRecord{Name: "DB_HOST", Payload: "db.example.com", Active: true}, nil),
+		secretStore.EXPECT().Get("DB_PASSWORD", "").Return(&api.SecretRecord{Name: "DB_PASSWORD", Payload: "hunter 2", Active: true}, nil),
+		secretStore.EXPECT().Get("OLD", "").Return(nil, &store.NotFoundError{Name: "OLD"}),
+		secretStore.EXPECT().Get("TLS_KEY", "").Return(&api.SecretRecord{Name: "TLS_KEY", Payload: "\x30\x82", Active: true, ContentType: api.OctetStreamContentType}, nil),
+	)
+	writer := &mockWriter{}
+	err := doExport(context, secretStore, writer)
+	if err != nil {
+		t.Fatalf("Error exporting secrets: %v", err)
+	}
+	if writer.filename != ".env" || writer.perm != exportFileMode {
+		t.Errorf("Secrets written to %s with mode %v", writer.filename, writer.perm)
+	}
+	expectedData := "DB_HOST=db.example.com\nDB_PASSWORD=\"hunter 2\"\n"
+	if string(writer.data) != expectedData {
+		t.Errorf("Mismatch between expected and exported secrets: %q != %q", string(writer.data), expectedData)
+	}
+}
+
+func TestDoExportDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "yaml", "")
+	flagSet.String(fileFlag, "secrets.yaml", "")
+	flagSet.String(prefixFlag, "prod/", "")
+	flagSet.Bool(dryRunFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().List("prod/", "", int64(0)).Return(&api.SecretList{
+			Secrets: []*api.SecretSummary{{Name: "prod/db"}},
+		}, nil),
+		secretStore.EXPECT().Get("prod/db", "").Return(&api.SecretRecord{Name: "prod/db", Payload: "hunter2", Active: true}, nil),
+	)
+	writer := &mockWriter{}
+	err := doExport(context, secretStore, writer)
+	if err != nil {
+		t.Fatalf("Error exporting secrets: %v", err)
+	}
+	if writer.data != nil {
+		t.Error("Expected no secrets to be written in a dry run")
+	}
+}
@@ -16,9 +16,11 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
 	log "github.com/cihub/seelog"
 
+	"github.com/awslabs/ecs-secrets/modules/api"
 	"github.com/awslabs/ecs-secrets/modules/store"
 	"github.com/urfave/cli"
 )
@@ -29,23 +31,64 @@ func fetchCommand(context *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	return doFetch(context, createSecretStore(appName))
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doFetch(context, secretStore)
 }
 
 func doFetch(context *cli.Context, secretStore store.Store) error {
 	// Validate secrets name has been specified
-	name, err := getRequiredArgumentFromFlag(context, nameFlag)
-	if err != nil {
-		return err
+	names := context.StringSlice(nameFlag)
+	if len(names) == 0 || names[0] == "" {
+		return fmt.Errorf("Missing required argument '%s'", nameFlag)
+	}
+	if len(names) > 1 {
+		return doFetchMany(context, names, secretStore)
 	}
+	name := names[0]
 
+	var err error
 	serial := context.String(serialFlag)
+	if label := context.String(labelFlag); label != "" {
+		if serial != "" {
+			return fmt.Errorf("Incorrect usage. Only one of '%s' or '%s' should be specified", serialFlag, labelFlag)
+		}
+		err = store.ValidateLabel(label)
+		if err != nil {
+			return err
+		}
+		serial = label
+	}
+	if context.String(fieldFlag) != "" && context.Bool(rawFlag) {
+		return fmt.Errorf("Incorrect usage. Only one of '%s' or '%s' should be specified", fieldFlag, rawFlag)
+	}
 	log.Debugf("Fetching secret name: %s with version: %s", name, serial)
 	secret, err := secretStore.Get(name, serial)
 	if err != nil {
 		return err
 	}
 
+	if field := context.String(fieldFlag); field != "" {
+		value, err := secret.Field(field)
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	}
+
+	if context.Bool(rawFlag) {
+		if !secret.Active {
+			return fmt.Errorf("Version %d of secret '%s' is not active", secret.Serial, secret.Name)
+		}
+		// Write the payload as is, since it may be binary
+		_, err = os.Stdout.WriteString(secret.Payload)
+		return err
+	}
+
+	secret.EncodePayload()
 	jsonBytes, err := json.Marshal(secret)
 	if err != nil {
 		return fmt.Errorf("Error encoding secret: %v", err)
@@ -55,3 +98,44 @@ func doFetch(context *cli.Context, secretStore store.Store) error {
 	fmt.Println(string(jsonBytes))
 	return nil
 }
+
+// doFetchMany fetches several secrets at once and prints them as a map of
+// their names to the secrets. The label, if specified, applies to every secret
+func doFetchMany(context *cli.Context, names []string, secretStore store.Store) error {
+	if context.String(serialFlag) != "" || context.String(fieldFlag) != "" || context.Bool(rawFlag) {
+		return fmt.Errorf("Incorrect usage. '%s', '%s' and '%s' cannot be used when fetching several secrets",
+			serialFlag, fieldFlag, rawFlag)
+	}
+	label := context.String(labelFlag)
+	if label != "" {
+		err := store.ValidateLabel(label)
+		if err != nil {
+			return err
+		}
+	}
+
+	var requests []*api.SecretRequest
+	for _, name := range names {
+		requests = append(requests, &api.SecretRequest{Name: name, Label: label})
+	}
+	log.Debugf("Fetching secrets: %v with label: %s", names, label)
+	batch, err := secretStore.GetMany(requests)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range batch.Secrets {
+		secret.EncodePayload()
+	}
+	jsonBytes, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("Error encoding secrets: %v", err)
+	}
+
+	// Print secrets to stdout, including those that could not be fetched
+	fmt.Println(string(jsonBytes))
+	if len(batch.Errors) != 0 {
+		return fmt.Errorf("%d of %d secrets could not be fetched", len(batch.Errors), len(names))
+	}
+	return nil
+}
@@ -27,7 +27,7 @@ import (
 
 func TestFetchCommandApplicationNameNotSet(t *testing.T) {
 	flagSet := flag.NewFlagSet("ecs-secrets", 0)
-	flagSet.String(nameFlag, "foo", "")
+	flagSet.Var(&cli.StringSlice{"foo"}, nameFlag, "")
 	flagSet.String(serialFlag, "1", "")
 	context := cli.NewContext(nil, flagSet, nil)
 	err := fetchCommand(context)
@@ -53,7 +53,7 @@ func TestDoFetch(t *testing.T) {
 
 	flagSet := flag.NewFlagSet("ecs-secrets", 0)
 	flagSet.String(applicationNameFlag, "myapp", "")
-	flagSet.String(nameFlag, "name", "")
+	flagSet.Var(&cli.StringSlice{"name"}, nameFlag, "")
 	context := cli.NewContext(nil, flagSet, nil)
 
 	secretStore := mock_store.NewMockStore(ctrl)
@@ -70,13 +70,133 @@ func TestDoFetch(t *testing.T) {
 	}
 }
 
+func TestDoFetchRawInactive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"name"}, nameFlag, "")
+	flagSet.Bool(rawFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Get("name", "").Return(&api.SecretRecord{
+		Name:   "name",
+		Serial: int64(1),
+	}, nil)
+	err := doFetch(context, secretStore)
+	if err == nil {
+		t.Error("Expected error fetching the raw payload of an inactive secret")
+	}
+}
+
+func TestDoFetchField(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"db"}, nameFlag, "")
+	flagSet.String(fieldFlag, "password", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Get("db", "").Return(&api.SecretRecord{
+		Name:        "db",
+		Serial:      int64(1),
+		Payload:     `{"password":"hunter2"}`,
+		Active:      true,
+		ContentType: api.StructuredContentType,
+	}, nil)
+	err := doFetch(context, secretStore)
+	if err != nil {
+		t.Errorf("Error fetching field of secret: %v", err)
+	}
+}
+
+func TestDoFetchFieldAndRaw(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"db"}, nameFlag, "")
+	flagSet.String(fieldFlag, "password", "")
+	flagSet.Bool(rawFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	err := doFetch(context, nil)
+	if err == nil {
+		t.Error("Expected error when both field and raw are specified")
+	}
+}
+
+func TestDoFetchMany(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"foo", "bar"}, nameFlag, "")
+	flagSet.String(labelFlag, "current", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().GetMany([]*api.SecretRequest{
+		{Name: "foo", Label: "current"},
+		{Name: "bar", Label: "current"},
+	}).Return(&api.SecretBatch{
+		Secrets: map[string]*api.SecretRecord{
+			"foo": {Name: "foo", Serial: 1, Payload: "one", Active: true},
+			"bar": {Name: "bar", Serial: 2, Payload: "two", Active: true},
+		},
+	}, nil)
+	err := doFetch(context, secretStore)
+	if err != nil {
+		t.Errorf("Error fetching secrets: %v", err)
+	}
+}
+
+func TestDoFetchManyPartialFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"foo", "bar"}, nameFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().GetMany(gomock.Any()).Return(&api.SecretBatch{
+		Secrets: map[string]*api.SecretRecord{
+			"foo": {Name: "foo", Serial: 1, Payload: "one", Active: true},
+		},
+		Errors: map[string]string{"bar": "Secret with name 'bar' not found"},
+	}, nil)
+	err := doFetch(context, secretStore)
+	if err == nil {
+		t.Error("Expected error when some of the secrets could not be fetched")
+	}
+}
+
+func TestDoFetchManyWithSerial(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"foo", "bar"}, nameFlag, "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	err := doFetch(context, nil)
+	if err == nil {
+		t.Error("Expected error when a serial is specified for several secrets")
+	}
+}
+
 func TestDoFetchOnSaveError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	flagSet := flag.NewFlagSet("ecs-secrets", 0)
 	flagSet.String(applicationNameFlag, "myapp", "")
-	flagSet.String(nameFlag, "name", "")
+	flagSet.Var(&cli.StringSlice{"name"}, nameFlag, "")
 	context := cli.NewContext(nil, flagSet, nil)
 
 	secretStore := mock_store.NewMockStore(ctrl)
@@ -86,3 +206,52 @@ func TestDoFetchOnSaveError(t *testing.T) {
 		t.Error("Expected error fetching secret")
 	}
 }
+
+func TestDoFetchByLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"name"}, nameFlag, "")
+	flagSet.String(labelFlag, "pending", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	apiSecret := &api.SecretRecord{
+		Name:    "name",
+		Serial:  int64(2),
+		Payload: "value",
+		Active:  true,
+	}
+	secretStore.EXPECT().Get("name", "pending").Return(apiSecret, nil)
+	err := doFetch(context, secretStore)
+	if err != nil {
+		t.Errorf("Error fetching secret: %v", err)
+	}
+}
+
+func TestDoFetchSerialAndLabelSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"name"}, nameFlag, "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.String(labelFlag, "pending", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doFetch(context, nil)
+	if err == nil {
+		t.Error("Expected error when both serial and label are specified")
+	}
+}
+
+func TestDoFetchInvalidLabel(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Var(&cli.StringSlice{"name"}, nameFlag, "")
+	flagSet.String(labelFlag, "2", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doFetch(context, nil)
+	if err == nil {
+		t.Error("Expected error when label is invalid")
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func gcCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doGC(context, secretStore)
+}
+
+func doGC(context *cli.Context, secretStore store.Store) error {
+	log.Debugf("Purging secrets whose recovery window has passed")
+	purged, err := secretStore.CollectGarbage()
+	for _, name := range purged {
+		log.Infof("Permanently deleted secret: %s", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Permanently deleted %d secrets scheduled for deletion", len(purged))
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestGCCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	context := cli.NewContext(nil, flagSet, nil)
+	err := gcCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoGC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().CollectGarbage().Return([]string{"foo", "bar"}, nil)
+	err := doGC(context, secretStore)
+	if err != nil {
+		t.Errorf("Error collecting garbage: %v", err)
+	}
+}
+
+func TestDoGCError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().CollectGarbage().Return([]string{"foo"}, fmt.Errorf("throttled"))
+	err := doGC(context, secretStore)
+	if err == nil {
+		t.Error("Expected error collecting garbage")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestHistoryCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := historyCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoHistorySecretNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doHistory(context, nil)
+	if err == nil {
+		t.Error("Expected error when name is not specified for the secret")
+	}
+}
+
+func TestDoHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().ListVersions("name").Return(&api.SecretHistory{
+		Name:     "name",
+		Versions: []*api.SecretSummary{{Name: "name", Serial: 1, Active: true}},
+	}, nil)
+	err := doHistory(context, secretStore)
+	if err != nil {
+		t.Errorf("Error listing versions of secret: %v", err)
+	}
+}
+
+func TestDoHistoryOnListVersionsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().ListVersions("name").Return(nil, fmt.Errorf("what secret?"))
+	err := doHistory(context, secretStore)
+	if err == nil {
+		t.Error("Expected error listing versions of secret")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/format"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func importCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doImport(context, secretStore, &ioutilFileReader{})
+}
+
+func doImport(context *cli.Context, secretStore store.Store, reader fileReader) error {
+	// Validate that the file to import has been specified
+	fileName, err := getRequiredArgumentFromFlag(context, fileFlag)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Reading from %s", fileName)
+	data, err := reader.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("Error reading from %s: %v", fileName, err)
+	}
+	secrets, err := format.Read(context.String(formatFlag), data)
+	if err != nil {
+		return err
+	}
+
+	// Validate all of the names before creating any secrets, so that a bad
+	// file does not leave the import half done
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		err = store.ValidateName(name)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dryRun := context.Bool(dryRunFlag)
+	created, updated, unchanged := 0, 0, 0
+	for _, name := range names {
+		latestSecret, err := secretStore.Get(name, "")
+		if err == nil && latestSecret.Payload == secrets[name] {
+			log.Infof("Secret with name: %s is unchanged", name)
+			unchanged++
+			continue
+		}
+		if err == nil {
+			updated++
+		} else if _, ok := err.(*store.NotFoundError); ok {
+			created++
+		} else {
+			return err
+		}
+
+		if dryRun {
+			if latestSecret == nil {
+				log.Infof("Would create secret with name: %s", name)
+			} else {
+				log.Infof("Would update secret with name: %s from version: %d", name, latestSecret.Serial)
+			}
+			continue
+		}
+
+		log.Debugf("Creating secret with name: %s", name)
+		editedSecret, err := secretStore.Save(&api.SecretRecord{
+			Name:    name,
+			Serial:  int64(1),
+			Payload: secrets[name],
+			Active:  true,
+		})
+		if err != nil {
+			if _, ok := err.(*store.ConflictError); ok {
+				return cli.NewExitError(err.Error(), conflictExitCode)
+			}
+			return err
+		}
+		log.Infof("Created application secret with name: %s and version: %d", editedSecret.Name, editedSecret.Serial)
+	}
+
+	if dryRun {
+		log.Infof("Dry run: %d secrets would be created, %d updated and %d are unchanged", created, updated, unchanged)
+	} else {
+		log.Infof("Imported secrets: %d created, %d updated and %d unchanged", created, updated, unchanged)
+	}
+	return nil
+}
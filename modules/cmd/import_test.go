@@ -0,0 +1,127 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+const testDotenv = `DB_HOST=db.example.com
+DB_PASSWORD=hunter2
+DB_USER=admin
+`
+
+func TestImportCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(fileFlag, ".env", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := importCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoImportFileNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "dotenv", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doImport(context, nil, &mockReader{payload: []byte(testDotenv)})
+	if err == nil {
+		t.Error("Expected error when file is not specified")
+	}
+}
+
+func TestDoImportInvalidName(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "dotenv", "")
+	flagSet.String(fileFlag, ".env", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doImport(context, nil, &mockReader{payload: []byte("DB_HOST=db\nDB/1=2\n")})
+	if err == nil {
+		t.Error("Expected error when a key is not a valid secret name")
+	}
+}
+
+func TestDoImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "dotenv", "")
+	flagSet.String(fileFlag, ".env", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().Get("DB_HOST", "").Return(&api.SecretRecord{Name: "DB_HOST", Serial: 1, Payload: "db.example.com", Active: true}, nil),
+		secretStore.EXPECT().Get("DB_PASSWORD", "").Return(&api.SecretRecord{Name: "DB_PASSWORD", Serial: 4, Payload: "letmein", Active: true}, nil),
+		secretStore.EXPECT().Save(&api.SecretRecord{Name: "DB_PASSWORD", Serial: 1, Payload: "hunter2", Active: true}).Return(&api.SecretRecord{Name: "DB_PASSWORD", Serial: 5}, nil),
+		secretStore.EXPECT().Get("DB_USER", "").Return(nil, &store.NotFoundError{Name: "DB_USER"}),
+		secretStore.EXPECT().Save(&api.SecretRecord{Name: "DB_USER", Serial: 1, Payload: "admin", Active: true}).Return(&api.SecretRecord{Name: "DB_USER", Serial: 1}, nil),
+	)
+	err := doImport(context, secretStore, &mockReader{payload: []byte(testDotenv)})
+	if err != nil {
+		t.Errorf("Error importing secrets: %v", err)
+	}
+}
+
+func TestDoImportDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "json", "")
+	flagSet.String(fileFlag, "secrets.json", "")
+	flagSet.Bool(dryRunFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().Get("DB_HOST", "").Return(&api.SecretRecord{Name: "DB_HOST", Serial: 1, Payload: "old.example.com", Active: true}, nil),
+		secretStore.EXPECT().Get("DB_USER", "").Return(nil, &store.NotFoundError{Name: "DB_USER"}),
+	)
+	err := doImport(context, secretStore, &mockReader{payload: []byte(`{"DB_HOST":"db.example.com","DB_USER":"admin"}`)})
+	if err != nil {
+		t.Errorf("Error importing secrets: %v", err)
+	}
+}
+
+func TestDoImportGetError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(formatFlag, "dotenv", "")
+	flagSet.String(fileFlag, ".env", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Get("DB_HOST", "").Return(nil, fmt.Errorf("throttled"))
+	err := doImport(context, secretStore, &mockReader{payload: []byte(testDotenv)})
+	if err == nil {
+		t.Error("Expected error when the latest version of a secret cannot be read")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func labelCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doLabel(context, secretStore)
+}
+
+func doLabel(context *cli.Context, secretStore store.Store) error {
+	// Validate that secrets name has been specified
+	name, err := getRequiredArgumentFromFlag(context, nameFlag)
+	if err != nil {
+		return err
+	}
+
+	// Validate that the label has been specified
+	label, err := getRequiredArgumentFromFlag(context, labelFlag)
+	if err != nil {
+		return err
+	}
+
+	// Validate that secrets version has been specified
+	serial, err := getRequiredArgumentFromFlag(context, serialFlag)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Moving label: %s of secret name: %s to version: %s", label, name, serial)
+	err = secretStore.MoveLabel(name, label, serial)
+	if err != nil {
+		if _, ok := err.(*store.ConflictError); ok {
+			return cli.NewExitError(err.Error(), conflictExitCode)
+		}
+		return err
+	}
+
+	log.Infof("Label %s of secret: %s now points at version: %s", label, name, serial)
+	return nil
+}
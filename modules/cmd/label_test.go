@@ -0,0 +1,98 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestLabelCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(labelFlag, "current", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := labelCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoLabelLabelNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doLabel(context, nil)
+	if err == nil {
+		t.Error("Expected error when label is not specified")
+	}
+}
+
+func TestDoLabelSerialNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(labelFlag, "current", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doLabel(context, nil)
+	if err == nil {
+		t.Error("Expected error when serial is not specified")
+	}
+}
+
+func TestDoLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(labelFlag, "current", "")
+	flagSet.String(serialFlag, "2", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().MoveLabel("foo", "current", "2").Return(nil)
+	err := doLabel(context, secretStore)
+	if err != nil {
+		t.Errorf("Error moving label: %v", err)
+	}
+}
+
+func TestDoLabelConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(labelFlag, "current", "")
+	flagSet.String(serialFlag, "2", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().MoveLabel("foo", "current", "2").Return(&store.ConflictError{Name: "foo", Reason: "busy"})
+	err := doLabel(context, secretStore)
+	exitErr, ok := err.(cli.ExitCoder)
+	if !ok || exitErr.ExitCode() != conflictExitCode {
+		t.Errorf("Expected exit error with conflict exit code, got: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func listCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doList(context, secretStore)
+}
+
+func doList(context *cli.Context, secretStore store.Store) error {
+	// Page through all of the secrets in the store
+	secrets := []*api.SecretSummary{}
+	prefix := context.String(prefixFlag)
+	nextToken := ""
+	for {
+		log.Debugf("Listing secrets, prefix: %s, nextToken: %s", prefix, nextToken)
+		secretList, err := secretStore.List(prefix, nextToken, 0)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, secretList.Secrets...)
+		nextToken = secretList.NextToken
+		if nextToken == "" {
+			break
+		}
+	}
+
+	jsonBytes, err := json.Marshal(&api.SecretList{Secrets: secrets})
+	if err != nil {
+		return fmt.Errorf("Error encoding secrets: %v", err)
+	}
+
+	// Print secrets to stdout
+	fmt.Println(string(jsonBytes))
+	return nil
+}
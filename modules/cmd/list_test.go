@@ -0,0 +1,94 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestListCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	context := cli.NewContext(nil, flagSet, nil)
+	err := listCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().List("", "", int64(0)).Return(&api.SecretList{
+			Secrets:   []*api.SecretSummary{{Name: "foo", Serial: 1, Active: true}},
+			NextToken: "token",
+		}, nil),
+		secretStore.EXPECT().List("", "token", int64(0)).Return(&api.SecretList{
+			Secrets: []*api.SecretSummary{{Name: "bar", Serial: 2, Active: true}},
+		}, nil),
+	)
+	err := doList(context, secretStore)
+	if err != nil {
+		t.Errorf("Error listing secrets: %v", err)
+	}
+}
+
+func TestDoListWithPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(prefixFlag, "prod/payments/", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().List("prod/payments/", "", int64(0)).Return(&api.SecretList{
+		Secrets: []*api.SecretSummary{{Name: "prod/payments/db-password", Serial: 1, Active: true}},
+	}, nil)
+	err := doList(context, secretStore)
+	if err != nil {
+		t.Errorf("Error listing secrets: %v", err)
+	}
+}
+
+func TestDoListOnListError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().List("", "", int64(0)).Return(nil, fmt.Errorf("i forgot"))
+	err := doList(context, secretStore)
+	if err == nil {
+		t.Error("Expected error listing secrets")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func pruneCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doPrune(context, secretStore)
+}
+
+func doPrune(context *cli.Context, secretStore store.Store) error {
+	purge := context.Bool(purgeFlag)
+	if purge && !context.Bool(confirmFlag) {
+		return fmt.Errorf("Permanently deleting versions of secrets cannot be undone. Specify '%s' to continue", confirmFlag)
+	}
+
+	names := []string{}
+	if name := context.String(nameFlag); name != "" {
+		names = append(names, name)
+	} else {
+		// Page through all of the secrets in the store
+		nextToken := ""
+		for {
+			secretList, err := secretStore.List("", nextToken, 0)
+			if err != nil {
+				return err
+			}
+			for _, secret := range secretList.Secrets {
+				names = append(names, secret.Name)
+			}
+			nextToken = secretList.NextToken
+			if nextToken == "" {
+				break
+			}
+		}
+	}
+
+	for _, name := range names {
+		log.Debugf("Pruning secret name: %s, purge: %t", name, purge)
+		pruned, err := secretStore.Prune(name, purge)
+		if err != nil {
+			return err
+		}
+		for _, version := range pruned {
+			if purge {
+				log.Infof("Permanently deleted secret: %s, version: %d", name, version.Serial)
+			} else {
+				log.Infof("Revoked secret: %s, version: %d", name, version.Serial)
+			}
+		}
+	}
+	return nil
+}
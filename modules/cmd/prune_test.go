@@ -0,0 +1,108 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestPruneCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := pruneCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoPrunePurgeNotConfirmed(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Bool(purgeFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doPrune(context, nil)
+	if err == nil {
+		t.Error("Expected error when purge is not confirmed")
+	}
+}
+
+func TestDoPrune(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Prune("foo", false).Return([]*api.SecretSummary{{Name: "foo", Serial: 1}}, nil)
+	err := doPrune(context, secretStore)
+	if err != nil {
+		t.Errorf("Error pruning secret: %v", err)
+	}
+}
+
+func TestDoPruneAllSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Bool(purgeFlag, true, "")
+	flagSet.Bool(confirmFlag, true, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	gomock.InOrder(
+		secretStore.EXPECT().List("", "", int64(0)).Return(&api.SecretList{
+			Secrets:   []*api.SecretSummary{{Name: "foo"}},
+			NextToken: "token",
+		}, nil),
+		secretStore.EXPECT().List("", "token", int64(0)).Return(&api.SecretList{
+			Secrets: []*api.SecretSummary{{Name: "bar"}},
+		}, nil),
+		secretStore.EXPECT().Prune("foo", true).Return(nil, nil),
+		secretStore.EXPECT().Prune("bar", true).Return(nil, nil),
+	)
+	err := doPrune(context, secretStore)
+	if err != nil {
+		t.Errorf("Error pruning secrets: %v", err)
+	}
+}
+
+func TestDoPruneOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Prune("foo", false).Return(nil, fmt.Errorf("throttled"))
+	err := doPrune(context, secretStore)
+	if err == nil {
+		t.Error("Expected error pruning secret")
+	}
+}
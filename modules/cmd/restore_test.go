@@ -0,0 +1,93 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestRestoreCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := restoreCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoRestoreSecretNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doRestore(context, nil)
+	if err == nil {
+		t.Error("Expected error when name is not specified for the secret")
+	}
+}
+
+func TestDoRestoreSecretSerialNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := doRestore(context, nil)
+	if err == nil {
+		t.Error("Expected error when serial is not specified for the secret")
+	}
+}
+
+func TestDoRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(serialFlag, "1", "")
+	flagSet.String(reasonFlag, "revoked by mistake", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Restore("name", "1", "revoked by mistake").Return(nil)
+	err := doRestore(context, secretStore)
+	if err != nil {
+		t.Errorf("Error restoring secret: %v", err)
+	}
+}
+
+func TestDoRestoreOnRestoreError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "name", "")
+	flagSet.String(serialFlag, "1", "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().Restore("name", "1", "").Return(fmt.Errorf("no such version"))
+	err := doRestore(context, secretStore)
+	if err == nil {
+		t.Error("Expected error restoring secret")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	log "github.com/cihub/seelog"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store"
+	"github.com/urfave/cli"
+)
+
+func retentionCommand(context *cli.Context) error {
+	// Validate that application name has been specified
+	appName, err := getRequiredArgumentFromFlag(context, applicationNameFlag)
+	if err != nil {
+		return err
+	}
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doRetention(context, secretStore)
+}
+
+func doRetention(context *cli.Context, secretStore store.Store) error {
+	name := context.String(nameFlag)
+	policy := &api.RetentionPolicy{
+		MaxVersions: context.Int64(maxVersionsFlag),
+		MaxAge:      context.Duration(maxAgeFlag),
+	}
+
+	log.Debugf("Setting retention policy of secret name: %s to: %v", name, policy)
+	err := secretStore.SetRetentionPolicy(name, policy)
+	if err != nil {
+		if _, ok := err.(*store.ConflictError); ok {
+			return cli.NewExitError(err.Error(), conflictExitCode)
+		}
+		return err
+	}
+
+	if name == "" {
+		log.Infof("Set default retention policy, versions: %d, age: %v", policy.MaxVersions, policy.MaxAge)
+	} else {
+		log.Infof("Set retention policy of secret: %s, versions: %d, age: %v", name, policy.MaxVersions, policy.MaxAge)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/urfave/cli"
+)
+
+func TestRetentionCommandApplicationNameNotSet(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Int64(maxVersionsFlag, 5, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	err := retentionCommand(context)
+	if err == nil {
+		t.Error("Expected error when application name is not specified")
+	}
+}
+
+func TestDoRetention(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.String(nameFlag, "foo", "")
+	flagSet.Int64(maxVersionsFlag, 5, "")
+	flagSet.Duration(maxAgeFlag, 720*time.Hour, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().SetRetentionPolicy("foo", &api.RetentionPolicy{
+		MaxVersions: 5,
+		MaxAge:      720 * time.Hour,
+	}).Return(nil)
+	err := doRetention(context, secretStore)
+	if err != nil {
+		t.Errorf("Error setting retention policy: %v", err)
+	}
+}
+
+func TestDoRetentionApplicationDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Int64(maxVersionsFlag, 10, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().SetRetentionPolicy("", &api.RetentionPolicy{MaxVersions: 10}).Return(nil)
+	err := doRetention(context, secretStore)
+	if err != nil {
+		t.Errorf("Error setting retention policy: %v", err)
+	}
+}
+
+func TestDoRetentionOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(applicationNameFlag, "myapp", "")
+	flagSet.Int64(maxVersionsFlag, -1, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	secretStore := mock_store.NewMockStore(ctrl)
+	secretStore.EXPECT().SetRetentionPolicy("", gomock.Any()).Return(fmt.Errorf("negative"))
+	err := doRetention(context, secretStore)
+	if err == nil {
+		t.Error("Expected error setting retention policy")
+	}
+}
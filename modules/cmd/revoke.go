@@ -26,7 +26,11 @@ func revokeCommand(context *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	return doRevoke(context, createSecretStore(appName))
+	secretStore, err := createSecretStore(context, appName)
+	if err != nil {
+		return err
+	}
+	return doRevoke(context, secretStore)
 }
 
 func doRevoke(context *cli.Context, secretStore store.Store) error {
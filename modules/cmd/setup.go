@@ -33,7 +33,8 @@ const (
     "Effect": "Allow",
     "Action": [
 	"dynamodb:Query",
-	"dynamodb:GetItem"
+	"dynamodb:GetItem",
+	"dynamodb:BatchGetItem"
     ],
     "Resource": [
 	"%s"
@@ -43,8 +44,10 @@ const (
 	secretsTablePutPolicyStatement = `{
     "Effect": "Allow",
     "Action": [
+	"dynamodb:DeleteItem",
 	"dynamodb:PutItem",
 	"dynamodb:Query",
+	"dynamodb:Scan",
 	"dynamodb:UpdateItem"
     ],
     "Resource": [
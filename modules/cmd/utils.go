@@ -15,21 +15,33 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/ecs-secrets/modules/backend"
 	"github.com/awslabs/ecs-secrets/modules/cache"
 	"github.com/awslabs/ecs-secrets/modules/crypt"
 	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/identity"
 	"github.com/awslabs/ecs-secrets/modules/logger"
 	"github.com/awslabs/ecs-secrets/modules/store"
+	log "github.com/cihub/seelog"
 	"github.com/urfave/cli"
 )
 
 const (
 	loglevelInfo  = "info"
 	loglevelDebug = "debug"
+
+	// conflictExitCode is the exit code used when a secret could not be
+	// saved because of other changes being made to it at the same time
+	conflictExitCode = 2
+
+	// masterKeyFileSuffix is appended to the name of the data file of the
+	// file backend to name the file its local master key is kept in
+	masterKeyFileSuffix = ".key"
 )
 
 func beforeCommand(context *cli.Context) error {
@@ -54,9 +66,122 @@ func getRequiredArgumentFromFlag(context *cli.Context, flagName string) (string,
 	return argValue, nil
 }
 
-func createSecretStore(appName string) store.Store {
-	dao := dao.NewDAO(appName, dynamodb.New(session.New()))
-	lruCache := cache.NewLRUCache(cache.KeyCacheSize, cache.KeyCacheTTL)
-	crypter := crypt.NewCrypter(kms.New(session.New()), lruCache, appName)
-	return store.NewStore(appName, dao, crypter)
+func createSecretStore(context *cli.Context, appName string) (store.Store, error) {
+	secretDAO, err := createSecretDAO(context, appName)
+	if err != nil {
+		return nil, err
+	}
+	crypter, err := createCrypter(context, appName)
+	if err != nil {
+		return nil, err
+	}
+	identityProvider, err := createIdentityProvider(context)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewStore(appName, secretDAO, crypter, identityProvider), nil
+}
+
+// createSecretDAO creates the data access layer for the secrets of the
+// application in the backend selected with the backend flags
+func createSecretDAO(context *cli.Context, appName string) (dao.DAO, error) {
+	backendName, options, err := getBackend(context)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Using backend: %s, options: %v", backendName, options)
+	return backend.New(backendName, appName, options)
+}
+
+// createCrypter creates the crypter for the secrets of the application. Secrets
+// stored in the file backend are encrypted with a local master key kept next
+// to the data file, and secrets stored in the vault backend with a key of the
+// transit secrets engine, so that no AWS service is needed to use them.
+// Secrets stored in every other backend are encrypted with KMS
+func createCrypter(context *cli.Context, appName string) (crypt.Crypter, error) {
+	backendName, options, err := getBackend(context)
+	if err != nil {
+		return nil, err
+	}
+	if backendName == backend.Vault {
+		vaultClient, err := backend.NewVaultClient(options)
+		if err != nil {
+			return nil, err
+		}
+		keyName := backend.TransitKeyName(appName, options)
+		log.Debugf("Using transit key: %s", keyName)
+		lruCache := cache.NewLRUCache(cache.KeyCacheSize, cache.KeyCacheTTL)
+		return crypt.NewVaultCrypter(vaultClient, lruCache, keyName), nil
+	}
+	if backendName != backend.File {
+		lruCache := cache.NewLRUCache(cache.KeyCacheSize, cache.KeyCacheTTL)
+		return crypt.NewCrypter(kms.New(session.New()), lruCache, appName), nil
+	}
+
+	dataFile := options[backend.DataFileOption]
+	if dataFile == "" {
+		return nil, fmt.Errorf("Missing required option '%s' for backend '%s'", backend.DataFileOption, backend.File)
+	}
+	masterKeyFile := dataFile + masterKeyFileSuffix
+	log.Debugf("Using local master key: %s", masterKeyFile)
+	masterKey, err := crypt.LoadOrCreateMasterKey(masterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return crypt.NewLocalCrypter(masterKey)
+}
+
+// createIdentityProvider creates the provider of the identity recorded in the
+// audit fields of secrets. The local user is used with the file backend, the
+// Vault token with the vault backend, the caller's IAM identity otherwise
+func createIdentityProvider(context *cli.Context) (identity.Provider, error) {
+	backendName, options, err := getBackend(context)
+	if err != nil {
+		return nil, err
+	}
+	switch backendName {
+	case backend.File:
+		return identity.NewLocalProvider(), nil
+	case backend.Vault:
+		vaultClient, err := backend.NewVaultClient(options)
+		if err != nil {
+			return nil, err
+		}
+		return identity.NewVaultProvider(vaultClient), nil
+	default:
+		return identity.NewSTSProvider(sts.New(session.New())), nil
+	}
+}
+
+// getBackend returns the name and the options of the backend selected with
+// the backend flags
+func getBackend(context *cli.Context) (string, map[string]string, error) {
+	backendName := context.String(backendFlag)
+	if backendName == "" {
+		backendName = backend.Default
+	}
+	options, err := parseBackendOptions(context.StringSlice(backendOptionFlag))
+	if err != nil {
+		return "", nil, err
+	}
+	// The data file flag is only used by the file backend, so that setting
+	// it in the environment doesn't break the other backends
+	if dataFile := context.String(dataFileFlag); dataFile != "" && backendName == backend.File {
+		options[backend.DataFileOption] = dataFile
+	}
+	return backendName, options, nil
+}
+
+// parseBackendOptions parses options of the backend specified as key=value
+// pairs
+func parseBackendOptions(optionArgs []string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, optionArg := range optionArgs {
+		parts := strings.SplitN(optionArg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Incorrect usage. Backend options should be specified as key=value, got '%s'", optionArg)
+		}
+		options[parts[0]] = parts[1]
+	}
+	return options, nil
 }
@@ -15,8 +15,18 @@ package cmd
 
 import (
 	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/backend"
+	"github.com/awslabs/ecs-secrets/modules/dao"
 	"github.com/urfave/cli"
 )
 
@@ -48,3 +58,120 @@ func TestGetLoglLevelReturnsInfoWhenDebugFlagIsSetToFalse(t *testing.T) {
 		t.Errorf("Inorrect loglevel set: %s", loglevel)
 	}
 }
+
+func TestParseBackendOptions(t *testing.T) {
+	options, err := parseBackendOptions([]string{"parameter-type=String", "key-id=alias/a=b"})
+	if err != nil {
+		t.Fatalf("Error parsing backend options: %v", err)
+	}
+	expectedOptions := map[string]string{"parameter-type": "String", "key-id": "alias/a=b"}
+	if !reflect.DeepEqual(options, expectedOptions) {
+		t.Errorf("Mismatch between expected and parsed options: %v != %v", expectedOptions, options)
+	}
+}
+
+func TestParseBackendOptionsInvalidOption(t *testing.T) {
+	_, err := parseBackendOptions([]string{"parameter-type"})
+	if err == nil {
+		t.Error("Expected error parsing an option that is not key=value")
+	}
+}
+
+func TestCreateSecretDAOUnknownBackend(t *testing.T) {
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(backendFlag, "etcd", "")
+	context := cli.NewContext(nil, flagSet, nil)
+	_, err := createSecretDAO(context, "myapp")
+	if err == nil {
+		t.Error("Expected error creating an unknown backend")
+	}
+}
+
+func TestCreateSecretStoreFileBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecs-secrets")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dataFile := filepath.Join(dir, "secrets.db")
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(backendFlag, backend.File, "")
+	flagSet.String(dataFileFlag, dataFile, "")
+	context := cli.NewContext(nil, flagSet, nil)
+	secretStore, err := createSecretStore(context, "myapp")
+	if err != nil {
+		t.Fatalf("Error creating secret store: %v", err)
+	}
+
+	saved, err := secretStore.Save(&api.SecretRecord{Name: "foo", Serial: 1, Payload: "bar", Active: true})
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+	if saved.Serial != 1 || !strings.HasPrefix(saved.CreatedBy, "local:") {
+		t.Errorf("Unexpected saved secret: %v", saved)
+	}
+	_, err = os.Stat(dataFile + masterKeyFileSuffix)
+	if err != nil {
+		t.Errorf("Expected master key file to be created: %v", err)
+	}
+
+	loaded, err := secretStore.Get("foo", "")
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
+	}
+	if loaded.Payload != "bar" {
+		t.Errorf("Mismatch between saved and loaded payloads, got: %s", loaded.Payload)
+	}
+}
+
+func TestCreateCrypterAndIdentityProviderVaultBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/ecs-secrets-myapp":
+			w.Write([]byte(`{"data":{"plaintext":"c3VwZXItYXdlc29tZS1hZXMta2V5LXNvLXNlY3VyZT8=","ciphertext":"vault:v1:wrapped"}}`))
+		case "/v1/transit/decrypt/ecs-secrets-myapp":
+			w.Write([]byte(`{"data":{"plaintext":"c3VwZXItYXdlc29tZS1hZXMta2V5LXNvLXNlY3VyZT8="}}`))
+		case "/v1/auth/token/lookup-self":
+			w.Write([]byte(`{"data":{"display_name":"token-ci"}}`))
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	flagSet := flag.NewFlagSet("ecs-secrets", 0)
+	flagSet.String(backendFlag, backend.Vault, "")
+	backendOptions := cli.StringSlice{"address=" + server.URL, "token=s.token"}
+	flagSet.Var(&backendOptions, backendOptionFlag, "")
+	context := cli.NewContext(nil, flagSet, nil)
+
+	crypter, err := createCrypter(context, "myapp")
+	if err != nil {
+		t.Fatalf("Error creating crypter: %v", err)
+	}
+	record, err := crypter.EncryptSecret(&dao.SecretRecord{Name: "foo", Serial: 1}, []byte("bar"))
+	if err != nil {
+		t.Fatalf("Error encrypting secret: %v", err)
+	}
+	secret, err := crypter.DecryptSecret(record)
+	if err != nil {
+		t.Fatalf("Error decrypting secret: %v", err)
+	}
+	if string(secret) != "bar" {
+		t.Errorf("Mismatch between encrypted and decrypted secrets, got: %s", secret)
+	}
+
+	identityProvider, err := createIdentityProvider(context)
+	if err != nil {
+		t.Fatalf("Error creating identity provider: %v", err)
+	}
+	caller, err := identityProvider.CallerIdentity()
+	if err != nil {
+		t.Fatalf("Error getting caller identity: %v", err)
+	}
+	if caller != "vault:token-ci" {
+		t.Errorf("Incorrect caller identity: %s", caller)
+	}
+}
@@ -30,8 +30,8 @@ const (
 
 // Crypter defines the interface to encrypt and decrypt secret records
 type Crypter interface {
-	EncryptSecret(*dao.SecretRecord, string) (*dao.SecretRecord, error)
-	DecryptSecret(*dao.SecretRecord) (*string, error)
+	EncryptSecret(*dao.SecretRecord, []byte) (*dao.SecretRecord, error)
+	DecryptSecret(*dao.SecretRecord) ([]byte, error)
 }
 
 // kmsCrypter implements the Crypter interface to encrypt and decrupt secret records
@@ -52,7 +52,7 @@ func NewCrypter(kmsClient client.Client, keyCache cache.Cache, appName string) C
 }
 
 // EncryptSecret encrypts a secret record using a KMS data key
-func (crypter *kmsCrypter) EncryptSecret(secretRecord *dao.SecretRecord, secret string) (*dao.SecretRecord, error) {
+func (crypter *kmsCrypter) EncryptSecret(secretRecord *dao.SecretRecord, secret []byte) (*dao.SecretRecord, error) {
 	// call kms to get datakey
 	result, err := crypter.generateDataKey()
 	if err != nil {
@@ -72,7 +72,7 @@ func (crypter *kmsCrypter) EncryptSecret(secretRecord *dao.SecretRecord, secret
 }
 
 // DecryptSecret decrypts a secret record using a KMS data key
-func (crypter *kmsCrypter) DecryptSecret(secretRecord *dao.SecretRecord) (*string, error) {
+func (crypter *kmsCrypter) DecryptSecret(secretRecord *dao.SecretRecord) ([]byte, error) {
 	// decrypt the datakey
 	dataKey, err := crypter.fetchDataKey(secretRecord)
 	if err != nil {
@@ -85,13 +85,7 @@ func (crypter *kmsCrypter) DecryptSecret(secretRecord *dao.SecretRecord) (*strin
 	}
 
 	// decrypt the data
-	decryptedData, err := decrypt(decodedData, dataKey)
-	if err != nil {
-		return nil, err
-	}
-
-	secretData := string(decryptedData)
-	return &secretData, nil
+	return decrypt(decodedData, dataKey)
 }
 
 func (crypter *kmsCrypter) generateDataKey() (*kms.GenerateDataKeyOutput, error) {
@@ -14,6 +14,7 @@
 package crypt
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -46,7 +47,7 @@ func TestEncryptSecret(t *testing.T) {
 	cache := mock_cache.NewMockCache(ctrl)
 	crypter := NewCrypter(kmsClient, cache, "myapp")
 	secret := &dao.SecretRecord{}
-	_, err := crypter.EncryptSecret(secret, "mysecret")
+	_, err := crypter.EncryptSecret(secret, []byte("mysecret"))
 	if err != nil {
 		t.Errorf("Error encrypting secret: %v", err)
 	}
@@ -65,7 +66,7 @@ func TestEncryptSecretOnGenerateDataKeyError(t *testing.T) {
 	cache := mock_cache.NewMockCache(ctrl)
 	crypter := NewCrypter(kmsClient, cache, "myapp")
 	secret := &dao.SecretRecord{}
-	_, err := crypter.EncryptSecret(secret, "mysecret")
+	_, err := crypter.EncryptSecret(secret, []byte("mysecret"))
 	if err == nil {
 		t.Error("Expected error encrypting secret")
 	}
@@ -91,7 +92,7 @@ func TestEncryptSecretOnAESEncryptError(t *testing.T) {
 	secret := &dao.SecretRecord{
 		EncryptedData: "",
 	}
-	_, err := crypter.EncryptSecret(secret, "mysecret")
+	_, err := crypter.EncryptSecret(secret, []byte("mysecret"))
 	if err == nil {
 		t.Error("Expected error encrypting secret")
 	}
@@ -105,7 +106,7 @@ func TestDecryptSecretCacheHit(t *testing.T) {
 	cache := mock_cache.NewMockCache(ctrl)
 
 	payload := "one divided by zero is infinity"
-	encrypted, err := encrypt(payload, []byte(aesKey))
+	encrypted, err := encrypt([]byte(payload), []byte(aesKey))
 	if err != nil {
 		t.Fatal("Error encrypting data: %v", err)
 	}
@@ -133,7 +134,7 @@ func TestDecryptSecretCacheMiss(t *testing.T) {
 	cache := mock_cache.NewMockCache(ctrl)
 
 	payload := "one divided by zero is infinity"
-	encrypted, err := encrypt(payload, []byte(aesKey))
+	encrypted, err := encrypt([]byte(payload), []byte(aesKey))
 	if err != nil {
 		t.Fatal("Error encrypting data: %v", err)
 	}
@@ -169,7 +170,7 @@ func TestDecryptSecretCacheMissOnKMSDecryptError(t *testing.T) {
 	cache := mock_cache.NewMockCache(ctrl)
 
 	payload := "one divided by zero is infinity"
-	encrypted, err := encrypt(payload, []byte(aesKey))
+	encrypted, err := encrypt([]byte(payload), []byte(aesKey))
 	if err != nil {
 		t.Fatal("Error encrypting data: %v", err)
 	}
@@ -193,3 +194,34 @@ func TestDecryptSecretCacheMissOnKMSDecryptError(t *testing.T) {
 		t.Error("Expected error decrypting secret")
 	}
 }
+
+func TestDecryptSecretBinaryPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	kmsClient := mock_client.NewMockClient(ctrl)
+	cache := mock_cache.NewMockCache(ctrl)
+
+	payload := []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x00, 0x00, 0x02, 0xff}
+	encrypted, err := encrypt(payload, []byte(aesKey))
+	if err != nil {
+		t.Fatalf("Error encrypting data: %v", err)
+	}
+	b64Encrypted := base64Encode(encrypted)
+
+	secret := &dao.SecretRecord{
+		EncryptedDataKey: b64Encrypted,
+		EncryptedData:    b64Encrypted,
+	}
+
+	cache.EXPECT().Get(secret.EncryptedDataKey).Return([]byte(aesKey), true)
+
+	crypter := NewCrypter(kmsClient, cache, "myapp")
+	decrypted, err := crypter.DecryptSecret(secret)
+	if err != nil {
+		t.Fatalf("Error decrypting secret: %v", err)
+	}
+	if !bytes.Equal(decrypted, payload) {
+		t.Errorf("Incorrect payload decrypted: %v", decrypted)
+	}
+}
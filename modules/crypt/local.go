@@ -0,0 +1,166 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package crypt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/gtank/cryptopasta"
+)
+
+const (
+	// MasterKeySize is the size of a local master key in bytes
+	MasterKeySize = 32
+	// masterKeyFileMode is the mode a master key file is created with
+	masterKeyFileMode = 0600
+)
+
+// localCrypter implements the Crypter interface to encrypt and decrypt secret
+// records with a master key held locally instead of a KMS key. Every secret
+// is encrypted with a new data key, which is stored encrypted with the master
+// key, the same way KMS data keys are
+type localCrypter struct {
+	masterKey []byte
+}
+
+// NewLocalCrypter creates a new Crypter object that uses a local master key
+func NewLocalCrypter(masterKey []byte) (Crypter, error) {
+	if len(masterKey) != MasterKeySize {
+		return nil, fmt.Errorf("Invalid master key size: %d bytes, expected %d", len(masterKey), MasterKeySize)
+	}
+	return &localCrypter{
+		masterKey: masterKey,
+	}, nil
+}
+
+// EncryptSecret encrypts a secret record using a data key generated locally
+func (crypter *localCrypter) EncryptSecret(secretRecord *dao.SecretRecord, secret []byte) (*dao.SecretRecord, error) {
+	dataKey := cryptopasta.NewEncryptionKey()
+	encryptedDataKey, err := encrypt(dataKey[:], crypter.masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedBlob, err := encrypt(secret, dataKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	secretRecord.EncryptedData = base64Encode(encryptedBlob)
+	secretRecord.EncryptedDataKey = base64Encode(encryptedDataKey)
+	return secretRecord, nil
+}
+
+// DecryptSecret decrypts a secret record using its data key, after decrypting
+// the data key with the master key
+func (crypter *localCrypter) DecryptSecret(secretRecord *dao.SecretRecord) ([]byte, error) {
+	decodedKey, err := base64Decode(secretRecord.EncryptedDataKey)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := decrypt(decodedKey, crypter.masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	decodedData, err := base64Decode(secretRecord.EncryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(decodedData, dataKey)
+}
+
+// GenerateMasterKey generates a new random master key
+func GenerateMasterKey() []byte {
+	masterKey := cryptopasta.NewEncryptionKey()
+	return masterKey[:]
+}
+
+// LoadOrCreateMasterKey reads a base64 encoded master key from a file. If the
+// file does not exist, a new master key is generated and written to the file,
+// readable only by its owner
+func LoadOrCreateMasterKey(filename string) ([]byte, error) {
+	masterKey, err := loadMasterKey(filename)
+	if err == nil || !os.IsNotExist(err) {
+		return masterKey, err
+	}
+
+	masterKey = GenerateMasterKey()
+	err = createMasterKeyFile(filename, masterKey)
+	if os.IsExist(err) {
+		// Another process created the file first, its key is used instead
+		return loadMasterKey(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error creating master key file '%s': %v", filename, err)
+	}
+	return masterKey, nil
+}
+
+// loadMasterKey reads a base64 encoded master key from a file. The error is
+// returned as is if the file does not exist
+func loadMasterKey(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading master key file '%s': %v", filename, err)
+	}
+	masterKey, err := base64Decode(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding master key file '%s': %v", filename, err)
+	}
+	return masterKey, nil
+}
+
+// createMasterKeyFile writes the master key to a temporary file next to the
+// file, which is then linked to the file's name. Other processes never see a
+// partly written key, and a key created by another process at the same time
+// is never overwritten, as linking fails if the file already exists
+func createMasterKeyFile(filename string, masterKey []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = writeMasterKey(file, masterKey)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Link(file.Name(), filename)
+}
+
+func writeMasterKey(file *os.File, masterKey []byte) error {
+	err := file.Chmod(masterKeyFileMode)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(base64Encode(masterKey) + "\n")
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
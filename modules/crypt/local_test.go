@@ -0,0 +1,132 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/dao"
+)
+
+func TestLocalCrypterEncryptDecrypt(t *testing.T) {
+	crypter, err := NewLocalCrypter(bytes.Repeat([]byte{1}, MasterKeySize))
+	if err != nil {
+		t.Fatalf("Error creating crypter: %v", err)
+	}
+
+	record, err := crypter.EncryptSecret(&dao.SecretRecord{Name: "foo", Serial: 1}, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Error encrypting secret: %v", err)
+	}
+	if record.EncryptedData == "" || record.EncryptedDataKey == "" {
+		t.Errorf("Expected encrypted data and data key to be set, got: %v", record)
+	}
+
+	secret, err := crypter.DecryptSecret(record)
+	if err != nil {
+		t.Fatalf("Error decrypting secret: %v", err)
+	}
+	if string(secret) != "secret" {
+		t.Errorf("Mismatch between encrypted and decrypted secrets, got: %s", secret)
+	}
+
+	other, _ := NewLocalCrypter(bytes.Repeat([]byte{2}, MasterKeySize))
+	_, err = other.DecryptSecret(record)
+	if err == nil {
+		t.Error("Expected error decrypting secret with another master key")
+	}
+}
+
+func TestNewLocalCrypterInvalidKey(t *testing.T) {
+	_, err := NewLocalCrypter([]byte("short"))
+	if err == nil {
+		t.Error("Expected error creating crypter with a short master key")
+	}
+}
+
+func TestLoadOrCreateMasterKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecs-secrets")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "secrets.db.key")
+
+	created, err := LoadOrCreateMasterKey(filename)
+	if err != nil {
+		t.Fatalf("Error creating master key: %v", err)
+	}
+	if len(created) != MasterKeySize {
+		t.Errorf("Unexpected master key size: %d", len(created))
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Error reading master key file: %v", err)
+	}
+	if info.Mode().Perm() != masterKeyFileMode {
+		t.Errorf("Unexpected master key file mode: %v", info.Mode())
+	}
+
+	loaded, err := LoadOrCreateMasterKey(filename)
+	if err != nil {
+		t.Fatalf("Error loading master key: %v", err)
+	}
+	if !bytes.Equal(created, loaded) {
+		t.Error("Mismatch between created and loaded master keys")
+	}
+}
+
+func TestLoadOrCreateMasterKeyConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ecs-secrets")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "secrets.db.key")
+
+	// Every caller must end up with the same key, however the creation of
+	// the file is interleaved
+	keys := make([][]byte, 10)
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, err := LoadOrCreateMasterKey(filename)
+			if err != nil {
+				t.Errorf("Error loading master key: %v", err)
+			}
+			keys[i] = key
+		}(i)
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		if len(key) != MasterKeySize || !bytes.Equal(key, keys[0]) {
+			t.Errorf("Mismatch between master key %d and the first master key", i)
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Error reading directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected only the master key file to be left, got %d files", len(files))
+	}
+}
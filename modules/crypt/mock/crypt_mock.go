@@ -41,9 +41,9 @@ func (_m *MockCrypter) EXPECT() *_MockCrypterRecorder {
 	return _m.recorder
 }
 
-func (_m *MockCrypter) DecryptSecret(_param0 *dao.SecretRecord) (*string, error) {
+func (_m *MockCrypter) DecryptSecret(_param0 *dao.SecretRecord) ([]byte, error) {
 	ret := _m.ctrl.Call(_m, "DecryptSecret", _param0)
-	ret0, _ := ret[0].(*string)
+	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -52,7 +52,7 @@ func (_mr *_MockCrypterRecorder) DecryptSecret(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DecryptSecret", arg0)
 }
 
-func (_m *MockCrypter) EncryptSecret(_param0 *dao.SecretRecord, _param1 string) (*dao.SecretRecord, error) {
+func (_m *MockCrypter) EncryptSecret(_param0 *dao.SecretRecord, _param1 []byte) (*dao.SecretRecord, error) {
 	ret := _m.ctrl.Call(_m, "EncryptSecret", _param0, _param1)
 	ret0, _ := ret[0].(*dao.SecretRecord)
 	ret1, _ := ret[1].(error)
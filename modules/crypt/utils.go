@@ -22,6 +22,20 @@ import (
 	"github.com/gtank/cryptopasta"
 )
 
+// encryptionOverhead is the number of bytes encryption adds to a secret, for
+// the nonce and the authentication tag
+const encryptionOverhead = 12 + 16
+
+// MaxPlaintextSize returns the size in bytes of the largest secret whose
+// encrypted data, once encoded, is at most encryptedDataSize characters long
+func MaxPlaintextSize(encryptedDataSize int) int {
+	size := base64.StdEncoding.DecodedLen(encryptedDataSize) - encryptionOverhead
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
 func base64Encode(input []byte) string {
 	return base64.StdEncoding.EncodeToString(input)
 }
@@ -40,12 +54,12 @@ func getCryptoKey(input []byte) (*[32]byte, error) {
 	return &key, nil
 }
 
-func encrypt(secret string, key []byte) ([]byte, error) {
+func encrypt(secret, key []byte) ([]byte, error) {
 	cryptoKey, err := getCryptoKey(key)
 	if err != nil {
 		return nil, err
 	}
-	encryptedBlob, err := cryptopasta.Encrypt([]byte(secret), cryptoKey)
+	encryptedBlob, err := cryptopasta.Encrypt(secret, cryptoKey)
 	if err != nil {
 		return nil, fmt.Errorf("Error encrypting secret: %v", err)
 	}
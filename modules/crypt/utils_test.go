@@ -25,3 +25,27 @@ func TestGetCryptoKey(t *testing.T) {
 		t.Error("Incorrect length returned for crypto key")
 	}
 }
+
+func TestMaxPlaintextSize(t *testing.T) {
+	key := []byte("super-awesome-aes-key-so-secure?")
+	for _, encryptedDataSize := range []int{100, 101, 102, 103, 4000} {
+		size := MaxPlaintextSize(encryptedDataSize)
+		encrypted, err := encrypt(make([]byte, size), key)
+		if err != nil {
+			t.Fatalf("Error encrypting: %v", err)
+		}
+		if len(base64Encode(encrypted)) > encryptedDataSize {
+			t.Errorf("Encrypted data of %d bytes is longer than %d characters", size, encryptedDataSize)
+		}
+		encrypted, err = encrypt(make([]byte, size+1), key)
+		if err != nil {
+			t.Fatalf("Error encrypting: %v", err)
+		}
+		if len(base64Encode(encrypted)) <= encryptedDataSize {
+			t.Errorf("Expected more than %d bytes to fit in %d characters", size, encryptedDataSize)
+		}
+	}
+	if MaxPlaintextSize(10) != 0 {
+		t.Error("Expected nothing to fit in 10 characters")
+	}
+}
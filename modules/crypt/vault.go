@@ -0,0 +1,88 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package crypt
+
+import (
+	"github.com/awslabs/ecs-secrets/modules/cache"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/vault/client"
+)
+
+// vaultCrypter implements the Crypter interface to encrypt and decrypt secret
+// records with data keys generated by the transit secrets engine of Vault,
+// the same way KMS data keys are used by kmsCrypter. The data key is stored
+// as the ciphertext returned by Vault
+type vaultCrypter struct {
+	vaultClient client.Client
+	keyCache    cache.Cache
+	keyName     string
+}
+
+// NewVaultCrypter creates a new Crypter object that uses the named key of the
+// transit secrets engine
+func NewVaultCrypter(vaultClient client.Client, keyCache cache.Cache, keyName string) Crypter {
+	return &vaultCrypter{
+		vaultClient: vaultClient,
+		keyCache:    keyCache,
+		keyName:     keyName,
+	}
+}
+
+// EncryptSecret encrypts a secret record using a data key generated by Vault
+func (crypter *vaultCrypter) EncryptSecret(secretRecord *dao.SecretRecord, secret []byte) (*dao.SecretRecord, error) {
+	dataKey, err := crypter.vaultClient.GenerateDataKey(crypter.keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedBlob, err := encrypt(secret, dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	secretRecord.EncryptedData = base64Encode(encryptedBlob)
+	secretRecord.EncryptedDataKey = dataKey.Ciphertext
+	return secretRecord, nil
+}
+
+// DecryptSecret decrypts a secret record using its data key, after decrypting
+// the data key with Vault
+func (crypter *vaultCrypter) DecryptSecret(secretRecord *dao.SecretRecord) ([]byte, error) {
+	dataKey, err := crypter.fetchDataKey(secretRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	decodedData, err := base64Decode(secretRecord.EncryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypt(decodedData, dataKey)
+}
+
+func (crypter *vaultCrypter) fetchDataKey(loadedSecret *dao.SecretRecord) ([]byte, error) {
+	if dataKey, ok := crypter.keyCache.Get(loadedSecret.EncryptedDataKey); ok {
+		return dataKey.([]byte), nil
+	}
+
+	dataKey, err := crypter.vaultClient.Decrypt(crypter.keyName, loadedSecret.EncryptedDataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	crypter.keyCache.Set(loadedSecret.EncryptedDataKey, dataKey)
+
+	return dataKey, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package crypt
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/cache"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client"
+	mock_vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestVaultCrypterEncryptDecrypt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vaultClient := mock_vaultclient.NewMockClient(ctrl)
+	vaultClient.EXPECT().GenerateDataKey("ecs-secrets-myapp").Return(&vaultclient.DataKey{
+		Plaintext:  []byte(aesKey),
+		Ciphertext: "vault:v1:wrapped",
+	}, nil)
+	// The data key is decrypted once, and then served from the cache
+	vaultClient.EXPECT().Decrypt("ecs-secrets-myapp", "vault:v1:wrapped").Return([]byte(aesKey), nil)
+
+	crypter := NewVaultCrypter(vaultClient, cache.NewLRUCache(cache.KeyCacheSize, cache.KeyCacheTTL), "ecs-secrets-myapp")
+	record, err := crypter.EncryptSecret(&dao.SecretRecord{Name: "foo", Serial: 1}, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Error encrypting secret: %v", err)
+	}
+	if record.EncryptedDataKey != "vault:v1:wrapped" {
+		t.Errorf("Unexpected encrypted data key: %s", record.EncryptedDataKey)
+	}
+
+	for i := 0; i < 2; i++ {
+		secret, err := crypter.DecryptSecret(record)
+		if err != nil {
+			t.Fatalf("Error decrypting secret: %v", err)
+		}
+		if string(secret) != "secret" {
+			t.Errorf("Mismatch between encrypted and decrypted secrets, got: %s", secret)
+		}
+	}
+}
+
+func TestVaultCrypterDecryptError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vaultClient := mock_vaultclient.NewMockClient(ctrl)
+	vaultClient.EXPECT().Decrypt(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("permission denied"))
+
+	crypter := NewVaultCrypter(vaultClient, cache.NewLRUCache(cache.KeyCacheSize, cache.KeyCacheTTL), "ecs-secrets-myapp")
+	_, err := crypter.DecryptSecret(&dao.SecretRecord{EncryptedDataKey: "vault:v1:wrapped"})
+	if err == nil {
+		t.Error("Expected error decrypting secret")
+	}
+}
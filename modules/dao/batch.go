@@ -0,0 +1,156 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	cfnclient "github.com/awslabs/ecs-secrets/modules/cloudformation/client"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// maxBatchGetKeys is the maximum number of keys that can be read with a
+// single BatchGetItem request
+const maxBatchGetKeys = 100
+
+// maxBatchGetAttempts is the number of times keys that DynamoDB leaves
+// unprocessed are requested before giving up
+const maxBatchGetAttempts = 5
+
+// batchGetRetryDelay is how long to wait before requesting unprocessed keys
+// again. The delay grows with every attempt
+const batchGetRetryDelay = 50 * time.Millisecond
+
+// SecretKey identifies a version of a secret
+type SecretKey struct {
+	Name   string
+	Serial int64
+}
+
+// GetSecretRecords gets versions of secrets from DynamoDB with as few
+// BatchGetItem requests as possible. Versions that don't exist are left out
+// and the records are returned in no particular order
+func (d *dao) GetSecretRecords(keys []SecretKey) ([]*SecretRecord, error) {
+	items, err := d.batchGetItems(keys, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*SecretRecord
+	for _, item := range items {
+		record := &SecretRecord{}
+		err = dynamodbattribute.UnmarshalMap(item, record)
+		if err != nil {
+			return nil, err
+		}
+		err = d.loadChunks(record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// getSecretSummaries gets versions of secrets from DynamoDB in the same way as
+// GetSecretRecords, without reading their encrypted data
+func (d *dao) getSecretSummaries(keys []SecretKey) ([]*SecretRecord, error) {
+	items, err := d.batchGetItems(keys, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*SecretRecord
+	for _, item := range items {
+		record := &SecretRecord{}
+		err = dynamodbattribute.UnmarshalMap(item, record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// batchGetItems gets the items of versions of secrets, splitting the keys into
+// as few batches as possible. Only the summary attributes of the versions are
+// read if summary is set
+func (d *dao) batchGetItems(keys []SecretKey, summary bool) ([]map[string]*dynamodb.AttributeValue, error) {
+	var keyItems []map[string]*dynamodb.AttributeValue
+	seen := make(map[SecretKey]bool)
+	for _, key := range keys {
+		// DynamoDB rejects requests with duplicate keys
+		if seen[key] || key.Serial <= labelsSerial {
+			continue
+		}
+		seen[key] = true
+		keyItems = append(keyItems, map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(key.Name)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(key.Serial, 10))},
+		})
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for start := 0; start < len(keyItems); start += maxBatchGetKeys {
+		end := start + maxBatchGetKeys
+		if end > len(keyItems) {
+			end = len(keyItems)
+		}
+		keysAndAttributes := &dynamodb.KeysAndAttributes{Keys: keyItems[start:end]}
+		if summary {
+			keysAndAttributes.ProjectionExpression = aws.String(summaryProjectionExpression)
+			keysAndAttributes.ExpressionAttributeNames = summaryAttributeNames()
+		}
+		batchItems, err := d.batchGetKeys(keysAndAttributes)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batchItems...)
+	}
+	return items, nil
+}
+
+// batchGetKeys gets a batch of items, requesting the keys left unprocessed by
+// DynamoDB again until every key has been read
+func (d *dao) batchGetKeys(keysAndAttributes *dynamodb.KeysAndAttributes) ([]map[string]*dynamodb.AttributeValue, error) {
+	tableName := cfnclient.GetSecretsTableName(d.appName)
+	requestItems := map[string]*dynamodb.KeysAndAttributes{
+		tableName: keysAndAttributes,
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for attempt := 1; len(requestItems) > 0; attempt++ {
+		if attempt > maxBatchGetAttempts {
+			return nil, fmt.Errorf("Secret records could not be read after %d attempts", maxBatchGetAttempts)
+		}
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * batchGetRetryDelay)
+		}
+
+		result, err := d.dynamodbClient.BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, result.Responses[tableName]...)
+		requestItems = result.UnprocessedKeys
+	}
+	return items, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/awslabs/ecs-secrets/modules/dynamodb/client/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestGetSecretRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	key := func(name string, serial string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String(name)},
+			"Serial": {N: aws.String(serial)},
+		}
+	}
+	ddbClient.EXPECT().BatchGetItem(&dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			"ECS-Secrets-myapp-Secrets": {
+				Keys: []map[string]*dynamodb.AttributeValue{key("foo", "1"), key("bar", "2")},
+			},
+		},
+	}).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]*dynamodb.AttributeValue{
+			"ECS-Secrets-myapp-Secrets": {
+				{"Name": {S: aws.String("bar")}, "Serial": {N: aws.String("2")}, "Active": {BOOL: aws.Bool(true)}},
+				{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("1")}, "Active": {BOOL: aws.Bool(true)}},
+			},
+		},
+	}, nil)
+
+	dao := NewDAO("myapp", ddbClient)
+	records, err := dao.GetSecretRecords([]SecretKey{
+		{Name: "foo", Serial: 1},
+		{Name: "bar", Serial: 2},
+		{Name: "foo", Serial: 1},
+		{Name: "baz", Serial: labelsSerial},
+	})
+	if err != nil {
+		t.Fatalf("Error getting secret records: %v", err)
+	}
+	expectedRecords := []*SecretRecord{
+		{Name: "bar", Serial: 2, Active: true},
+		{Name: "foo", Serial: 1, Active: true},
+	}
+	if !reflect.DeepEqual(records, expectedRecords) {
+		t.Errorf("Mismatch between expected and received records: %v != %v", records, expectedRecords)
+	}
+}
+
+func TestGetSecretRecordsSplitsIntoBatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	var keys []SecretKey
+	for serial := int64(1); serial <= maxBatchGetKeys+1; serial++ {
+		keys = append(keys, SecretKey{Name: "foo", Serial: serial})
+	}
+	var batchSizes []int
+	ddbClient.EXPECT().BatchGetItem(gomock.Any()).Do(func(input *dynamodb.BatchGetItemInput) {
+		batchSizes = append(batchSizes, len(input.RequestItems["ECS-Secrets-myapp-Secrets"].Keys))
+	}).Return(&dynamodb.BatchGetItemOutput{}, nil).Times(2)
+
+	dao := NewDAO("myapp", ddbClient)
+	_, err := dao.GetSecretRecords(keys)
+	if err != nil {
+		t.Fatalf("Error getting secret records: %v", err)
+	}
+	if !reflect.DeepEqual(batchSizes, []int{maxBatchGetKeys, 1}) {
+		t.Errorf("Incorrect batches requested: %v", batchSizes)
+	}
+}
+
+func TestGetSecretRecordsRetriesUnprocessedKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	unprocessedKeys := map[string]*dynamodb.KeysAndAttributes{
+		"ECS-Secrets-myapp-Secrets": {
+			Keys: []map[string]*dynamodb.AttributeValue{
+				{"Name": {S: aws.String("bar")}, "Serial": {N: aws.String("2")}},
+			},
+		},
+	}
+	gomock.InOrder(
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {
+					{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("1")}},
+				},
+			},
+			UnprocessedKeys: unprocessedKeys,
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: unprocessedKeys,
+		}).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {
+					{"Name": {S: aws.String("bar")}, "Serial": {N: aws.String("2")}},
+				},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	records, err := dao.GetSecretRecords([]SecretKey{{Name: "foo", Serial: 1}, {Name: "bar", Serial: 2}})
+	if err != nil {
+		t.Fatalf("Error getting secret records: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected 2 records, got: %v", records)
+	}
+}
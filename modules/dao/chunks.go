@@ -0,0 +1,226 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cfnclient "github.com/awslabs/ecs-secrets/modules/cloudformation/client"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Encrypted data that doesn't fit in a single DynamoDB item, which is limited
+// to 400KB, is split into chunks. Each chunk is stored as an item of its own
+// next to the versions of the secret, with a negative serial derived from the
+// serial of the version and the index of the chunk
+const (
+	// maxInlineDataSize is the size of the largest encrypted data that is
+	// stored in the item of the version itself. This leaves room for the
+	// encrypted data key, description and tags of the version
+	maxInlineDataSize = 300 * 1024
+	// chunkSize is the size of the encrypted data stored in each chunk
+	chunkSize = 350 * 1024
+	// maxChunks is the number of chunk serials reserved for every version
+	maxChunks = 100
+)
+
+// secretChunk defines a chunk of the encrypted data of a version of a secret
+type secretChunk struct {
+	Name          string
+	Serial        int64
+	EncryptedData string
+	ExpiresAt     int64 `dynamodbav:",omitempty"`
+}
+
+// chunkSerial returns the serial of the chunk with the index, which starts at
+// 1, of the version of the secret
+func chunkSerial(serial int64, index int64) int64 {
+	return -(serial*maxChunks + index)
+}
+
+// encryptedDataChecksum returns the checksum used to verify the integrity of
+// encrypted data that is reassembled from chunks
+func encryptedDataChecksum(encryptedData string) string {
+	sum := sha256.Sum256([]byte(encryptedData))
+	return hex.EncodeToString(sum[:])
+}
+
+// putChunkedSecretRecord puts a secret record whose encrypted data is too large
+// for a single item. The version is put first, without its encrypted data and
+// as an inactive version, so that its serial is claimed before any chunk is
+// written. It is activated once all the chunks have been written, so readers
+// never see a version with missing chunks. If any of this fails, the version
+// and the chunks written so far are deleted again
+func (d *dao) putChunkedSecretRecord(record *SecretRecord) error {
+	encryptedData := record.EncryptedData
+	numChunks := int64((len(encryptedData) + chunkSize - 1) / chunkSize)
+	if numChunks >= maxChunks {
+		return fmt.Errorf("Encrypted data of secret '%s' is too large to be stored", record.Name)
+	}
+
+	versionRecord := *record
+	versionRecord.EncryptedData = ""
+	versionRecord.Chunks = numChunks
+	versionRecord.EncryptedDataChecksum = encryptedDataChecksum(encryptedData)
+	versionRecord.Active = false
+	err := d.putSecretRecord(&versionRecord)
+	if err != nil {
+		return err
+	}
+
+	err = d.putChunks(record, encryptedData, numChunks)
+	if err != nil {
+		// Deleting the version is best effort, the error that caused it is
+		// returned either way
+		d.DeleteSecretRecord(record.Name, record.Serial)
+		return err
+	}
+	record.Chunks = versionRecord.Chunks
+	record.EncryptedDataChecksum = versionRecord.EncryptedDataChecksum
+	return nil
+}
+
+// putChunks writes the chunks of the encrypted data of the version of the
+// secret, activating the version afterwards if the record is active
+func (d *dao) putChunks(record *SecretRecord, encryptedData string, numChunks int64) error {
+	for index := int64(1); index <= numChunks; index++ {
+		start := (index - 1) * chunkSize
+		end := start + chunkSize
+		if end > int64(len(encryptedData)) {
+			end = int64(len(encryptedData))
+		}
+		item, err := dynamodbattribute.MarshalMap(secretChunk{
+			Name:          record.Name,
+			Serial:        chunkSerial(record.Serial, index),
+			EncryptedData: encryptedData[start:end],
+			ExpiresAt:     record.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = d.dynamodbClient.PutItem(&dynamodb.PutItemInput{
+			Item:      item,
+			TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !record.Active {
+		return nil
+	}
+	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(record.Name)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(record.Serial, 10))},
+		},
+		UpdateExpression:    aws.String("SET Active = :active"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":active": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+		},
+	})
+	return err
+}
+
+// loadChunks reassembles the encrypted data of the secret record from its
+// chunks, if it has any. An error is returned if any of the chunks is missing
+// or if the reassembled data doesn't match the checksum of the version
+func (d *dao) loadChunks(record *SecretRecord) error {
+	if record == nil || record.Chunks == 0 {
+		return nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		KeyConditionExpression:   aws.String("#N = :val AND Serial BETWEEN :first AND :last"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val":   &dynamodb.AttributeValue{S: aws.String(record.Name)},
+			":first": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(chunkSerial(record.Serial, record.Chunks), 10))},
+			":last":  &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(chunkSerial(record.Serial, 1), 10))},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	var chunks []*secretChunk
+	for {
+		result, err := d.dynamodbClient.Query(input)
+		if err != nil {
+			return err
+		}
+		for _, item := range result.Items {
+			chunk := &secretChunk{}
+			err = dynamodbattribute.UnmarshalMap(item, chunk)
+			if err != nil {
+				return err
+			}
+			chunks = append(chunks, chunk)
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if int64(len(chunks)) != record.Chunks {
+		return fmt.Errorf("Encrypted data of secret '%s' with serial %d is incomplete, found %d of %d chunks",
+			record.Name, record.Serial, len(chunks), record.Chunks)
+	}
+	// Chunk serials decrease as the index of the chunk increases
+	sort.Sort(sort.Reverse(chunksBySerial(chunks)))
+	chunkData := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		chunkData[i] = chunk.EncryptedData
+	}
+	encryptedData := strings.Join(chunkData, "")
+	if encryptedDataChecksum(encryptedData) != record.EncryptedDataChecksum {
+		return fmt.Errorf("Encrypted data of secret '%s' with serial %d failed the integrity check",
+			record.Name, record.Serial)
+	}
+	record.EncryptedData = encryptedData
+	return nil
+}
+
+// deleteChunks deletes the chunks of the version of the secret
+func (d *dao) deleteChunks(name string, serial int64, numChunks int64) error {
+	for index := int64(1); index <= numChunks; index++ {
+		_, err := d.dynamodbClient.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+			Key: map[string]*dynamodb.AttributeValue{
+				"Name":   &dynamodb.AttributeValue{S: aws.String(name)},
+				"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(chunkSerial(serial, index), 10))},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type chunksBySerial []*secretChunk
+
+func (chunks chunksBySerial) Len() int           { return len(chunks) }
+func (chunks chunksBySerial) Less(i, j int) bool { return chunks[i].Serial < chunks[j].Serial }
+func (chunks chunksBySerial) Swap(i, j int)      { chunks[i], chunks[j] = chunks[j], chunks[i] }
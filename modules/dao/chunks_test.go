@@ -0,0 +1,340 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package dao
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/awslabs/ecs-secrets/modules/dynamodb/client/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestPutSecretRecordSplitsLargeData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	encryptedData := strings.Repeat("a", chunkSize) + strings.Repeat("b", 10)
+	var chunks []string
+	gomock.InOrder(
+		ddbClient.EXPECT().PutItem(gomock.Any()).Do(func(input *dynamodb.PutItemInput) {
+			if aws.StringValue(input.ConditionExpression) != "attribute_not_exists(Serial)" {
+				t.Errorf("Expected version to be put conditionally, got: %s", aws.StringValue(input.ConditionExpression))
+			}
+			if input.Item["EncryptedData"].NULL == nil {
+				t.Error("Expected encrypted data not to be stored with the version")
+			}
+			if aws.BoolValue(input.Item["Active"].BOOL) {
+				t.Error("Expected version to be inactive until its chunks are written")
+			}
+			if aws.StringValue(input.Item["Chunks"].N) != "2" {
+				t.Errorf("Incorrect number of chunks: %v", input.Item["Chunks"])
+			}
+		}).Return(nil, nil),
+		ddbClient.EXPECT().UpdateItem(gomock.Any()).Do(func(input *dynamodb.UpdateItemInput) {
+			if aws.StringValue(input.ExpressionAttributeValues[":serial"].N) != "3" {
+				t.Errorf("Incorrect latest serial: %v", input.ExpressionAttributeValues)
+			}
+		}).Return(nil, nil),
+		ddbClient.EXPECT().PutItem(gomock.Any()).Do(func(input *dynamodb.PutItemInput) {
+			if aws.StringValue(input.Item["Serial"].N) != "-301" {
+				t.Errorf("Incorrect serial of chunk: %v", input.Item["Serial"])
+			}
+			chunks = append(chunks, aws.StringValue(input.Item["EncryptedData"].S))
+		}).Return(nil, nil),
+		ddbClient.EXPECT().PutItem(gomock.Any()).Do(func(input *dynamodb.PutItemInput) {
+			if aws.StringValue(input.Item["Serial"].N) != "-302" {
+				t.Errorf("Incorrect serial of chunk: %v", input.Item["Serial"])
+			}
+			chunks = append(chunks, aws.StringValue(input.Item["EncryptedData"].S))
+		}).Return(nil, nil),
+		ddbClient.EXPECT().UpdateItem(gomock.Any()).Do(func(input *dynamodb.UpdateItemInput) {
+			if aws.StringValue(input.Key["Serial"].N) != "3" {
+				t.Errorf("Incorrect version activated: %v", input.Key)
+			}
+		}).Return(nil, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	record := &SecretRecord{
+		Name:          "foo",
+		Serial:        3,
+		Active:        true,
+		EncryptedData: encryptedData,
+	}
+	err := dao.PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+	if strings.Join(chunks, "") != encryptedData {
+		t.Error("Chunks do not add up to the encrypted data")
+	}
+	if record.Chunks != 2 || record.EncryptedDataChecksum != encryptedDataChecksum(encryptedData) {
+		t.Errorf("Incorrect chunks recorded: %d, %s", record.Chunks, record.EncryptedDataChecksum)
+	}
+}
+
+func TestPutSecretRecordChunkPutFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	key := func(serial string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("foo")},
+			"Serial": {N: aws.String(serial)},
+		}
+	}
+	gomock.InOrder(
+		// The version, its latest serial and the first chunk are written
+		// before the second chunk fails
+		ddbClient.EXPECT().PutItem(gomock.Any()).Return(nil, nil),
+		ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil, nil),
+		ddbClient.EXPECT().PutItem(gomock.Any()).Return(nil, nil),
+		ddbClient.EXPECT().PutItem(gomock.Any()).Return(nil, fmt.Errorf("throttled")),
+		ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+			TableName:    aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:          key("3"),
+			ReturnValues: aws.String("ALL_OLD"),
+		}).Return(&dynamodb.DeleteItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("foo")},
+				"Serial": {N: aws.String("3")},
+				"Chunks": {N: aws.String("3")},
+			},
+		}, nil),
+		ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:       key("-301"),
+		}).Return(nil, nil),
+		ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:       key("-302"),
+		}).Return(nil, nil),
+		ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:       key("-303"),
+		}).Return(nil, nil),
+		ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"LatestSerial": {N: aws.String("3")},
+			},
+		}, nil),
+		ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+			Count: aws.Int64(1),
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Serial": {N: aws.String("2")}},
+			},
+		}, nil),
+		ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	record := &SecretRecord{
+		Name:          "foo",
+		Serial:        3,
+		Active:        true,
+		EncryptedData: strings.Repeat("a", 2*chunkSize+10),
+	}
+	err := dao.PutSecretRecord(record)
+	if err == nil {
+		t.Fatal("Expected error putting secret record when a chunk cannot be put")
+	}
+	if record.Chunks != 0 {
+		t.Errorf("Expected no chunks to be recorded, got: %d", record.Chunks)
+	}
+}
+
+func TestPutSecretRecordLargeDataAlreadyExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().PutItem(gomock.Any()).Return(nil,
+		awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.PutSecretRecord(&SecretRecord{
+		Name:          "foo",
+		Serial:        3,
+		Active:        true,
+		EncryptedData: strings.Repeat("a", maxInlineDataSize+1),
+	})
+	if err != ErrSecretRecordExists {
+		t.Errorf("Expected secret record exists error, got: %v", err)
+	}
+}
+
+func TestGetSecretRecordReassemblesChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	encryptedData := "chunk1chunk2"
+	ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"Name":                  {S: aws.String("foo")},
+			"Serial":                {N: aws.String("3")},
+			"Active":                {BOOL: aws.Bool(true)},
+			"Chunks":                {N: aws.String("2")},
+			"EncryptedDataChecksum": {S: aws.String(encryptedDataChecksum(encryptedData))},
+		},
+	}, nil)
+	ddbClient.EXPECT().Query(&dynamodb.QueryInput{
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		KeyConditionExpression:   aws.String("#N = :val AND Serial BETWEEN :first AND :last"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val":   {S: aws.String("foo")},
+			":first": {N: aws.String("-302")},
+			":last":  {N: aws.String("-301")},
+		},
+		ConsistentRead: aws.Bool(true),
+	}).Return(&dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("-302")}, "EncryptedData": {S: aws.String("chunk2")}},
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("-301")}, "EncryptedData": {S: aws.String("chunk1")}},
+		},
+	}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	record, err := dao.GetSecretRecord("foo", 3)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if record.EncryptedData != encryptedData {
+		t.Errorf("Incorrect encrypted data reassembled: %s", record.EncryptedData)
+	}
+}
+
+func TestGetSecretRecordMissingChunk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"Name":                  {S: aws.String("foo")},
+			"Serial":                {N: aws.String("3")},
+			"Chunks":                {N: aws.String("2")},
+			"EncryptedDataChecksum": {S: aws.String(encryptedDataChecksum("chunk1chunk2"))},
+		},
+	}, nil)
+	ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("-301")}, "EncryptedData": {S: aws.String("chunk1")}},
+		},
+	}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	_, err := dao.GetSecretRecord("foo", 3)
+	if err == nil {
+		t.Error("Expected error getting secret record with a missing chunk")
+	}
+}
+
+func TestGetSecretRecordCorruptChunk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"Name":                  {S: aws.String("foo")},
+			"Serial":                {N: aws.String("3")},
+			"Chunks":                {N: aws.String("2")},
+			"EncryptedDataChecksum": {S: aws.String(encryptedDataChecksum("chunk1chunk2"))},
+		},
+	}, nil)
+	ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("-302")}, "EncryptedData": {S: aws.String("chunkX")}},
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("-301")}, "EncryptedData": {S: aws.String("chunk1")}},
+		},
+	}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	_, err := dao.GetSecretRecord("foo", 3)
+	if err == nil {
+		t.Error("Expected error getting secret record with a corrupt chunk")
+	}
+}
+
+func TestDeleteSecretRecordDeletesChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	chunkKey := func(serial string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("foo")},
+			"Serial": {N: aws.String(serial)},
+		}
+	}
+	gomock.InOrder(
+		ddbClient.EXPECT().DeleteItem(gomock.Any()).Return(&dynamodb.DeleteItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("foo")},
+				"Serial": {N: aws.String("3")},
+				"Chunks": {N: aws.String("2")},
+			},
+		}, nil),
+		ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:       chunkKey("-301"),
+		}).Return(nil, nil),
+		ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:       chunkKey("-302"),
+		}).Return(nil, nil),
+		ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"LatestSerial": {N: aws.String("4")},
+			},
+		}, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.DeleteSecretRecord("foo", 3)
+	if err != nil {
+		t.Errorf("Error deleting secret record: %v", err)
+	}
+}
+
+func TestListVersionsSkipsChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("-101")}},
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("0")}},
+			{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("1")}, "Chunks": {N: aws.String("1")}},
+		},
+	}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	records, err := dao.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if len(records) != 1 || records[0].Serial != 1 {
+		t.Errorf("Expected only the version to be listed, got: %v", records)
+	}
+}
@@ -14,19 +14,57 @@
 package dao
 
 import (
-	"fmt"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"strconv"
+	"strings"
 
 	cfnclient "github.com/awslabs/ecs-secrets/modules/cloudformation/client"
 	ddbclient "github.com/awslabs/ecs-secrets/modules/dynamodb/client"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
 //go:generate mockgen.sh github.com/awslabs/ecs-secrets/modules/dao DAO mock/dao_mock.go
 
+const conditionalCheckFailedErrorCode = "ConditionalCheckFailedException"
+
+// ErrSecretRecordExists is returned when putting a secret record whose serial
+// has already been used by another version of the secret
+var ErrSecretRecordExists = errors.New("Secret record with the same serial already exists in the data store")
+
+// ErrSecretRecordNotFound is returned when the secret record being read or
+// updated does not exist
+var ErrSecretRecordNotFound = errors.New("Secret record not found in the data store")
+
+// ErrInvalidNextToken is returned when listing secrets with a pagination token
+// that was not returned by an earlier page
+var ErrInvalidNextToken = errors.New("Invalid pagination token")
+
+// ErrSecretLabelsModified is returned when putting the labels of a secret that
+// have been modified since they were read
+var ErrSecretLabelsModified = errors.New("Secret labels have been modified in the data store")
+
+// labelsSerial is the serial of the record that holds the labels of a secret,
+// along with the rest of its metadata. Versions of secrets are numbered from
+// 1, so this never clashes with them
+const labelsSerial = int64(0)
+
+// latestSerialAttribute is the attribute of the metadata item of a secret that
+// holds the serial of its latest version. It is kept up to date as versions
+// are put and deleted, so that secrets can be listed without querying every
+// one of them
+const latestSerialAttribute = "LatestSerial"
+
+// ApplicationMetadataName is the name under which metadata that applies to
+// every secret of the application is stored. It can never be the name of a
+// secret
+const ApplicationMetadataName = "*"
+
 // SecretRecord defines the payload used to interact with DynamoDB
 type SecretRecord struct {
 	Name             string
@@ -34,14 +72,103 @@ type SecretRecord struct {
 	EncryptedData    string
 	EncryptedDataKey string
 	Active           bool
+	CreatedAt        int64             `dynamodbav:",omitempty"`
+	CreatedBy        string            `dynamodbav:",omitempty"`
+	Description      string            `dynamodbav:",omitempty"`
+	Tags             map[string]string `dynamodbav:",omitempty"`
+	RestoredAt       int64             `dynamodbav:",omitempty"`
+	RestoredBy       string            `dynamodbav:",omitempty"`
+	RestoreReason    string            `dynamodbav:",omitempty"`
+	ExpiresAt        int64             `dynamodbav:",omitempty"`
+	ContentType      string            `dynamodbav:",omitempty"`
+	// Chunks is the number of chunks the encrypted data is split into, if
+	// it is too large to be stored along with the rest of the record
+	Chunks                int64  `dynamodbav:",omitempty"`
+	EncryptedDataChecksum string `dynamodbav:",omitempty"`
+	// PurgeAt is set on every version of a secret that is scheduled for
+	// deletion, along with who scheduled it and when
+	DeletedAt int64  `dynamodbav:",omitempty"`
+	DeletedBy string `dynamodbav:",omitempty"`
+	PurgeAt   int64  `dynamodbav:",omitempty"`
+}
+
+// SecretLabels defines the labels of a secret, mapping each label to the
+// serial of the version it points at, along with the rest of the metadata
+// that applies to all of its versions. The retention policy of the secret is
+// defined by the maximum number of versions to keep and the maximum age of
+// versions in seconds. Revision is incremented every time the labels are put,
+// so that concurrent modifications can be detected
+type SecretLabels struct {
+	Name                 string
+	Serial               int64
+	Labels               map[string]int64 `dynamodbav:",omitempty"`
+	RetentionMaxVersions int64            `dynamodbav:",omitempty"`
+	RetentionMaxAge      int64            `dynamodbav:",omitempty"`
+	Revision             int64
+}
+
+// Restoration describes who reinstated a revoked secret record, when and why
+type Restoration struct {
+	RestoredAt int64
+	RestoredBy string
+	Reason     string
+}
+
+// Deletion describes who scheduled a secret for deletion, when, and when the
+// secret can be purged once its recovery window has passed
+type Deletion struct {
+	DeletedAt int64
+	DeletedBy string
+	PurgeAt   int64
 }
 
 // DAO defines the interface to interact with the Data Access Layer for accessing secrets
 type DAO interface {
 	GetLatestVersion(string) (*SecretRecord, error)
+	GetLatestActiveVersion(string, int64) (*SecretRecord, error)
 	GetSecretRecord(string, int64) (*SecretRecord, error)
+	GetSecretRecords([]SecretKey) ([]*SecretRecord, error)
 	PutSecretRecord(*SecretRecord) error
 	RevokeSecretRecord(string, int64) error
+	RestoreSecretRecord(string, int64, *Restoration) error
+	ScheduleSecretRecordDeletion(string, int64, *Deletion) error
+	CancelSecretRecordDeletion(string, int64) error
+	DeleteSecretRecord(string, int64) error
+	ListSecrets(string, string, int64) ([]*SecretRecord, string, error)
+	ListVersions(string) ([]*SecretRecord, error)
+	GetSecretLabels(string) (*SecretLabels, error)
+	PutSecretLabels(*SecretLabels) error
+	DeleteSecretLabels(string) error
+}
+
+// SizeLimiter is implemented by DAOs that cannot store secret records as large
+// as the store allows. MaxEncryptedDataSize returns the length of the longest
+// encrypted data that can be stored along with the rest of the record, which
+// is not encrypted yet
+type SizeLimiter interface {
+	MaxEncryptedDataSize(*SecretRecord) int
+}
+
+// summaryProjectionExpression defines the attributes read when listing secret
+// records. The encrypted data is never read when listing
+const summaryProjectionExpression = "#N, Serial, Active, CreatedAt, CreatedBy, #D, Tags, " +
+	"RestoredAt, RestoredBy, RestoreReason, ExpiresAt, ContentType, DeletedAt, DeletedBy, PurgeAt"
+
+// summaryAttributeNames returns the expression attribute names used with
+// summaryProjectionExpression
+func summaryAttributeNames() map[string]*string {
+	return aws.StringMap(map[string]string{"#N": "Name", "#D": "Description"})
+}
+
+// latestActivePageSize is the number of versions evaluated per query when
+// looking for the latest active version of a secret
+const latestActivePageSize = int64(10)
+
+// listToken is the decoded form of the pagination token returned by
+// ListSecrets. It holds the key of the last item of the last secret listed
+type listToken struct {
+	Name   string `json:"name"`
+	Serial int64  `json:"serial"`
 }
 
 type dao struct {
@@ -59,6 +186,9 @@ func NewDAO(appName string, dynamodbClient ddbclient.Client) DAO {
 
 // GetSecretRecord gets a secret record from DynamoDB
 func (d *dao) GetSecretRecord(namespace string, serial int64) (*SecretRecord, error) {
+	if serial == labelsSerial {
+		return nil, ErrSecretRecordNotFound
+	}
 	key := map[string]*dynamodb.AttributeValue{
 		"Name": {
 			S: aws.String(namespace),
@@ -78,7 +208,7 @@ func (d *dao) GetSecretRecord(namespace string, serial int64) (*SecretRecord, er
 	}
 
 	if itemResult == nil || itemResult.Item == nil || len(itemResult.Item) == 0 {
-		return nil, fmt.Errorf("Secret record not found in the data store")
+		return nil, ErrSecretRecordNotFound
 	}
 
 	loadedSecret := &SecretRecord{}
@@ -88,23 +218,64 @@ func (d *dao) GetSecretRecord(namespace string, serial int64) (*SecretRecord, er
 		return nil, err
 	}
 
+	err = d.loadChunks(loadedSecret)
+	if err != nil {
+		return nil, err
+	}
+
 	return loadedSecret, nil
 }
 
-// PutSecretRecord puts a secret record into DynamoDB
+// PutSecretRecord puts a secret record into DynamoDB. Existing records are
+// never overwritten, ErrSecretRecordExists is returned if a record with the
+// same serial already exists. Encrypted data that is too large to be stored
+// in a single item is split into chunks
 func (d *dao) PutSecretRecord(record *SecretRecord) error {
+	if len(record.EncryptedData) > maxInlineDataSize {
+		return d.putChunkedSecretRecord(record)
+	}
+	return d.putSecretRecord(record)
+}
+
+func (d *dao) putSecretRecord(record *SecretRecord) error {
 	item, err := dynamodbattribute.MarshalMap(*record)
 	if err != nil {
 		return err
 	}
 	_, err = d.dynamodbClient.PutItem(&dynamodb.PutItemInput{
-		Item:      item,
-		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Item:                item,
+		TableName:           aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		ConditionExpression: aws.String("attribute_not_exists(Serial)"),
+	})
+	if conditionalCheckFailedError(err) {
+		return ErrSecretRecordExists
+	}
+	if err != nil {
+		return err
+	}
+	return d.putLatestSerial(record.Name, record.Serial)
+}
+
+// putLatestSerial records the serial as the latest version of the secret on
+// its metadata item, unless a later version has already been recorded
+func (d *dao) putLatestSerial(secretName string, serial int64) error {
+	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:                 metadataKey(secretName),
+		UpdateExpression:    aws.String("SET LatestSerial = :serial"),
+		ConditionExpression: aws.String("attribute_not_exists(LatestSerial) OR LatestSerial < :serial"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(serial, 10))},
+		},
 	})
+	if conditionalCheckFailedError(err) {
+		return nil
+	}
 	return err
 }
 
-// RevokeSecretRecord revokes a secret record in DynamoDB.
+// RevokeSecretRecord revokes a secret record in DynamoDB. An error is
+// returned if the record does not exist
 func (d *dao) RevokeSecretRecord(namespace string, serial int64) error {
 	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
 		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
@@ -112,13 +283,193 @@ func (d *dao) RevokeSecretRecord(namespace string, serial int64) error {
 			"Name":   &dynamodb.AttributeValue{S: aws.String(namespace)},
 			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(serial, 10))},
 		},
-		AttributeUpdates: map[string]*dynamodb.AttributeValueUpdate{
-			"Active": &dynamodb.AttributeValueUpdate{
-				Value:  &dynamodb.AttributeValue{BOOL: aws.Bool(false)},
-				Action: aws.String("PUT"),
-			},
+		UpdateExpression:    aws.String("SET Active = :active"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":active": &dynamodb.AttributeValue{BOOL: aws.Bool(false)},
+		},
+	})
+	if conditionalCheckFailedError(err) {
+		return ErrSecretRecordNotFound
+	}
+	return err
+}
+
+// RestoreSecretRecord reinstates a revoked secret record in DynamoDB, recording
+// who restored it, when and why. An error is returned if the record does not
+// exist
+func (d *dao) RestoreSecretRecord(namespace string, serial int64, restoration *Restoration) error {
+	// DynamoDB does not allow empty strings to be stored. Attributes that are
+	// empty are removed instead, so that details of an earlier restore are
+	// not left behind
+	setExpressions := []string{"Active = :active", "RestoredAt = :restoredAt"}
+	removeExpressions := []string{}
+	values := map[string]*dynamodb.AttributeValue{
+		":active":     &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+		":restoredAt": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(restoration.RestoredAt, 10))},
+	}
+	if restoration.RestoredBy != "" {
+		setExpressions = append(setExpressions, "RestoredBy = :restoredBy")
+		values[":restoredBy"] = &dynamodb.AttributeValue{S: aws.String(restoration.RestoredBy)}
+	} else {
+		removeExpressions = append(removeExpressions, "RestoredBy")
+	}
+	if restoration.Reason != "" {
+		setExpressions = append(setExpressions, "RestoreReason = :reason")
+		values[":reason"] = &dynamodb.AttributeValue{S: aws.String(restoration.Reason)}
+	} else {
+		removeExpressions = append(removeExpressions, "RestoreReason")
+	}
+	updateExpression := "SET " + strings.Join(setExpressions, ", ")
+	if len(removeExpressions) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removeExpressions, ", ")
+	}
+
+	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(namespace)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(serial, 10))},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: values,
+	})
+	if conditionalCheckFailedError(err) {
+		return ErrSecretRecordNotFound
+	}
+	return err
+}
+
+// ScheduleSecretRecordDeletion marks a secret record in DynamoDB as scheduled
+// for deletion
+func (d *dao) ScheduleSecretRecordDeletion(namespace string, serial int64, deletion *Deletion) error {
+	setExpressions := []string{"DeletedAt = :deletedAt", "PurgeAt = :purgeAt"}
+	values := map[string]*dynamodb.AttributeValue{
+		":deletedAt": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(deletion.DeletedAt, 10))},
+		":purgeAt":   &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(deletion.PurgeAt, 10))},
+	}
+	if deletion.DeletedBy != "" {
+		setExpressions = append(setExpressions, "DeletedBy = :deletedBy")
+		values[":deletedBy"] = &dynamodb.AttributeValue{S: aws.String(deletion.DeletedBy)}
+	}
+
+	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(namespace)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(serial, 10))},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(setExpressions, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: values,
+	})
+	if conditionalCheckFailedError(err) {
+		return ErrSecretRecordNotFound
+	}
+	return err
+}
+
+// CancelSecretRecordDeletion removes the scheduled deletion of a secret record
+// in DynamoDB
+func (d *dao) CancelSecretRecordDeletion(namespace string, serial int64) error {
+	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(namespace)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(serial, 10))},
+		},
+		UpdateExpression:    aws.String("REMOVE DeletedAt, DeletedBy, PurgeAt"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+	})
+	if conditionalCheckFailedError(err) {
+		return ErrSecretRecordNotFound
+	}
+	return err
+}
+
+// DeleteSecretRecord deletes a secret record from DynamoDB, along with its
+// encrypted data and any chunks of it
+func (d *dao) DeleteSecretRecord(namespace string, serial int64) error {
+	result, err := d.dynamodbClient.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(namespace)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(serial, 10))},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil || result == nil || len(result.Attributes) == 0 {
+		return err
+	}
+
+	deletedRecord := &SecretRecord{}
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, deletedRecord)
+	if err != nil {
+		return err
+	}
+	err = d.deleteChunks(namespace, serial, deletedRecord.Chunks)
+	if err != nil {
+		return err
+	}
+	return d.deleteLatestSerial(namespace, serial)
+}
+
+// deleteLatestSerial moves the latest serial recorded on the metadata item of
+// the secret back to the latest remaining version, if the version with the
+// serial was the latest and has been deleted. The latest serial is removed
+// once no versions are left
+func (d *dao) deleteLatestSerial(secretName string, serial int64) error {
+	itemResult, err := d.dynamodbClient.GetItem(&dynamodb.GetItemInput{
+		TableName:            aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:                  metadataKey(secretName),
+		ProjectionExpression: aws.String(latestSerialAttribute),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	latestSerial, ok := itemResult.Item[latestSerialAttribute]
+	if !ok || aws.StringValue(latestSerial.N) != strconv.FormatInt(serial, 10) {
+		return nil
+	}
+
+	result, err := d.dynamodbClient.Query(&dynamodb.QueryInput{
+		TableName:                aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int64(1),
+		KeyConditionExpression:   aws.String("#N = :val AND Serial > :metadata"),
+		ProjectionExpression:     aws.String("Serial"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val":      &dynamodb.AttributeValue{S: aws.String(secretName)},
+			":metadata": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(labelsSerial, 10))},
 		},
+		ConsistentRead: aws.Bool(true),
 	})
+	if err != nil {
+		return err
+	}
+
+	// The latest serial is only moved if no version has been put since it was
+	// read
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:                 metadataKey(secretName),
+		UpdateExpression:    aws.String("REMOVE LatestSerial"),
+		ConditionExpression: aws.String("LatestSerial = :serial"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":serial": latestSerial,
+		},
+	}
+	if len(result.Items) > 0 {
+		input.UpdateExpression = aws.String("SET LatestSerial = :latest")
+		input.ExpressionAttributeValues[":latest"] = result.Items[0]["Serial"]
+	}
+	_, err = d.dynamodbClient.UpdateItem(input)
+	if conditionalCheckFailedError(err) {
+		return nil
+	}
 	return err
 }
 
@@ -136,12 +487,15 @@ func (d *dao) GetLatestVersion(secretName string) (*SecretRecord, error) {
 		},
 	}
 	result, err := d.dynamodbClient.Query(&dynamodb.QueryInput{
-		TableName:        aws.String(cfnclient.GetSecretsTableName(d.appName)),
-		ScanIndexForward: aws.Bool(false),
-		Limit:            aws.Int64(1),
+		TableName:                 aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(1),
 		KeyConditionExpression:    aws.String(expression),
 		ExpressionAttributeNames:  aws.StringMap(expressionNames),
 		ExpressionAttributeValues: expressionValues,
+		// The latest version is used to pick the serial of the next one, so
+		// a version that was just put must not be missed
+		ConsistentRead: aws.Bool(true),
 	})
 
 	if err != nil {
@@ -152,7 +506,411 @@ func (d *dao) GetLatestVersion(secretName string) (*SecretRecord, error) {
 		return nil, nil
 	}
 
+	// Only the metadata of the secret is left once all of its versions have
+	// been deleted
+	if !isVersionItem(result.Items[0]) {
+		return nil, nil
+	}
+
 	loadedSecret := &SecretRecord{}
 	err = dynamodbattribute.ConvertFromMap(result.Items[0], loadedSecret)
+	if err != nil {
+		return nil, err
+	}
+	err = d.loadChunks(loadedSecret)
 	return loadedSecret, err
 }
+
+// GetLatestActiveVersion gets the latest version of the secret from DynamoDB
+// that is active and has not expired at the time given in seconds. Revoked and
+// expired versions are filtered out by DynamoDB, so the query is paged until
+// an active version is found
+func (d *dao) GetLatestActiveVersion(secretName string, now int64) (*SecretRecord, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int64(latestActivePageSize),
+		KeyConditionExpression:   aws.String("#N = :val"),
+		FilterExpression:         aws.String("Active = :active AND (attribute_not_exists(ExpiresAt) OR ExpiresAt > :now)"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val":    &dynamodb.AttributeValue{S: aws.String(secretName)},
+			":active": &dynamodb.AttributeValue{BOOL: aws.Bool(true)},
+			":now":    &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now, 10))},
+		},
+	}
+
+	for {
+		result, err := d.dynamodbClient.Query(input)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Items) > 0 {
+			loadedSecret := &SecretRecord{}
+			err = dynamodbattribute.UnmarshalMap(result.Items[0], loadedSecret)
+			if err != nil {
+				return nil, err
+			}
+			err = d.loadChunks(loadedSecret)
+			return loadedSecret, err
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// ListSecrets lists the latest version of every secret in DynamoDB whose name
+// starts with the prefix, one page at a time. The keys of the items of secrets
+// are scanned, and the latest versions are read in batches, so that the limit
+// applies to the number of secrets listed. Every secret is listed if the limit
+// is not positive. The records returned do not contain any encrypted data. The
+// token returned can be used to fetch the next page of results and is empty
+// when there are no more pages
+func (d *dao) ListSecrets(prefix string, nextToken string, limit int64) ([]*SecretRecord, string, error) {
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		ProjectionExpression:     aws.String("#N, Serial, LatestSerial"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+	}
+	if prefix != "" {
+		input.FilterExpression = aws.String("begins_with(#N, :prefix)")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":prefix": &dynamodb.AttributeValue{S: aws.String(prefix)},
+		}
+	}
+	startName := ""
+	if nextToken != "" {
+		token, err := decodeListToken(nextToken)
+		if err != nil {
+			return nil, "", err
+		}
+		startName = token.Name
+		input.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"Name":   &dynamodb.AttributeValue{S: aws.String(token.Name)},
+			"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(token.Serial, 10))},
+		}
+	}
+
+	// The scan carries on until one more secret than the limit has been
+	// found, so that a token is only returned when there is another page. The
+	// token points at the last item of the last secret listed
+	var keys []SecretKey
+	var lastKey listToken
+	hasMore := false
+	addSecret := func(secret *scannedSecret) {
+		if secret != nil && secret.latestSerial() > labelsSerial {
+			keys = append(keys, SecretKey{Name: secret.name, Serial: secret.latestSerial()})
+			lastKey = listToken{Name: secret.name, Serial: secret.lastSerial}
+		}
+	}
+
+	var current *scannedSecret
+	for !hasMore {
+		result, err := d.dynamodbClient.Scan(input)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, item := range result.Items {
+			scannedItem := struct {
+				Name         string
+				Serial       int64
+				LatestSerial *int64
+			}{}
+			err = dynamodbattribute.UnmarshalMap(item, &scannedItem)
+			if err != nil {
+				return nil, "", err
+			}
+			// Versions put since the previous page was listed are not
+			// listed again
+			if scannedItem.Name == startName {
+				continue
+			}
+			if current == nil || current.name != scannedItem.Name {
+				addSecret(current)
+				current = &scannedSecret{name: scannedItem.Name}
+			}
+			current.add(scannedItem.Serial, scannedItem.LatestSerial)
+			if limit > 0 && int64(len(keys)) >= limit && current.latestSerial() > labelsSerial {
+				hasMore = true
+				break
+			}
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	if !hasMore {
+		addSecret(current)
+	}
+
+	summaries, err := d.getSecretSummaries(keys)
+	if err != nil {
+		return nil, "", err
+	}
+	summariesByName := make(map[string]*SecretRecord)
+	for _, summary := range summaries {
+		summariesByName[summary.Name] = summary
+	}
+	// Versions deleted since the scan are left out
+	var records []*SecretRecord
+	for _, key := range keys {
+		if summary, ok := summariesByName[key.Name]; ok {
+			records = append(records, summary)
+		}
+	}
+
+	token := ""
+	if hasMore {
+		token, err = encodeListToken(&lastKey)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return records, token, nil
+}
+
+// scannedSecret collects the serials of the items of a secret found while
+// scanning the table. The items of a secret are scanned one after the other,
+// in the order of their serials
+type scannedSecret struct {
+	name              string
+	lastSerial        int64
+	recordedSerial    int64
+	latestVersionSeen int64
+	hasRecordedSerial bool
+}
+
+// add records the serial of an item of the secret, along with the latest
+// serial recorded on it if it is the metadata item
+func (secret *scannedSecret) add(serial int64, latestSerial *int64) {
+	secret.lastSerial = serial
+	if serial == labelsSerial && latestSerial != nil {
+		secret.recordedSerial = *latestSerial
+		secret.hasRecordedSerial = true
+	}
+	if serial > secret.latestVersionSeen {
+		secret.latestVersionSeen = serial
+	}
+}
+
+// latestSerial returns the serial of the latest version of the secret. The
+// serial recorded on its metadata item is used if there is one. Secrets put
+// before the latest serial was recorded fall back to the latest version found
+// by the scan
+func (secret *scannedSecret) latestSerial() int64 {
+	if secret.hasRecordedSerial {
+		return secret.recordedSerial
+	}
+	return secret.latestVersionSeen
+}
+
+// ListVersions lists every version of the secret in DynamoDB, in the order of
+// their serials. The records returned do not contain any encrypted data
+func (d *dao) ListVersions(secretName string) ([]*SecretRecord, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		ScanIndexForward:         aws.Bool(true),
+		KeyConditionExpression:   aws.String("#N = :val"),
+		ProjectionExpression:     aws.String(summaryProjectionExpression),
+		ExpressionAttributeNames: summaryAttributeNames(),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val": &dynamodb.AttributeValue{S: aws.String(secretName)},
+		},
+	}
+
+	var records []*SecretRecord
+	for {
+		result, err := d.dynamodbClient.Query(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range result.Items {
+			if !isVersionItem(item) {
+				continue
+			}
+			record := &SecretRecord{}
+			err = dynamodbattribute.UnmarshalMap(item, record)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return records, nil
+}
+
+// GetSecretLabels gets the labels and the rest of the metadata of the secret
+// from DynamoDB. Labels with no revision are returned if none have been put
+// for the secret yet
+func (d *dao) GetSecretLabels(secretName string) (*SecretLabels, error) {
+	itemResult, err := d.dynamodbClient.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:            metadataKey(secretName),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &SecretLabels{}
+	if itemResult != nil && len(itemResult.Item) != 0 {
+		err = dynamodbattribute.UnmarshalMap(itemResult.Item, metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+	metadata.Name = secretName
+	metadata.Serial = labelsSerial
+	if metadata.Labels == nil {
+		metadata.Labels = map[string]int64{}
+	}
+	return metadata, nil
+}
+
+// PutSecretLabels puts the labels and the rest of the metadata of the secret
+// into DynamoDB, replacing all of it at once. ErrSecretLabelsModified is
+// returned if they have been put by someone else since they were read. The
+// revision is incremented when they are put. The latest serial kept on the
+// same item is left untouched
+func (d *dao) PutSecretLabels(metadata *SecretLabels) error {
+	condition := "attribute_not_exists(Revision)"
+	newRevision := metadata.Revision + 1
+	values := map[string]*dynamodb.AttributeValue{
+		":newRevision": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(newRevision, 10))},
+	}
+	if metadata.Revision != 0 {
+		condition = "Revision = :revision"
+		values[":revision"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(metadata.Revision, 10))}
+	}
+
+	// DynamoDB does not allow empty values to be stored, attributes that are
+	// not set are removed instead
+	setExpressions := []string{"Revision = :newRevision"}
+	removeExpressions := []string{}
+	if len(metadata.Labels) > 0 {
+		labels, err := dynamodbattribute.Marshal(metadata.Labels)
+		if err != nil {
+			return err
+		}
+		setExpressions = append(setExpressions, "Labels = :labels")
+		values[":labels"] = labels
+	} else {
+		removeExpressions = append(removeExpressions, "Labels")
+	}
+	if metadata.RetentionMaxVersions != 0 {
+		setExpressions = append(setExpressions, "RetentionMaxVersions = :maxVersions")
+		values[":maxVersions"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(metadata.RetentionMaxVersions, 10))}
+	} else {
+		removeExpressions = append(removeExpressions, "RetentionMaxVersions")
+	}
+	if metadata.RetentionMaxAge != 0 {
+		setExpressions = append(setExpressions, "RetentionMaxAge = :maxAge")
+		values[":maxAge"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(metadata.RetentionMaxAge, 10))}
+	} else {
+		removeExpressions = append(removeExpressions, "RetentionMaxAge")
+	}
+	updateExpression := "SET " + strings.Join(setExpressions, ", ")
+	if len(removeExpressions) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removeExpressions, ", ")
+	}
+
+	_, err := d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:                       metadataKey(metadata.Name),
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+	})
+	if conditionalCheckFailedError(err) {
+		return ErrSecretLabelsModified
+	}
+	if err != nil {
+		return err
+	}
+	metadata.Revision = newRevision
+	return nil
+}
+
+// DeleteSecretLabels deletes all labels of the secret, along with the rest of
+// its metadata, from DynamoDB. The metadata item itself is kept if a version of the
+// secret has been put since its versions were deleted, so that the latest
+// serial recorded on it is not lost
+func (d *dao) DeleteSecretLabels(secretName string) error {
+	_, err := d.dynamodbClient.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:                 metadataKey(secretName),
+		ConditionExpression: aws.String("attribute_not_exists(LatestSerial)"),
+	})
+	if !conditionalCheckFailedError(err) {
+		return err
+	}
+	_, err = d.dynamodbClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(cfnclient.GetSecretsTableName(d.appName)),
+		Key:              metadataKey(secretName),
+		UpdateExpression: aws.String("REMOVE Labels, RetentionMaxVersions, RetentionMaxAge, Revision"),
+	})
+	return err
+}
+
+// metadataKey returns the key of the item that holds the metadata of the
+// secret
+func metadataKey(secretName string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Name":   &dynamodb.AttributeValue{S: aws.String(secretName)},
+		"Serial": &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(labelsSerial, 10))},
+	}
+}
+
+// isVersionItem returns false if the item holds the metadata of a secret or a
+// chunk of the encrypted data of a version, rather than a version of the
+// secret. These are stored with serials that are not positive
+func isVersionItem(item map[string]*dynamodb.AttributeValue) bool {
+	serial, ok := item["Serial"]
+	if !ok || serial == nil || serial.N == nil {
+		return true
+	}
+	serialInt, err := strconv.ParseInt(aws.StringValue(serial.N), 10, 64)
+	return err != nil || serialInt > labelsSerial
+}
+
+func conditionalCheckFailedError(err error) bool {
+	return awsErrorCode(err) == conditionalCheckFailedErrorCode
+}
+
+// awsErrorCode returns the code of the error returned by an AWS service, or
+// an empty string if the error did not come from a service
+func awsErrorCode(err error) string {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+func encodeListToken(token *listToken) (string, error) {
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(tokenBytes), nil
+}
+
+func decodeListToken(nextToken string) (*listToken, error) {
+	tokenBytes, err := base64.URLEncoding.DecodeString(nextToken)
+	if err != nil {
+		return nil, ErrInvalidNextToken
+	}
+	token := &listToken{}
+	err = json.Unmarshal(tokenBytes, token)
+	if err != nil {
+		return nil, ErrInvalidNextToken
+	}
+	return token, nil
+}
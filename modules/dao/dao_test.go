@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/awslabs/ecs-secrets/modules/dynamodb/client/mock"
 	"github.com/golang/mock/gomock"
@@ -120,7 +121,8 @@ func TestPutSecretRecord(t *testing.T) {
 	ddbClient := mock_client.NewMockClient(ctrl)
 
 	ddbClient.EXPECT().PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		TableName:           aws.String("ECS-Secrets-myapp-Secrets"),
+		ConditionExpression: aws.String("attribute_not_exists(Serial)"),
 		Item: map[string]*dynamodb.AttributeValue{
 			"Name": {
 				S: aws.String("foo-name"),
@@ -139,6 +141,18 @@ func TestPutSecretRecord(t *testing.T) {
 			},
 		},
 	}).Return(nil, nil)
+	ddbClient.EXPECT().UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("foo-name")},
+			"Serial": {N: aws.String("0")},
+		},
+		UpdateExpression:    aws.String("SET LatestSerial = :serial"),
+		ConditionExpression: aws.String("attribute_not_exists(LatestSerial) OR LatestSerial < :serial"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":serial": {N: aws.String("1")},
+		},
+	}).Return(nil, nil)
 	dao := NewDAO("myapp", ddbClient)
 	secret := &SecretRecord{
 		Name:             "foo-name",
@@ -160,7 +174,8 @@ func TestPutSecretRecordError(t *testing.T) {
 	ddbClient := mock_client.NewMockClient(ctrl)
 
 	ddbClient.EXPECT().PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		TableName:           aws.String("ECS-Secrets-myapp-Secrets"),
+		ConditionExpression: aws.String("attribute_not_exists(Serial)"),
 		Item: map[string]*dynamodb.AttributeValue{
 			"Name": {
 				S: aws.String("foo-name"),
@@ -193,6 +208,86 @@ func TestPutSecretRecordError(t *testing.T) {
 	}
 }
 
+func TestPutSecretRecordWithMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String("ECS-Secrets-myapp-Secrets"),
+		ConditionExpression: aws.String("attribute_not_exists(Serial)"),
+		Item: map[string]*dynamodb.AttributeValue{
+			"Name":             {S: aws.String("foo-name")},
+			"Serial":           {N: aws.String("1")},
+			"EncryptedData":    {S: aws.String("foo-data")},
+			"EncryptedDataKey": {S: aws.String("foo-data-key")},
+			"Active":           {BOOL: aws.Bool(true)},
+			"CreatedAt":        {N: aws.String("1500000000")},
+			"CreatedBy":        {S: aws.String("arn:aws:iam::123456789012:user/foo")},
+			"Description":      {S: aws.String("first")},
+			"Tags": {M: map[string]*dynamodb.AttributeValue{
+				"team": {S: aws.String("payments")},
+			}},
+		},
+	}).Return(nil, nil)
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.PutSecretRecord(&SecretRecord{
+		Name:             "foo-name",
+		Serial:           1,
+		EncryptedData:    "foo-data",
+		EncryptedDataKey: "foo-data-key",
+		Active:           true,
+		CreatedAt:        1500000000,
+		CreatedBy:        "arn:aws:iam::123456789012:user/foo",
+		Description:      "first",
+		Tags:             map[string]string{"team": "payments"},
+	})
+	if err != nil {
+		t.Errorf("Error putting secret record: %v", err)
+	}
+}
+
+func TestPutSecretRecordAlreadyExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().PutItem(gomock.Any()).Return(nil,
+		awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.PutSecretRecord(&SecretRecord{
+		Name:   "foo-name",
+		Serial: 1,
+		Active: true,
+	})
+	if err != ErrSecretRecordExists {
+		t.Errorf("Expected secret record exists error, got: %v", err)
+	}
+}
+
+func TestPutSecretRecordLaterLatestSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().PutItem(gomock.Any()).Return(nil, nil)
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil,
+		awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.PutSecretRecord(&SecretRecord{
+		Name:   "foo-name",
+		Serial: 1,
+		Active: true,
+	})
+	if err != nil {
+		t.Errorf("Error putting secret record: %v", err)
+	}
+}
+
 func TestRevokeSecretRecord(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -209,12 +304,11 @@ func TestRevokeSecretRecord(t *testing.T) {
 				N: aws.String("1"),
 			},
 		},
-		AttributeUpdates: map[string]*dynamodb.AttributeValueUpdate{
-			"Active": &dynamodb.AttributeValueUpdate{
-				Value: &dynamodb.AttributeValue{
-					BOOL: aws.Bool(false),
-				},
-				Action: aws.String("PUT"),
+		UpdateExpression:    aws.String("SET Active = :active"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":active": {
+				BOOL: aws.Bool(false),
 			},
 		},
 	}).Return(nil, nil)
@@ -225,6 +319,538 @@ func TestRevokeSecretRecord(t *testing.T) {
 	}
 }
 
+func TestRevokeSecretRecordNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil,
+		awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.RevokeSecretRecord("foo", 1)
+	if err != ErrSecretRecordNotFound {
+		t.Errorf("Expected ErrSecretRecordNotFound, got: %v", err)
+	}
+}
+
+func TestRestoreSecretRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name": {
+				S: aws.String("foo"),
+			},
+			"Serial": {
+				N: aws.String("1"),
+			},
+		},
+		UpdateExpression:    aws.String("SET Active = :active, RestoredAt = :restoredAt, RestoredBy = :restoredBy, RestoreReason = :reason"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":active":     {BOOL: aws.Bool(true)},
+			":restoredAt": {N: aws.String("1487116800")},
+			":restoredBy": {S: aws.String("arn:aws:iam::123456789012:user/admin")},
+			":reason":     {S: aws.String("revoked by mistake")},
+		},
+	}).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.RestoreSecretRecord("foo", 1, &Restoration{
+		RestoredAt: 1487116800,
+		RestoredBy: "arn:aws:iam::123456789012:user/admin",
+		Reason:     "revoked by mistake",
+	})
+	if err != nil {
+		t.Errorf("Error restoring secret record: %v", err)
+	}
+}
+
+func TestScheduleSecretRecordDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name": {
+				S: aws.String("foo"),
+			},
+			"Serial": {
+				N: aws.String("2"),
+			},
+		},
+		UpdateExpression:    aws.String("SET DeletedAt = :deletedAt, PurgeAt = :purgeAt, DeletedBy = :deletedBy"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deletedAt": {N: aws.String("1487116800")},
+			":purgeAt":   {N: aws.String("1489708800")},
+			":deletedBy": {S: aws.String("arn:aws:iam::123456789012:user/admin")},
+		},
+	}).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.ScheduleSecretRecordDeletion("foo", 2, &Deletion{
+		DeletedAt: 1487116800,
+		DeletedBy: "arn:aws:iam::123456789012:user/admin",
+		PurgeAt:   1489708800,
+	})
+	if err != nil {
+		t.Errorf("Error scheduling deletion of secret record: %v", err)
+	}
+}
+
+func TestCancelSecretRecordDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name": {
+				S: aws.String("foo"),
+			},
+			"Serial": {
+				N: aws.String("2"),
+			},
+		},
+		UpdateExpression:    aws.String("REMOVE DeletedAt, DeletedBy, PurgeAt"),
+		ConditionExpression: aws.String("attribute_exists(Serial)"),
+	}).Return(nil, awserr.New(conditionalCheckFailedErrorCode, "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.CancelSecretRecordDeletion("foo", 2)
+	if err == nil {
+		t.Error("Expected error cancelling deletion of a secret record that doesn't exist")
+	}
+}
+
+func TestRestoreSecretRecordWithoutReason(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Do(func(input *dynamodb.UpdateItemInput) {
+		expected := "SET Active = :active, RestoredAt = :restoredAt, RestoredBy = :restoredBy REMOVE RestoreReason"
+		if aws.StringValue(input.UpdateExpression) != expected {
+			t.Errorf("Incorrect update expression: %s", aws.StringValue(input.UpdateExpression))
+		}
+		if _, ok := input.ExpressionAttributeValues[":reason"]; ok {
+			t.Error("Unexpected value for restore reason")
+		}
+	}).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.RestoreSecretRecord("foo", 1, &Restoration{
+		RestoredAt: 1487116800,
+		RestoredBy: "arn:aws:iam::123456789012:user/admin",
+	})
+	if err != nil {
+		t.Errorf("Error restoring secret record: %v", err)
+	}
+}
+
+func TestRestoreSecretRecordNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil,
+		awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.RestoreSecretRecord("foo", 1, &Restoration{RestoredAt: 1487116800})
+	if err == nil {
+		t.Error("Expected error restoring secret record that does not exist")
+	}
+}
+
+func TestGetSecretLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("foo")},
+			"Serial": {N: aws.String("0")},
+		},
+		ConsistentRead: aws.Bool(true),
+	}).Return(&dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("foo")},
+			"Serial": {N: aws.String("0")},
+			"Labels": {M: map[string]*dynamodb.AttributeValue{
+				"current": {N: aws.String("2")},
+			}},
+			"Revision": {N: aws.String("3")},
+		},
+	}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	metadata, err := dao.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	expectedMetadata := &SecretLabels{
+		Name:     "foo",
+		Labels:   map[string]int64{"current": 2},
+		Revision: 3,
+	}
+	if !reflect.DeepEqual(metadata, expectedMetadata) {
+		t.Errorf("Mismatch between expected and received metadata: %v != %v", metadata, expectedMetadata)
+	}
+}
+
+func TestGetSecretLabelsNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	metadata, err := dao.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if metadata.Revision != 0 || len(metadata.Labels) != 0 {
+		t.Errorf("Expected no metadata, got: %v", metadata)
+	}
+}
+
+func TestPutSecretLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("foo")},
+			"Serial": {N: aws.String("0")},
+		},
+		UpdateExpression:    aws.String("SET Revision = :newRevision, Labels = :labels REMOVE RetentionMaxVersions, RetentionMaxAge"),
+		ConditionExpression: aws.String("Revision = :revision"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":newRevision": {N: aws.String("4")},
+			":revision":    {N: aws.String("3")},
+			":labels": {M: map[string]*dynamodb.AttributeValue{
+				"current": {N: aws.String("2")},
+			}},
+		},
+	}).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	metadata := &SecretLabels{
+		Name:     "foo",
+		Labels:   map[string]int64{"current": 2},
+		Revision: 3,
+	}
+	err := dao.PutSecretLabels(metadata)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	if metadata.Revision != 4 {
+		t.Errorf("Expected revision of metadata to be incremented, got: %d", metadata.Revision)
+	}
+}
+
+func TestPutSecretLabelsFirstRevision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Do(func(input *dynamodb.UpdateItemInput) {
+		if aws.StringValue(input.ConditionExpression) != "attribute_not_exists(Revision)" {
+			t.Errorf("Incorrect condition expression: %s", aws.StringValue(input.ConditionExpression))
+		}
+	}).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.PutSecretLabels(&SecretLabels{
+		Name:   "foo",
+		Labels: map[string]int64{"current": 1},
+	})
+	if err != nil {
+		t.Errorf("Error putting secret metadata: %v", err)
+	}
+}
+
+func TestPutSecretLabelsModified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().UpdateItem(gomock.Any()).Return(nil,
+		awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil))
+	dao := NewDAO("myapp", ddbClient)
+	metadata := &SecretLabels{
+		Name:     "foo",
+		Labels:   map[string]int64{"current": 2},
+		Revision: 3,
+	}
+	err := dao.PutSecretLabels(metadata)
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected secret metadata modified error, got: %v", err)
+	}
+	if metadata.Revision != 3 {
+		t.Errorf("Expected revision of metadata to be unchanged, got: %d", metadata.Revision)
+	}
+}
+
+func TestGetLatestActiveVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int64(latestActivePageSize),
+		KeyConditionExpression:   aws.String("#N = :val"),
+		FilterExpression:         aws.String("Active = :active AND (attribute_not_exists(ExpiresAt) OR ExpiresAt > :now)"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val":    {S: aws.String("foo")},
+			":active": {BOOL: aws.Bool(true)},
+			":now":    {N: aws.String("1500000000")},
+		},
+	}
+	lastEvaluatedKey := map[string]*dynamodb.AttributeValue{
+		"Name":   {S: aws.String("foo")},
+		"Serial": {N: aws.String("3")},
+	}
+	nextInput := *input
+	nextInput.ExclusiveStartKey = lastEvaluatedKey
+
+	gomock.InOrder(
+		// None of the versions on the first page are active
+		ddbClient.EXPECT().Query(input).Return(&dynamodb.QueryOutput{
+			Count:            aws.Int64(0),
+			LastEvaluatedKey: lastEvaluatedKey,
+		}, nil),
+		ddbClient.EXPECT().Query(&nextInput).Return(&dynamodb.QueryOutput{
+			Count: aws.Int64(1),
+			Items: []map[string]*dynamodb.AttributeValue{
+				{
+					"Name":   {S: aws.String("foo")},
+					"Serial": {N: aws.String("2")},
+					"Active": {BOOL: aws.Bool(true)},
+				},
+			},
+			LastEvaluatedKey: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("foo")},
+				"Serial": {N: aws.String("2")},
+			},
+		}, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	record, err := dao.GetLatestActiveVersion("foo", 1500000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	expectedRecord := &SecretRecord{Name: "foo", Serial: 2, Active: true}
+	if !reflect.DeepEqual(record, expectedRecord) {
+		t.Errorf("Mismatch between expected and received record: %v != %v", record, expectedRecord)
+	}
+}
+
+func TestGetLatestActiveVersionNoneActive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{Count: aws.Int64(0)}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	record, err := dao.GetLatestActiveVersion("foo", 1500000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if record != nil {
+		t.Errorf("Expected no version to be returned, got: %v", record)
+	}
+}
+
+func TestGetLatestActiveVersionQueryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Query(gomock.Any()).Return(nil, fmt.Errorf("throttled"))
+	dao := NewDAO("myapp", ddbClient)
+	_, err := dao.GetLatestActiveVersion("foo", 1500000000)
+	if err == nil {
+		t.Error("Expected error getting latest active version")
+	}
+}
+
+func TestGetLatestVersionOnlyLabelsLeft(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+		Count: aws.Int64(1),
+		Items: []map[string]*dynamodb.AttributeValue{
+			{
+				"Name":     {S: aws.String("foo")},
+				"Serial":   {N: aws.String("0")},
+				"Revision": {N: aws.String("1")},
+			},
+		},
+	}, nil)
+	dao := NewDAO("myapp", ddbClient)
+	record, err := dao.GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	if record != nil {
+		t.Errorf("Expected no version to be returned, got: %v", record)
+	}
+}
+
+func TestDeleteSecretRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String("ECS-Secrets-myapp-Secrets"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Name": {
+				S: aws.String("foo"),
+			},
+			"Serial": {
+				N: aws.String("1"),
+			},
+		},
+		ReturnValues: aws.String("ALL_OLD"),
+	}).Return(nil, nil)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Errorf("Error deleting secret record: %v", err)
+	}
+}
+
+func TestDeleteSecretRecordMovesLatestSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	metadataKey := map[string]*dynamodb.AttributeValue{
+		"Name":   {S: aws.String("foo")},
+		"Serial": {N: aws.String("0")},
+	}
+	gomock.InOrder(
+		ddbClient.EXPECT().DeleteItem(gomock.Any()).Return(&dynamodb.DeleteItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("foo")},
+				"Serial": {N: aws.String("3")},
+			},
+		}, nil),
+		ddbClient.EXPECT().GetItem(&dynamodb.GetItemInput{
+			TableName:            aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:                  metadataKey,
+			ProjectionExpression: aws.String("LatestSerial"),
+			ConsistentRead:       aws.Bool(true),
+		}).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"LatestSerial": {N: aws.String("3")},
+			},
+		}, nil),
+		ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{
+			Count: aws.Int64(1),
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Serial": {N: aws.String("2")}},
+			},
+		}, nil),
+		ddbClient.EXPECT().UpdateItem(&dynamodb.UpdateItemInput{
+			TableName:           aws.String("ECS-Secrets-myapp-Secrets"),
+			Key:                 metadataKey,
+			UpdateExpression:    aws.String("SET LatestSerial = :latest"),
+			ConditionExpression: aws.String("LatestSerial = :serial"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":serial": {N: aws.String("3")},
+				":latest": {N: aws.String("2")},
+			},
+		}).Return(nil, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.DeleteSecretRecord("foo", 3)
+	if err != nil {
+		t.Errorf("Error deleting secret record: %v", err)
+	}
+}
+
+func TestDeleteSecretRecordRemovesLatestSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		ddbClient.EXPECT().DeleteItem(gomock.Any()).Return(&dynamodb.DeleteItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("foo")},
+				"Serial": {N: aws.String("1")},
+			},
+		}, nil),
+		ddbClient.EXPECT().GetItem(gomock.Any()).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"LatestSerial": {N: aws.String("1")},
+			},
+		}, nil),
+		ddbClient.EXPECT().Query(gomock.Any()).Return(&dynamodb.QueryOutput{Count: aws.Int64(0)}, nil),
+		ddbClient.EXPECT().UpdateItem(gomock.Any()).Do(func(input *dynamodb.UpdateItemInput) {
+			if aws.StringValue(input.UpdateExpression) != "REMOVE LatestSerial" {
+				t.Errorf("Incorrect update expression: %s", aws.StringValue(input.UpdateExpression))
+			}
+		}).Return(nil, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Errorf("Error deleting secret record: %v", err)
+	}
+}
+
+func TestDeleteSecretLabelsKeepsLatestSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		ddbClient.EXPECT().DeleteItem(gomock.Any()).Return(nil,
+			awserr.New("ConditionalCheckFailedException", "The conditional request failed", nil)),
+		ddbClient.EXPECT().UpdateItem(gomock.Any()).Do(func(input *dynamodb.UpdateItemInput) {
+			if aws.StringValue(input.UpdateExpression) != "REMOVE Labels, RetentionMaxVersions, RetentionMaxAge, Revision" {
+				t.Errorf("Incorrect update expression: %s", aws.StringValue(input.UpdateExpression))
+			}
+		}).Return(nil, nil),
+	)
+	dao := NewDAO("myapp", ddbClient)
+	err := dao.DeleteSecretLabels("foo")
+	if err != nil {
+		t.Errorf("Error deleting secret metadata: %v", err)
+	}
+}
+
 func TestGetLatestVersion(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -232,9 +858,9 @@ func TestGetLatestVersion(t *testing.T) {
 	ddbClient := mock_client.NewMockClient(ctrl)
 
 	ddbClient.EXPECT().Query(&dynamodb.QueryInput{
-		TableName:        aws.String("ECS-Secrets-myapp-Secrets"),
-		ScanIndexForward: aws.Bool(false),
-		Limit:            aws.Int64(1),
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int64(1),
 		KeyConditionExpression:   aws.String("#N = :val"),
 		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
@@ -242,6 +868,7 @@ func TestGetLatestVersion(t *testing.T) {
 				S: aws.String("foo"),
 			},
 		},
+		ConsistentRead: aws.Bool(true),
 	}).Return(&dynamodb.QueryOutput{
 		Count: aws.Int64(1),
 		Items: []map[string]*dynamodb.AttributeValue{
@@ -272,9 +899,9 @@ func TestGetLatestVersionReturnsEmptyItems(t *testing.T) {
 	ddbClient := mock_client.NewMockClient(ctrl)
 
 	ddbClient.EXPECT().Query(&dynamodb.QueryInput{
-		TableName:        aws.String("ECS-Secrets-myapp-Secrets"),
-		ScanIndexForward: aws.Bool(false),
-		Limit:            aws.Int64(1),
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int64(1),
 		KeyConditionExpression:   aws.String("#N = :val"),
 		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
@@ -282,6 +909,7 @@ func TestGetLatestVersionReturnsEmptyItems(t *testing.T) {
 				S: aws.String("foo"),
 			},
 		},
+		ConsistentRead: aws.Bool(true),
 	}).Return(&dynamodb.QueryOutput{
 		Count: aws.Int64(0),
 	}, nil)
@@ -303,9 +931,9 @@ func TestGetLatestVersionQueryError(t *testing.T) {
 	ddbClient := mock_client.NewMockClient(ctrl)
 
 	ddbClient.EXPECT().Query(&dynamodb.QueryInput{
-		TableName:        aws.String("ECS-Secrets-myapp-Secrets"),
-		ScanIndexForward: aws.Bool(false),
-		Limit:            aws.Int64(1),
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ScanIndexForward:         aws.Bool(false),
+		Limit:                    aws.Int64(1),
 		KeyConditionExpression:   aws.String("#N = :val"),
 		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
@@ -313,6 +941,7 @@ func TestGetLatestVersionQueryError(t *testing.T) {
 				S: aws.String("foo"),
 			},
 		},
+		ConsistentRead: aws.Bool(true),
 	}).Return(nil, fmt.Errorf("enough already"))
 	dao := NewDAO("myapp", ddbClient)
 	_, err := dao.GetLatestVersion("foo")
@@ -320,3 +949,424 @@ func TestGetLatestVersionQueryError(t *testing.T) {
 		t.Errorf("Expected error getting latest version")
 	}
 }
+
+func TestListSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ProjectionExpression:     aws.String("#N, Serial, LatestSerial"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+	}
+	gomock.InOrder(
+		ddbClient.EXPECT().Scan(scanInput).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Name": {S: aws.String("foo")}, "LatestSerial": {N: aws.String("3")}},
+			},
+			LastEvaluatedKey: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("foo")},
+				"Serial": {N: aws.String("2")},
+			},
+		}, nil),
+		ddbClient.EXPECT().Scan(gomock.Any()).Do(func(input *dynamodb.ScanInput) {
+			if aws.StringValue(input.ExclusiveStartKey["Serial"].N) != "2" {
+				t.Errorf("Expected scan to carry on from the last evaluated key, got: %v", input.ExclusiveStartKey)
+			}
+		}).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Name": {S: aws.String("bar")}, "LatestSerial": {N: aws.String("1")}},
+			},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				"ECS-Secrets-myapp-Secrets": {
+					Keys: []map[string]*dynamodb.AttributeValue{
+						{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("3")}},
+						{"Name": {S: aws.String("bar")}, "Serial": {N: aws.String("1")}},
+					},
+					ProjectionExpression:     aws.String(summaryProjectionExpression),
+					ExpressionAttributeNames: summaryAttributeNames(),
+				},
+			},
+		}).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {
+					{"Name": {S: aws.String("bar")}, "Serial": {N: aws.String("1")}},
+					{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("3")}, "Active": {BOOL: aws.Bool(true)}},
+				},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, nextToken, err := dao.ListSecrets("", "", 10)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	expectedSecrets := []*SecretRecord{
+		{Name: "foo", Serial: 3, Active: true},
+		{Name: "bar", Serial: 1},
+	}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and recieved secrets: %v != %v", secrets, expectedSecrets)
+	}
+	if nextToken != "" {
+		t.Errorf("Expected empty token for the last page, got: %s", nextToken)
+	}
+}
+
+func TestListSecretsWithPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Scan(&dynamodb.ScanInput{
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ProjectionExpression:     aws.String("#N, Serial, LatestSerial"),
+		FilterExpression:         aws.String("begins_with(#N, :prefix)"),
+		ExpressionAttributeNames: aws.StringMap(map[string]string{"#N": "Name"}),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix": {S: aws.String("prod/payments/")},
+		},
+	}).Return(&dynamodb.ScanOutput{}, nil)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, nextToken, err := dao.ListSecrets("prod/payments/", "", 10)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(secrets) != 0 || nextToken != "" {
+		t.Errorf("Expected no secrets and no token for the next page, got: %v, %s", secrets, nextToken)
+	}
+}
+
+func TestListSecretsLimitCountsSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	metadataItem := func(name string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":         {S: aws.String(name)},
+			"LatestSerial": {N: aws.String("1")},
+		}
+	}
+	summary := func(name string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String(name)},
+			"Serial": {N: aws.String("1")},
+		}
+	}
+	gomock.InOrder(
+		ddbClient.EXPECT().Scan(gomock.Any()).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{metadataItem("foo"), metadataItem("bar"), metadataItem("baz")},
+			LastEvaluatedKey: map[string]*dynamodb.AttributeValue{
+				"Name":   {S: aws.String("baz")},
+				"Serial": {N: aws.String("1")},
+			},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Do(func(input *dynamodb.BatchGetItemInput) {
+			if len(input.RequestItems["ECS-Secrets-myapp-Secrets"].Keys) != 2 {
+				t.Errorf("Expected the summaries of 2 secrets to be read, got: %v", input.RequestItems)
+			}
+		}).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {summary("foo"), summary("bar")},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, nextToken, err := dao.ListSecrets("", "", 2)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	expectedSecrets := []*SecretRecord{
+		{Name: "foo", Serial: 1},
+		{Name: "bar", Serial: 1},
+	}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and recieved secrets: %v != %v", secrets, expectedSecrets)
+	}
+	token, err := decodeListToken(nextToken)
+	if err != nil {
+		t.Fatalf("Error decoding token for the next page: %v", err)
+	}
+	if token.Name != "bar" || token.Serial != labelsSerial {
+		t.Errorf("Expected token to point at the last secret listed, got: %v", token)
+	}
+}
+
+func TestListSecretsWithoutLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		ddbClient.EXPECT().Scan(gomock.Any()).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"Name": {S: aws.String("foo")}, "LatestSerial": {N: aws.String("1")}},
+				{"Name": {S: aws.String("bar")}, "LatestSerial": {N: aws.String("2")}},
+			},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {
+					{"Name": {S: aws.String("foo")}, "Serial": {N: aws.String("1")}},
+					{"Name": {S: aws.String("bar")}, "Serial": {N: aws.String("2")}},
+				},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, nextToken, err := dao.ListSecrets("", "", 0)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(secrets) != 2 || nextToken != "" {
+		t.Errorf("Expected every secret and no token for the next page, got: %v, %s", secrets, nextToken)
+	}
+}
+
+func TestListSecretsWithoutLatestSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	item := func(name string, serial string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String(name)},
+			"Serial": {N: aws.String(serial)},
+		}
+	}
+	gomock.InOrder(
+		// foo was put before latest serials were recorded and has labels,
+		// bar has a chunked version and no metadata item, and the metadata
+		// of baz is all that is left of it
+		ddbClient.EXPECT().Scan(gomock.Any()).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				item("foo", "0"), item("foo", "1"), item("foo", "2"),
+				item("bar", "-4"), item("bar", "-3"), item("bar", "1"),
+				item("baz", "0"),
+			},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Do(func(input *dynamodb.BatchGetItemInput) {
+			expectedKeys := []map[string]*dynamodb.AttributeValue{item("foo", "2"), item("bar", "1")}
+			if !reflect.DeepEqual(input.RequestItems["ECS-Secrets-myapp-Secrets"].Keys, expectedKeys) {
+				t.Errorf("Unexpected keys of the latest versions: %v", input.RequestItems)
+			}
+		}).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {item("foo", "2"), item("bar", "1")},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, nextToken, err := dao.ListSecrets("", "", 10)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	expectedSecrets := []*SecretRecord{
+		{Name: "foo", Serial: 2},
+		{Name: "bar", Serial: 1},
+	}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and recieved secrets: %v != %v", secrets, expectedSecrets)
+	}
+	if nextToken != "" {
+		t.Errorf("Expected empty token for the last page, got: %s", nextToken)
+	}
+}
+
+func TestListSecretsTokenSkipsVersionsOfLastSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	item := func(name string, serial string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String(name)},
+			"Serial": {N: aws.String(serial)},
+		}
+	}
+	gomock.InOrder(
+		ddbClient.EXPECT().Scan(gomock.Any()).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{item("foo", "1"), item("foo", "2"), item("bar", "1")},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {item("foo", "2")},
+			},
+		}, nil),
+		// A version of foo put since the first page was listed is found
+		// after the token, and is not listed again
+		ddbClient.EXPECT().Scan(gomock.Any()).Do(func(input *dynamodb.ScanInput) {
+			if !reflect.DeepEqual(input.ExclusiveStartKey, item("foo", "2")) {
+				t.Errorf("Expected scan to start after the last item of foo, got: %v", input.ExclusiveStartKey)
+			}
+		}).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{item("foo", "3"), item("bar", "1")},
+		}, nil),
+		ddbClient.EXPECT().BatchGetItem(gomock.Any()).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"ECS-Secrets-myapp-Secrets": {item("bar", "1")},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, nextToken, err := dao.ListSecrets("", "", 1)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "foo" || nextToken == "" {
+		t.Fatalf("Expected foo and a token for the next page, got: %v, %s", secrets, nextToken)
+	}
+	secrets, nextToken, err = dao.ListSecrets("", nextToken, 1)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "bar" || nextToken != "" {
+		t.Errorf("Expected bar and no token for the next page, got: %v, %s", secrets, nextToken)
+	}
+}
+
+func TestListSecretsStartsFromToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	nextToken, err := encodeListToken(&listToken{Name: "bar", Serial: labelsSerial})
+	if err != nil {
+		t.Fatalf("Error encoding token: %v", err)
+	}
+	ddbClient.EXPECT().Scan(gomock.Any()).Do(func(input *dynamodb.ScanInput) {
+		expectedKey := map[string]*dynamodb.AttributeValue{
+			"Name":   {S: aws.String("bar")},
+			"Serial": {N: aws.String("0")},
+		}
+		if !reflect.DeepEqual(input.ExclusiveStartKey, expectedKey) {
+			t.Errorf("Incorrect start key: %v", input.ExclusiveStartKey)
+		}
+	}).Return(&dynamodb.ScanOutput{}, nil)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, token, err := dao.ListSecrets("", nextToken, 10)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(secrets) != 0 || token != "" {
+		t.Errorf("Expected no secrets and no token for the next page, got: %v, %s", secrets, token)
+	}
+}
+
+func TestListSecretsInvalidToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	dao := NewDAO("myapp", ddbClient)
+	_, _, err := dao.ListSecrets("", "not a token", 10)
+	if err == nil {
+		t.Error("Expected error listing secrets with an invalid token")
+	}
+}
+
+func TestListSecretsScanError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Scan(gomock.Any()).Return(nil, fmt.Errorf("nothing to see here"))
+	dao := NewDAO("myapp", ddbClient)
+	_, _, err := dao.ListSecrets("", "", 10)
+	if err == nil {
+		t.Error("Expected error listing secrets")
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String("ECS-Secrets-myapp-Secrets"),
+		ScanIndexForward:         aws.Bool(true),
+		KeyConditionExpression:   aws.String("#N = :val"),
+		ProjectionExpression:     aws.String(summaryProjectionExpression),
+		ExpressionAttributeNames: summaryAttributeNames(),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":val": {S: aws.String("foo")},
+		},
+	}
+	lastEvaluatedKey := map[string]*dynamodb.AttributeValue{
+		"Name":   {S: aws.String("foo")},
+		"Serial": {N: aws.String("1")},
+	}
+	nextInput := *input
+	nextInput.ExclusiveStartKey = lastEvaluatedKey
+
+	gomock.InOrder(
+		ddbClient.EXPECT().Query(input).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{
+					"Name":   {S: aws.String("foo")},
+					"Serial": {N: aws.String("1")},
+					"Active": {BOOL: aws.Bool(false)},
+				},
+			},
+			LastEvaluatedKey: lastEvaluatedKey,
+		}, nil),
+		ddbClient.EXPECT().Query(&nextInput).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{
+					"Name":   {S: aws.String("foo")},
+					"Serial": {N: aws.String("2")},
+					"Active": {BOOL: aws.Bool(true)},
+				},
+			},
+		}, nil),
+	)
+
+	dao := NewDAO("myapp", ddbClient)
+	secrets, err := dao.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	expectedSecrets := []*SecretRecord{
+		{Name: "foo", Serial: 1, Active: false},
+		{Name: "foo", Serial: 2, Active: true},
+	}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and recieved secrets: %v != %v", secrets, expectedSecrets)
+	}
+}
+
+func TestListVersionsQueryError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ddbClient := mock_client.NewMockClient(ctrl)
+
+	ddbClient.EXPECT().Query(gomock.Any()).Return(nil, fmt.Errorf("history is lost"))
+	dao := NewDAO("myapp", ddbClient)
+	_, err := dao.ListVersions("foo")
+	if err == nil {
+		t.Error("Expected error listing versions")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+// versionDocument defines a version of a secret as it is stored by backends
+// that keep versions in JSON documents. Placeholder versions hold no secret,
+// they are stored by backends that number versions themselves to skip serials
+// that are not in use
+type versionDocument struct {
+	EncryptedData    string            `json:"encryptedData,omitempty"`
+	EncryptedDataKey string            `json:"encryptedDataKey,omitempty"`
+	Active           bool              `json:"active,omitempty"`
+	CreatedAt        int64             `json:"createdAt,omitempty"`
+	CreatedBy        string            `json:"createdBy,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	RestoredAt       int64             `json:"restoredAt,omitempty"`
+	RestoredBy       string            `json:"restoredBy,omitempty"`
+	RestoreReason    string            `json:"restoreReason,omitempty"`
+	ExpiresAt        int64             `json:"expiresAt,omitempty"`
+	ContentType      string            `json:"contentType,omitempty"`
+	DeletedAt        int64             `json:"deletedAt,omitempty"`
+	DeletedBy        string            `json:"deletedBy,omitempty"`
+	PurgeAt          int64             `json:"purgeAt,omitempty"`
+	Placeholder      bool              `json:"placeholder,omitempty"`
+}
+
+func newVersionDocument(record *SecretRecord) *versionDocument {
+	return &versionDocument{
+		EncryptedData:    record.EncryptedData,
+		EncryptedDataKey: record.EncryptedDataKey,
+		Active:           record.Active,
+		CreatedAt:        record.CreatedAt,
+		CreatedBy:        record.CreatedBy,
+		Description:      record.Description,
+		Tags:             record.Tags,
+		RestoredAt:       record.RestoredAt,
+		RestoredBy:       record.RestoredBy,
+		RestoreReason:    record.RestoreReason,
+		ExpiresAt:        record.ExpiresAt,
+		ContentType:      record.ContentType,
+		DeletedAt:        record.DeletedAt,
+		DeletedBy:        record.DeletedBy,
+		PurgeAt:          record.PurgeAt,
+	}
+}
+
+func (v *versionDocument) secretRecord(name string, serial int64) *SecretRecord {
+	return &SecretRecord{
+		Name:             name,
+		Serial:           serial,
+		EncryptedData:    v.EncryptedData,
+		EncryptedDataKey: v.EncryptedDataKey,
+		Active:           v.Active,
+		CreatedAt:        v.CreatedAt,
+		CreatedBy:        v.CreatedBy,
+		Description:      v.Description,
+		Tags:             v.Tags,
+		RestoredAt:       v.RestoredAt,
+		RestoredBy:       v.RestoredBy,
+		RestoreReason:    v.RestoreReason,
+		ExpiresAt:        v.ExpiresAt,
+		ContentType:      v.ContentType,
+		DeletedAt:        v.DeletedAt,
+		DeletedBy:        v.DeletedBy,
+		PurgeAt:          v.PurgeAt,
+	}
+}
+
+// metadataDocument defines the metadata of a secret as it is stored by
+// backends that keep it in JSON documents
+type metadataDocument struct {
+	Labels               map[string]int64 `json:"labels,omitempty"`
+	RetentionMaxVersions int64            `json:"retentionMaxVersions,omitempty"`
+	RetentionMaxAge      int64            `json:"retentionMaxAge,omitempty"`
+	Revision             int64            `json:"revision"`
+}
+
+type recordsBySerial []*SecretRecord
+
+func (r recordsBySerial) Len() int           { return len(r) }
+func (r recordsBySerial) Less(i, j int) bool { return r[i].Serial < r[j].Serial }
+func (r recordsBySerial) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
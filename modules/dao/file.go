@@ -0,0 +1,476 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Secrets are stored in a single BoltDB file, which can hold the secrets of
+// several applications. Every application has a bucket of its own, with a
+// nested bucket per secret that maps serials to versions, and a bucket for
+// the metadata of its secrets. All changes are made in transactions, so
+// serials and revisions are checked the same way DynamoDB checks them
+const (
+	// fileMode is the mode the data file is created with
+	fileMode = 0600
+	// fileLockTimeout is how long to wait for another process using the data
+	// file to finish
+	fileLockTimeout = 5 * time.Second
+)
+
+var (
+	secretsBucketName  = []byte("secrets")
+	metadataBucketName = []byte("metadata")
+)
+
+// openDataFiles holds the data files opened by the process. BoltDB locks a
+// data file for as long as it is open, so every DAO using the same file
+// shares one handle to it
+var (
+	openDataFilesLock sync.Mutex
+	openDataFiles     = make(map[string]*bolt.DB)
+)
+
+type fileDAO struct {
+	appName  string
+	filename string
+	db       *bolt.DB
+}
+
+// NewFileDAO creates a new DAO object backed by a BoltDB data file, which is
+// created if it does not exist, along with the bucket of the application. The
+// file is kept open and locked until the process exits
+func NewFileDAO(appName string, filename string) (DAO, error) {
+	db, err := openDataFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return createAppBucket(tx, appName)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fileDAO{
+		appName:  appName,
+		filename: filename,
+		db:       db,
+	}, nil
+}
+
+// GetSecretRecord gets a version of a secret from the data file
+func (d *fileDAO) GetSecretRecord(namespace string, serial int64) (*SecretRecord, error) {
+	var record *SecretRecord
+	err := d.view(func(app *bolt.Bucket) error {
+		var err error
+		record, err = getFileRecord(app, namespace, serial)
+		if err == nil && record == nil {
+			err = ErrSecretRecordNotFound
+		}
+		return err
+	})
+	return record, err
+}
+
+// GetSecretRecords gets versions of secrets from the data file in a single
+// transaction. Versions that don't exist are left out
+func (d *fileDAO) GetSecretRecords(keys []SecretKey) ([]*SecretRecord, error) {
+	var records []*SecretRecord
+	err := d.view(func(app *bolt.Bucket) error {
+		seen := make(map[SecretKey]bool)
+		for _, key := range keys {
+			if seen[key] || key.Serial <= labelsSerial {
+				continue
+			}
+			seen[key] = true
+			record, err := getFileRecord(app, key.Name, key.Serial)
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				records = append(records, record)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// PutSecretRecord puts a version of a secret into the data file. Existing
+// versions are never overwritten, ErrSecretRecordExists is returned if a
+// version with the same serial already exists
+func (d *fileDAO) PutSecretRecord(record *SecretRecord) error {
+	value, err := json.Marshal(newVersionDocument(record))
+	if err != nil {
+		return err
+	}
+	return d.update(func(app *bolt.Bucket) error {
+		versions, err := app.Bucket(secretsBucketName).CreateBucketIfNotExists([]byte(record.Name))
+		if err != nil {
+			return err
+		}
+		key := serialKey(record.Serial)
+		if versions.Get(key) != nil {
+			return ErrSecretRecordExists
+		}
+		return versions.Put(key, value)
+	})
+}
+
+// RevokeSecretRecord revokes a version of a secret in the data file
+func (d *fileDAO) RevokeSecretRecord(namespace string, serial int64) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.Active = false
+	})
+}
+
+// RestoreSecretRecord reinstates a revoked version of a secret in the data
+// file, recording who restored it, when and why
+func (d *fileDAO) RestoreSecretRecord(namespace string, serial int64, restoration *Restoration) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.Active = true
+		record.RestoredAt = restoration.RestoredAt
+		record.RestoredBy = restoration.RestoredBy
+		record.RestoreReason = restoration.Reason
+	})
+}
+
+// ScheduleSecretRecordDeletion marks a version of a secret in the data file as
+// scheduled for deletion
+func (d *fileDAO) ScheduleSecretRecordDeletion(namespace string, serial int64, deletion *Deletion) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.DeletedAt = deletion.DeletedAt
+		record.DeletedBy = deletion.DeletedBy
+		record.PurgeAt = deletion.PurgeAt
+	})
+}
+
+// CancelSecretRecordDeletion removes the scheduled deletion of a version of a
+// secret in the data file
+func (d *fileDAO) CancelSecretRecordDeletion(namespace string, serial int64) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.DeletedAt = 0
+		record.DeletedBy = ""
+		record.PurgeAt = 0
+	})
+}
+
+// DeleteSecretRecord deletes a version of a secret from the data file
+func (d *fileDAO) DeleteSecretRecord(namespace string, serial int64) error {
+	return d.update(func(app *bolt.Bucket) error {
+		secrets := app.Bucket(secretsBucketName)
+		versions := secrets.Bucket([]byte(namespace))
+		if versions == nil {
+			return nil
+		}
+		err := versions.Delete(serialKey(serial))
+		if err != nil {
+			return err
+		}
+		if key, _ := versions.Cursor().First(); key == nil {
+			return secrets.DeleteBucket([]byte(namespace))
+		}
+		return nil
+	})
+}
+
+// GetLatestVersion gets the latest version of the secret from the data file
+func (d *fileDAO) GetLatestVersion(secretName string) (*SecretRecord, error) {
+	var record *SecretRecord
+	err := d.view(func(app *bolt.Bucket) error {
+		versions := app.Bucket(secretsBucketName).Bucket([]byte(secretName))
+		if versions == nil {
+			return nil
+		}
+		key, value := versions.Cursor().Last()
+		if key == nil {
+			return nil
+		}
+		var err error
+		record, err = decodeFileRecord(secretName, key, value)
+		return err
+	})
+	return record, err
+}
+
+// GetLatestActiveVersion gets the latest version of the secret from the data
+// file that is active and has not expired at the time given in seconds
+func (d *fileDAO) GetLatestActiveVersion(secretName string, now int64) (*SecretRecord, error) {
+	var record *SecretRecord
+	err := d.view(func(app *bolt.Bucket) error {
+		versions := app.Bucket(secretsBucketName).Bucket([]byte(secretName))
+		if versions == nil {
+			return nil
+		}
+		cursor := versions.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			candidate, err := decodeFileRecord(secretName, key, value)
+			if err != nil {
+				return err
+			}
+			if candidate.Active && (candidate.ExpiresAt == 0 || candidate.ExpiresAt > now) {
+				record = candidate
+				return nil
+			}
+		}
+		return nil
+	})
+	return record, err
+}
+
+// ListSecrets lists the latest version of every secret in the data file whose
+// name starts with the prefix, in the order of their names, one page at a
+// time. The records returned do not contain any encrypted data. The token
+// returned is the name of the last secret listed, and is empty when there are
+// no more pages
+func (d *fileDAO) ListSecrets(prefix string, nextToken string, limit int64) ([]*SecretRecord, string, error) {
+	var records []*SecretRecord
+	token := ""
+	err := d.view(func(app *bolt.Bucket) error {
+		secrets := app.Bucket(secretsBucketName)
+		cursor := secrets.Cursor()
+		name, _ := cursor.Seek([]byte(prefix))
+		if nextToken != "" {
+			name, _ = cursor.Seek([]byte(nextToken))
+			if name != nil && string(name) == nextToken {
+				name, _ = cursor.Next()
+			}
+		}
+		for ; name != nil && strings.HasPrefix(string(name), prefix); name, _ = cursor.Next() {
+			if limit > 0 && int64(len(records)) == limit {
+				token = string(records[len(records)-1].Name)
+				return nil
+			}
+			key, value := secrets.Bucket(name).Cursor().Last()
+			if key == nil {
+				continue
+			}
+			record, err := decodeFileRecord(string(name), key, value)
+			if err != nil {
+				return err
+			}
+			record.EncryptedData = ""
+			record.EncryptedDataKey = ""
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, token, err
+}
+
+// ListVersions lists every version of the secret in the data file, in the
+// order of their serials. The records returned do not contain any encrypted
+// data
+func (d *fileDAO) ListVersions(secretName string) ([]*SecretRecord, error) {
+	var records []*SecretRecord
+	err := d.view(func(app *bolt.Bucket) error {
+		versions := app.Bucket(secretsBucketName).Bucket([]byte(secretName))
+		if versions == nil {
+			return nil
+		}
+		return versions.ForEach(func(key []byte, value []byte) error {
+			record, err := decodeFileRecord(secretName, key, value)
+			if err != nil {
+				return err
+			}
+			record.EncryptedData = ""
+			record.EncryptedDataKey = ""
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// GetSecretLabels gets the metadata of the secret from the data file.
+// Metadata with no revision is returned if none has been put for the secret
+// yet
+func (d *fileDAO) GetSecretLabels(secretName string) (*SecretLabels, error) {
+	document := &metadataDocument{}
+	err := d.view(func(app *bolt.Bucket) error {
+		value := app.Bucket(metadataBucketName).Get([]byte(secretName))
+		if value == nil {
+			return nil
+		}
+		return json.Unmarshal(value, document)
+	})
+	if err != nil {
+		return nil, err
+	}
+	labels := document.Labels
+	if labels == nil {
+		labels = map[string]int64{}
+	}
+	return &SecretLabels{
+		Name:                 secretName,
+		Serial:               labelsSerial,
+		Labels:               labels,
+		RetentionMaxVersions: document.RetentionMaxVersions,
+		RetentionMaxAge:      document.RetentionMaxAge,
+		Revision:             document.Revision,
+	}, nil
+}
+
+// PutSecretLabels puts the metadata of the secret into the data file.
+// ErrSecretLabelsModified is returned if the metadata has been put by
+// someone else since it was read. The revision of the metadata is incremented
+// when it is put
+func (d *fileDAO) PutSecretLabels(metadata *SecretLabels) error {
+	document := &metadataDocument{
+		Labels:               metadata.Labels,
+		RetentionMaxVersions: metadata.RetentionMaxVersions,
+		RetentionMaxAge:      metadata.RetentionMaxAge,
+		Revision:             metadata.Revision + 1,
+	}
+	value, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	err = d.update(func(app *bolt.Bucket) error {
+		bucket := app.Bucket(metadataBucketName)
+		current := &metadataDocument{}
+		if currentValue := bucket.Get([]byte(metadata.Name)); currentValue != nil {
+			err := json.Unmarshal(currentValue, current)
+			if err != nil {
+				return err
+			}
+		}
+		if current.Revision != metadata.Revision {
+			return ErrSecretLabelsModified
+		}
+		return bucket.Put([]byte(metadata.Name), value)
+	})
+	if err != nil {
+		return err
+	}
+	metadata.Revision = document.Revision
+	return nil
+}
+
+// DeleteSecretLabels deletes the metadata of the secret, including all of its
+// labels, from the data file
+func (d *fileDAO) DeleteSecretLabels(secretName string) error {
+	return d.update(func(app *bolt.Bucket) error {
+		return app.Bucket(metadataBucketName).Delete([]byte(secretName))
+	})
+}
+
+// updateRecord changes a version of the secret with update. An error is
+// returned if the version does not exist
+func (d *fileDAO) updateRecord(secretName string, serial int64, update func(*SecretRecord)) error {
+	return d.update(func(app *bolt.Bucket) error {
+		record, err := getFileRecord(app, secretName, serial)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			return ErrSecretRecordNotFound
+		}
+		update(record)
+		value, err := json.Marshal(newVersionDocument(record))
+		if err != nil {
+			return err
+		}
+		return app.Bucket(secretsBucketName).Bucket([]byte(secretName)).Put(serialKey(serial), value)
+	})
+}
+
+// view reads the bucket of the application in a read-only transaction
+func (d *fileDAO) view(read func(*bolt.Bucket) error) error {
+	return d.db.View(func(tx *bolt.Tx) error {
+		return read(tx.Bucket([]byte(d.appName)))
+	})
+}
+
+// update changes the bucket of the application in a read-write transaction
+func (d *fileDAO) update(write func(*bolt.Bucket) error) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return write(tx.Bucket([]byte(d.appName)))
+	})
+}
+
+// openDataFile opens the data file, or returns the handle to it if it has
+// already been opened by the process
+func openDataFile(filename string) (*bolt.DB, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	openDataFilesLock.Lock()
+	defer openDataFilesLock.Unlock()
+
+	if db, ok := openDataFiles[path]; ok {
+		return db, nil
+	}
+	db, err := bolt.Open(path, fileMode, &bolt.Options{Timeout: fileLockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("Error opening data file '%s': %v", filename, err)
+	}
+	openDataFiles[path] = db
+	return db, nil
+}
+
+func createAppBucket(tx *bolt.Tx, appName string) error {
+	app, err := tx.CreateBucketIfNotExists([]byte(appName))
+	if err != nil {
+		return err
+	}
+	for _, name := range [][]byte{secretsBucketName, metadataBucketName} {
+		_, err = app.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getFileRecord gets a version of the secret, or nil if it does not exist
+func getFileRecord(app *bolt.Bucket, secretName string, serial int64) (*SecretRecord, error) {
+	versions := app.Bucket(secretsBucketName).Bucket([]byte(secretName))
+	if versions == nil || serial <= labelsSerial {
+		return nil, nil
+	}
+	key := serialKey(serial)
+	value := versions.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+	return decodeFileRecord(secretName, key, value)
+}
+
+func decodeFileRecord(secretName string, key []byte, value []byte) (*SecretRecord, error) {
+	document := &versionDocument{}
+	err := json.Unmarshal(value, document)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding secret record '%s': %v", secretName, err)
+	}
+	return document.secretRecord(secretName, int64(binary.BigEndian.Uint64(key))), nil
+}
+
+// serialKey returns the key of a version of a secret. Serials are encoded in
+// big endian, so that versions are sorted by their serials
+func serialKey(serial int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(serial))
+	return key
+}
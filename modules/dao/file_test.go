@@ -0,0 +1,326 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func newTestFileDAO(t *testing.T, appName string) (DAO, func()) {
+	dir, err := ioutil.TempDir("", "ecs-secrets")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	d, err := NewFileDAO(appName, filepath.Join(dir, "secrets.db"))
+	if err != nil {
+		t.Fatalf("Error creating file DAO: %v", err)
+	}
+	return d, func() { os.RemoveAll(dir) }
+}
+
+func putTestFileRecords(t *testing.T, d DAO, name string, serials ...int64) {
+	for _, serial := range serials {
+		err := d.PutSecretRecord(&SecretRecord{
+			Name:             name,
+			Serial:           serial,
+			EncryptedData:    "data-" + strconv.FormatInt(serial, 10),
+			EncryptedDataKey: "key",
+			Active:           true,
+			CreatedAt:        1500000000 + serial,
+		})
+		if err != nil {
+			t.Fatalf("Error putting secret record %s:%d: %v", name, serial, err)
+		}
+	}
+}
+
+func TestFilePutSecretRecord(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+
+	record := &SecretRecord{
+		Name:             "foo",
+		Serial:           1,
+		EncryptedData:    "data",
+		EncryptedDataKey: "key",
+		Active:           true,
+		CreatedAt:        1500000000,
+		CreatedBy:        "someone",
+		Tags:             map[string]string{"team": "payments"},
+	}
+	err := d.PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+	err = d.PutSecretRecord(record)
+	if err != ErrSecretRecordExists {
+		t.Errorf("Expected ErrSecretRecordExists, got: %v", err)
+	}
+
+	stored, err := d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if !reflect.DeepEqual(stored, record) {
+		t.Errorf("Mismatch between put and stored records. Expected: %v, got: %v", record, stored)
+	}
+	_, err = d.GetSecretRecord("foo", 2)
+	if err == nil {
+		t.Error("Expected error getting a missing secret record")
+	}
+}
+
+func TestFileApplicationsAreIsolated(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	putTestFileRecords(t, d, "foo", 1)
+
+	other, err := NewFileDAO("otherapp", d.(*fileDAO).filename)
+	if err != nil {
+		t.Fatalf("Error creating file DAO: %v", err)
+	}
+	if other.(*fileDAO).db != d.(*fileDAO).db {
+		t.Error("Expected the data file to be opened once")
+	}
+	latest, err := other.GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	if latest != nil {
+		t.Errorf("Expected no secret for another application, got: %v", latest)
+	}
+}
+
+func TestFileGetSecretRecords(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	putTestFileRecords(t, d, "foo", 1, 2)
+	putTestFileRecords(t, d, "bar", 1)
+
+	records, err := d.GetSecretRecords([]SecretKey{
+		{Name: "foo", Serial: 2},
+		{Name: "bar", Serial: 1},
+		{Name: "foo", Serial: 2},
+		{Name: "foo", Serial: 5},
+		{Name: "baz", Serial: 1},
+	})
+	if err != nil {
+		t.Fatalf("Error getting secret records: %v", err)
+	}
+	if len(records) != 2 || records[0].Name != "foo" || records[1].Name != "bar" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
+
+func TestFileRevokeAndRestoreSecretRecord(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	putTestFileRecords(t, d, "foo", 1, 2)
+
+	err := d.RevokeSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+	active, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active == nil || active.Serial != 1 {
+		t.Errorf("Expected version 1 to be the latest active version, got: %v", active)
+	}
+
+	err = d.RestoreSecretRecord("foo", 2, &Restoration{RestoredAt: 1600000000, RestoredBy: "someone", Reason: "mistake"})
+	if err != nil {
+		t.Fatalf("Error restoring secret record: %v", err)
+	}
+	restored, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if restored == nil || restored.Serial != 2 || restored.RestoredBy != "someone" || restored.RestoreReason != "mistake" {
+		t.Errorf("Expected version 2 to be restored, got: %v", restored)
+	}
+
+	err = d.RevokeSecretRecord("foo", 3)
+	if err == nil {
+		t.Error("Expected error revoking a missing secret record")
+	}
+}
+
+func TestFileGetLatestActiveVersionSkipsExpired(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	putTestFileRecords(t, d, "foo", 1)
+	err := d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: 2, Active: true, ExpiresAt: 1600000000})
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+
+	active, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active == nil || active.Serial != 1 {
+		t.Errorf("Expected version 1 to be the latest active version, got: %v", active)
+	}
+}
+
+func TestFileScheduleAndCancelSecretRecordDeletion(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	putTestFileRecords(t, d, "foo", 1)
+
+	err := d.ScheduleSecretRecordDeletion("foo", 1, &Deletion{DeletedAt: 1600000000, DeletedBy: "someone", PurgeAt: 1602592000})
+	if err != nil {
+		t.Fatalf("Error scheduling deletion: %v", err)
+	}
+	record, err := d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if record.PurgeAt != 1602592000 || record.DeletedBy != "someone" {
+		t.Errorf("Expected deletion to be scheduled, got: %v", record)
+	}
+
+	err = d.CancelSecretRecordDeletion("foo", 1)
+	if err != nil {
+		t.Fatalf("Error cancelling deletion: %v", err)
+	}
+	record, err = d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if record.PurgeAt != 0 || record.DeletedAt != 0 || record.DeletedBy != "" {
+		t.Errorf("Expected deletion to be cancelled, got: %v", record)
+	}
+}
+
+func TestFileDeleteSecretRecord(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	putTestFileRecords(t, d, "foo", 1, 2)
+
+	err := d.DeleteSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(versions), []int64{1}) {
+		t.Errorf("Unexpected versions after delete: %v", serialsOf(versions))
+	}
+
+	err = d.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	records, _, err := d.ListSecrets("", "", 0)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no secrets after deleting every version, got: %v", records)
+	}
+}
+
+func TestFileListSecrets(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+	for _, name := range []string{"db/password", "db/user", "api-key", "dbx", "token"} {
+		putTestFileRecords(t, d, name, 1, 2)
+	}
+
+	var listed []string
+	token := ""
+	pages := 0
+	for {
+		records, nextToken, err := d.ListSecrets("db", token, 2)
+		if err != nil {
+			t.Fatalf("Error listing secrets: %v", err)
+		}
+		for _, record := range records {
+			if record.Serial != 2 || record.EncryptedData != "" {
+				t.Errorf("Expected summary of the latest version, got: %v", record)
+			}
+			listed = append(listed, record.Name)
+		}
+		pages++
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	if !reflect.DeepEqual(listed, []string{"db/password", "db/user", "dbx"}) {
+		t.Errorf("Unexpected secrets listed: %v", listed)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages, got %d", pages)
+	}
+}
+
+func TestFileSecretLabels(t *testing.T) {
+	d, cleanup := newTestFileDAO(t, "myapp")
+	defer cleanup()
+
+	metadata, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if metadata.Revision != 0 || len(metadata.Labels) != 0 {
+		t.Errorf("Expected empty metadata, got: %v", metadata)
+	}
+
+	metadata.Labels["prod"] = 1
+	metadata.RetentionMaxVersions = 3
+	err = d.PutSecretLabels(metadata)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	if metadata.Revision != 1 {
+		t.Errorf("Expected revision to be incremented, got: %d", metadata.Revision)
+	}
+
+	stale := &SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 2}}
+	err = d.PutSecretLabels(stale)
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+
+	stored, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if !reflect.DeepEqual(stored, metadata) {
+		t.Errorf("Mismatch between put and stored metadata. Expected: %v, got: %v", metadata, stored)
+	}
+
+	err = d.DeleteSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error deleting secret metadata: %v", err)
+	}
+	deleted, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if deleted.Revision != 0 {
+		t.Errorf("Expected metadata to be deleted, got: %v", deleted)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryDAO implements the DAO interface by keeping secrets in memory. The
+// secrets are lost when the process exits, so it is only meant for
+// development and tests. Records are copied on the way in and out, so that
+// callers can't change what is stored by changing the records they hold
+type memoryDAO struct {
+	sync.Mutex
+	appName  string
+	versions map[string]map[int64]*SecretRecord
+	metadata map[string]*SecretLabels
+}
+
+// NewMemoryDAO creates a new DAO object that keeps secrets in memory
+func NewMemoryDAO(appName string) DAO {
+	return &memoryDAO{
+		appName:  appName,
+		versions: make(map[string]map[int64]*SecretRecord),
+		metadata: make(map[string]*SecretLabels),
+	}
+}
+
+// GetSecretRecord gets a version of a secret
+func (d *memoryDAO) GetSecretRecord(namespace string, serial int64) (*SecretRecord, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	record, ok := d.versions[namespace][serial]
+	if !ok {
+		return nil, ErrSecretRecordNotFound
+	}
+	return copyRecord(record), nil
+}
+
+// GetSecretRecords gets versions of secrets. Versions that don't exist are
+// left out
+func (d *memoryDAO) GetSecretRecords(keys []SecretKey) ([]*SecretRecord, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var records []*SecretRecord
+	seen := make(map[SecretKey]bool)
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if record, ok := d.versions[key.Name][key.Serial]; ok {
+			records = append(records, copyRecord(record))
+		}
+	}
+	return records, nil
+}
+
+// PutSecretRecord puts a version of a secret. Existing versions are never
+// overwritten, ErrSecretRecordExists is returned if a version with the same
+// serial already exists
+func (d *memoryDAO) PutSecretRecord(record *SecretRecord) error {
+	d.Lock()
+	defer d.Unlock()
+
+	versions, ok := d.versions[record.Name]
+	if !ok {
+		versions = make(map[int64]*SecretRecord)
+		d.versions[record.Name] = versions
+	}
+	if _, ok := versions[record.Serial]; ok {
+		return ErrSecretRecordExists
+	}
+	versions[record.Serial] = copyRecord(record)
+	return nil
+}
+
+// RevokeSecretRecord revokes a version of a secret
+func (d *memoryDAO) RevokeSecretRecord(namespace string, serial int64) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.Active = false
+	})
+}
+
+// RestoreSecretRecord reinstates a revoked version of a secret, recording who
+// restored it, when and why
+func (d *memoryDAO) RestoreSecretRecord(namespace string, serial int64, restoration *Restoration) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.Active = true
+		record.RestoredAt = restoration.RestoredAt
+		record.RestoredBy = restoration.RestoredBy
+		record.RestoreReason = restoration.Reason
+	})
+}
+
+// ScheduleSecretRecordDeletion marks a version of a secret as scheduled for
+// deletion
+func (d *memoryDAO) ScheduleSecretRecordDeletion(namespace string, serial int64, deletion *Deletion) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.DeletedAt = deletion.DeletedAt
+		record.DeletedBy = deletion.DeletedBy
+		record.PurgeAt = deletion.PurgeAt
+	})
+}
+
+// CancelSecretRecordDeletion removes the scheduled deletion of a version of a
+// secret
+func (d *memoryDAO) CancelSecretRecordDeletion(namespace string, serial int64) error {
+	return d.updateRecord(namespace, serial, func(record *SecretRecord) {
+		record.DeletedAt = 0
+		record.DeletedBy = ""
+		record.PurgeAt = 0
+	})
+}
+
+// DeleteSecretRecord deletes a version of a secret
+func (d *memoryDAO) DeleteSecretRecord(namespace string, serial int64) error {
+	d.Lock()
+	defer d.Unlock()
+
+	versions, ok := d.versions[namespace]
+	if !ok {
+		return nil
+	}
+	delete(versions, serial)
+	if len(versions) == 0 {
+		delete(d.versions, namespace)
+	}
+	return nil
+}
+
+// GetLatestVersion gets the latest version of the secret
+func (d *memoryDAO) GetLatestVersion(secretName string) (*SecretRecord, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	records := d.sortedVersions(secretName)
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return copyRecord(records[len(records)-1]), nil
+}
+
+// GetLatestActiveVersion gets the latest version of the secret that is active
+// and has not expired at the time given in seconds
+func (d *memoryDAO) GetLatestActiveVersion(secretName string, now int64) (*SecretRecord, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	records := d.sortedVersions(secretName)
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Active && (record.ExpiresAt == 0 || record.ExpiresAt > now) {
+			return copyRecord(record), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListSecrets lists the latest version of every secret whose name starts with
+// the prefix, in the order of their names, one page at a time. The records
+// returned do not contain any encrypted data. The token returned is the name
+// of the last secret listed, and is empty when there are no more pages
+func (d *memoryDAO) ListSecrets(prefix string, nextToken string, limit int64) ([]*SecretRecord, string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var names []string
+	for name := range d.versions {
+		if strings.HasPrefix(name, prefix) && name > nextToken {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	token := ""
+	if limit > 0 && int64(len(names)) > limit {
+		names = names[:limit]
+		token = names[len(names)-1]
+	}
+	records := make([]*SecretRecord, 0, len(names))
+	for _, name := range names {
+		versions := d.sortedVersions(name)
+		records = append(records, summaryRecord(versions[len(versions)-1]))
+	}
+	return records, token, nil
+}
+
+// ListVersions lists every version of the secret, in the order of their
+// serials. The records returned do not contain any encrypted data
+func (d *memoryDAO) ListVersions(secretName string) ([]*SecretRecord, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	var records []*SecretRecord
+	for _, record := range d.sortedVersions(secretName) {
+		records = append(records, summaryRecord(record))
+	}
+	return records, nil
+}
+
+// GetSecretLabels gets the metadata of the secret. Metadata with no revision
+// is returned if none has been put for the secret yet
+func (d *memoryDAO) GetSecretLabels(secretName string) (*SecretLabels, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	metadata, ok := d.metadata[secretName]
+	if !ok {
+		return &SecretLabels{
+			Name:   secretName,
+			Serial: labelsSerial,
+			Labels: map[string]int64{},
+		}, nil
+	}
+	return copyMetadata(metadata), nil
+}
+
+// PutSecretLabels puts the metadata of the secret. ErrSecretLabelsModified
+// is returned if the metadata has been put by someone else since it was read.
+// The revision of the metadata is incremented when it is put
+func (d *memoryDAO) PutSecretLabels(metadata *SecretLabels) error {
+	d.Lock()
+	defer d.Unlock()
+
+	revision := int64(0)
+	if current, ok := d.metadata[metadata.Name]; ok {
+		revision = current.Revision
+	}
+	if revision != metadata.Revision {
+		return ErrSecretLabelsModified
+	}
+	metadata.Revision++
+	d.metadata[metadata.Name] = copyMetadata(metadata)
+	return nil
+}
+
+// DeleteSecretLabels deletes the metadata of the secret, including all of its
+// labels
+func (d *memoryDAO) DeleteSecretLabels(secretName string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	delete(d.metadata, secretName)
+	return nil
+}
+
+// updateRecord changes a version of the secret with update. An error is
+// returned if the version does not exist
+func (d *memoryDAO) updateRecord(secretName string, serial int64, update func(*SecretRecord)) error {
+	d.Lock()
+	defer d.Unlock()
+
+	record, ok := d.versions[secretName][serial]
+	if !ok {
+		return ErrSecretRecordNotFound
+	}
+	update(record)
+	return nil
+}
+
+// sortedVersions returns the versions of the secret in the order of their
+// serials. It must be called with the lock held
+func (d *memoryDAO) sortedVersions(secretName string) []*SecretRecord {
+	records := make([]*SecretRecord, 0, len(d.versions[secretName]))
+	for _, record := range d.versions[secretName] {
+		records = append(records, record)
+	}
+	sort.Sort(recordsBySerial(records))
+	return records
+}
+
+func copyRecord(record *SecretRecord) *SecretRecord {
+	copied := *record
+	if record.Tags != nil {
+		copied.Tags = make(map[string]string, len(record.Tags))
+		for key, value := range record.Tags {
+			copied.Tags[key] = value
+		}
+	}
+	return &copied
+}
+
+// summaryRecord returns a copy of the record without its encrypted data
+func summaryRecord(record *SecretRecord) *SecretRecord {
+	summary := copyRecord(record)
+	summary.EncryptedData = ""
+	summary.EncryptedDataKey = ""
+	return summary
+}
+
+func copyMetadata(metadata *SecretLabels) *SecretLabels {
+	copied := *metadata
+	copied.Labels = make(map[string]int64, len(metadata.Labels))
+	for label, serial := range metadata.Labels {
+		copied.Labels[label] = serial
+	}
+	return &copied
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func putTestMemoryRecords(t *testing.T, d DAO, name string, serials ...int64) {
+	for _, serial := range serials {
+		err := d.PutSecretRecord(&SecretRecord{
+			Name:             name,
+			Serial:           serial,
+			EncryptedData:    "data-" + strconv.FormatInt(serial, 10),
+			EncryptedDataKey: "key",
+			Active:           true,
+			CreatedAt:        1500000000 + serial,
+		})
+		if err != nil {
+			t.Fatalf("Error putting secret record %s:%d: %v", name, serial, err)
+		}
+	}
+}
+
+func TestMemoryPutSecretRecord(t *testing.T) {
+	d := NewMemoryDAO("myapp")
+	record := &SecretRecord{
+		Name:          "foo",
+		Serial:        1,
+		EncryptedData: "data",
+		Active:        true,
+		Tags:          map[string]string{"team": "payments"},
+	}
+	err := d.PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+	err = d.PutSecretRecord(record)
+	if err != ErrSecretRecordExists {
+		t.Errorf("Expected ErrSecretRecordExists, got: %v", err)
+	}
+
+	// Changing the record that was put must not change the stored record
+	record.Tags["team"] = "billing"
+	stored, err := d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if stored.Tags["team"] != "payments" {
+		t.Errorf("Expected stored record to be unchanged, got: %v", stored)
+	}
+}
+
+func TestMemoryGetLatestActiveVersion(t *testing.T) {
+	d := NewMemoryDAO("myapp")
+	putTestMemoryRecords(t, d, "foo", 1, 2, 3)
+	err := d.RevokeSecretRecord("foo", 3)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+
+	latest, err := d.GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	if latest.Serial != 3 {
+		t.Errorf("Expected version 3 to be the latest version, got: %v", latest)
+	}
+	active, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active.Serial != 2 {
+		t.Errorf("Expected version 2 to be the latest active version, got: %v", active)
+	}
+}
+
+func TestMemoryListSecrets(t *testing.T) {
+	d := NewMemoryDAO("myapp")
+	for _, name := range []string{"db/password", "db/user", "api-key", "dbx"} {
+		putTestMemoryRecords(t, d, name, 1, 2)
+	}
+
+	records, token, err := d.ListSecrets("db", "", 2)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if token != "db/user" || len(records) != 2 || records[0].EncryptedData != "" {
+		t.Errorf("Unexpected first page: %v, token: %s", records, token)
+	}
+	records, token, err = d.ListSecrets("db", token, 2)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if token != "" || len(records) != 1 || records[0].Name != "dbx" || records[0].Serial != 2 {
+		t.Errorf("Unexpected last page: %v, token: %s", records, token)
+	}
+}
+
+func TestMemorySecretLabels(t *testing.T) {
+	d := NewMemoryDAO("myapp")
+	metadata, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	metadata.Labels["prod"] = 1
+	err = d.PutSecretLabels(metadata)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	err = d.PutSecretLabels(&SecretLabels{Name: "foo"})
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+
+	stored, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if !reflect.DeepEqual(stored, metadata) {
+		t.Errorf("Mismatch between put and stored metadata. Expected: %v, got: %v", metadata, stored)
+	}
+}
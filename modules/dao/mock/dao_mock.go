@@ -41,6 +41,47 @@ func (_m *MockDAO) EXPECT() *_MockDAORecorder {
 	return _m.recorder
 }
 
+func (_m *MockDAO) CancelSecretRecordDeletion(_param0 string, _param1 int64) error {
+	ret := _m.ctrl.Call(_m, "CancelSecretRecordDeletion", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDAORecorder) CancelSecretRecordDeletion(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CancelSecretRecordDeletion", arg0, arg1)
+}
+
+func (_m *MockDAO) DeleteSecretLabels(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteSecretLabels", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDAORecorder) DeleteSecretLabels(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteSecretLabels", arg0)
+}
+
+func (_m *MockDAO) DeleteSecretRecord(_param0 string, _param1 int64) error {
+	ret := _m.ctrl.Call(_m, "DeleteSecretRecord", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDAORecorder) DeleteSecretRecord(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteSecretRecord", arg0, arg1)
+}
+
+func (_m *MockDAO) GetLatestActiveVersion(_param0 string, _param1 int64) (*dao.SecretRecord, error) {
+	ret := _m.ctrl.Call(_m, "GetLatestActiveVersion", _param0, _param1)
+	ret0, _ := ret[0].(*dao.SecretRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDAORecorder) GetLatestActiveVersion(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLatestActiveVersion", arg0, arg1)
+}
+
 func (_m *MockDAO) GetLatestVersion(_param0 string) (*dao.SecretRecord, error) {
 	ret := _m.ctrl.Call(_m, "GetLatestVersion", _param0)
 	ret0, _ := ret[0].(*dao.SecretRecord)
@@ -52,6 +93,17 @@ func (_mr *_MockDAORecorder) GetLatestVersion(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLatestVersion", arg0)
 }
 
+func (_m *MockDAO) GetSecretLabels(_param0 string) (*dao.SecretLabels, error) {
+	ret := _m.ctrl.Call(_m, "GetSecretLabels", _param0)
+	ret0, _ := ret[0].(*dao.SecretLabels)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDAORecorder) GetSecretLabels(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecretLabels", arg0)
+}
+
 func (_m *MockDAO) GetSecretRecord(_param0 string, _param1 int64) (*dao.SecretRecord, error) {
 	ret := _m.ctrl.Call(_m, "GetSecretRecord", _param0, _param1)
 	ret0, _ := ret[0].(*dao.SecretRecord)
@@ -63,6 +115,50 @@ func (_mr *_MockDAORecorder) GetSecretRecord(arg0, arg1 interface{}) *gomock.Cal
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecretRecord", arg0, arg1)
 }
 
+func (_m *MockDAO) GetSecretRecords(_param0 []dao.SecretKey) ([]*dao.SecretRecord, error) {
+	ret := _m.ctrl.Call(_m, "GetSecretRecords", _param0)
+	ret0, _ := ret[0].([]*dao.SecretRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDAORecorder) GetSecretRecords(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecretRecords", arg0)
+}
+
+func (_m *MockDAO) ListSecrets(_param0 string, _param1 string, _param2 int64) ([]*dao.SecretRecord, string, error) {
+	ret := _m.ctrl.Call(_m, "ListSecrets", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]*dao.SecretRecord)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+func (_mr *_MockDAORecorder) ListSecrets(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListSecrets", arg0, arg1, arg2)
+}
+
+func (_m *MockDAO) ListVersions(_param0 string) ([]*dao.SecretRecord, error) {
+	ret := _m.ctrl.Call(_m, "ListVersions", _param0)
+	ret0, _ := ret[0].([]*dao.SecretRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDAORecorder) ListVersions(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListVersions", arg0)
+}
+
+func (_m *MockDAO) PutSecretLabels(_param0 *dao.SecretLabels) error {
+	ret := _m.ctrl.Call(_m, "PutSecretLabels", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDAORecorder) PutSecretLabels(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PutSecretLabels", arg0)
+}
+
 func (_m *MockDAO) PutSecretRecord(_param0 *dao.SecretRecord) error {
 	ret := _m.ctrl.Call(_m, "PutSecretRecord", _param0)
 	ret0, _ := ret[0].(error)
@@ -73,6 +169,16 @@ func (_mr *_MockDAORecorder) PutSecretRecord(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "PutSecretRecord", arg0)
 }
 
+func (_m *MockDAO) RestoreSecretRecord(_param0 string, _param1 int64, _param2 *dao.Restoration) error {
+	ret := _m.ctrl.Call(_m, "RestoreSecretRecord", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDAORecorder) RestoreSecretRecord(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RestoreSecretRecord", arg0, arg1, arg2)
+}
+
 func (_m *MockDAO) RevokeSecretRecord(_param0 string, _param1 int64) error {
 	ret := _m.ctrl.Call(_m, "RevokeSecretRecord", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -82,3 +188,13 @@ func (_m *MockDAO) RevokeSecretRecord(_param0 string, _param1 int64) error {
 func (_mr *_MockDAORecorder) RevokeSecretRecord(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RevokeSecretRecord", arg0, arg1)
 }
+
+func (_m *MockDAO) ScheduleSecretRecordDeletion(_param0 string, _param1 int64, _param2 *dao.Deletion) error {
+	ret := _m.ctrl.Call(_m, "ScheduleSecretRecordDeletion", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDAORecorder) ScheduleSecretRecordDeletion(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScheduleSecretRecordDeletion", arg0, arg1, arg2)
+}
@@ -0,0 +1,631 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	s3client "github.com/awslabs/ecs-secrets/modules/s3/client"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Secrets are stored in a versioned S3 bucket, under a prefix that is specific
+// to the application. Every version of a secret is a version of the object
+// named after the secret. The versions of each secret are indexed in a
+// manifest object, which maps serials to object versions and holds the state
+// of every version along with the labels and retention policy of the secret.
+// Secrets are listed and their latest versions are found by reading manifests
+// alone
+const (
+	// DefaultS3KeyPrefix is the prefix of the keys of the objects of every
+	// application, unless another one is used
+	DefaultS3KeyPrefix = "ecs-secrets/"
+	// maxS3ManifestAttempts is the number of times the manifest of a secret
+	// is read and put again when it is modified by someone else at the same
+	// time
+	maxS3ManifestAttempts = 3
+	// s3ManifestVersionsChecked is the number of versions of a manifest that
+	// are listed after putting it, to find the version put before it
+	s3ManifestVersionsChecked = int64(10)
+)
+
+// errManifestUnchanged is returned by updates of a manifest that leave it as
+// it is, so that it is not put again
+var errManifestUnchanged = errors.New("Manifest unchanged")
+
+// s3ManifestEntry defines a version of a secret in its manifest. The
+// encrypted data of the version is only stored in its object version
+type s3ManifestEntry struct {
+	Serial    int64  `json:"serial"`
+	VersionID string `json:"versionId"`
+	versionDocument
+}
+
+// s3Manifest defines the value of the manifest object of a secret. Versions
+// are kept in the order of their serials
+type s3Manifest struct {
+	Labels               map[string]int64   `json:"labels,omitempty"`
+	RetentionMaxVersions int64              `json:"retentionMaxVersions,omitempty"`
+	RetentionMaxAge      int64              `json:"retentionMaxAge,omitempty"`
+	Revision             int64              `json:"revision"`
+	Versions             []*s3ManifestEntry `json:"versions,omitempty"`
+}
+
+type s3DAO struct {
+	appName   string
+	s3Client  s3client.Client
+	bucket    string
+	keyPrefix string
+}
+
+// NewS3DAO creates a new DAO object backed by a versioned S3 bucket. The keys
+// of the objects of the application start with the key prefix followed by
+// the name of the application
+func NewS3DAO(appName string, s3Client s3client.Client, bucket string, keyPrefix string) DAO {
+	return &s3DAO{
+		appName:   appName,
+		s3Client:  s3Client,
+		bucket:    bucket,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// GetSecretRecord gets a version of a secret from S3
+func (d *s3DAO) GetSecretRecord(namespace string, serial int64) (*SecretRecord, error) {
+	manifest, _, err := d.getManifest(namespace)
+	if err != nil {
+		return nil, err
+	}
+	entry := manifest.entry(serial)
+	if entry == nil {
+		return nil, ErrSecretRecordNotFound
+	}
+	return d.getVersion(namespace, entry)
+}
+
+// GetSecretRecords gets versions of secrets from S3, reading the manifest of
+// every secret once. Versions that don't exist are left out
+func (d *s3DAO) GetSecretRecords(keys []SecretKey) ([]*SecretRecord, error) {
+	manifests := make(map[string]*s3Manifest)
+	seen := make(map[SecretKey]bool)
+	var records []*SecretRecord
+	for _, key := range keys {
+		if seen[key] || key.Serial <= labelsSerial {
+			continue
+		}
+		seen[key] = true
+
+		manifest, ok := manifests[key.Name]
+		if !ok {
+			var err error
+			manifest, _, err = d.getManifest(key.Name)
+			if err != nil {
+				return nil, err
+			}
+			manifests[key.Name] = manifest
+		}
+		entry := manifest.entry(key.Serial)
+		if entry == nil {
+			continue
+		}
+		record, err := d.getVersion(key.Name, entry)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// PutSecretRecord puts a version of a secret into S3 and adds it to the
+// manifest of the secret. ErrSecretRecordExists is returned if the manifest
+// already has a version with the same serial, in which case the object
+// version put is deleted again
+func (d *s3DAO) PutSecretRecord(record *SecretRecord) error {
+	versioning, err := d.s3Client.GetBucketVersioning(&s3.GetBucketVersioningInput{
+		Bucket: aws.String(d.bucket),
+	})
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(versioning.Status) != s3.BucketVersioningStatusEnabled {
+		return fmt.Errorf("Versioning is not enabled on bucket '%s'", d.bucket)
+	}
+
+	document := newVersionDocument(record)
+	body, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	key := d.secretKey(record.Name)
+	result, err := d.s3Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return err
+	}
+
+	entry := &s3ManifestEntry{
+		Serial:          record.Serial,
+		VersionID:       aws.StringValue(result.VersionId),
+		versionDocument: *document,
+	}
+	entry.EncryptedData = ""
+	entry.EncryptedDataKey = ""
+	err = d.updateManifest(record.Name, func(manifest *s3Manifest) error {
+		if existing := manifest.entry(record.Serial); existing != nil {
+			// A manifest put by an earlier attempt that lost a race may
+			// have been built on by someone else before it was deleted,
+			// in which case the version is already in the manifest
+			if existing.VersionID == entry.VersionID {
+				return errManifestUnchanged
+			}
+			return ErrSecretRecordExists
+		}
+		manifest.Versions = append(manifest.Versions, entry)
+		sort.Sort(manifestEntriesBySerial(manifest.Versions))
+		return nil
+	})
+	if err == ErrSecretRecordExists {
+		// The object version is not in the manifest, so nothing would
+		// ever delete it
+		d.deleteObjectVersion(key, entry.VersionID)
+		return err
+	}
+	if err != nil {
+		// The object version is only deleted if the manifest is known not
+		// to point at it, for the same reason
+		manifest, _, getErr := d.getManifest(record.Name)
+		if getErr != nil {
+			return err
+		}
+		if existing := manifest.entry(record.Serial); existing != nil && existing.VersionID == entry.VersionID {
+			return nil
+		}
+		d.deleteObjectVersion(key, entry.VersionID)
+		return err
+	}
+	return nil
+}
+
+// RevokeSecretRecord revokes a version of a secret in S3
+func (d *s3DAO) RevokeSecretRecord(namespace string, serial int64) error {
+	return d.updateEntry(namespace, serial, func(entry *s3ManifestEntry) {
+		entry.Active = false
+	})
+}
+
+// RestoreSecretRecord reinstates a revoked version of a secret in S3,
+// recording who restored it, when and why
+func (d *s3DAO) RestoreSecretRecord(namespace string, serial int64, restoration *Restoration) error {
+	return d.updateEntry(namespace, serial, func(entry *s3ManifestEntry) {
+		entry.Active = true
+		entry.RestoredAt = restoration.RestoredAt
+		entry.RestoredBy = restoration.RestoredBy
+		entry.RestoreReason = restoration.Reason
+	})
+}
+
+// ScheduleSecretRecordDeletion marks a version of a secret in S3 as scheduled
+// for deletion
+func (d *s3DAO) ScheduleSecretRecordDeletion(namespace string, serial int64, deletion *Deletion) error {
+	return d.updateEntry(namespace, serial, func(entry *s3ManifestEntry) {
+		entry.DeletedAt = deletion.DeletedAt
+		entry.DeletedBy = deletion.DeletedBy
+		entry.PurgeAt = deletion.PurgeAt
+	})
+}
+
+// CancelSecretRecordDeletion removes the scheduled deletion of a version of a
+// secret in S3
+func (d *s3DAO) CancelSecretRecordDeletion(namespace string, serial int64) error {
+	return d.updateEntry(namespace, serial, func(entry *s3ManifestEntry) {
+		entry.DeletedAt = 0
+		entry.DeletedBy = ""
+		entry.PurgeAt = 0
+	})
+}
+
+// DeleteSecretRecord removes a version of a secret from its manifest and
+// permanently deletes its object version from S3
+func (d *s3DAO) DeleteSecretRecord(namespace string, serial int64) error {
+	versionID := ""
+	err := d.updateManifest(namespace, func(manifest *s3Manifest) error {
+		for i, entry := range manifest.Versions {
+			if entry.Serial == serial {
+				versionID = entry.VersionID
+				manifest.Versions = append(manifest.Versions[:i], manifest.Versions[i+1:]...)
+				return nil
+			}
+		}
+		return errManifestUnchanged
+	})
+	if err != nil || versionID == "" {
+		return err
+	}
+	return d.deleteObjectVersion(d.secretKey(namespace), versionID)
+}
+
+// GetLatestVersion gets the latest version of the secret from S3
+func (d *s3DAO) GetLatestVersion(secretName string) (*SecretRecord, error) {
+	manifest, _, err := d.getManifest(secretName)
+	if err != nil || len(manifest.Versions) == 0 {
+		return nil, err
+	}
+	return d.getVersion(secretName, manifest.Versions[len(manifest.Versions)-1])
+}
+
+// GetLatestActiveVersion gets the latest version of the secret from S3 that
+// is active and has not expired at the time given in seconds. Only the object
+// version of that version is read
+func (d *s3DAO) GetLatestActiveVersion(secretName string, now int64) (*SecretRecord, error) {
+	manifest, _, err := d.getManifest(secretName)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(manifest.Versions) - 1; i >= 0; i-- {
+		entry := manifest.Versions[i]
+		if entry.Active && (entry.ExpiresAt == 0 || entry.ExpiresAt > now) {
+			return d.getVersion(secretName, entry)
+		}
+	}
+	return nil, nil
+}
+
+// ListSecrets lists the latest version of every secret in S3 whose name
+// starts with the prefix, one page at a time. The records returned do not
+// contain any encrypted data. The token returned can be used to fetch the next
+// page of results and is empty when there are no more pages
+func (d *s3DAO) ListSecrets(prefix string, nextToken string, limit int64) ([]*SecretRecord, string, error) {
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(d.bucket),
+		Prefix: aws.String(d.manifestPrefix() + prefix),
+	}
+	if limit > 0 {
+		input.MaxKeys = aws.Int64(limit)
+	}
+	if nextToken != "" {
+		input.Marker = aws.String(nextToken)
+	}
+	result, err := d.s3Client.ListObjects(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var records []*SecretRecord
+	for _, object := range result.Contents {
+		name := strings.TrimPrefix(aws.StringValue(object.Key), d.manifestPrefix())
+		manifest, _, err := d.getManifest(name)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(manifest.Versions) > 0 {
+			records = append(records, manifest.Versions[len(manifest.Versions)-1].secretRecord(name))
+		}
+	}
+
+	token := ""
+	if aws.BoolValue(result.IsTruncated) && len(result.Contents) > 0 {
+		token = aws.StringValue(result.Contents[len(result.Contents)-1].Key)
+	}
+	return records, token, nil
+}
+
+// ListVersions lists every version of the secret in S3, in the order of their
+// serials. The records returned do not contain any encrypted data
+func (d *s3DAO) ListVersions(secretName string) ([]*SecretRecord, error) {
+	manifest, _, err := d.getManifest(secretName)
+	if err != nil {
+		return nil, err
+	}
+	var records []*SecretRecord
+	for _, entry := range manifest.Versions {
+		records = append(records, entry.secretRecord(secretName))
+	}
+	return records, nil
+}
+
+// GetSecretLabels gets the metadata of the secret from its manifest in S3.
+// Metadata with no revision is returned if there is no manifest for the
+// secret yet
+func (d *s3DAO) GetSecretLabels(secretName string) (*SecretLabels, error) {
+	manifest, _, err := d.getManifest(secretName)
+	if err != nil {
+		return nil, err
+	}
+	labels := manifest.Labels
+	if labels == nil {
+		labels = map[string]int64{}
+	}
+	return &SecretLabels{
+		Name:                 secretName,
+		Serial:               labelsSerial,
+		Labels:               labels,
+		RetentionMaxVersions: manifest.RetentionMaxVersions,
+		RetentionMaxAge:      manifest.RetentionMaxAge,
+		Revision:             manifest.Revision,
+	}, nil
+}
+
+// PutSecretLabels puts the metadata of the secret into its manifest in S3.
+// ErrSecretLabelsModified is returned if the manifest has been put by
+// someone else since the metadata was read. The revision of the metadata is
+// incremented when it is put
+func (d *s3DAO) PutSecretLabels(metadata *SecretLabels) error {
+	manifest, versionID, err := d.getManifest(metadata.Name)
+	if err != nil {
+		return err
+	}
+	if manifest.Revision != metadata.Revision {
+		return ErrSecretLabelsModified
+	}
+
+	manifest.Labels = metadata.Labels
+	manifest.RetentionMaxVersions = metadata.RetentionMaxVersions
+	manifest.RetentionMaxAge = metadata.RetentionMaxAge
+	err = d.putManifest(metadata.Name, manifest, versionID)
+	if err != nil {
+		return err
+	}
+	metadata.Revision = manifest.Revision
+	return nil
+}
+
+// DeleteSecretLabels deletes the labels and retention policy of the secret
+// from its manifest in S3
+func (d *s3DAO) DeleteSecretLabels(secretName string) error {
+	return d.updateManifest(secretName, func(manifest *s3Manifest) error {
+		manifest.Labels = nil
+		manifest.RetentionMaxVersions = 0
+		manifest.RetentionMaxAge = 0
+		return nil
+	})
+}
+
+// secretKey returns the key of the object that holds the versions of the
+// secret
+func (d *s3DAO) secretKey(secretName string) string {
+	return d.keyPrefix + d.appName + "/secrets/" + secretName
+}
+
+// manifestPrefix returns the prefix of the keys of the manifests of every
+// secret
+func (d *s3DAO) manifestPrefix() string {
+	return d.keyPrefix + d.appName + "/manifests/"
+}
+
+// manifestKey returns the key of the manifest of the secret. The manifest of
+// the application is kept apart, so that it is never listed as a secret
+func (d *s3DAO) manifestKey(secretName string) string {
+	if secretName == ApplicationMetadataName {
+		return d.keyPrefix + d.appName + "/application"
+	}
+	return d.manifestPrefix() + secretName
+}
+
+// getVersion reads the encrypted data of the version of the secret from its
+// object version
+func (d *s3DAO) getVersion(secretName string, entry *s3ManifestEntry) (*SecretRecord, error) {
+	document := &versionDocument{}
+	_, err := d.getObject(d.secretKey(secretName), entry.VersionID, document)
+	if err != nil {
+		return nil, err
+	}
+	record := entry.secretRecord(secretName)
+	record.EncryptedData = document.EncryptedData
+	record.EncryptedDataKey = document.EncryptedDataKey
+	return record, nil
+}
+
+// updateEntry changes the entry of a version of the secret in its manifest.
+// An error is returned if the version does not exist
+func (d *s3DAO) updateEntry(secretName string, serial int64, update func(*s3ManifestEntry)) error {
+	return d.updateManifest(secretName, func(manifest *s3Manifest) error {
+		entry := manifest.entry(serial)
+		if entry == nil {
+			return ErrSecretRecordNotFound
+		}
+		update(entry)
+		return nil
+	})
+}
+
+// updateManifest reads the manifest of the secret, changes it with update and
+// puts it back, starting over if it was modified by someone else in the
+// meantime. The manifest is deleted once it is left empty
+func (d *s3DAO) updateManifest(secretName string, update func(*s3Manifest) error) error {
+	for attempt := 0; attempt < maxS3ManifestAttempts; attempt++ {
+		manifest, versionID, err := d.getManifest(secretName)
+		if err != nil {
+			return err
+		}
+		err = update(manifest)
+		if err == errManifestUnchanged {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(manifest.Labels) == 0 && manifest.RetentionMaxVersions == 0 &&
+			manifest.RetentionMaxAge == 0 && len(manifest.Versions) == 0 {
+			if versionID == "" {
+				return nil
+			}
+			return d.deleteObjectVersion(d.manifestKey(secretName), versionID)
+		}
+		err = d.putManifest(secretName, manifest, versionID)
+		if err != ErrSecretLabelsModified {
+			return err
+		}
+	}
+	return ErrSecretLabelsModified
+}
+
+// getManifest gets the manifest of the secret along with its object version,
+// which is empty if there is no manifest
+func (d *s3DAO) getManifest(secretName string) (*s3Manifest, string, error) {
+	manifest := &s3Manifest{}
+	versionID, err := d.getObject(d.manifestKey(secretName), "", manifest)
+	if awsErrorCode(err) == s3client.NoSuchKeyErrorCode {
+		return &s3Manifest{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, versionID, nil
+}
+
+// putManifest puts the manifest of the secret that was read at the object
+// version, incrementing its revision. S3 does not support conditional puts,
+// so the versions of the manifest are listed afterwards instead. If someone
+// else put the manifest in between, the version just put is deleted again and
+// ErrSecretLabelsModified is returned. Otherwise, the version that was read
+// is deleted, as only the latest version of a manifest is ever read
+func (d *s3DAO) putManifest(secretName string, manifest *s3Manifest, readVersionID string) error {
+	newManifest := *manifest
+	newManifest.Revision = manifest.Revision + 1
+	body, err := json.Marshal(newManifest)
+	if err != nil {
+		return err
+	}
+	key := d.manifestKey(secretName)
+	result, err := d.s3Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return err
+	}
+	versionID := aws.StringValue(result.VersionId)
+	if versionID == "" {
+		return fmt.Errorf("Versioning is not enabled on bucket '%s'", d.bucket)
+	}
+
+	versions, err := d.s3Client.ListObjectVersions(&s3.ListObjectVersionsInput{
+		Bucket:  aws.String(d.bucket),
+		Prefix:  aws.String(key),
+		MaxKeys: aws.Int64(s3ManifestVersionsChecked),
+	})
+	if err != nil {
+		return err
+	}
+	previous, ok := previousVersionID(key, versionID, versions)
+	if !ok || previous != readVersionID {
+		err = d.deleteObjectVersion(key, versionID)
+		if err != nil {
+			return err
+		}
+		return ErrSecretLabelsModified
+	}
+
+	manifest.Revision = newManifest.Revision
+	if readVersionID == "" {
+		return nil
+	}
+	return d.deleteObjectVersion(key, readVersionID)
+}
+
+// getObject decodes the object version into value and returns the version
+// read. The latest version is read if versionID is empty
+func (d *s3DAO) getObject(key string, versionID string, value interface{}) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	result, err := d.s3Client.GetObject(input)
+	if err != nil {
+		return "", err
+	}
+	defer result.Body.Close()
+
+	body, err := ioutil.ReadAll(result.Body)
+	if err != nil {
+		return "", err
+	}
+	err = json.Unmarshal(body, value)
+	if err != nil {
+		return "", fmt.Errorf("Error decoding object '%s': %v", key, err)
+	}
+	return aws.StringValue(result.VersionId), nil
+}
+
+// deleteObjectVersion permanently deletes the object version
+func (d *s3DAO) deleteObjectVersion(key string, versionID string) error {
+	_, err := d.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    aws.String(d.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	return err
+}
+
+// previousVersionID returns the object version of the key that was put right
+// before the version, which is empty if the version is the first one. False
+// is returned if the versions listed do not tell which version that is
+func previousVersionID(key string, versionID string, versions *s3.ListObjectVersionsOutput) (string, bool) {
+	found := false
+	for _, version := range versions.Versions {
+		// Versions of other keys that start with the key are listed after
+		// all of the versions of the key
+		if aws.StringValue(version.Key) != key {
+			if found {
+				return "", true
+			}
+			continue
+		}
+		if found {
+			return aws.StringValue(version.VersionId), true
+		}
+		found = aws.StringValue(version.VersionId) == versionID
+	}
+	// Versions are listed from the most recent, the previous version may be
+	// on the next page
+	return "", found && !aws.BoolValue(versions.IsTruncated)
+}
+
+func (m *s3Manifest) entry(serial int64) *s3ManifestEntry {
+	for _, entry := range m.Versions {
+		if entry.Serial == serial {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (e *s3ManifestEntry) secretRecord(secretName string) *SecretRecord {
+	return e.versionDocument.secretRecord(secretName, e.Serial)
+}
+
+type manifestEntriesBySerial []*s3ManifestEntry
+
+func (e manifestEntriesBySerial) Len() int           { return len(e) }
+func (e manifestEntriesBySerial) Less(i, j int) bool { return e[i].Serial < e[j].Serial }
+func (e manifestEntriesBySerial) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
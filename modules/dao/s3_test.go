@@ -0,0 +1,545 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	s3client "github.com/awslabs/ecs-secrets/modules/s3/client"
+	"github.com/awslabs/ecs-secrets/modules/s3/client/mock"
+	"github.com/golang/mock/gomock"
+)
+
+const (
+	testBucket      = "secrets-bucket"
+	testSecretKey   = "ecs-secrets/myapp/secrets/foo"
+	testManifestKey = "ecs-secrets/myapp/manifests/foo"
+)
+
+func newTestS3DAO(s3Client s3client.Client) DAO {
+	return NewS3DAO("myapp", s3Client, testBucket, DefaultS3KeyPrefix)
+}
+
+func getObjectOutput(t *testing.T, value interface{}, versionID string) *s3.GetObjectOutput {
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Error encoding object: %v", err)
+	}
+	return &s3.GetObjectOutput{
+		Body:      ioutil.NopCloser(bytes.NewReader(body)),
+		VersionId: aws.String(versionID),
+	}
+}
+
+func expectGetManifest(t *testing.T, s3Client *mock_client.MockClient, key string, manifest *s3Manifest, versionID string) *gomock.Call {
+	input := &s3.GetObjectInput{Bucket: aws.String(testBucket), Key: aws.String(key)}
+	if manifest == nil {
+		return s3Client.EXPECT().GetObject(input).Return(nil, awserr.New(s3client.NoSuchKeyErrorCode, "", nil))
+	}
+	return s3Client.EXPECT().GetObject(input).Return(getObjectOutput(t, manifest, versionID), nil)
+}
+
+// expectPutManifest expects the manifest to be put as a new object version,
+// decoding it into putManifest, and its versions to be listed. The versions
+// listed are given from the most recent
+func expectPutManifest(t *testing.T, s3Client *mock_client.MockClient, versionID string, listed []string, putManifest *s3Manifest) []*gomock.Call {
+	var versions []*s3.ObjectVersion
+	for _, listedVersionID := range listed {
+		versions = append(versions, &s3.ObjectVersion{Key: aws.String(testManifestKey), VersionId: aws.String(listedVersionID)})
+	}
+	return []*gomock.Call{
+		s3Client.EXPECT().PutObject(gomock.Any()).Do(func(input *s3.PutObjectInput) {
+			if aws.StringValue(input.Key) != testManifestKey {
+				t.Errorf("Expected manifest to be put, got: %s", aws.StringValue(input.Key))
+			}
+			body, _ := ioutil.ReadAll(input.Body)
+			err := json.Unmarshal(body, putManifest)
+			if err != nil {
+				t.Errorf("Error decoding manifest: %v", err)
+			}
+		}).Return(&s3.PutObjectOutput{VersionId: aws.String(versionID)}, nil),
+		s3Client.EXPECT().ListObjectVersions(&s3.ListObjectVersionsInput{
+			Bucket:  aws.String(testBucket),
+			Prefix:  aws.String(testManifestKey),
+			MaxKeys: aws.Int64(s3ManifestVersionsChecked),
+		}).Return(&s3.ListObjectVersionsOutput{Versions: versions}, nil),
+	}
+}
+
+func expectDeleteObjectVersion(s3Client *mock_client.MockClient, key string, versionID string) *gomock.Call {
+	return s3Client.EXPECT().DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    aws.String(testBucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}).Return(&s3.DeleteObjectOutput{}, nil)
+}
+
+func expectVersioning(s3Client *mock_client.MockClient, status string) *gomock.Call {
+	return s3Client.EXPECT().GetBucketVersioning(&s3.GetBucketVersioningInput{
+		Bucket: aws.String(testBucket),
+	}).Return(&s3.GetBucketVersioningOutput{Status: aws.String(status)}, nil)
+}
+
+func testManifestEntry(serial int64, active bool) *s3ManifestEntry {
+	return &s3ManifestEntry{
+		Serial:          serial,
+		VersionID:       "data-v" + strconv.FormatInt(serial, 10),
+		versionDocument: versionDocument{Active: active, CreatedAt: 1500000000 + serial},
+	}
+}
+
+func TestS3PutSecretRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	record := &SecretRecord{
+		Name:             "foo",
+		Serial:           1,
+		EncryptedData:    "data",
+		EncryptedDataKey: "key",
+		Active:           true,
+		CreatedAt:        1500000000,
+		Description:      "database password",
+	}
+	putManifest := &s3Manifest{}
+	calls := []*gomock.Call{
+		expectVersioning(s3Client, s3.BucketVersioningStatusEnabled),
+		s3Client.EXPECT().PutObject(gomock.Any()).Do(func(input *s3.PutObjectInput) {
+			if aws.StringValue(input.Bucket) != testBucket || aws.StringValue(input.Key) != testSecretKey {
+				t.Errorf("Unexpected object put: %s/%s", aws.StringValue(input.Bucket), aws.StringValue(input.Key))
+			}
+			document := &versionDocument{}
+			body, _ := ioutil.ReadAll(input.Body)
+			json.Unmarshal(body, document)
+			if document.EncryptedData != "data" || document.EncryptedDataKey != "key" {
+				t.Errorf("Expected encrypted data in the object, got: %s", string(body))
+			}
+		}).Return(&s3.PutObjectOutput{VersionId: aws.String("data-v1")}, nil),
+		expectGetManifest(t, s3Client, testManifestKey, nil, ""),
+	}
+	calls = append(calls, expectPutManifest(t, s3Client, "manifest-v1", []string{"manifest-v1"}, putManifest)...)
+	gomock.InOrder(calls...)
+
+	err := newTestS3DAO(s3Client).PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+	expectedManifest := &s3Manifest{
+		Revision: 1,
+		Versions: []*s3ManifestEntry{{
+			Serial:          1,
+			VersionID:       "data-v1",
+			versionDocument: versionDocument{Active: true, CreatedAt: 1500000000, Description: "database password"},
+		}},
+	}
+	if !reflect.DeepEqual(putManifest, expectedManifest) {
+		t.Errorf("Mismatch between expected and put manifest: %v != %v", expectedManifest, putManifest)
+	}
+}
+
+func TestS3PutSecretRecordSerialExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		expectVersioning(s3Client, s3.BucketVersioningStatusEnabled),
+		s3Client.EXPECT().PutObject(gomock.Any()).Return(&s3.PutObjectOutput{VersionId: aws.String("data-v2")}, nil),
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 1,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true)},
+		}, "manifest-v1"),
+		expectDeleteObjectVersion(s3Client, testSecretKey, "data-v2"),
+	)
+
+	err := newTestS3DAO(s3Client).PutSecretRecord(&SecretRecord{Name: "foo", Serial: 1})
+	if err != ErrSecretRecordExists {
+		t.Errorf("Expected ErrSecretRecordExists, got: %v", err)
+	}
+}
+
+func TestS3PutSecretRecordAlreadyInManifest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	// A manifest put by an earlier attempt lost a race, but was built on by
+	// someone else before it was deleted
+	entry := testManifestEntry(2, true)
+	gomock.InOrder(
+		expectVersioning(s3Client, s3.BucketVersioningStatusEnabled),
+		s3Client.EXPECT().PutObject(gomock.Any()).Return(&s3.PutObjectOutput{VersionId: aws.String(entry.VersionID)}, nil),
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 3,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true), entry},
+		}, "manifest-v3"),
+	)
+
+	err := newTestS3DAO(s3Client).PutSecretRecord(&SecretRecord{Name: "foo", Serial: 2, Active: true})
+	if err != nil {
+		t.Errorf("Error putting secret record: %v", err)
+	}
+}
+
+func TestS3PutSecretRecordKeepsVersionInManifestOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	entry := testManifestEntry(1, true)
+	calls := []*gomock.Call{
+		expectVersioning(s3Client, s3.BucketVersioningStatusEnabled),
+		s3Client.EXPECT().PutObject(gomock.Any()).Return(&s3.PutObjectOutput{VersionId: aws.String(entry.VersionID)}, nil),
+		expectGetManifest(t, s3Client, testManifestKey, nil, ""),
+	}
+	// The manifest put loses a race, and is built on before it is deleted.
+	// Deleting it then fails
+	calls = append(calls, expectPutManifest(t, s3Client, "manifest-v2", []string{"manifest-v3", "manifest-v2", "manifest-v1"}, &s3Manifest{})...)
+	calls = append(calls,
+		s3Client.EXPECT().DeleteObject(gomock.Any()).Return(nil, fmt.Errorf("throttled")),
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 2,
+			Versions: []*s3ManifestEntry{entry},
+		}, "manifest-v3"),
+	)
+	gomock.InOrder(calls...)
+
+	err := newTestS3DAO(s3Client).PutSecretRecord(&SecretRecord{Name: "foo", Serial: 1, Active: true})
+	if err != nil {
+		t.Errorf("Error putting secret record: %v", err)
+	}
+}
+
+func TestS3PutSecretRecordVersioningDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expectVersioning(s3Client, s3.BucketVersioningStatusSuspended)
+
+	err := newTestS3DAO(s3Client).PutSecretRecord(&SecretRecord{Name: "foo", Serial: 1})
+	if err == nil {
+		t.Error("Expected error putting a secret record into a bucket without versioning")
+	}
+}
+
+func TestS3GetLatestVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	revoked := testManifestEntry(2, false)
+	gomock.InOrder(
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 3,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true), revoked},
+		}, "manifest-v3"),
+		s3Client.EXPECT().GetObject(&s3.GetObjectInput{
+			Bucket:    aws.String(testBucket),
+			Key:       aws.String(testSecretKey),
+			VersionId: aws.String("data-v2"),
+		}).Return(getObjectOutput(t, &versionDocument{EncryptedData: "data", EncryptedDataKey: "key", Active: true}, "data-v2"), nil),
+	)
+
+	record, err := newTestS3DAO(s3Client).GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	expectedRecord := &SecretRecord{
+		Name:             "foo",
+		Serial:           2,
+		EncryptedData:    "data",
+		EncryptedDataKey: "key",
+		CreatedAt:        1500000002,
+	}
+	if !reflect.DeepEqual(record, expectedRecord) {
+		t.Errorf("Mismatch between expected and loaded record: %v != %v", expectedRecord, record)
+	}
+}
+
+func TestS3GetLatestVersionNoManifest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expectGetManifest(t, s3Client, testManifestKey, nil, "")
+
+	record, err := newTestS3DAO(s3Client).GetLatestVersion("foo")
+	if err != nil || record != nil {
+		t.Errorf("Expected no latest version, got: %v, %v", record, err)
+	}
+}
+
+func TestS3GetLatestActiveVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expired := testManifestEntry(3, true)
+	expired.ExpiresAt = 1500000000
+	gomock.InOrder(
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 3,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true), testManifestEntry(2, false), expired},
+		}, "manifest-v3"),
+		s3Client.EXPECT().GetObject(&s3.GetObjectInput{
+			Bucket:    aws.String(testBucket),
+			Key:       aws.String(testSecretKey),
+			VersionId: aws.String("data-v1"),
+		}).Return(getObjectOutput(t, &versionDocument{EncryptedData: "data"}, "data-v1"), nil),
+	)
+
+	record, err := newTestS3DAO(s3Client).GetLatestActiveVersion("foo", 1500000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if record.Serial != 1 || !record.Active || record.EncryptedData != "data" {
+		t.Errorf("Expected serial 1 to be the latest active version, got: %v", record)
+	}
+}
+
+func TestS3RevokeSecretRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	putManifest := &s3Manifest{}
+	calls := []*gomock.Call{
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Labels:   map[string]int64{"prod": 1},
+			Revision: 2,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true)},
+		}, "manifest-v2"),
+	}
+	calls = append(calls, expectPutManifest(t, s3Client, "manifest-v3", []string{"manifest-v3", "manifest-v2"}, putManifest)...)
+	calls = append(calls, expectDeleteObjectVersion(s3Client, testManifestKey, "manifest-v2"))
+	gomock.InOrder(calls...)
+
+	err := newTestS3DAO(s3Client).RevokeSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+	if putManifest.Revision != 3 || putManifest.Versions[0].Active || putManifest.Labels["prod"] != 1 {
+		t.Errorf("Unexpected manifest put: %v", putManifest)
+	}
+}
+
+func TestS3RevokeSecretRecordNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+		Revision: 1,
+		Versions: []*s3ManifestEntry{testManifestEntry(1, true)},
+	}, "manifest-v1")
+
+	err := newTestS3DAO(s3Client).RevokeSecretRecord("foo", 2)
+	if err == nil {
+		t.Error("Expected error revoking a secret record that does not exist")
+	}
+}
+
+func TestS3ScheduleSecretRecordDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	putManifest := &s3Manifest{}
+	calls := []*gomock.Call{
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 1,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true)},
+		}, "manifest-v1"),
+	}
+	calls = append(calls, expectPutManifest(t, s3Client, "manifest-v2", []string{"manifest-v2", "manifest-v1"}, putManifest)...)
+	calls = append(calls, expectDeleteObjectVersion(s3Client, testManifestKey, "manifest-v1"))
+	gomock.InOrder(calls...)
+
+	err := newTestS3DAO(s3Client).ScheduleSecretRecordDeletion("foo", 1, &Deletion{DeletedAt: 1500000000, DeletedBy: "alice", PurgeAt: 1502592000})
+	if err != nil {
+		t.Fatalf("Error scheduling deletion: %v", err)
+	}
+	entry := putManifest.Versions[0]
+	if entry.DeletedAt != 1500000000 || entry.DeletedBy != "alice" || entry.PurgeAt != 1502592000 {
+		t.Errorf("Unexpected manifest entry put: %v", entry)
+	}
+}
+
+func TestS3PutSecretLabelsConcurrentPut(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	calls := []*gomock.Call{
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{Revision: 1}, "manifest-v1"),
+	}
+	// Someone else put manifest-v2 between the manifest being read and put
+	calls = append(calls, expectPutManifest(t, s3Client, "manifest-v3", []string{"manifest-v3", "manifest-v2", "manifest-v1"}, &s3Manifest{})...)
+	calls = append(calls, expectDeleteObjectVersion(s3Client, testManifestKey, "manifest-v3"))
+	gomock.InOrder(calls...)
+
+	metadata := &SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 1}, Revision: 1}
+	err := newTestS3DAO(s3Client).PutSecretLabels(metadata)
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+	if metadata.Revision != 1 {
+		t.Errorf("Expected revision to be left as it was, got %d", metadata.Revision)
+	}
+}
+
+func TestS3PutSecretLabelsStaleRevision(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{Revision: 2}, "manifest-v2")
+
+	err := newTestS3DAO(s3Client).PutSecretLabels(&SecretLabels{Name: "foo", Revision: 1})
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+}
+
+func TestS3DeleteSecretRecordLastVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 1,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true)},
+		}, "manifest-v1"),
+		// The manifest is left empty, so it is deleted rather than put
+		expectDeleteObjectVersion(s3Client, testManifestKey, "manifest-v1"),
+		expectDeleteObjectVersion(s3Client, testSecretKey, "data-v1"),
+	)
+
+	err := newTestS3DAO(s3Client).DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Errorf("Error deleting secret record: %v", err)
+	}
+}
+
+func TestS3DeleteSecretRecordNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expectGetManifest(t, s3Client, testManifestKey, nil, "")
+
+	err := newTestS3DAO(s3Client).DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Errorf("Error deleting secret record: %v", err)
+	}
+}
+
+func TestS3ListSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	gomock.InOrder(
+		s3Client.EXPECT().ListObjects(&s3.ListObjectsInput{
+			Bucket:  aws.String(testBucket),
+			Prefix:  aws.String("ecs-secrets/myapp/manifests/f"),
+			MaxKeys: aws.Int64(2),
+			Marker:  aws.String("ecs-secrets/myapp/manifests/bar"),
+		}).Return(&s3.ListObjectsOutput{
+			Contents: []*s3.Object{
+				{Key: aws.String("ecs-secrets/myapp/manifests/foo")},
+				{Key: aws.String("ecs-secrets/myapp/manifests/foo/bar")},
+			},
+			IsTruncated: aws.Bool(true),
+		}, nil),
+		expectGetManifest(t, s3Client, testManifestKey, &s3Manifest{
+			Revision: 2,
+			Versions: []*s3ManifestEntry{testManifestEntry(1, true), testManifestEntry(2, true)},
+		}, "manifest-v2"),
+		// Only labels are left of foo/bar
+		expectGetManifest(t, s3Client, "ecs-secrets/myapp/manifests/foo/bar", &s3Manifest{
+			Labels:   map[string]int64{"prod": 1},
+			Revision: 3,
+		}, "manifest-v3"),
+	)
+
+	records, token, err := newTestS3DAO(s3Client).ListSecrets("f", "ecs-secrets/myapp/manifests/bar", 2)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(records) != 1 || records[0].Name != "foo" || records[0].Serial != 2 {
+		t.Errorf("Unexpected secrets listed: %v", records)
+	}
+	if token != "ecs-secrets/myapp/manifests/foo/bar" {
+		t.Errorf("Unexpected token: %s", token)
+	}
+}
+
+func TestS3GetApplicationMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mock_client.NewMockClient(ctrl)
+
+	expectGetManifest(t, s3Client, "ecs-secrets/myapp/application", &s3Manifest{
+		RetentionMaxVersions: 5,
+		Revision:             4,
+	}, "manifest-v4")
+
+	metadata, err := newTestS3DAO(s3Client).GetSecretLabels(ApplicationMetadataName)
+	if err != nil {
+		t.Fatalf("Error getting application metadata: %v", err)
+	}
+	expectedMetadata := &SecretLabels{
+		Name:                 ApplicationMetadataName,
+		Labels:               map[string]int64{},
+		RetentionMaxVersions: 5,
+		Revision:             4,
+	}
+	if !reflect.DeepEqual(metadata, expectedMetadata) {
+		t.Errorf("Mismatch between expected and loaded metadata: %v != %v", expectedMetadata, metadata)
+	}
+}
+
+func TestPreviousVersionID(t *testing.T) {
+	versions := &s3.ListObjectVersionsOutput{
+		Versions: []*s3.ObjectVersion{
+			{Key: aws.String("a"), VersionId: aws.String("v2")},
+			{Key: aws.String("a"), VersionId: aws.String("v1")},
+			{Key: aws.String("a/b"), VersionId: aws.String("v3")},
+		},
+		IsTruncated: aws.Bool(true),
+	}
+	for versionID, expected := range map[string]string{"v2": "v1", "v1": ""} {
+		previous, ok := previousVersionID("a", versionID, versions)
+		if !ok || previous != expected {
+			t.Errorf("Expected %s to be put before %s, got: %s, %t", expected, versionID, previous, ok)
+		}
+	}
+	_, ok := previousVersionID("a", "v4", versions)
+	if ok {
+		t.Error("Expected previous version of a version that is not listed to be unknown")
+	}
+}
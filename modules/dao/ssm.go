@@ -0,0 +1,932 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	ssmclient "github.com/awslabs/ecs-secrets/modules/ssm/client"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Secrets are stored in Parameter Store under a path that is specific to the
+// application. Every version of a secret is a version of the parameter named
+// after the secret, so that serials are the versions of the parameter. As
+// versions of parameters cannot be modified, whether a version is active,
+// restored or scheduled for deletion is stored in a second parameter, along
+// with the labels and retention policy of the secret
+const (
+	// ssmParameterPrefix is the path under which the parameters of every
+	// application are stored
+	ssmParameterPrefix = "/ecs-secrets"
+	// maxSSMValueSize is the size of the largest value that can be stored in
+	// a standard parameter
+	maxSSMValueSize = 4096
+	// ssmDataKeySize is the room left for the encrypted data key when
+	// checking the size of a secret record before it is encrypted. Data keys
+	// wrapped by KMS take up 248 characters once encoded, those wrapped
+	// locally or by Vault less
+	ssmDataKeySize = 256
+	// maxSSMParameterVersions is the number of versions of a parameter kept
+	// by Parameter Store. The oldest version is dropped when another one is
+	// put, so a secret cannot have versions whose serials are this far apart
+	maxSSMParameterVersions = int64(100)
+	// ssmHistoryPageSize is the number of versions read per request when
+	// reading the history of a parameter
+	ssmHistoryPageSize = int64(50)
+	// maxSSMListPageSize is the largest number of parameters that can be
+	// read per request when listing parameters by path
+	maxSSMListPageSize = int64(10)
+	// maxSSMMetadataAttempts is the number of times the metadata of a secret
+	// is read and put again when it is modified by someone else at the same
+	// time
+	maxSSMMetadataAttempts = 3
+)
+
+// ssmVersionState defines the state of a version of a secret that has changed
+// since the version was put. Versions that are purged are hidden, as their
+// parameter versions can only be deleted along with the whole parameter
+type ssmVersionState struct {
+	Active        bool   `json:"active,omitempty"`
+	RestoredAt    int64  `json:"restoredAt,omitempty"`
+	RestoredBy    string `json:"restoredBy,omitempty"`
+	RestoreReason string `json:"restoreReason,omitempty"`
+	DeletedAt     int64  `json:"deletedAt,omitempty"`
+	DeletedBy     string `json:"deletedBy,omitempty"`
+	PurgeAt       int64  `json:"purgeAt,omitempty"`
+	Purged        bool   `json:"purged,omitempty"`
+}
+
+// ssmMetadata defines the value of the metadata parameter of a secret. The
+// revision of the metadata is the version of the parameter. Parameter Store
+// does not support conditional puts, so every version records the revision it
+// was based on. A version is only in effect if it was based on the revision in
+// effect when it was put, versions put by writers that lost a race with
+// another writer are ignored. Only the versions that differ from the version
+// of the secret as it was put are kept
+type ssmMetadata struct {
+	Base                 int64                      `json:"base,omitempty"`
+	Labels               map[string]int64           `json:"labels,omitempty"`
+	RetentionMaxVersions int64                      `json:"retentionMaxVersions,omitempty"`
+	RetentionMaxAge      int64                      `json:"retentionMaxAge,omitempty"`
+	Versions             map[int64]*ssmVersionState `json:"versions,omitempty"`
+}
+
+type ssmDAO struct {
+	appName       string
+	ssmClient     ssmclient.Client
+	parameterType string
+	keyID         string
+}
+
+// NewSSMDAO creates a new DAO object backed by SSM Parameter Store. Secrets
+// are always encrypted before they are stored. They are encrypted once more
+// by Parameter Store if the parameter type is SecureString, using the KMS key
+// with keyID or the default key of the account if keyID is empty
+func NewSSMDAO(appName string, ssmClient ssmclient.Client, parameterType string, keyID string) (DAO, error) {
+	switch parameterType {
+	case ssmclient.StringParameterType:
+		if keyID != "" {
+			return nil, fmt.Errorf("A key can only be used with %s parameters", ssmclient.SecureStringParameterType)
+		}
+	case ssmclient.SecureStringParameterType:
+	default:
+		return nil, fmt.Errorf("Unknown parameter type '%s', should be one of %s, %s",
+			parameterType, ssmclient.StringParameterType, ssmclient.SecureStringParameterType)
+	}
+	return &ssmDAO{
+		appName:       appName,
+		ssmClient:     ssmClient,
+		parameterType: parameterType,
+		keyID:         keyID,
+	}, nil
+}
+
+// GetSecretRecord gets a version of a secret from Parameter Store
+func (d *ssmDAO) GetSecretRecord(namespace string, serial int64) (*SecretRecord, error) {
+	metadata, _, err := d.getMetadata(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return d.getVersion(namespace, serial, metadata)
+}
+
+// GetSecretRecords gets versions of secrets from Parameter Store, reading the
+// history of every secret once. Versions that don't exist are left out
+func (d *ssmDAO) GetSecretRecords(keys []SecretKey) ([]*SecretRecord, error) {
+	versions := make(map[string]map[int64]*SecretRecord)
+	seen := make(map[SecretKey]bool)
+	var records []*SecretRecord
+	for _, key := range keys {
+		if seen[key] || key.Serial <= labelsSerial {
+			continue
+		}
+		seen[key] = true
+
+		serials, ok := versions[key.Name]
+		if !ok {
+			metadata, _, err := d.getMetadata(key.Name)
+			if err != nil {
+				return nil, err
+			}
+			secretRecords, err := d.getVersions(key.Name, metadata)
+			if err != nil {
+				return nil, err
+			}
+			serials = make(map[int64]*SecretRecord)
+			for _, record := range secretRecords {
+				serials[record.Serial] = record
+			}
+			versions[key.Name] = serials
+		}
+		if record, ok := serials[key.Serial]; ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// PutSecretRecord puts a version of a secret into Parameter Store. The serial
+// of the record must be greater than the version of the parameter, otherwise
+// ErrSecretRecordExists is returned. Placeholder versions are put to skip any
+// serials in between
+func (d *ssmDAO) PutSecretRecord(record *SecretRecord) error {
+	value, err := encodeSSMValue(newVersionDocument(record))
+	if err != nil {
+		return err
+	}
+
+	parameterName := d.secretParameterName(record.Name)
+	parameter, err := d.getParameter(parameterName, false)
+	if err != nil {
+		return err
+	}
+	current := int64(0)
+	if parameter != nil {
+		current = aws.Int64Value(parameter.Version)
+	}
+	if record.Serial <= current {
+		return ErrSecretRecordExists
+	}
+	oldest := record.Serial - maxSSMParameterVersions
+	if oldest > 0 {
+		err = d.checkVersionsKept(record.Name, record.Serial, oldest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if current == 0 {
+		// State of versions may have been left behind if purging the
+		// last version of the secret failed half way through. It does not
+		// apply to the versions of a new parameter
+		err = d.updateMetadata(record.Name, func(metadata *ssmMetadata) error {
+			metadata.Versions = nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	placeholder, err := encodeSSMValue(&versionDocument{Placeholder: true})
+	if err != nil {
+		return err
+	}
+	for serial := current + 1; serial < record.Serial; serial++ {
+		err = d.putVersion(record.Name, serial, placeholder)
+		if err != nil {
+			return err
+		}
+	}
+	err = d.putVersion(record.Name, record.Serial, value)
+	if err != nil || oldest <= 0 {
+		return err
+	}
+
+	// The state of versions that Parameter Store no longer keeps is not
+	// needed any more
+	return d.updateMetadata(record.Name, func(metadata *ssmMetadata) error {
+		for serial := range metadata.Versions {
+			if serial <= oldest {
+				delete(metadata.Versions, serial)
+			}
+		}
+		return nil
+	})
+}
+
+// MaxEncryptedDataSize returns the length of the longest encrypted data that
+// fits in a parameter along with the rest of the record
+func (d *ssmDAO) MaxEncryptedDataSize(record *SecretRecord) int {
+	sized := *record
+	sized.EncryptedData = "x"
+	sized.EncryptedDataKey = strings.Repeat("x", ssmDataKeySize)
+	value, err := json.Marshal(newVersionDocument(&sized))
+	if err != nil || len(value) > maxSSMValueSize {
+		return 0
+	}
+	return maxSSMValueSize - len(value) + len(sized.EncryptedData)
+}
+
+// checkVersionsKept returns an error if putting the serial would make
+// Parameter Store drop a version of the secret that has not been purged, as
+// versions up to the oldest serial are dropped
+func (d *ssmDAO) checkVersionsKept(secretName string, serial int64, oldest int64) error {
+	records, err := d.ListVersions(secretName)
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 && records[0].Serial <= oldest {
+		return fmt.Errorf("Parameter Store keeps %d versions of secret '%s', saving version %d would drop version %d. Purge version %d first",
+			maxSSMParameterVersions, secretName, serial, records[0].Serial, records[0].Serial)
+	}
+	return nil
+}
+
+// RevokeSecretRecord revokes a version of a secret in Parameter Store
+func (d *ssmDAO) RevokeSecretRecord(namespace string, serial int64) error {
+	return d.updateVersion(namespace, serial, func(record *SecretRecord) {
+		record.Active = false
+	})
+}
+
+// RestoreSecretRecord reinstates a revoked version of a secret in Parameter
+// Store, recording who restored it, when and why
+func (d *ssmDAO) RestoreSecretRecord(namespace string, serial int64, restoration *Restoration) error {
+	return d.updateVersion(namespace, serial, func(record *SecretRecord) {
+		record.Active = true
+		record.RestoredAt = restoration.RestoredAt
+		record.RestoredBy = restoration.RestoredBy
+		record.RestoreReason = restoration.Reason
+	})
+}
+
+// ScheduleSecretRecordDeletion marks a version of a secret in Parameter Store
+// as scheduled for deletion
+func (d *ssmDAO) ScheduleSecretRecordDeletion(namespace string, serial int64, deletion *Deletion) error {
+	return d.updateVersion(namespace, serial, func(record *SecretRecord) {
+		record.DeletedAt = deletion.DeletedAt
+		record.DeletedBy = deletion.DeletedBy
+		record.PurgeAt = deletion.PurgeAt
+	})
+}
+
+// CancelSecretRecordDeletion removes the scheduled deletion of a version of a
+// secret in Parameter Store
+func (d *ssmDAO) CancelSecretRecordDeletion(namespace string, serial int64) error {
+	return d.updateVersion(namespace, serial, func(record *SecretRecord) {
+		record.DeletedAt = 0
+		record.DeletedBy = ""
+		record.PurgeAt = 0
+	})
+}
+
+// DeleteSecretRecord hides a version of a secret in Parameter Store. The
+// parameter is deleted along with its history once every version has been
+// deleted. The metadata parameter is kept so that its revisions keep
+// increasing
+func (d *ssmDAO) DeleteSecretRecord(namespace string, serial int64) error {
+	err := d.updateMetadata(namespace, func(metadata *ssmMetadata) error {
+		if metadata.Versions == nil {
+			metadata.Versions = make(map[int64]*ssmVersionState)
+		}
+		metadata.Versions[serial] = &ssmVersionState{Purged: true}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	records, err := d.ListVersions(namespace)
+	if err != nil || len(records) > 0 {
+		return err
+	}
+	err = d.deleteParameter(d.secretParameterName(namespace))
+	if err != nil {
+		return err
+	}
+	return d.updateMetadata(namespace, func(metadata *ssmMetadata) error {
+		metadata.Versions = nil
+		return nil
+	})
+}
+
+// GetLatestVersion gets the latest version of the secret from Parameter
+// Store. The history of the secret is only read if the latest version of the
+// parameter has been purged or is a placeholder
+func (d *ssmDAO) GetLatestVersion(secretName string) (*SecretRecord, error) {
+	metadata, _, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	parameter, err := d.getParameter(d.secretParameterName(secretName), true)
+	if err != nil || parameter == nil {
+		return nil, err
+	}
+	record, err := decodeSSMVersion(secretName, parameter.Value, aws.Int64Value(parameter.Version), metadata)
+	if err != nil || record != nil {
+		return record, err
+	}
+	records, err := d.getVersions(secretName, metadata)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[len(records)-1], nil
+}
+
+// GetLatestActiveVersion gets the latest version of the secret from Parameter
+// Store that is active and has not expired at the time given in seconds. The
+// history of the secret is only read if the latest version of the parameter
+// is not that version
+func (d *ssmDAO) GetLatestActiveVersion(secretName string, now int64) (*SecretRecord, error) {
+	metadata, _, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	parameter, err := d.getParameter(d.secretParameterName(secretName), true)
+	if err != nil || parameter == nil {
+		return nil, err
+	}
+	record, err := decodeSSMVersion(secretName, parameter.Value, aws.Int64Value(parameter.Version), metadata)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil && record.Active && (record.ExpiresAt == 0 || record.ExpiresAt > now) {
+		return record, nil
+	}
+	records, err := d.getVersions(secretName, metadata)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Active && (record.ExpiresAt == 0 || record.ExpiresAt > now) {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListSecrets lists the latest version of every secret in Parameter Store
+// whose name starts with the prefix, one page at a time. The records returned
+// do not contain any encrypted data. Parameter Store returns at most 10
+// parameters per page, so the limit is lowered to that. Pages may be empty
+// when none of the secrets evaluated match the prefix. The latest version of
+// every parameter comes with the page, the history of a secret is only read
+// if that version has been purged or is a placeholder
+func (d *ssmDAO) ListSecrets(prefix string, nextToken string, limit int64) ([]*SecretRecord, string, error) {
+	if limit <= 0 || limit > maxSSMListPageSize {
+		limit = maxSSMListPageSize
+	}
+	path := d.parameterPath("secrets")
+	input := &ssmclient.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+		MaxResults:     aws.Int64(limit),
+	}
+	if nextToken != "" {
+		input.NextToken = aws.String(nextToken)
+	}
+	result, err := d.ssmClient.GetParametersByPath(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var records []*SecretRecord
+	for _, parameter := range result.Parameters {
+		name := strings.TrimPrefix(aws.StringValue(parameter.Name), path+"/")
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		metadata, _, err := d.getMetadata(name)
+		if err != nil {
+			return nil, "", err
+		}
+		record, err := decodeSSMVersion(name, parameter.Value, aws.Int64Value(parameter.Version), metadata)
+		if err != nil {
+			return nil, "", err
+		}
+		if record == nil {
+			versions, err := d.getVersions(name, metadata)
+			if err != nil {
+				return nil, "", err
+			}
+			if len(versions) == 0 {
+				continue
+			}
+			record = versions[len(versions)-1]
+		}
+		record.EncryptedData = ""
+		record.EncryptedDataKey = ""
+		records = append(records, record)
+	}
+	return records, aws.StringValue(result.NextToken), nil
+}
+
+// ListVersions lists every version of the secret in Parameter Store, in the
+// order of their serials. The records returned do not contain any encrypted
+// data
+func (d *ssmDAO) ListVersions(secretName string) ([]*SecretRecord, error) {
+	metadata, _, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	records, err := d.getVersions(secretName, metadata)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		record.EncryptedData = ""
+		record.EncryptedDataKey = ""
+	}
+	return records, nil
+}
+
+// GetSecretLabels gets the metadata of the secret from Parameter Store.
+// Metadata with no revision is returned if none has been put for the secret
+// yet
+func (d *ssmDAO) GetSecretLabels(secretName string) (*SecretLabels, error) {
+	metadata, revision, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	labels := metadata.Labels
+	if labels == nil {
+		labels = map[string]int64{}
+	}
+	return &SecretLabels{
+		Name:                 secretName,
+		Serial:               labelsSerial,
+		Labels:               labels,
+		RetentionMaxVersions: metadata.RetentionMaxVersions,
+		RetentionMaxAge:      metadata.RetentionMaxAge,
+		Revision:             revision,
+	}, nil
+}
+
+// PutSecretLabels puts the metadata of the secret into Parameter Store.
+// ErrSecretLabelsModified is returned if the metadata has been put by
+// someone else since it was read. The revision of the metadata is set to the
+// version of the parameter put, which may be more than one above the previous
+// revision if someone else lost a race in between
+func (d *ssmDAO) PutSecretLabels(metadata *SecretLabels) error {
+	current, revision, err := d.getMetadata(metadata.Name)
+	if err != nil {
+		return err
+	}
+	if revision != metadata.Revision {
+		return ErrSecretLabelsModified
+	}
+
+	current.Labels = metadata.Labels
+	current.RetentionMaxVersions = metadata.RetentionMaxVersions
+	current.RetentionMaxAge = metadata.RetentionMaxAge
+	revision, err = d.putMetadata(metadata.Name, current, revision)
+	if err != nil {
+		return err
+	}
+	metadata.Revision = revision
+	return nil
+}
+
+// DeleteSecretLabels deletes the labels and retention policy of the secret
+// from Parameter Store. The state of its versions is kept until the versions
+// are deleted
+func (d *ssmDAO) DeleteSecretLabels(secretName string) error {
+	return d.updateMetadata(secretName, func(metadata *ssmMetadata) error {
+		metadata.Labels = nil
+		metadata.RetentionMaxVersions = 0
+		metadata.RetentionMaxAge = 0
+		return nil
+	})
+}
+
+// parameterPath returns the path of the parameters of the given kind
+func (d *ssmDAO) parameterPath(kind string) string {
+	return strings.Join([]string{ssmParameterPrefix, d.appName, kind}, "/")
+}
+
+// secretParameterName returns the name of the parameter that holds the
+// versions of the secret
+func (d *ssmDAO) secretParameterName(secretName string) string {
+	return d.parameterPath("secrets") + "/" + secretName
+}
+
+// metadataParameterName returns the name of the parameter that holds the
+// metadata of the secret. The metadata of the application is kept apart, as
+// its name is not a valid parameter name
+func (d *ssmDAO) metadataParameterName(secretName string) string {
+	if secretName == ApplicationMetadataName {
+		return d.parameterPath("application")
+	}
+	return d.parameterPath("metadata") + "/" + secretName
+}
+
+// getVersions gets every version of the secret that has not been purged, in
+// the order of their serials, applying the state of each version recorded in
+// the metadata
+func (d *ssmDAO) getVersions(secretName string, metadata *ssmMetadata) ([]*SecretRecord, error) {
+	history, err := d.getParameterHistory(d.secretParameterName(secretName))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*SecretRecord
+	for _, parameter := range history {
+		record, err := decodeSSMVersion(secretName, parameter.Value, aws.Int64Value(parameter.Version), metadata)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+	sort.Sort(recordsBySerial(records))
+	return records, nil
+}
+
+// getVersion gets a version of the secret that has not been purged, reading
+// only that version of the parameter
+func (d *ssmDAO) getVersion(secretName string, serial int64, metadata *ssmMetadata) (*SecretRecord, error) {
+	if serial <= labelsSerial {
+		return nil, ErrSecretRecordNotFound
+	}
+	if state := metadata.Versions[serial]; state != nil && state.Purged {
+		return nil, ErrSecretRecordNotFound
+	}
+	result, err := d.ssmClient.GetParameter(&ssmclient.GetParameterInput{
+		Name:           aws.String(fmt.Sprintf("%s:%d", d.secretParameterName(secretName), serial)),
+		WithDecryption: aws.Bool(true),
+	})
+	switch awsErrorCode(err) {
+	case ssmclient.ParameterNotFoundErrorCode, ssmclient.ParameterVersionNotFoundErrorCode:
+		return nil, ErrSecretRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record, err := decodeSSMVersion(secretName, result.Parameter.Value, serial, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrSecretRecordNotFound
+	}
+	return record, nil
+}
+
+// putVersion puts the value as the version of the secret with the serial. If
+// someone else put a version at the same time, the version put lands at
+// another serial. It is hidden and ErrSecretRecordExists is returned
+func (d *ssmDAO) putVersion(secretName string, serial int64, value string) error {
+	result, err := d.ssmClient.PutParameter(&ssmclient.PutParameterInput{
+		Name:      aws.String(d.secretParameterName(secretName)),
+		Value:     aws.String(value),
+		Type:      aws.String(d.parameterType),
+		KeyId:     d.keyIDValue(),
+		Overwrite: aws.Bool(serial != 1),
+	})
+	if awsErrorCode(err) == ssmclient.ParameterAlreadyExistsErrorCode {
+		return ErrSecretRecordExists
+	}
+	if err != nil {
+		return err
+	}
+
+	version := aws.Int64Value(result.Version)
+	if version == serial {
+		return nil
+	}
+	err = d.updateMetadata(secretName, func(metadata *ssmMetadata) error {
+		if metadata.Versions == nil {
+			metadata.Versions = make(map[int64]*ssmVersionState)
+		}
+		metadata.Versions[version] = &ssmVersionState{Purged: true}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ErrSecretRecordExists
+}
+
+// updateVersion records the state of a version of the secret after it has
+// been changed by update. The state is removed from the metadata if it is the
+// same as when the version was put. An error is returned if the version does
+// not exist
+func (d *ssmDAO) updateVersion(secretName string, serial int64, update func(*SecretRecord)) error {
+	return d.updateMetadata(secretName, func(metadata *ssmMetadata) error {
+		state := metadata.Versions[serial]
+		if state != nil && state.Purged {
+			return ErrSecretRecordNotFound
+		}
+		record, err := d.getVersion(secretName, serial, &ssmMetadata{})
+		if err != nil {
+			return err
+		}
+		putState := newSSMVersionState(record)
+		if state != nil {
+			state.apply(record)
+		}
+		update(record)
+
+		state = newSSMVersionState(record)
+		if *state == *putState {
+			delete(metadata.Versions, serial)
+			return nil
+		}
+		if metadata.Versions == nil {
+			metadata.Versions = make(map[int64]*ssmVersionState)
+		}
+		metadata.Versions[serial] = state
+		return nil
+	})
+}
+
+// updateMetadata reads the metadata of the secret, changes it with update and
+// puts it back if it changed, starting over if it was modified by someone
+// else in the meantime
+func (d *ssmDAO) updateMetadata(secretName string, update func(*ssmMetadata) error) error {
+	for attempt := 0; attempt < maxSSMMetadataAttempts; attempt++ {
+		metadata, revision, err := d.getMetadata(secretName)
+		if err != nil {
+			return err
+		}
+		read, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		err = update(metadata)
+		if err != nil {
+			return err
+		}
+		updated, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(read, updated) {
+			return nil
+		}
+
+		_, err = d.putMetadata(secretName, metadata, revision)
+		if err != ErrSecretLabelsModified {
+			return err
+		}
+	}
+	return ErrSecretLabelsModified
+}
+
+// getMetadata gets the metadata of the secret in effect along with its
+// revision, which is 0 if there is no metadata parameter. The latest version
+// of the parameter is in effect if it was based on the version before it.
+// Otherwise it was put by a writer that lost a race, and the history of the
+// parameter is read to find the version in effect
+func (d *ssmDAO) getMetadata(secretName string) (*ssmMetadata, int64, error) {
+	parameter, err := d.getParameter(d.metadataParameterName(secretName), true)
+	if err != nil || parameter == nil {
+		return &ssmMetadata{}, 0, err
+	}
+	metadata, err := decodeSSMMetadata(secretName, parameter.Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	version := aws.Int64Value(parameter.Version)
+	if metadata.Base == version-1 {
+		return metadata, version, nil
+	}
+	return d.getMetadataInEffect(secretName)
+}
+
+// getMetadataInEffect replays the history of the metadata parameter of the
+// secret to find the version in effect. Parameter Store only keeps the latest
+// versions of a parameter, so the history is replayed from the latest version
+// that was based on the version before it, which is always in effect
+func (d *ssmDAO) getMetadataInEffect(secretName string) (*ssmMetadata, int64, error) {
+	history, err := d.getParameterHistory(d.metadataParameterName(secretName))
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Sort(parameterHistoryByVersion(history))
+
+	versions := make([]*ssmMetadata, len(history))
+	start := 0
+	for i, parameter := range history {
+		versions[i], err = decodeSSMMetadata(secretName, parameter.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+		if versions[i].Base == aws.Int64Value(parameter.Version)-1 {
+			start = i
+		}
+	}
+
+	metadata := &ssmMetadata{}
+	revision := int64(0)
+	for i := start; i < len(history); i++ {
+		version := aws.Int64Value(history[i].Version)
+		if i == start || versions[i].Base == revision {
+			metadata = versions[i]
+			revision = version
+		}
+	}
+	return metadata, revision, nil
+}
+
+// putMetadata puts the metadata of the secret that was read at the revision,
+// returning the revision put. Parameter Store does not support conditional
+// puts, so the version put is checked afterwards instead. If someone else put
+// the metadata in between, the version put is not in effect and
+// ErrSecretLabelsModified is returned. It is never put back, as that could
+// overwrite the metadata put by yet another writer
+func (d *ssmDAO) putMetadata(secretName string, metadata *ssmMetadata, revision int64) (int64, error) {
+	metadata.Base = revision
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) > maxSSMValueSize {
+		return 0, fmt.Errorf("Metadata of secret '%s' is too large to be stored in a parameter, %d bytes is more than %d bytes. Remove labels or purge versions of the secret to make room",
+			secretName, len(value), maxSSMValueSize)
+	}
+	result, err := d.ssmClient.PutParameter(&ssmclient.PutParameterInput{
+		Name:      aws.String(d.metadataParameterName(secretName)),
+		Value:     aws.String(string(value)),
+		Type:      aws.String(d.parameterType),
+		KeyId:     d.keyIDValue(),
+		Overwrite: aws.Bool(revision != 0),
+	})
+	if awsErrorCode(err) == ssmclient.ParameterAlreadyExistsErrorCode {
+		return 0, ErrSecretLabelsModified
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	version := aws.Int64Value(result.Version)
+	if version == revision+1 {
+		return version, nil
+	}
+	_, inEffect, err := d.getMetadataInEffect(secretName)
+	if err != nil {
+		return 0, err
+	}
+	if inEffect != version {
+		return 0, ErrSecretLabelsModified
+	}
+	return version, nil
+}
+
+// getParameter gets the latest version of the parameter, or nil if it does
+// not exist
+func (d *ssmDAO) getParameter(name string, withDecryption bool) (*ssmclient.Parameter, error) {
+	result, err := d.ssmClient.GetParameter(&ssmclient.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(withDecryption),
+	})
+	if awsErrorCode(err) == ssmclient.ParameterNotFoundErrorCode {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.Parameter, nil
+}
+
+// getParameterHistory gets every version of the parameter, decrypted. No
+// versions are returned if the parameter does not exist
+func (d *ssmDAO) getParameterHistory(name string) ([]*ssmclient.ParameterHistory, error) {
+	input := &ssmclient.GetParameterHistoryInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+		MaxResults:     aws.Int64(ssmHistoryPageSize),
+	}
+	var history []*ssmclient.ParameterHistory
+	for {
+		result, err := d.ssmClient.GetParameterHistory(input)
+		if awsErrorCode(err) == ssmclient.ParameterNotFoundErrorCode {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, result.Parameters...)
+		if aws.StringValue(result.NextToken) == "" {
+			return history, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// deleteParameter deletes the parameter, if it exists
+func (d *ssmDAO) deleteParameter(name string) error {
+	_, err := d.ssmClient.DeleteParameter(&ssmclient.DeleteParameterInput{
+		Name: aws.String(name),
+	})
+	if awsErrorCode(err) == ssmclient.ParameterNotFoundErrorCode {
+		return nil
+	}
+	return err
+}
+
+func (d *ssmDAO) keyIDValue() *string {
+	if d.keyID == "" {
+		return nil
+	}
+	return aws.String(d.keyID)
+}
+
+func newSSMVersionState(record *SecretRecord) *ssmVersionState {
+	return &ssmVersionState{
+		Active:        record.Active,
+		RestoredAt:    record.RestoredAt,
+		RestoredBy:    record.RestoredBy,
+		RestoreReason: record.RestoreReason,
+		DeletedAt:     record.DeletedAt,
+		DeletedBy:     record.DeletedBy,
+		PurgeAt:       record.PurgeAt,
+	}
+}
+
+func (s *ssmVersionState) apply(record *SecretRecord) {
+	record.Active = s.Active
+	record.RestoredAt = s.RestoredAt
+	record.RestoredBy = s.RestoredBy
+	record.RestoreReason = s.RestoreReason
+	record.DeletedAt = s.DeletedAt
+	record.DeletedBy = s.DeletedBy
+	record.PurgeAt = s.PurgeAt
+}
+
+// decodeSSMVersion decodes the value of a version of the parameter of the
+// secret, applying the state of the version recorded in the metadata. No
+// record is returned if the version has been purged or is a placeholder
+func decodeSSMVersion(secretName string, value *string, serial int64, metadata *ssmMetadata) (*SecretRecord, error) {
+	state := metadata.Versions[serial]
+	if state != nil && state.Purged {
+		return nil, nil
+	}
+	version := &versionDocument{}
+	err := json.Unmarshal([]byte(aws.StringValue(value)), version)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding version %d of secret '%s': %v", serial, secretName, err)
+	}
+	if version.Placeholder {
+		return nil, nil
+	}
+	record := version.secretRecord(secretName, serial)
+	if state != nil {
+		state.apply(record)
+	}
+	return record, nil
+}
+
+// decodeSSMMetadata decodes the value of a version of the metadata parameter
+// of the secret
+func decodeSSMMetadata(secretName string, value *string) (*ssmMetadata, error) {
+	metadata := &ssmMetadata{}
+	err := json.Unmarshal([]byte(aws.StringValue(value)), metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding metadata of secret '%s': %v", secretName, err)
+	}
+	return metadata, nil
+}
+
+// parameterHistoryByVersion sorts versions of a parameter by their version
+type parameterHistoryByVersion []*ssmclient.ParameterHistory
+
+func (h parameterHistoryByVersion) Len() int      { return len(h) }
+func (h parameterHistoryByVersion) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h parameterHistoryByVersion) Less(i, j int) bool {
+	return aws.Int64Value(h[i].Version) < aws.Int64Value(h[j].Version)
+}
+
+// encodeSSMValue encodes the value of a parameter, which is limited in size
+func encodeSSMValue(value interface{}) (string, error) {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	if len(valueBytes) > maxSSMValueSize {
+		return "", fmt.Errorf("Secret record is too large to be stored in a parameter, %d bytes is more than %d bytes",
+			len(valueBytes), maxSSMValueSize)
+	}
+	return string(valueBytes), nil
+}
@@ -0,0 +1,829 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	ssmclient "github.com/awslabs/ecs-secrets/modules/ssm/client"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// fakeSSMClient is an in-memory stand-in for Parameter Store, which keeps the
+// last maxSSMParameterVersions versions of every parameter. beforePut, if set,
+// is called before the next put so that concurrent puts can be simulated. The
+// names of the parameters read are recorded in gets and histories
+type fakeSSMClient struct {
+	mutex      sync.Mutex
+	parameters map[string][]*ssmclient.ParameterHistory
+	beforePut  func(*ssmclient.PutParameterInput)
+	gets       []string
+	histories  []string
+}
+
+func newFakeSSMClient() *fakeSSMClient {
+	return &fakeSSMClient{parameters: make(map[string][]*ssmclient.ParameterHistory)}
+}
+
+func (c *fakeSSMClient) DeleteParameter(input *ssmclient.DeleteParameterInput) (*ssmclient.DeleteParameterOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name := aws.StringValue(input.Name)
+	if _, ok := c.parameters[name]; !ok {
+		return nil, awserr.New(ssmclient.ParameterNotFoundErrorCode, name, nil)
+	}
+	delete(c.parameters, name)
+	return &ssmclient.DeleteParameterOutput{}, nil
+}
+
+func (c *fakeSSMClient) GetParameter(input *ssmclient.GetParameterInput) (*ssmclient.GetParameterOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.gets = append(c.gets, aws.StringValue(input.Name))
+	name, selector := splitParameterSelector(aws.StringValue(input.Name))
+	history, ok := c.parameters[name]
+	if !ok {
+		return nil, awserr.New(ssmclient.ParameterNotFoundErrorCode, name, nil)
+	}
+	if selector == "" {
+		return &ssmclient.GetParameterOutput{Parameter: latestParameter(history)}, nil
+	}
+	for i := range history {
+		if strconv.FormatInt(aws.Int64Value(history[i].Version), 10) == selector {
+			return &ssmclient.GetParameterOutput{Parameter: latestParameter(history[:i+1])}, nil
+		}
+	}
+	return nil, awserr.New(ssmclient.ParameterVersionNotFoundErrorCode, name, nil)
+}
+
+// splitParameterSelector splits the version selector off a parameter name
+func splitParameterSelector(name string) (string, string) {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+func (c *fakeSSMClient) GetParameterHistory(input *ssmclient.GetParameterHistoryInput) (*ssmclient.GetParameterHistoryOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name := aws.StringValue(input.Name)
+	c.histories = append(c.histories, name)
+	history, ok := c.parameters[name]
+	if !ok {
+		return nil, awserr.New(ssmclient.ParameterNotFoundErrorCode, name, nil)
+	}
+	start, end, nextToken := fakePage(len(history), input.NextToken, input.MaxResults)
+	return &ssmclient.GetParameterHistoryOutput{
+		Parameters: history[start:end],
+		NextToken:  nextToken,
+	}, nil
+}
+
+func (c *fakeSSMClient) GetParametersByPath(input *ssmclient.GetParametersByPathInput) (*ssmclient.GetParametersByPathOutput, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var names []string
+	for name := range c.parameters {
+		if strings.HasPrefix(name, aws.StringValue(input.Path)+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	start, end, nextToken := fakePage(len(names), input.NextToken, input.MaxResults)
+	output := &ssmclient.GetParametersByPathOutput{NextToken: nextToken}
+	for _, name := range names[start:end] {
+		output.Parameters = append(output.Parameters, latestParameter(c.parameters[name]))
+	}
+	return output, nil
+}
+
+func (c *fakeSSMClient) PutParameter(input *ssmclient.PutParameterInput) (*ssmclient.PutParameterOutput, error) {
+	c.mutex.Lock()
+	beforePut := c.beforePut
+	c.beforePut = nil
+	c.mutex.Unlock()
+	if beforePut != nil {
+		beforePut(input)
+	}
+	// Give concurrent writers a chance to read before the put
+	runtime.Gosched()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	name := aws.StringValue(input.Name)
+	history, ok := c.parameters[name]
+	if ok && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(ssmclient.ParameterAlreadyExistsErrorCode, name, nil)
+	}
+	version := int64(1)
+	if ok {
+		version = aws.Int64Value(history[len(history)-1].Version) + 1
+	}
+	history = append(history, &ssmclient.ParameterHistory{
+		Name:    input.Name,
+		Type:    input.Type,
+		KeyId:   input.KeyId,
+		Value:   input.Value,
+		Version: aws.Int64(version),
+	})
+	if int64(len(history)) > maxSSMParameterVersions {
+		history = history[1:]
+	}
+	c.parameters[name] = history
+	return &ssmclient.PutParameterOutput{Version: aws.Int64(version)}, nil
+}
+
+func latestParameter(history []*ssmclient.ParameterHistory) *ssmclient.Parameter {
+	latest := history[len(history)-1]
+	return &ssmclient.Parameter{
+		Name:    latest.Name,
+		Type:    latest.Type,
+		Value:   latest.Value,
+		Version: latest.Version,
+	}
+}
+
+func fakePage(length int, nextToken *string, maxResults *int64) (int, int, *string) {
+	start, _ := strconv.Atoi(aws.StringValue(nextToken))
+	end := start + int(aws.Int64Value(maxResults))
+	if end >= length {
+		return start, length, nil
+	}
+	return start, end, aws.String(strconv.Itoa(end))
+}
+
+func newTestSSMDAO(t *testing.T, ssmClient ssmclient.Client) DAO {
+	d, err := NewSSMDAO("myapp", ssmClient, ssmclient.StringParameterType, "")
+	if err != nil {
+		t.Fatalf("Error creating dao: %v", err)
+	}
+	return d
+}
+
+func putTestSSMRecords(t *testing.T, d DAO, name string, serials ...int64) {
+	for _, serial := range serials {
+		err := d.PutSecretRecord(&SecretRecord{
+			Name:             name,
+			Serial:           serial,
+			EncryptedData:    "data-" + strconv.FormatInt(serial, 10),
+			EncryptedDataKey: "key",
+			Active:           true,
+			CreatedAt:        1500000000 + serial,
+		})
+		if err != nil {
+			t.Fatalf("Error putting secret record %s:%d: %v", name, serial, err)
+		}
+	}
+}
+
+func serialsOf(records []*SecretRecord) []int64 {
+	serials := []int64{}
+	for _, record := range records {
+		serials = append(serials, record.Serial)
+	}
+	return serials
+}
+
+func TestNewSSMDAOInvalidParameterType(t *testing.T) {
+	_, err := NewSSMDAO("myapp", newFakeSSMClient(), "StringList", "")
+	if err == nil {
+		t.Error("Expected error creating dao with an unknown parameter type")
+	}
+	_, err = NewSSMDAO("myapp", newFakeSSMClient(), ssmclient.StringParameterType, "alias/key")
+	if err == nil {
+		t.Error("Expected error creating dao with a key for String parameters")
+	}
+}
+
+func TestSSMPutSecretRecord(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d, err := NewSSMDAO("myapp", ssmClient, ssmclient.SecureStringParameterType, "alias/key")
+	if err != nil {
+		t.Fatalf("Error creating dao: %v", err)
+	}
+	record := &SecretRecord{
+		Name:             "foo",
+		Serial:           1,
+		EncryptedData:    "data",
+		EncryptedDataKey: "key",
+		Active:           true,
+		CreatedAt:        1500000000,
+		CreatedBy:        "arn:aws:iam::123456789012:user/alice",
+		Description:      "database password",
+		Tags:             map[string]string{"team": "db"},
+	}
+	err = d.PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+
+	history := ssmClient.parameters["/ecs-secrets/myapp/secrets/foo"]
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 version of the parameter, got %d", len(history))
+	}
+	if aws.StringValue(history[0].Type) != ssmclient.SecureStringParameterType || aws.StringValue(history[0].KeyId) != "alias/key" {
+		t.Errorf("Unexpected type or key of the parameter: %s, %s", aws.StringValue(history[0].Type), aws.StringValue(history[0].KeyId))
+	}
+
+	loaded, err := d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, record) {
+		t.Errorf("Mismatch between expected and loaded record: %v != %v", record, loaded)
+	}
+}
+
+func TestSSMPutSecretRecordSerialInUse(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	putTestSSMRecords(t, d, "foo", 1, 2)
+
+	for _, serial := range []int64{1, 2} {
+		err := d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: serial})
+		if err != ErrSecretRecordExists {
+			t.Errorf("Expected ErrSecretRecordExists putting serial %d, got: %v", serial, err)
+		}
+	}
+}
+
+func TestSSMPutSecretRecordConcurrentPut(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 1)
+
+	ssmClient.beforePut = func(input *ssmclient.PutParameterInput) {
+		ssmClient.PutParameter(&ssmclient.PutParameterInput{
+			Name:      input.Name,
+			Value:     aws.String(`{"encryptedData":"other","active":true}`),
+			Type:      input.Type,
+			Overwrite: aws.Bool(true),
+		})
+	}
+	err := d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: 2, EncryptedData: "mine", Active: true})
+	if err != ErrSecretRecordExists {
+		t.Fatalf("Expected ErrSecretRecordExists, got: %v", err)
+	}
+
+	latest, err := d.GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	if latest.Serial != 2 || latest.EncryptedData != "other" {
+		t.Errorf("Expected the version put by someone else to be the latest, got: %v", latest)
+	}
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(versions), []int64{1, 2}) {
+		t.Errorf("Expected the version that lost the race to be hidden, got serials: %v", serialsOf(versions))
+	}
+}
+
+func TestSSMPutSecretRecordSkipsSerials(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 3)
+
+	if len(ssmClient.parameters["/ecs-secrets/myapp/secrets/foo"]) != 3 {
+		t.Errorf("Expected placeholder versions to be put for skipped serials")
+	}
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(versions), []int64{3}) {
+		t.Errorf("Expected only serial 3 to be listed, got: %v", serialsOf(versions))
+	}
+	_, err = d.GetSecretRecord("foo", 1)
+	if err == nil {
+		t.Error("Expected error getting a placeholder version")
+	}
+}
+
+func TestSSMPutSecretRecordTooLarge(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	err := d.PutSecretRecord(&SecretRecord{
+		Name:          "foo",
+		Serial:        1,
+		EncryptedData: strings.Repeat("a", maxSSMValueSize),
+	})
+	if err == nil {
+		t.Error("Expected error putting a record larger than a parameter")
+	}
+}
+
+func TestSSMGetSecretRecordNotFound(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	putTestSSMRecords(t, d, "foo", 1)
+
+	for _, key := range []SecretKey{{Name: "foo", Serial: 0}, {Name: "foo", Serial: 2}, {Name: "bar", Serial: 1}} {
+		_, err := d.GetSecretRecord(key.Name, key.Serial)
+		if err == nil {
+			t.Errorf("Expected error getting %s:%d", key.Name, key.Serial)
+		}
+	}
+}
+
+func TestSSMGetSecretRecords(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	putTestSSMRecords(t, d, "foo", 1, 2)
+	putTestSSMRecords(t, d, "bar", 1)
+
+	records, err := d.GetSecretRecords([]SecretKey{
+		{Name: "foo", Serial: 2},
+		{Name: "bar", Serial: 1},
+		{Name: "foo", Serial: 2},
+		{Name: "foo", Serial: 3},
+		{Name: "baz", Serial: 1},
+	})
+	if err != nil {
+		t.Fatalf("Error getting secret records: %v", err)
+	}
+	if len(records) != 2 || records[0].EncryptedData != "data-2" || records[1].Name != "bar" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
+
+func TestSSMRevokeAndRestoreSecretRecord(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	putTestSSMRecords(t, d, "foo", 1, 2)
+
+	err := d.RevokeSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+	active, err := d.GetLatestActiveVersion("foo", 1500000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active.Serial != 1 {
+		t.Errorf("Expected serial 1 to be the latest active version, got: %d", active.Serial)
+	}
+
+	err = d.RestoreSecretRecord("foo", 2, &Restoration{RestoredAt: 1500000100, RestoredBy: "bob", Reason: "mistake"})
+	if err != nil {
+		t.Fatalf("Error restoring secret record: %v", err)
+	}
+	record, err := d.GetSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if !record.Active || record.RestoredAt != 1500000100 || record.RestoredBy != "bob" || record.RestoreReason != "mistake" {
+		t.Errorf("Unexpected restored record: %v", record)
+	}
+
+	err = d.RevokeSecretRecord("foo", 3)
+	if err == nil {
+		t.Error("Expected error revoking a secret record that does not exist")
+	}
+}
+
+func TestSSMGetLatestActiveVersionSkipsExpired(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	putTestSSMRecords(t, d, "foo", 1)
+	err := d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: 2, Active: true, ExpiresAt: 1500000000})
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+
+	active, err := d.GetLatestActiveVersion("foo", 1500000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active.Serial != 1 {
+		t.Errorf("Expected serial 1 to be the latest active version, got: %d", active.Serial)
+	}
+
+	active, err = d.GetLatestActiveVersion("bar", 1500000000)
+	if err != nil || active != nil {
+		t.Errorf("Expected no active version of a secret that does not exist, got: %v, %v", active, err)
+	}
+}
+
+func TestSSMScheduleAndCancelSecretRecordDeletion(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	putTestSSMRecords(t, d, "foo", 1)
+
+	err := d.ScheduleSecretRecordDeletion("foo", 1, &Deletion{DeletedAt: 1500000000, DeletedBy: "alice", PurgeAt: 1502592000})
+	if err != nil {
+		t.Fatalf("Error scheduling deletion: %v", err)
+	}
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if versions[0].PurgeAt != 1502592000 || versions[0].DeletedBy != "alice" || !versions[0].Active {
+		t.Errorf("Unexpected scheduled record: %v", versions[0])
+	}
+
+	err = d.CancelSecretRecordDeletion("foo", 1)
+	if err != nil {
+		t.Fatalf("Error cancelling deletion: %v", err)
+	}
+	record, err := d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if record.PurgeAt != 0 || record.DeletedAt != 0 || record.DeletedBy != "" {
+		t.Errorf("Expected deletion to be cancelled: %v", record)
+	}
+}
+
+func TestSSMDeleteSecretRecord(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 1, 2)
+
+	err := d.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(versions), []int64{2}) {
+		t.Errorf("Expected only serial 2 to be left, got: %v", serialsOf(versions))
+	}
+
+	err = d.DeleteSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	if _, ok := ssmClient.parameters["/ecs-secrets/myapp/secrets/foo"]; ok || len(ssmClient.parameters) != 1 {
+		t.Errorf("Expected only the metadata parameter to be kept, got: %v", ssmClient.parameters)
+	}
+	metadata, _, err := d.(*ssmDAO).getMetadata("foo")
+	if err != nil || len(metadata.Versions) != 0 {
+		t.Errorf("Expected no state of versions to be kept, got: %v, %v", metadata, err)
+	}
+
+	// Serials start over once the parameter has been deleted, as they do
+	// once every version has been purged from DynamoDB
+	putTestSSMRecords(t, d, "foo", 1)
+}
+
+func TestSSMListSecrets(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	names := []string{"db/password", "db/user", "api-key", "dbx", "token"}
+	for _, name := range names {
+		putTestSSMRecords(t, d, name, 1, 2)
+	}
+	err := d.PutSecretLabels(&SecretLabels{Name: ApplicationMetadataName, RetentionMaxVersions: 2})
+	if err != nil {
+		t.Fatalf("Error putting application metadata: %v", err)
+	}
+
+	var listed []string
+	token := ""
+	pages := 0
+	for {
+		records, nextToken, err := d.ListSecrets("db", token, 2)
+		if err != nil {
+			t.Fatalf("Error listing secrets: %v", err)
+		}
+		for _, record := range records {
+			if record.Serial != 2 || record.EncryptedData != "" {
+				t.Errorf("Expected summary of the latest version, got: %v", record)
+			}
+			listed = append(listed, record.Name)
+		}
+		pages++
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	sort.Strings(listed)
+	if !reflect.DeepEqual(listed, []string{"db/password", "db/user", "dbx"}) {
+		t.Errorf("Unexpected secrets listed: %v", listed)
+	}
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+}
+
+func TestSSMSecretLabels(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 1)
+
+	metadata, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if metadata.Revision != 0 || metadata.Labels == nil {
+		t.Errorf("Expected empty metadata, got: %v", metadata)
+	}
+
+	metadata.Labels["prod"] = 1
+	err = d.PutSecretLabels(metadata)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	if metadata.Revision != 1 {
+		t.Errorf("Expected revision 1, got %d", metadata.Revision)
+	}
+
+	stale := &SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 1}}
+	err = d.PutSecretLabels(stale)
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+
+	// Revoking a version changes the metadata parameter, but not its labels
+	err = d.RevokeSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+	loaded, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if loaded.Revision != 2 || loaded.Labels["prod"] != 1 {
+		t.Errorf("Unexpected metadata: %v", loaded)
+	}
+
+	err = d.DeleteSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error deleting secret metadata: %v", err)
+	}
+	loaded, err = d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if len(loaded.Labels) != 0 {
+		t.Errorf("Expected labels to be deleted, got: %v", loaded.Labels)
+	}
+	record, err := d.GetSecretRecord("foo", 1)
+	if err != nil || record.Active {
+		t.Errorf("Expected the version to stay revoked, got: %v, %v", record, err)
+	}
+}
+
+func TestSSMApplicationMetadata(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+
+	err := d.PutSecretLabels(&SecretLabels{Name: ApplicationMetadataName, RetentionMaxAge: 3600})
+	if err != nil {
+		t.Fatalf("Error putting application metadata: %v", err)
+	}
+	if _, ok := ssmClient.parameters["/ecs-secrets/myapp/application"]; !ok {
+		t.Errorf("Expected application metadata parameter, got: %v", ssmClient.parameters)
+	}
+	metadata, err := d.GetSecretLabels(ApplicationMetadataName)
+	if err != nil {
+		t.Fatalf("Error getting application metadata: %v", err)
+	}
+	if metadata.RetentionMaxAge != 3600 || metadata.Revision != 1 {
+		t.Errorf("Unexpected application metadata: %v", metadata)
+	}
+}
+
+func TestSSMPutSecretLabelsConcurrentPut(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	err := d.PutSecretLabels(&SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 1}})
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+
+	ssmClient.beforePut = func(input *ssmclient.PutParameterInput) {
+		err := d.PutSecretLabels(&SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 2}, Revision: 1})
+		if err != nil {
+			t.Fatalf("Error putting secret metadata: %v", err)
+		}
+	}
+	err = d.PutSecretLabels(&SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 3}, Revision: 1})
+	if err != ErrSecretLabelsModified {
+		t.Fatalf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+
+	metadata, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if metadata.Labels["prod"] != 2 || metadata.Revision != 2 {
+		t.Errorf("Expected the metadata put by someone else to be in effect, got: %v", metadata)
+	}
+	if history := ssmClient.parameters["/ecs-secrets/myapp/metadata/foo"]; len(history) != 3 {
+		t.Errorf("Expected the metadata put by someone else not to be put back, got %d versions", len(history))
+	}
+
+	// The version that lost the race is ignored when the metadata is put
+	// again at the revision in effect
+	metadata.Labels["prod"] = 4
+	err = d.PutSecretLabels(metadata)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	if metadata.Revision != 4 {
+		t.Errorf("Expected revision 4, got %d", metadata.Revision)
+	}
+	loaded, err := d.GetSecretLabels("foo")
+	if err != nil || loaded.Labels["prod"] != 4 {
+		t.Errorf("Unexpected metadata: %v, %v", loaded, err)
+	}
+}
+
+func TestSSMConcurrentMetadataUpdates(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	serials := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	putTestSSMRecords(t, d, "foo", serials...)
+
+	// Every writer revokes its own version, retrying when it loses a race.
+	// None of the versions revoked may be lost
+	var wg sync.WaitGroup
+	for _, serial := range serials {
+		wg.Add(1)
+		go func(serial int64) {
+			defer wg.Done()
+			for {
+				err := d.RevokeSecretRecord("foo", serial)
+				if err == nil {
+					return
+				}
+				if err != ErrSecretLabelsModified {
+					t.Errorf("Error revoking secret record %d: %v", serial, err)
+					return
+				}
+			}
+		}(serial)
+	}
+	wg.Wait()
+
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	for _, version := range versions {
+		if version.Active {
+			t.Errorf("Expected version %d to be revoked", version.Serial)
+		}
+	}
+}
+
+func TestSSMUpdateVersionPrunesState(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 1)
+
+	err := d.ScheduleSecretRecordDeletion("foo", 1, &Deletion{DeletedAt: 1500000000, DeletedBy: "alice", PurgeAt: 1502592000})
+	if err != nil {
+		t.Fatalf("Error scheduling deletion: %v", err)
+	}
+	err = d.CancelSecretRecordDeletion("foo", 1)
+	if err != nil {
+		t.Fatalf("Error cancelling deletion: %v", err)
+	}
+	metadata, _, err := d.(*ssmDAO).getMetadata("foo")
+	if err != nil {
+		t.Fatalf("Error getting metadata: %v", err)
+	}
+	if len(metadata.Versions) != 0 {
+		t.Errorf("Expected the state of a version back as it was put to be removed, got: %v", metadata.Versions)
+	}
+}
+
+func TestSSMPutSecretRecordVersionsKept(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 1, 2)
+
+	err := d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: 101, Active: true})
+	if err == nil {
+		t.Fatal("Expected error putting a version that would drop version 1")
+	}
+	if len(ssmClient.parameters["/ecs-secrets/myapp/secrets/foo"]) != 2 {
+		t.Errorf("Expected no version to be put")
+	}
+
+	err = d.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	putTestSSMRecords(t, d, "foo", 101)
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(versions), []int64{2, 101}) {
+		t.Errorf("Unexpected serials: %v", serialsOf(versions))
+	}
+	metadata, _, err := d.(*ssmDAO).getMetadata("foo")
+	if err != nil {
+		t.Fatalf("Error getting metadata: %v", err)
+	}
+	if _, ok := metadata.Versions[1]; ok {
+		t.Errorf("Expected the state of the version dropped by Parameter Store to be removed, got: %v", metadata.Versions)
+	}
+
+	err = d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: 102, Active: true})
+	if err == nil {
+		t.Error("Expected error putting a version that would drop version 2")
+	}
+}
+
+func TestSSMPutSecretLabelsTooLarge(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	labels := make(map[string]int64)
+	for i := 0; i < 100; i++ {
+		labels["label-"+strings.Repeat("x", 30)+strconv.Itoa(i)] = 1
+	}
+	err := d.PutSecretLabels(&SecretLabels{Name: "foo", Labels: labels})
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("Expected error putting metadata larger than a parameter, got: %v", err)
+	}
+	if len(ssmClient.parameters) != 0 {
+		t.Errorf("Expected no parameter to be put, got: %v", ssmClient.parameters)
+	}
+}
+
+func TestSSMReadsOnlyVersionsNeeded(t *testing.T) {
+	ssmClient := newFakeSSMClient()
+	d := newTestSSMDAO(t, ssmClient)
+	putTestSSMRecords(t, d, "foo", 1, 2, 3)
+	ssmClient.histories = nil
+
+	record, err := d.GetSecretRecord("foo", 2)
+	if err != nil || record.Serial != 2 {
+		t.Fatalf("Expected serial 2, got: %v, %v", record, err)
+	}
+	record, err = d.GetLatestVersion("foo")
+	if err != nil || record.Serial != 3 {
+		t.Fatalf("Expected serial 3, got: %v, %v", record, err)
+	}
+	record, err = d.GetLatestActiveVersion("foo", 0)
+	if err != nil || record.Serial != 3 {
+		t.Fatalf("Expected serial 3, got: %v, %v", record, err)
+	}
+	records, _, err := d.ListSecrets("", "", 0)
+	if err != nil || len(records) != 1 || records[0].Serial != 3 || records[0].EncryptedData != "" {
+		t.Fatalf("Expected summary of serial 3, got: %v, %v", records, err)
+	}
+	if len(ssmClient.histories) != 0 {
+		t.Errorf("Expected no history to be read, got: %v", ssmClient.histories)
+	}
+	_, err = d.GetSecretRecord("foo", 4)
+	if err != ErrSecretRecordNotFound {
+		t.Errorf("Expected ErrSecretRecordNotFound, got: %v", err)
+	}
+
+	// The history is read once the latest version no longer matches
+	err = d.RevokeSecretRecord("foo", 3)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+	record, err = d.GetLatestActiveVersion("foo", 0)
+	if err != nil || record.Serial != 2 {
+		t.Fatalf("Expected serial 2, got: %v, %v", record, err)
+	}
+}
+
+func TestSSMMaxEncryptedDataSize(t *testing.T) {
+	d := newTestSSMDAO(t, newFakeSSMClient())
+	record := &SecretRecord{
+		Name:             "foo",
+		Serial:           1,
+		Active:           true,
+		CreatedBy:        "arn:aws:iam::123456789012:user/foo",
+		EncryptedDataKey: strings.Repeat("k", ssmDataKeySize),
+	}
+	size := d.(SizeLimiter).MaxEncryptedDataSize(record)
+	if size <= 0 || size >= maxSSMValueSize {
+		t.Fatalf("Unexpected maximum size: %d", size)
+	}
+
+	record.EncryptedData = strings.Repeat("a", size+1)
+	if err := d.PutSecretRecord(record); err == nil {
+		t.Error("Expected error putting encrypted data larger than the maximum size")
+	}
+	record.EncryptedData = strings.Repeat("a", size)
+	if err := d.PutSecretRecord(record); err != nil {
+		t.Errorf("Error putting encrypted data of the maximum size: %v", err)
+	}
+}
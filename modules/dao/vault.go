@@ -0,0 +1,801 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client"
+)
+
+// Secrets are stored in the KV version 2 secrets engine of Vault. Every
+// version of a secret is a version of the secret at
+// <prefix><app>/secrets/<name>, so serials are the versions Vault gives them.
+// Revoking a version deletes it in Vault, which hides its data until it is
+// undeleted, and purging a version destroys it. Labels, retention policies and
+// the state of versions that Vault does not keep are kept in a secret per
+// secret at <prefix><app>/metadata/<name>, whose version is the revision of
+// the metadata. Every write is a check-and-set write, so serials and revisions
+// are checked the same way DynamoDB checks them
+const (
+	// DefaultVaultKeyPrefix is the prefix of the paths of the secrets of every
+	// application, relative to the mount of the secrets engine
+	DefaultVaultKeyPrefix = "ecs-secrets/"
+	// maxVaultMetadataAttempts is the number of times the metadata of a secret
+	// is read and written again when it is modified by someone else at the
+	// same time
+	maxVaultMetadataAttempts = 3
+	// vaultMaxVersions is the number of versions Vault is told to keep of
+	// every secret, rather than the 10 it keeps by default. Vault destroys the
+	// oldest versions beyond that
+	vaultMaxVersions = int64(100)
+)
+
+// errVaultMetadataUnchanged is returned by updates of the metadata of a secret
+// that leave it as it was, so that it is not written again
+var errVaultMetadataUnchanged = errors.New("Metadata unchanged")
+
+// vaultVersionState defines the state of a version of a secret that has
+// changed since the version was written, as versions can't be changed in
+// Vault. The data of revoked versions can't be read until they are restored,
+// so the rest of the version, without its encrypted data, is kept when it is
+// revoked
+type vaultVersionState struct {
+	RestoredAt    int64            `json:"restoredAt,omitempty"`
+	RestoredBy    string           `json:"restoredBy,omitempty"`
+	RestoreReason string           `json:"restoreReason,omitempty"`
+	DeletedAt     int64            `json:"deletedAt,omitempty"`
+	DeletedBy     string           `json:"deletedBy,omitempty"`
+	PurgeAt       int64            `json:"purgeAt,omitempty"`
+	Revoked       *versionDocument `json:"revoked,omitempty"`
+}
+
+func (s *vaultVersionState) apply(record *SecretRecord) {
+	record.RestoredAt = s.RestoredAt
+	record.RestoredBy = s.RestoredBy
+	record.RestoreReason = s.RestoreReason
+	record.DeletedAt = s.DeletedAt
+	record.DeletedBy = s.DeletedBy
+	record.PurgeAt = s.PurgeAt
+}
+
+func (s *vaultVersionState) empty() bool {
+	return *s == vaultVersionState{}
+}
+
+// vaultMetadata defines the data of the metadata secret of a secret
+type vaultMetadata struct {
+	Labels               map[string]int64             `json:"labels,omitempty"`
+	RetentionMaxVersions int64                        `json:"retentionMaxVersions,omitempty"`
+	RetentionMaxAge      int64                        `json:"retentionMaxAge,omitempty"`
+	Versions             map[int64]*vaultVersionState `json:"versions,omitempty"`
+}
+
+func (m *vaultMetadata) empty() bool {
+	return len(m.Labels) == 0 && m.RetentionMaxVersions == 0 && m.RetentionMaxAge == 0 && len(m.Versions) == 0
+}
+
+// versionState returns the state of a version, adding it if needed. Empty
+// states are removed before the metadata is written
+func (m *vaultMetadata) versionState(serial int64) *vaultVersionState {
+	if m.Versions == nil {
+		m.Versions = make(map[int64]*vaultVersionState)
+	}
+	state, ok := m.Versions[serial]
+	if !ok {
+		state = &vaultVersionState{}
+		m.Versions[serial] = state
+	}
+	return state
+}
+
+type vaultDAO struct {
+	appName     string
+	vaultClient vaultclient.Client
+	keyPrefix   string
+}
+
+// NewVaultDAO creates a new DAO object backed by the KV version 2 secrets
+// engine of Vault. Secrets are always encrypted before they are stored
+func NewVaultDAO(appName string, vaultClient vaultclient.Client, keyPrefix string) DAO {
+	return &vaultDAO{
+		appName:     appName,
+		vaultClient: vaultClient,
+		keyPrefix:   keyPrefix,
+	}
+}
+
+// GetSecretRecord gets a version of a secret from Vault. Revoked versions are
+// returned without their encrypted data
+func (d *vaultDAO) GetSecretRecord(namespace string, serial int64) (*SecretRecord, error) {
+	metadata, _, err := d.getMetadata(namespace)
+	if err != nil {
+		return nil, err
+	}
+	secretMetadata, err := d.readSecretMetadata(namespace)
+	if err != nil {
+		return nil, err
+	}
+	record, err := d.getVersion(namespace, serial, secretMetadata, metadata)
+	if err == nil && record == nil {
+		err = ErrSecretRecordNotFound
+	}
+	return record, err
+}
+
+// GetSecretRecords gets versions of secrets from Vault. Versions that don't
+// exist are left out
+func (d *vaultDAO) GetSecretRecords(keys []SecretKey) ([]*SecretRecord, error) {
+	var records []*SecretRecord
+	seen := make(map[SecretKey]bool)
+	metadataByName := make(map[string]*vaultMetadata)
+	secretMetadataByName := make(map[string]*vaultclient.Metadata)
+	for _, key := range keys {
+		if seen[key] || key.Serial <= labelsSerial {
+			continue
+		}
+		seen[key] = true
+
+		metadata, ok := metadataByName[key.Name]
+		if !ok {
+			var err error
+			metadata, _, err = d.getMetadata(key.Name)
+			if err != nil {
+				return nil, err
+			}
+			metadataByName[key.Name] = metadata
+		}
+		secretMetadata, ok := secretMetadataByName[key.Name]
+		if !ok {
+			var err error
+			secretMetadata, err = d.readSecretMetadata(key.Name)
+			if err != nil {
+				return nil, err
+			}
+			secretMetadataByName[key.Name] = secretMetadata
+		}
+
+		record, err := d.getVersion(key.Name, key.Serial, secretMetadata, metadata)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// PutSecretRecord writes a version of a secret to Vault. Existing versions are
+// never overwritten, ErrSecretRecordExists is returned if a version with the
+// same serial already exists. Serials that are skipped are filled with
+// destroyed placeholder versions, as Vault numbers versions itself. Writing
+// the version fails if Vault would destroy a version that has not been purged
+// to make room for it
+func (d *vaultDAO) PutSecretRecord(record *SecretRecord) error {
+	secretMetadata, err := d.readSecretMetadata(record.Name)
+	if err != nil {
+		return err
+	}
+	currentVersion := int64(0)
+	maxVersions := int64(0)
+	if secretMetadata != nil {
+		currentVersion = secretMetadata.CurrentVersion
+		maxVersions = secretMetadata.MaxVersions
+	}
+	if record.Serial <= currentVersion {
+		return ErrSecretRecordExists
+	}
+
+	path := d.secretPath(record.Name)
+	if maxVersions < vaultMaxVersions {
+		// Secrets written before the number of versions was set keep it
+		// from their next version on
+		maxVersions = vaultMaxVersions
+		err = d.vaultClient.WriteMetadata(path, maxVersions)
+		if err != nil {
+			return err
+		}
+	}
+	if secretMetadata != nil {
+		for _, serial := range vaultSerials(secretMetadata) {
+			if serial <= record.Serial-maxVersions {
+				return fmt.Errorf("Vault keeps %d versions of secret '%s', saving version %d would destroy version %d. Purge version %d first",
+					maxVersions, record.Name, record.Serial, serial, serial)
+			}
+		}
+	}
+	for version := currentVersion + 1; version < record.Serial; version++ {
+		err = d.writeVersion(path, &versionDocument{Placeholder: true}, version-1)
+		if err != nil {
+			return err
+		}
+		err = d.vaultClient.DestroyVersions(path, []int64{version})
+		if err != nil {
+			return err
+		}
+	}
+	err = d.writeVersion(path, newVersionDocument(record), record.Serial-1)
+	if err != nil || record.Active {
+		return err
+	}
+	return d.RevokeSecretRecord(record.Name, record.Serial)
+}
+
+// RevokeSecretRecord revokes a version of a secret by deleting it in Vault
+func (d *vaultDAO) RevokeSecretRecord(namespace string, serial int64) error {
+	err := d.updateMetadata(namespace, func(metadata *vaultMetadata) error {
+		record, err := d.getLiveVersion(namespace, serial, metadata)
+		if err != nil {
+			return err
+		}
+		if !record.Active {
+			return errVaultMetadataUnchanged
+		}
+		revoked := newVersionDocument(record)
+		revoked.EncryptedData = ""
+		revoked.EncryptedDataKey = ""
+		state := metadata.versionState(serial)
+		state.Revoked = revoked
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return d.vaultClient.DeleteVersions(d.secretPath(namespace), []int64{serial})
+}
+
+// RestoreSecretRecord reinstates a revoked version of a secret by undeleting it
+// in Vault, recording who restored it, when and why
+func (d *vaultDAO) RestoreSecretRecord(namespace string, serial int64, restoration *Restoration) error {
+	_, err := d.getLiveVersion(namespace, serial, &vaultMetadata{})
+	if err != nil {
+		return err
+	}
+	err = d.vaultClient.UndeleteVersions(d.secretPath(namespace), []int64{serial})
+	if err != nil {
+		return err
+	}
+	return d.updateMetadata(namespace, func(metadata *vaultMetadata) error {
+		state := metadata.versionState(serial)
+		state.RestoredAt = restoration.RestoredAt
+		state.RestoredBy = restoration.RestoredBy
+		state.RestoreReason = restoration.Reason
+		state.Revoked = nil
+		return nil
+	})
+}
+
+// ScheduleSecretRecordDeletion marks a version of a secret in Vault as
+// scheduled for deletion
+func (d *vaultDAO) ScheduleSecretRecordDeletion(namespace string, serial int64, deletion *Deletion) error {
+	return d.updateVersionState(namespace, serial, func(state *vaultVersionState) {
+		state.DeletedAt = deletion.DeletedAt
+		state.DeletedBy = deletion.DeletedBy
+		state.PurgeAt = deletion.PurgeAt
+	})
+}
+
+// CancelSecretRecordDeletion removes the scheduled deletion of a version of a
+// secret in Vault
+func (d *vaultDAO) CancelSecretRecordDeletion(namespace string, serial int64) error {
+	return d.updateVersionState(namespace, serial, func(state *vaultVersionState) {
+		state.DeletedAt = 0
+		state.DeletedBy = ""
+		state.PurgeAt = 0
+	})
+}
+
+// DeleteSecretRecord destroys a version of a secret in Vault. The secret is
+// deleted along with its metadata in Vault once every version has been
+// destroyed
+func (d *vaultDAO) DeleteSecretRecord(namespace string, serial int64) error {
+	secretMetadata, err := d.readSecretMetadata(namespace)
+	if err != nil || secretMetadata == nil {
+		return err
+	}
+	path := d.secretPath(namespace)
+	if version, ok := secretMetadata.Versions[serial]; ok && !version.Destroyed {
+		err = d.vaultClient.DestroyVersions(path, []int64{serial})
+		if err != nil {
+			return err
+		}
+		version.Destroyed = true
+	}
+
+	err = d.updateMetadata(namespace, func(metadata *vaultMetadata) error {
+		if _, ok := metadata.Versions[serial]; !ok {
+			return errVaultMetadataUnchanged
+		}
+		delete(metadata.Versions, serial)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, version := range secretMetadata.Versions {
+		if !version.Destroyed {
+			return nil
+		}
+	}
+	return d.deleteSecret(path)
+}
+
+// GetLatestVersion gets the latest version of the secret from Vault
+func (d *vaultDAO) GetLatestVersion(secretName string) (*SecretRecord, error) {
+	return d.getLatestVersion(secretName, func(record *SecretRecord) bool {
+		return true
+	})
+}
+
+// GetLatestActiveVersion gets the latest version of the secret from Vault that
+// is active and has not expired at the time given in seconds
+func (d *vaultDAO) GetLatestActiveVersion(secretName string, now int64) (*SecretRecord, error) {
+	return d.getLatestVersion(secretName, func(record *SecretRecord) bool {
+		return record.Active && (record.ExpiresAt == 0 || record.ExpiresAt > now)
+	})
+}
+
+// ListSecrets lists the latest version of every secret in Vault whose name
+// starts with the prefix, in the order of their names, one page at a time.
+// The records returned do not contain any encrypted data. Vault does not page
+// its listings, so the token returned is the name of the last secret listed,
+// and is empty when there are no more pages. Folders that only hold secrets
+// listed on earlier pages are not listed again, and no more secrets are read
+// once the page is full
+func (d *vaultDAO) ListSecrets(prefix string, nextToken string, limit int64) ([]*SecretRecord, string, error) {
+	var records []*SecretRecord
+	token := ""
+	err := d.walkSecretNames("", prefix, nextToken, func(name string) (bool, error) {
+		if limit > 0 && int64(len(records)) == limit {
+			token = records[len(records)-1].Name
+			return false, nil
+		}
+		record, err := d.GetLatestVersion(name)
+		if err != nil || record == nil {
+			return true, err
+		}
+		record.EncryptedData = ""
+		record.EncryptedDataKey = ""
+		records = append(records, record)
+		return true, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return records, token, nil
+}
+
+// ListVersions lists every version of the secret in Vault, in the order of
+// their serials. The records returned do not contain any encrypted data
+func (d *vaultDAO) ListVersions(secretName string) ([]*SecretRecord, error) {
+	metadata, _, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	secretMetadata, err := d.readSecretMetadata(secretName)
+	if err != nil || secretMetadata == nil {
+		return nil, err
+	}
+
+	var records []*SecretRecord
+	for _, serial := range vaultSerials(secretMetadata) {
+		record, err := d.getVersion(secretName, serial, secretMetadata, metadata)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+		record.EncryptedData = ""
+		record.EncryptedDataKey = ""
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// GetSecretLabels gets the metadata of the secret from Vault. Metadata with
+// no revision is returned if none has been written for the secret yet
+func (d *vaultDAO) GetSecretLabels(secretName string) (*SecretLabels, error) {
+	metadata, revision, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	labels := metadata.Labels
+	if labels == nil {
+		labels = map[string]int64{}
+	}
+	return &SecretLabels{
+		Name:                 secretName,
+		Serial:               labelsSerial,
+		Labels:               labels,
+		RetentionMaxVersions: metadata.RetentionMaxVersions,
+		RetentionMaxAge:      metadata.RetentionMaxAge,
+		Revision:             revision,
+	}, nil
+}
+
+// PutSecretLabels writes the metadata of the secret to Vault.
+// ErrSecretLabelsModified is returned if the metadata has been written by
+// someone else since it was read. The revision of the metadata is incremented
+// when it is written
+func (d *vaultDAO) PutSecretLabels(metadata *SecretLabels) error {
+	current, revision, err := d.getMetadata(metadata.Name)
+	if err != nil {
+		return err
+	}
+	if revision != metadata.Revision {
+		return ErrSecretLabelsModified
+	}
+	current.Labels = metadata.Labels
+	current.RetentionMaxVersions = metadata.RetentionMaxVersions
+	current.RetentionMaxAge = metadata.RetentionMaxAge
+	revision, err = d.putMetadata(metadata.Name, current, revision)
+	if err != nil {
+		return err
+	}
+	metadata.Revision = revision
+	return nil
+}
+
+// DeleteSecretLabels deletes the labels and retention policy of the secret
+// from Vault. The state of its versions is kept until the versions are
+// deleted, the metadata secret is deleted once nothing is left in it
+func (d *vaultDAO) DeleteSecretLabels(secretName string) error {
+	empty := false
+	err := d.updateMetadata(secretName, func(metadata *vaultMetadata) error {
+		metadata.Labels = nil
+		metadata.RetentionMaxVersions = 0
+		metadata.RetentionMaxAge = 0
+		empty = metadata.empty()
+		return nil
+	})
+	if err != nil || !empty {
+		return err
+	}
+	return d.deleteSecret(d.metadataPath(secretName))
+}
+
+// appPath returns the path of the secrets of the given kind
+func (d *vaultDAO) appPath(kind string) string {
+	return d.keyPrefix + d.appName + "/" + kind
+}
+
+// secretPath returns the path of the Vault secret that holds the versions of
+// the secret
+func (d *vaultDAO) secretPath(secretName string) string {
+	return d.appPath("secrets") + "/" + secretName
+}
+
+// metadataPath returns the path of the Vault secret that holds the metadata
+// of the secret. The metadata of the application is kept apart, as its name
+// is not a valid path
+func (d *vaultDAO) metadataPath(secretName string) string {
+	if secretName == ApplicationMetadataName {
+		return d.appPath("application")
+	}
+	return d.appPath("metadata") + "/" + secretName
+}
+
+// getLatestVersion gets the latest version of the secret that matches. Only
+// the versions that need to be checked are read
+func (d *vaultDAO) getLatestVersion(secretName string, matches func(*SecretRecord) bool) (*SecretRecord, error) {
+	secretMetadata, err := d.readSecretMetadata(secretName)
+	if err != nil || secretMetadata == nil {
+		return nil, err
+	}
+	metadata, _, err := d.getMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+
+	serials := vaultSerials(secretMetadata)
+	for i := len(serials) - 1; i >= 0; i-- {
+		record, err := d.getVersion(secretName, serials[i], secretMetadata, metadata)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil && matches(record) {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+// getVersion gets a version of the secret, or nil if it does not exist, has
+// been destroyed or is a placeholder. The state of the version recorded in
+// the metadata is applied to it
+func (d *vaultDAO) getVersion(secretName string, serial int64, secretMetadata *vaultclient.Metadata, metadata *vaultMetadata) (*SecretRecord, error) {
+	if secretMetadata == nil {
+		return nil, nil
+	}
+	version, ok := secretMetadata.Versions[serial]
+	if !ok || version.Destroyed {
+		return nil, nil
+	}
+	state := metadata.Versions[serial]
+
+	var record *SecretRecord
+	if vaultVersionDeleted(version) {
+		if state != nil && state.Revoked != nil {
+			record = state.Revoked.secretRecord(secretName, serial)
+		} else {
+			// The version was deleted outside of ecs-secrets, so all that
+			// is known about it is what Vault keeps
+			record = &SecretRecord{
+				Name:      secretName,
+				Serial:    serial,
+				CreatedAt: vaultTime(version.CreatedTime),
+			}
+		}
+		record.Active = false
+	} else {
+		output, err := d.vaultClient.ReadVersion(d.secretPath(secretName), serial)
+		if vaultclient.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		document := &versionDocument{}
+		err = json.Unmarshal(output.Data, document)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding version %d of secret '%s': %v", serial, secretName, err)
+		}
+		if document.Placeholder {
+			return nil, nil
+		}
+		record = document.secretRecord(secretName, serial)
+		record.Active = true
+	}
+	if state != nil {
+		state.apply(record)
+	}
+	return record, nil
+}
+
+// getLiveVersion gets a version of the secret that has not been destroyed,
+// returning an error if it does not exist
+func (d *vaultDAO) getLiveVersion(secretName string, serial int64, metadata *vaultMetadata) (*SecretRecord, error) {
+	secretMetadata, err := d.readSecretMetadata(secretName)
+	if err != nil {
+		return nil, err
+	}
+	record, err := d.getVersion(secretName, serial, secretMetadata, metadata)
+	if err == nil && record == nil {
+		err = ErrSecretRecordNotFound
+	}
+	return record, err
+}
+
+// updateVersionState changes the state of a version of the secret recorded in
+// the metadata. An error is returned if the version does not exist
+func (d *vaultDAO) updateVersionState(secretName string, serial int64, update func(*vaultVersionState)) error {
+	return d.updateMetadata(secretName, func(metadata *vaultMetadata) error {
+		_, err := d.getLiveVersion(secretName, serial, metadata)
+		if err != nil {
+			return err
+		}
+		update(metadata.versionState(serial))
+		return nil
+	})
+}
+
+// updateMetadata reads the metadata of the secret, changes it with update and
+// writes it back, starting over if it was modified by someone else in the
+// meantime
+func (d *vaultDAO) updateMetadata(secretName string, update func(*vaultMetadata) error) error {
+	for attempt := 0; attempt < maxVaultMetadataAttempts; attempt++ {
+		metadata, revision, err := d.getMetadata(secretName)
+		if err != nil {
+			return err
+		}
+		err = update(metadata)
+		if err == errVaultMetadataUnchanged {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for serial, state := range metadata.Versions {
+			if state.empty() {
+				delete(metadata.Versions, serial)
+			}
+		}
+		if revision == 0 && metadata.empty() {
+			return nil
+		}
+
+		_, err = d.putMetadata(secretName, metadata, revision)
+		if err != ErrSecretLabelsModified {
+			return err
+		}
+	}
+	return ErrSecretLabelsModified
+}
+
+// getMetadata gets the metadata of the secret along with its revision, which
+// is 0 if there is no metadata secret
+func (d *vaultDAO) getMetadata(secretName string) (*vaultMetadata, int64, error) {
+	output, err := d.vaultClient.ReadVersion(d.metadataPath(secretName), 0)
+	if vaultclient.IsNotFound(err) {
+		// The current version of the metadata secret may have been deleted
+		// outside of ecs-secrets, in which case it still has to be written
+		// at the current version
+		secretMetadata, err := d.readMetadata(d.metadataPath(secretName))
+		if err != nil || secretMetadata == nil {
+			return &vaultMetadata{}, 0, err
+		}
+		return &vaultMetadata{}, secretMetadata.CurrentVersion, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	metadata := &vaultMetadata{}
+	err = json.Unmarshal(output.Data, metadata)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error decoding metadata of secret '%s': %v", secretName, err)
+	}
+	return metadata, output.Metadata.Version, nil
+}
+
+// putMetadata writes the metadata of the secret that was read at the revision,
+// returning its new revision. ErrSecretLabelsModified is returned if it has
+// been written by someone else since
+func (d *vaultDAO) putMetadata(secretName string, metadata *vaultMetadata, revision int64) (int64, error) {
+	output, err := d.vaultClient.WriteVersion(d.metadataPath(secretName), metadata, revision)
+	if vaultclient.IsCheckAndSetMismatch(err) {
+		return 0, ErrSecretLabelsModified
+	}
+	if err != nil {
+		return 0, err
+	}
+	return output.Version, nil
+}
+
+// writeVersion writes a version of the secret at the path, if its current
+// version is cas. ErrSecretRecordExists is returned if it is not
+func (d *vaultDAO) writeVersion(path string, document *versionDocument, cas int64) error {
+	_, err := d.vaultClient.WriteVersion(path, document, cas)
+	if vaultclient.IsCheckAndSetMismatch(err) {
+		return ErrSecretRecordExists
+	}
+	return err
+}
+
+// readSecretMetadata reads the metadata Vault keeps about the versions of the
+// secret, or nil if the secret does not exist
+func (d *vaultDAO) readSecretMetadata(secretName string) (*vaultclient.Metadata, error) {
+	return d.readMetadata(d.secretPath(secretName))
+}
+
+func (d *vaultDAO) readMetadata(path string) (*vaultclient.Metadata, error) {
+	metadata, err := d.vaultClient.ReadMetadata(path)
+	if vaultclient.IsNotFound(err) {
+		return nil, nil
+	}
+	return metadata, err
+}
+
+// deleteSecret deletes the Vault secret at the path along with all of its
+// versions
+func (d *vaultDAO) deleteSecret(path string) error {
+	err := d.vaultClient.DeleteMetadata(path)
+	if vaultclient.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// errVaultWalkStopped is returned by walkSecretNames once visit asks it to
+// stop
+var errVaultWalkStopped = errors.New("Walk stopped")
+
+// walkSecretNames visits the names of the secrets in the folder that start
+// with the prefix and come after the token, in order, until visit returns
+// false. Secrets whose names contain '/' are nested in folders, which are only
+// listed if they can contain such names
+func (d *vaultDAO) walkSecretNames(folder string, prefix string, after string, visit func(string) (bool, error)) error {
+	err := d.walkFolder(folder, prefix, after, visit)
+	if err == errVaultWalkStopped {
+		return nil
+	}
+	return err
+}
+
+func (d *vaultDAO) walkFolder(folder string, prefix string, after string, visit func(string) (bool, error)) error {
+	keys, err := d.vaultClient.List(d.appPath("secrets") + "/" + folder)
+	if vaultclient.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Folders end with '/', so sorting the keys sorts the names in them too
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := folder + key
+		if !strings.HasSuffix(name, "/") {
+			if name <= after || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			more, err := visit(name)
+			if err != nil {
+				return err
+			}
+			if !more {
+				return errVaultWalkStopped
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) && !strings.HasPrefix(prefix, name) {
+			continue
+		}
+		// Every name in a folder that sorts before the token comes before
+		// it, unless the token is in the folder
+		if name <= after && !strings.HasPrefix(after, name) {
+			continue
+		}
+		err = d.walkFolder(name, prefix, after, visit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vaultSerials returns the versions of the secret that have not been
+// destroyed, in order
+func vaultSerials(secretMetadata *vaultclient.Metadata) []int64 {
+	var serials []int64
+	for serial, version := range secretMetadata.Versions {
+		if !version.Destroyed {
+			serials = append(serials, serial)
+		}
+	}
+	sort.Sort(int64s(serials))
+	return serials
+}
+
+// vaultVersionDeleted returns true if the version has been deleted. Versions
+// can be deleted in the future when the secret has a delete_version_after
+func vaultVersionDeleted(version *vaultclient.VersionMetadata) bool {
+	if version.DeletionTime == "" {
+		return false
+	}
+	deletionTime, err := time.Parse(time.RFC3339Nano, version.DeletionTime)
+	return err != nil || !deletionTime.After(time.Now())
+}
+
+// vaultTime converts a time returned by Vault to seconds
+func vaultTime(value string) int64 {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0
+	}
+	return parsed.Unix()
+}
+
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,600 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dao
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client"
+)
+
+const testVaultToken = "s.test"
+
+// fakeVaultDefaultMaxVersions is the number of versions Vault keeps of
+// secrets that have no max_versions of their own
+const fakeVaultDefaultMaxVersions = 10
+
+type fakeVaultVersion struct {
+	data      json.RawMessage
+	created   time.Time
+	deleted   bool
+	destroyed bool
+	pruned    bool
+}
+
+// fakeVault is an in-memory stand-in for the HTTP API of the KV version 2
+// secrets engine of Vault, mounted at secret/. Like Vault, it drops the
+// oldest versions of a secret beyond its max_versions. The endpoints and paths
+// of the requests made are recorded in requests
+type fakeVault struct {
+	sync.Mutex
+	secrets     map[string][]*fakeVaultVersion
+	maxVersions map[string]int64
+	requests    []string
+}
+
+func newTestVaultDAO(t *testing.T) (DAO, *fakeVault, func()) {
+	vault := &fakeVault{
+		secrets:     make(map[string][]*fakeVaultVersion),
+		maxVersions: make(map[string]int64),
+	}
+	server := httptest.NewServer(vault)
+	vaultClient, err := vaultclient.NewClient(vaultclient.Config{Address: server.URL, Token: testVaultToken})
+	if err != nil {
+		t.Fatalf("Error creating Vault client: %v", err)
+	}
+	return NewVaultDAO("myapp", vaultClient, DefaultVaultKeyPrefix), vault, server.Close
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.Lock()
+	defer v.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != testVaultToken {
+		writeVaultResponse(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/secret/"), "/", 2)
+	endpoint, path := parts[0], parts[1]
+	v.requests = append(v.requests, endpoint+"/"+path)
+	versions := v.secrets[path]
+
+	switch {
+	case endpoint == "data" && r.Method == "GET":
+		version := int64(len(versions))
+		if query := r.URL.Query().Get("version"); query != "" {
+			version, _ = strconv.ParseInt(query, 10, 64)
+		}
+		if version < 1 || version > int64(len(versions)) || versions[version-1].deleted || versions[version-1].destroyed || versions[version-1].pruned {
+			writeVaultResponse(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"data":     versions[version-1].data,
+			"metadata": fakeVersionMetadata(version, versions[version-1]),
+		}})
+	case endpoint == "data" && r.Method == "POST":
+		input := &struct {
+			Options struct {
+				CAS int64 `json:"cas"`
+			} `json:"options"`
+			Data json.RawMessage `json:"data"`
+		}{}
+		json.NewDecoder(r.Body).Decode(input)
+		if input.Options.CAS != int64(len(versions)) {
+			writeVaultResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"errors": []string{"check-and-set parameter did not match the current version"},
+			})
+			return
+		}
+		version := &fakeVaultVersion{data: input.Data, created: time.Now()}
+		v.secrets[path] = append(versions, version)
+		maxVersions := v.maxVersions[path]
+		if maxVersions == 0 {
+			maxVersions = fakeVaultDefaultMaxVersions
+		}
+		for i := 0; i < len(versions)+1-int(maxVersions); i++ {
+			v.secrets[path][i].pruned = true
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"data": fakeVersionMetadata(int64(len(versions)+1), version)})
+	case endpoint == "metadata" && r.Method == "GET" && r.URL.Query().Get("list") == "true":
+		keys := v.list(path)
+		if len(keys) == 0 {
+			writeVaultResponse(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+	case endpoint == "metadata" && r.Method == "GET":
+		if versions == nil {
+			writeVaultResponse(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		versionsMetadata := make(map[string]interface{})
+		for i, version := range versions {
+			if !version.pruned {
+				versionsMetadata[strconv.Itoa(i+1)] = fakeVersionMetadata(int64(i+1), version)
+			}
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"current_version": len(versions),
+			"max_versions":    v.maxVersions[path],
+			"versions":        versionsMetadata,
+		}})
+	case endpoint == "metadata" && r.Method == "POST":
+		input := &struct {
+			MaxVersions int64 `json:"max_versions"`
+		}{}
+		json.NewDecoder(r.Body).Decode(input)
+		v.maxVersions[path] = input.MaxVersions
+		if versions == nil {
+			v.secrets[path] = []*fakeVaultVersion{}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "metadata" && r.Method == "DELETE":
+		delete(v.secrets, path)
+		delete(v.maxVersions, path)
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "delete" || endpoint == "undelete" || endpoint == "destroy":
+		input := &struct {
+			Versions []int64 `json:"versions"`
+		}{}
+		json.NewDecoder(r.Body).Decode(input)
+		for _, version := range input.Versions {
+			if version < 1 || version > int64(len(versions)) {
+				continue
+			}
+			switch endpoint {
+			case "delete":
+				versions[version-1].deleted = true
+			case "undelete":
+				versions[version-1].deleted = false
+			case "destroy":
+				versions[version-1].destroyed = true
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeVaultResponse(w, http.StatusMethodNotAllowed, map[string]interface{}{"errors": []string{"unsupported"}})
+	}
+}
+
+// list lists the keys in the folder, with nested folders ending with '/'
+func (v *fakeVault) list(folder string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for path := range v.secrets {
+		if !strings.HasPrefix(path, folder) {
+			continue
+		}
+		key := strings.TrimPrefix(path, folder)
+		if i := strings.Index(key, "/"); i >= 0 {
+			key = key[:i+1]
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fakeVersionMetadata(version int64, fake *fakeVaultVersion) map[string]interface{} {
+	deletionTime := ""
+	if fake.deleted {
+		deletionTime = fake.created.Format(time.RFC3339Nano)
+	}
+	return map[string]interface{}{
+		"version":       version,
+		"created_time":  fake.created.Format(time.RFC3339Nano),
+		"deletion_time": deletionTime,
+		"destroyed":     fake.destroyed,
+	}
+}
+
+func writeVaultResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func putTestVaultRecords(t *testing.T, d DAO, name string, serials ...int64) {
+	for _, serial := range serials {
+		err := d.PutSecretRecord(&SecretRecord{
+			Name:             name,
+			Serial:           serial,
+			EncryptedData:    "data-" + strconv.FormatInt(serial, 10),
+			EncryptedDataKey: "key",
+			Active:           true,
+			CreatedAt:        1500000000 + serial,
+		})
+		if err != nil {
+			t.Fatalf("Error putting secret record %s:%d: %v", name, serial, err)
+		}
+	}
+}
+
+func TestVaultPutSecretRecord(t *testing.T) {
+	d, _, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+
+	record := &SecretRecord{
+		Name:             "foo",
+		Serial:           1,
+		EncryptedData:    "data",
+		EncryptedDataKey: "key",
+		Active:           true,
+		CreatedAt:        1500000000,
+		CreatedBy:        "someone",
+		Tags:             map[string]string{"team": "payments"},
+	}
+	err := d.PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+	err = d.PutSecretRecord(record)
+	if err != ErrSecretRecordExists {
+		t.Errorf("Expected ErrSecretRecordExists, got: %v", err)
+	}
+
+	stored, err := d.GetSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error getting secret record: %v", err)
+	}
+	if !reflect.DeepEqual(stored, record) {
+		t.Errorf("Mismatch between put and stored records. Expected: %v, got: %v", record, stored)
+	}
+}
+
+func TestVaultPutSecretRecordKeepsMoreVersionsThanDefault(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+
+	var serials []int64
+	for serial := int64(1); serial <= fakeVaultDefaultMaxVersions+2; serial++ {
+		serials = append(serials, serial)
+	}
+	putTestVaultRecords(t, d, "foo", serials[:2]...)
+	// Secrets written before max_versions was set get it on their next write
+	delete(vault.maxVersions, "ecs-secrets/myapp/secrets/foo")
+	putTestVaultRecords(t, d, "foo", serials[2:]...)
+
+	if vault.maxVersions["ecs-secrets/myapp/secrets/foo"] != vaultMaxVersions {
+		t.Errorf("Expected max_versions of %d, got %d", vaultMaxVersions, vault.maxVersions["ecs-secrets/myapp/secrets/foo"])
+	}
+	records, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(records), serials) {
+		t.Errorf("Expected every version to be kept, got: %v", serialsOf(records))
+	}
+	if _, err = d.GetSecretRecord("foo", 1); err != nil {
+		t.Errorf("Error getting the first version: %v", err)
+	}
+}
+
+func TestVaultPutSecretRecordVersionsKept(t *testing.T) {
+	d, _, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	putTestVaultRecords(t, d, "foo", 1, 2)
+
+	record := &SecretRecord{Name: "foo", Serial: vaultMaxVersions + 1, Active: true}
+	err := d.PutSecretRecord(record)
+	if err == nil {
+		t.Fatal("Expected error putting a version that would destroy version 1")
+	}
+	err = d.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	err = d.PutSecretRecord(record)
+	if err != nil {
+		t.Fatalf("Error putting secret record once version 1 was purged: %v", err)
+	}
+	if _, err = d.GetSecretRecord("foo", 2); err != nil {
+		t.Errorf("Error getting version 2: %v", err)
+	}
+}
+
+func TestVaultPutSecretRecordSkipsSerials(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	putTestVaultRecords(t, d, "foo", 3)
+
+	versions := vault.secrets["ecs-secrets/myapp/secrets/foo"]
+	if len(versions) != 3 || !versions[0].destroyed || !versions[1].destroyed || versions[2].destroyed {
+		t.Errorf("Expected versions 1 and 2 to be destroyed placeholders, got: %v", versions)
+	}
+	records, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(records), []int64{3}) {
+		t.Errorf("Unexpected versions: %v", serialsOf(records))
+	}
+}
+
+func TestVaultRevokeAndRestoreSecretRecord(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	putTestVaultRecords(t, d, "foo", 1, 2)
+
+	err := d.RevokeSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error revoking secret record: %v", err)
+	}
+	if !vault.secrets["ecs-secrets/myapp/secrets/foo"][1].deleted {
+		t.Error("Expected revoked version to be deleted in Vault")
+	}
+	active, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active == nil || active.Serial != 1 {
+		t.Errorf("Expected version 1 to be the latest active version, got: %v", active)
+	}
+	revoked, err := d.GetSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error getting revoked secret record: %v", err)
+	}
+	if revoked.Active || revoked.EncryptedData != "" || revoked.CreatedAt != 1500000002 {
+		t.Errorf("Expected revoked version without its data, got: %v", revoked)
+	}
+
+	err = d.RestoreSecretRecord("foo", 2, &Restoration{RestoredAt: 1600000000, RestoredBy: "someone", Reason: "mistake"})
+	if err != nil {
+		t.Fatalf("Error restoring secret record: %v", err)
+	}
+	restored, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if restored == nil || restored.Serial != 2 || restored.EncryptedData != "data-2" || restored.RestoredBy != "someone" {
+		t.Errorf("Expected version 2 to be restored, got: %v", restored)
+	}
+
+	err = d.RevokeSecretRecord("foo", 3)
+	if err == nil {
+		t.Error("Expected error revoking a missing secret record")
+	}
+}
+
+func TestVaultGetLatestActiveVersionSkipsExpired(t *testing.T) {
+	d, _, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	putTestVaultRecords(t, d, "foo", 1)
+	err := d.PutSecretRecord(&SecretRecord{Name: "foo", Serial: 2, Active: true, ExpiresAt: 1600000000})
+	if err != nil {
+		t.Fatalf("Error putting secret record: %v", err)
+	}
+
+	active, err := d.GetLatestActiveVersion("foo", 1600000000)
+	if err != nil {
+		t.Fatalf("Error getting latest active version: %v", err)
+	}
+	if active == nil || active.Serial != 1 {
+		t.Errorf("Expected version 1 to be the latest active version, got: %v", active)
+	}
+}
+
+func TestVaultScheduleAndCancelSecretRecordDeletion(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	putTestVaultRecords(t, d, "foo", 1)
+
+	err := d.ScheduleSecretRecordDeletion("foo", 1, &Deletion{DeletedAt: 1600000000, DeletedBy: "someone", PurgeAt: 1602592000})
+	if err != nil {
+		t.Fatalf("Error scheduling deletion: %v", err)
+	}
+	record, err := d.GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	if record.PurgeAt != 1602592000 || record.DeletedBy != "someone" {
+		t.Errorf("Expected deletion to be scheduled, got: %v", record)
+	}
+
+	err = d.CancelSecretRecordDeletion("foo", 1)
+	if err != nil {
+		t.Fatalf("Error cancelling deletion: %v", err)
+	}
+	record, err = d.GetLatestVersion("foo")
+	if err != nil {
+		t.Fatalf("Error getting latest version: %v", err)
+	}
+	if record.PurgeAt != 0 || record.DeletedAt != 0 || record.DeletedBy != "" {
+		t.Errorf("Expected deletion to be cancelled, got: %v", record)
+	}
+	if len(vault.secrets["ecs-secrets/myapp/metadata/foo"]) != 2 {
+		t.Errorf("Expected metadata to be written twice, got: %v", vault.secrets["ecs-secrets/myapp/metadata/foo"])
+	}
+}
+
+func TestVaultDeleteSecretRecord(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	putTestVaultRecords(t, d, "foo", 1, 2)
+
+	err := d.DeleteSecretRecord("foo", 2)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	versions, err := d.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if !reflect.DeepEqual(serialsOf(versions), []int64{1}) {
+		t.Errorf("Unexpected versions after delete: %v", serialsOf(versions))
+	}
+
+	err = d.DeleteSecretRecord("foo", 1)
+	if err != nil {
+		t.Fatalf("Error deleting secret record: %v", err)
+	}
+	if _, ok := vault.secrets["ecs-secrets/myapp/secrets/foo"]; ok {
+		t.Error("Expected secret to be deleted from Vault once every version is deleted")
+	}
+}
+
+func TestVaultListSecrets(t *testing.T) {
+	d, _, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	for _, name := range []string{"db/password", "db/user", "api-key", "dbx", "token"} {
+		putTestVaultRecords(t, d, name, 1, 2)
+	}
+	err := d.PutSecretLabels(&SecretLabels{Name: ApplicationMetadataName, RetentionMaxVersions: 2})
+	if err != nil {
+		t.Fatalf("Error putting application metadata: %v", err)
+	}
+
+	var listed []string
+	token := ""
+	pages := 0
+	for {
+		records, nextToken, err := d.ListSecrets("db", token, 2)
+		if err != nil {
+			t.Fatalf("Error listing secrets: %v", err)
+		}
+		for _, record := range records {
+			if record.Serial != 2 || record.EncryptedData != "" {
+				t.Errorf("Expected summary of the latest version, got: %v", record)
+			}
+			listed = append(listed, record.Name)
+		}
+		pages++
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+	if !reflect.DeepEqual(listed, []string{"db/password", "db/user", "dbx"}) {
+		t.Errorf("Unexpected secrets listed: %v", listed)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages, got %d", pages)
+	}
+}
+
+func TestVaultListSecretsOnlyReadsPage(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+	for _, name := range []string{"a/1", "a/2", "b/1", "b/2", "c"} {
+		putTestVaultRecords(t, d, name, 1)
+	}
+
+	vault.requests = nil
+	records, token, err := d.ListSecrets("", "", 2)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(records) != 2 || records[0].Name != "a/1" || records[1].Name != "a/2" || token != "a/2" {
+		t.Fatalf("Unexpected first page: %v, %s", records, token)
+	}
+	for _, request := range vault.requests {
+		if strings.HasSuffix(request, "/b/1") || request == "metadata/ecs-secrets/myapp/secrets/c" {
+			t.Errorf("Expected secrets after the page not to be read, got: %s", request)
+		}
+	}
+
+	vault.requests = nil
+	records, token, err = d.ListSecrets("", "b/2", 2)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(records) != 1 || records[0].Name != "c" || token != "" {
+		t.Fatalf("Unexpected last page: %v, %s", records, token)
+	}
+	for _, request := range vault.requests {
+		if strings.HasPrefix(request, "metadata/ecs-secrets/myapp/secrets/a/") {
+			t.Errorf("Expected folders before the token not to be listed, got: %s", request)
+		}
+	}
+}
+
+func TestVaultSecretLabels(t *testing.T) {
+	d, vault, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+
+	metadata, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if metadata.Revision != 0 || len(metadata.Labels) != 0 {
+		t.Errorf("Expected empty metadata, got: %v", metadata)
+	}
+
+	metadata.Labels["prod"] = 1
+	metadata.RetentionMaxVersions = 3
+	err = d.PutSecretLabels(metadata)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	if metadata.Revision != 1 {
+		t.Errorf("Expected revision to be incremented, got: %d", metadata.Revision)
+	}
+
+	err = d.PutSecretLabels(&SecretLabels{Name: "foo", Labels: map[string]int64{"prod": 2}})
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+	stored, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	if !reflect.DeepEqual(stored, metadata) {
+		t.Errorf("Mismatch between put and stored metadata. Expected: %v, got: %v", metadata, stored)
+	}
+
+	err = d.DeleteSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error deleting secret metadata: %v", err)
+	}
+	if _, ok := vault.secrets["ecs-secrets/myapp/metadata/foo"]; ok {
+		t.Error("Expected empty metadata to be deleted from Vault")
+	}
+}
+
+func TestVaultPutSecretLabelsConcurrentPut(t *testing.T) {
+	d, _, cleanup := newTestVaultDAO(t)
+	defer cleanup()
+
+	first, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	second, err := d.GetSecretLabels("foo")
+	if err != nil {
+		t.Fatalf("Error getting secret metadata: %v", err)
+	}
+	first.Labels["prod"] = 1
+	second.Labels["prod"] = 2
+	err = d.PutSecretLabels(first)
+	if err != nil {
+		t.Fatalf("Error putting secret metadata: %v", err)
+	}
+	err = d.PutSecretLabels(second)
+	if err != ErrSecretLabelsModified {
+		t.Errorf("Expected ErrSecretLabelsModified, got: %v", err)
+	}
+}
@@ -20,8 +20,11 @@ import "github.com/aws/aws-sdk-go/service/dynamodb"
 // Client defines a subset of the dynamodb client methods. The methods defined
 // here are used to interact with DynamoDB service by the data store accessors
 type Client interface {
+	BatchGetItem(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
 	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
 	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
 	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
 	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	Scan(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
 }
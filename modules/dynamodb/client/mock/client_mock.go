@@ -41,6 +41,28 @@ func (_m *MockClient) EXPECT() *_MockClientRecorder {
 	return _m.recorder
 }
 
+func (_m *MockClient) BatchGetItem(_param0 *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	ret := _m.ctrl.Call(_m, "BatchGetItem", _param0)
+	ret0, _ := ret[0].(*dynamodb.BatchGetItemOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) BatchGetItem(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "BatchGetItem", arg0)
+}
+
+func (_m *MockClient) DeleteItem(_param0 *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	ret := _m.ctrl.Call(_m, "DeleteItem", _param0)
+	ret0, _ := ret[0].(*dynamodb.DeleteItemOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) DeleteItem(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteItem", arg0)
+}
+
 func (_m *MockClient) GetItem(_param0 *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
 	ret := _m.ctrl.Call(_m, "GetItem", _param0)
 	ret0, _ := ret[0].(*dynamodb.GetItemOutput)
@@ -74,6 +96,17 @@ func (_mr *_MockClientRecorder) Query(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Query", arg0)
 }
 
+func (_m *MockClient) Scan(_param0 *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	ret := _m.ctrl.Call(_m, "Scan", _param0)
+	ret0, _ := ret[0].(*dynamodb.ScanOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) Scan(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Scan", arg0)
+}
+
 func (_m *MockClient) UpdateItem(_param0 *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
 	ret := _m.ctrl.Call(_m, "UpdateItem", _param0)
 	ret0, _ := ret[0].(*dynamodb.UpdateItemOutput)
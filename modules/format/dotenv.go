@@ -0,0 +1,151 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// unquotedValuePattern matches values that can be written to a dotenv file
+// without quotes
+var unquotedValuePattern = regexp.MustCompile(`^[a-zA-Z0-9_./:@%+,=-]*$`)
+
+// dotenvEscapes maps characters that are escaped in double quoted values to
+// their escape sequences
+var dotenvEscapes = map[byte]string{
+	'\\': `\\`,
+	'"':  `\"`,
+	'$':  `\$`,
+	'\n': `\n`,
+	'\r': `\r`,
+	'\t': `\t`,
+}
+
+// dotenvUnescapes maps escape sequences in double quoted values to the
+// characters they stand for
+var dotenvUnescapes = map[byte]byte{
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+}
+
+// readDotenv parses KEY=value pairs, one per line. Blank lines and lines
+// starting with # are ignored, as is an "export" keyword before the key.
+// Values can be unquoted, single quoted to be taken literally or double
+// quoted to allow escape sequences
+func readDotenv(data []byte) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("Error parsing line %d: expected KEY=value", i+1)
+		}
+		value, err := parseDotenvValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing line %d: %v", i+1, err)
+		}
+		if _, ok := secrets[key]; ok {
+			return nil, fmt.Errorf("Error parsing line %d: duplicate key '%s'", i+1, key)
+		}
+		secrets[key] = value
+	}
+	return secrets, nil
+}
+
+func parseDotenvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	var parsed string
+	var rest string
+	switch value[0] {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		parsed, rest = value[1:end+1], value[end+2:]
+	case '"':
+		var buf bytes.Buffer
+		end := -1
+		for i := 1; i < len(value); i++ {
+			if value[i] == '"' {
+				end = i
+				break
+			}
+			if value[i] == '\\' && i+1 < len(value) {
+				if unescaped, ok := dotenvUnescapes[value[i+1]]; ok {
+					buf.WriteByte(unescaped)
+					i++
+					continue
+				}
+			}
+			buf.WriteByte(value[i])
+		}
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		parsed, rest = buf.String(), value[end+1:]
+	default:
+		// Unquoted values end at a comment
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected characters after closing quote")
+	}
+	return parsed, nil
+}
+
+// writeDotenv writes a KEY=value pair for each secret, double quoting values
+// that contain anything other than a conservative set of characters
+func writeDotenv(secrets map[string]string) []byte {
+	var buf bytes.Buffer
+	for _, name := range sortedNames(secrets) {
+		value := secrets[name]
+		if unquotedValuePattern.MatchString(value) {
+			fmt.Fprintf(&buf, "%s=%s\n", name, value)
+			continue
+		}
+
+		buf.WriteString(name + `="`)
+		for i := 0; i < len(value); i++ {
+			if escaped, ok := dotenvEscapes[value[i]]; ok {
+				buf.WriteString(escaped)
+			} else {
+				buf.WriteByte(value[i])
+			}
+		}
+		buf.WriteString("\"\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package format reads and writes sets of secrets in the file formats used to
+// configure applications, such as dotenv files.
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Dotenv is the format of files with a KEY=value pair on each line
+	Dotenv = "dotenv"
+	// JSON is the format of files with a single JSON object whose members are
+	// all strings
+	JSON = "json"
+	// YAML is the format of files with a single YAML mapping of scalars
+	YAML = "yaml"
+)
+
+// Formats lists the supported formats
+var Formats = []string{Dotenv, JSON, YAML}
+
+// Read parses the secrets in data, keyed by name
+func Read(format string, data []byte) (map[string]string, error) {
+	var secrets map[string]string
+	switch format {
+	case Dotenv:
+		return readDotenv(data)
+	case JSON:
+		if err := json.Unmarshal(data, &secrets); err != nil {
+			return nil, fmt.Errorf("Error parsing JSON: %v", err)
+		}
+	case YAML:
+		if err := yaml.Unmarshal(data, &secrets); err != nil {
+			return nil, fmt.Errorf("Error parsing YAML: %v", err)
+		}
+	default:
+		return nil, unknownFormatError(format)
+	}
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+	return secrets, nil
+}
+
+// Write encodes the secrets in the format. Secrets are written in the order
+// of their names so that the output is stable
+func Write(format string, secrets map[string]string) ([]byte, error) {
+	switch format {
+	case Dotenv:
+		return writeDotenv(secrets), nil
+	case JSON:
+		data, err := json.MarshalIndent(secrets, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding JSON: %v", err)
+		}
+		return append(data, '\n'), nil
+	case YAML:
+		data, err := yaml.Marshal(secrets)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding YAML: %v", err)
+		}
+		return data, nil
+	default:
+		return nil, unknownFormatError(format)
+	}
+}
+
+// sortedNames returns the names of the secrets in order
+func sortedNames(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func unknownFormatError(format string) error {
+	return fmt.Errorf("Unknown format '%s', expected one of %v", format, Formats)
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package format
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadDotenv(t *testing.T) {
+	data := `# database settings
+DB_HOST=db.example.com
+export DB_USER = admin # the admin user
+DB_PASSWORD="hunter2 \"quoted\"\n\$HOME"
+DB_NAME='literal \n'
+
+EMPTY=
+`
+	secrets, err := Read(Dotenv, []byte(data))
+	if err != nil {
+		t.Fatalf("Error reading dotenv: %v", err)
+	}
+	expectedSecrets := map[string]string{
+		"DB_HOST":     "db.example.com",
+		"DB_USER":     "admin",
+		"DB_PASSWORD": "hunter2 \"quoted\"\n$HOME",
+		"DB_NAME":     `literal \n`,
+		"EMPTY":       "",
+	}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and read secrets: %v != %v", secrets, expectedSecrets)
+	}
+}
+
+func TestReadDotenvInvalid(t *testing.T) {
+	for _, data := range []string{"FOO", "=bar", "FOO BAR=baz", `FOO="bar`, "FOO='bar' baz", "FOO=a\nFOO=b"} {
+		_, err := Read(Dotenv, []byte(data))
+		if err == nil {
+			t.Errorf("Expected error reading dotenv %q", data)
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	secrets, err := Read(JSON, []byte(`{"DB_HOST":"db.example.com","DB_PASSWORD":"hunter2"}`))
+	if err != nil {
+		t.Fatalf("Error reading JSON: %v", err)
+	}
+	expectedSecrets := map[string]string{"DB_HOST": "db.example.com", "DB_PASSWORD": "hunter2"}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and read secrets: %v != %v", secrets, expectedSecrets)
+	}
+
+	_, err = Read(JSON, []byte(`{"DB_PORT":5432}`))
+	if err == nil {
+		t.Error("Expected error reading JSON with a value that is not a string")
+	}
+}
+
+func TestReadYAML(t *testing.T) {
+	secrets, err := Read(YAML, []byte("DB_HOST: db.example.com\nDB_PORT: 5432\n"))
+	if err != nil {
+		t.Fatalf("Error reading YAML: %v", err)
+	}
+	expectedSecrets := map[string]string{"DB_HOST": "db.example.com", "DB_PORT": "5432"}
+	if !reflect.DeepEqual(secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and read secrets: %v != %v", secrets, expectedSecrets)
+	}
+
+	_, err = Read(YAML, []byte("DB:\n  HOST: db.example.com\n"))
+	if err == nil {
+		t.Error("Expected error reading YAML with a nested mapping")
+	}
+}
+
+func TestReadUnknownFormat(t *testing.T) {
+	_, err := Read("toml", []byte("FOO = 'bar'"))
+	if err == nil {
+		t.Error("Expected error reading unknown format")
+	}
+}
+
+func TestWriteDotenv(t *testing.T) {
+	data, err := Write(Dotenv, map[string]string{
+		"DB_PASSWORD": "hunter2 \"quoted\"\n$HOME",
+		"DB_HOST":     "db.example.com",
+	})
+	if err != nil {
+		t.Fatalf("Error writing dotenv: %v", err)
+	}
+	expectedData := "DB_HOST=db.example.com\nDB_PASSWORD=\"hunter2 \\\"quoted\\\"\\n\\$HOME\"\n"
+	if string(data) != expectedData {
+		t.Errorf("Mismatch between expected and written dotenv: %q != %q", string(data), expectedData)
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	secrets := map[string]string{
+		"PLAIN":   "value",
+		"SPACES":  " padded value ",
+		"SPECIAL": "a'b\"c\\d#e\tf",
+		"EMPTY":   "",
+	}
+	for _, format := range Formats {
+		data, err := Write(format, secrets)
+		if err != nil {
+			t.Fatalf("Error writing %s: %v", format, err)
+		}
+		readSecrets, err := Read(format, data)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", format, err)
+		}
+		if !reflect.DeepEqual(readSecrets, secrets) {
+			t.Errorf("Mismatch between written and read %s secrets: %v != %v", format, readSecrets, secrets)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package identity
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/ecs-secrets/modules/sts/client"
+)
+
+//go:generate mockgen.sh github.com/awslabs/ecs-secrets/modules/identity Provider mock/identity_mock.go
+
+// Provider defines the interface to get the identity of the caller
+// interacting with secrets
+type Provider interface {
+	CallerIdentity() (string, error)
+}
+
+// stsProvider implements the Provider interface by using the ARN returned by
+// the STS GetCallerIdentity API as the identity of the caller
+type stsProvider struct {
+	sync.Mutex
+	stsClient client.Client
+	arn       string
+}
+
+// NewSTSProvider creates a new Provider object backed by STS
+func NewSTSProvider(stsClient client.Client) Provider {
+	return &stsProvider{
+		stsClient: stsClient,
+	}
+}
+
+// CallerIdentity returns the ARN of the caller. The ARN is looked up once
+// and reused for subsequent calls
+func (provider *stsProvider) CallerIdentity() (string, error) {
+	provider.Lock()
+	defer provider.Unlock()
+
+	if provider.arn != "" {
+		return provider.arn, nil
+	}
+
+	result, err := provider.stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("Error getting caller identity: %v", err)
+	}
+
+	provider.arn = aws.StringValue(result.Arn)
+	return provider.arn, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package identity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/awslabs/ecs-secrets/modules/sts/client/mock"
+	vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client"
+	mock_vaultclient "github.com/awslabs/ecs-secrets/modules/vault/client/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCallerIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stsClient := mock_client.NewMockClient(ctrl)
+
+	stsClient.EXPECT().GetCallerIdentity(&sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Arn: aws.String("arn:aws:iam::123456789012:user/foo"),
+	}, nil)
+
+	provider := NewSTSProvider(stsClient)
+	for i := 0; i < 2; i++ {
+		arn, err := provider.CallerIdentity()
+		if err != nil {
+			t.Fatalf("Error getting caller identity: %v", err)
+		}
+		if arn != "arn:aws:iam::123456789012:user/foo" {
+			t.Errorf("Incorrect caller identity: %s", arn)
+		}
+	}
+}
+
+func TestCallerIdentityError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stsClient := mock_client.NewMockClient(ctrl)
+
+	stsClient.EXPECT().GetCallerIdentity(gomock.Any()).Return(nil, fmt.Errorf("nobody"))
+
+	provider := NewSTSProvider(stsClient)
+	_, err := provider.CallerIdentity()
+	if err == nil {
+		t.Error("Expected error getting caller identity")
+	}
+}
+
+func TestVaultCallerIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vaultClient := mock_vaultclient.NewMockClient(ctrl)
+
+	vaultClient.EXPECT().LookupSelf().Return(&vaultclient.TokenInfo{
+		DisplayName: "approle",
+		EntityID:    "7d2e3179",
+	}, nil)
+
+	provider := NewVaultProvider(vaultClient)
+	for i := 0; i < 2; i++ {
+		caller, err := provider.CallerIdentity()
+		if err != nil {
+			t.Fatalf("Error getting caller identity: %v", err)
+		}
+		if caller != "vault:approle:7d2e3179" {
+			t.Errorf("Incorrect caller identity: %s", caller)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package identity
+
+import (
+	"os"
+	"os/user"
+)
+
+// localIdentityPrefix is prepended to the names of local users, so that they
+// can't be mistaken for IAM principals
+const localIdentityPrefix = "local:"
+
+// localProvider implements the Provider interface by using the name of the
+// user running the process as the identity of the caller, for use when no AWS
+// credentials are available
+type localProvider struct{}
+
+// NewLocalProvider creates a new Provider object backed by the local user
+func NewLocalProvider() Provider {
+	return &localProvider{}
+}
+
+// CallerIdentity returns the name of the local user
+func (provider *localProvider) CallerIdentity() (string, error) {
+	current, err := user.Current()
+	if err == nil && current.Username != "" {
+		return localIdentityPrefix + current.Username, nil
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return localIdentityPrefix + name, nil
+	}
+	return localIdentityPrefix + "unknown", nil
+}
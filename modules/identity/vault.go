@@ -0,0 +1,63 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package identity
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/awslabs/ecs-secrets/modules/vault/client"
+)
+
+// vaultIdentityPrefix is prepended to the display names of Vault tokens, so
+// that they can't be mistaken for IAM principals or local users
+const vaultIdentityPrefix = "vault:"
+
+// vaultProvider implements the Provider interface by using the token the
+// Vault client is authenticated with as the identity of the caller
+type vaultProvider struct {
+	sync.Mutex
+	vaultClient client.Client
+	identity    string
+}
+
+// NewVaultProvider creates a new Provider object backed by Vault
+func NewVaultProvider(vaultClient client.Client) Provider {
+	return &vaultProvider{
+		vaultClient: vaultClient,
+	}
+}
+
+// CallerIdentity returns the display name of the token, followed by the ID of
+// the entity it belongs to when there is one. The identity is looked up once
+// and reused for subsequent calls
+func (provider *vaultProvider) CallerIdentity() (string, error) {
+	provider.Lock()
+	defer provider.Unlock()
+
+	if provider.identity != "" {
+		return provider.identity, nil
+	}
+
+	info, err := provider.vaultClient.LookupSelf()
+	if err != nil {
+		return "", fmt.Errorf("Error getting caller identity: %v", err)
+	}
+
+	provider.identity = vaultIdentityPrefix + info.DisplayName
+	if info.EntityID != "" {
+		provider.identity += ":" + info.EntityID
+	}
+	return provider.identity, nil
+}
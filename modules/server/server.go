@@ -15,7 +15,14 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/awslabs/ecs-secrets/modules/api"
 
@@ -28,11 +35,32 @@ import (
 
 const listeningPort = "8080"
 
+const (
+	// DefaultAddress is the address the server listens on by default, on
+	// every interface
+	DefaultAddress = ":" + listeningPort
+	// LoopbackAddress is the address the server listens on when it should
+	// only be reachable from the same host
+	LoopbackAddress = "127.0.0.1:" + listeningPort
+)
+
+const (
+	// namePattern matches the names of secrets in paths, including those
+	// that contain '/'
+	namePattern = "{name:.+}"
+	// serialPattern matches the serials of secrets in the paths used to
+	// fetch them
+	serialPattern = "{serial:[0-9]+|" + store.LatestVersion + "}"
+	// purgeSerialPattern matches the serials of secrets in the paths used to
+	// purge them
+	purgeSerialPattern = "{serial:[0-9]+|" + store.AllVersions + "}"
+)
+
 // Server interface defines methods to route and serve requests when running
 // in 'daemon' mode
 type Server interface {
 	Router() *mux.Router
-	Serve() error
+	Serve(string) error
 }
 
 type server struct {
@@ -51,10 +79,11 @@ func NewServer(secretStore store.Store) Server {
 	}
 }
 
-func (s *server) Serve() error {
+// Serve serves requests on the address, such as DefaultAddress
+func (s *server) Serve(address string) error {
 	router := s.Router()
-	log.Debugf("Starting api server")
-	return http.ListenAndServe(":"+listeningPort, router)
+	log.Debugf("Starting api server on %s", address)
+	return http.ListenAndServe(address, router)
 }
 
 func (s *server) Router() *mux.Router {
@@ -73,42 +102,124 @@ func (s *server) createV1SubRouter(path string, router *mux.Router) {
 	// GET /v1/secrets/version
 	subrouter.HandleFunc("/version", s.version).Methods("GET")
 
+	// Names of secrets may be paths such as prod/payments/db-password. Since
+	// the name pattern is greedy, the serial or label that follows a name is
+	// always the last segment of the path. Names are validated so that their
+	// last segment is never a serial or a reserved label
+
 	// Handler for creating secrets
 	// POST /v1/secrets/com.foo.app1.mysql
 	//                Content-Type: application/json
-	//                 {secret: ...}
-	// POST /latest/secrets/com.foo.app1.mysql
+	//                 {payload: ..., description: ..., tags: {...}, expiresAt: ...}
+	// POST /latest/secrets/prod/payments/db-password
 	//                Content-Type: application/json
-	//                 {secret: ...}
-	subrouter.HandleFunc("/secrets/{name}", s.postSecret).Methods("POST")
+	//                If-Match: 4
+	//                 {payload: ..., contentType: ..., encoding: base64}
+	// POST /latest/secrets/com.foo.app1.keystore?contentType=application/x-java-keystore
+	//                Content-Type: application/octet-stream
+	//                 <payload>
+	subrouter.HandleFunc("/secrets/"+namePattern, s.postSecret).Methods("POST")
 
 	// Handler for removing secrets:
 	// POST /v1/revoke/com.foo.app1.mysql/1
-	// POST /latest/revoke/com.foo.app1.mysql/1
-	subrouter.HandleFunc("/revoke/{name}/{serial}", s.revokeSecret).Methods("POST")
+	// POST /latest/revoke/prod/payments/db-password/1
+	subrouter.HandleFunc("/revoke/"+namePattern+"/{serial}", s.revokeSecret).Methods("POST")
 
-	// Handler for fetching secrets:
-	// GET /v1/secrets/com.foo.app1.mysql
-	// GET /latest/secrets/com.foo.app1.mysql
-	subrouter.HandleFunc("/secrets/{name}", s.getSecret).Methods("GET")
+	// Handler for reinstating revoked secrets. The reason is optional:
+	// POST /v1/restore/com.foo.app1.mysql/1
+	//                Content-Type: application/json
+	//                 {reason: ...}
+	// POST /latest/restore/prod/payments/db-password/1
+	subrouter.HandleFunc("/restore/"+namePattern+"/{serial}", s.restoreSecret).Methods("POST")
+
+	// Handler for permanently deleting versions of secrets. Since this cannot
+	// be undone, purge has to be set explicitly. 'all' can be used in place
+	// of the serial to delete every version:
+	// DELETE /v1/secrets/com.foo.app1.mysql/1?purge=true
+	// DELETE /latest/secrets/prod/payments/db-password/all?purge=true
+	subrouter.HandleFunc("/secrets/"+namePattern+"/"+purgeSerialPattern, s.purgeSecret).Methods("DELETE")
+
+	// Handler for scheduling secrets for deletion. The secret can be undeleted
+	// until the recovery window, 30 days unless specified, has passed. Every
+	// version is deleted straight away if purge is set:
+	// DELETE /v1/secrets/com.foo.app1.mysql
+	// DELETE /latest/secrets/prod/payments/db-password?recoveryWindowInDays=7
+	// DELETE /latest/secrets/prod/payments/db-password?purge=true
+	subrouter.HandleFunc("/secrets/"+namePattern, s.deleteSecret).Methods("DELETE")
 
-	// Handler for fetching secrets with version:
+	// Handler for cancelling the scheduled deletion of secrets:
+	// POST /v1/undelete/com.foo.app1.mysql
+	// POST /latest/undelete/prod/payments/db-password
+	subrouter.HandleFunc("/undelete/"+namePattern, s.undeleteSecret).Methods("POST")
+
+	// Handler for fetching several secrets at once. Secrets that cannot be
+	// fetched are listed in the errors of the response:
+	// POST /v1/secrets:batchGet
+	//                Content-Type: application/json
+	//                 {secrets: [{name: ...}, {name: ..., serial: ...}, {name: ..., label: ...}]}
+	subrouter.HandleFunc("/secrets:batchGet", s.batchGetSecrets).Methods("POST")
+
+	// Handler for listing secrets, optionally only those whose names start
+	// with a prefix:
+	// GET /v1/secrets
+	// GET /latest/secrets?prefix=prod/payments/&maxResults=10&nextToken=...
+	subrouter.HandleFunc("/secrets", s.listSecrets).Methods("GET")
+
+	// Handler for pointing labels of secrets at versions:
+	// PUT /v1/secrets/com.foo.app1.mysql/labels/current
+	//                Content-Type: application/json
+	//                 {serial: ...}
+	subrouter.HandleFunc("/secrets/"+namePattern+"/labels/{label}", s.moveLabel).Methods("PUT")
+
+	// Handler for listing the versions of a secret. This is registered ahead
+	// of the handlers for fetching secrets so that 'versions' is not treated
+	// as a part of the name:
+	// GET /v1/secrets/com.foo.app1.mysql/versions
+	// GET /latest/secrets/prod/payments/db-password/versions
+	subrouter.HandleFunc("/secrets/"+namePattern+"/versions", s.listVersions).Methods("GET")
+
+	// Handler for fetching secrets with version. 'latest' can be used in
+	// place of the serial to fetch the latest version even if it is inactive:
 	// GET /v1/secrets/com.foo.app1.mysql/2
-	// GET /latest/secrets/com.foo.app1.mysql/2
-	subrouter.HandleFunc("/secrets/{name}/{serial}", s.getSecret).Methods("GET")
+	// GET /latest/secrets/prod/payments/db-password/latest
+	subrouter.HandleFunc("/secrets/"+namePattern+"/"+serialPattern, s.getSecret).Methods("GET")
+
+	// Handler for fetching secrets. A label can be used to fetch the version
+	// of the secret it points at. Binary payloads are base64 encoded unless
+	// the request accepts application/octet-stream:
+	// GET /v1/secrets/com.foo.app1.mysql
+	// GET /latest/secrets/prod/payments/db-password?label=pending
+	// A single field of a structured secret can be fetched as plain text:
+	// GET /latest/secrets/com.foo.app1.mysql?field=password
+	subrouter.HandleFunc("/secrets/"+namePattern, s.getSecret).Methods("GET")
 }
 
 func (s *server) postSecret(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	name := vars["name"]
+	if err := store.ValidateName(name); err != nil {
+		log.Errorf("Bad name supplied for creating secret: %v", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	if request.Body == nil {
 		log.Errorf("Bad data supplied for creating secret: %s", name)
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	decoder := json.NewDecoder(request.Body)
-	var secretPayload api.SecretPayload
-	err := decoder.Decode(&secretPayload)
+	secretPayload, err := readSecretPayload(request)
+	if err != nil {
+		log.Errorf("Bad data supplied for creating secret: %s, error: %v", name, err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	payload, err := secretPayload.DecodePayload()
+	if err != nil {
+		log.Errorf("Bad data supplied for creating secret: %s, error: %v", name, err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	expectedSerial, err := ifMatchSerial(request)
 	if err != nil {
 		log.Errorf("Bad data supplied for creating secret: %s, error: %v", name, err)
 		writer.WriteHeader(http.StatusBadRequest)
@@ -117,16 +228,66 @@ func (s *server) postSecret(writer http.ResponseWriter, request *http.Request) {
 
 	log.Debugf("Creating secret: name: %s", name)
 	_, err = s.secretStore.Save(&api.SecretRecord{
-		Name:    name,
-		Serial:  int64(1),
-		Payload: secretPayload.Payload,
-		Active:  true,
+		Name:           name,
+		Serial:         int64(1),
+		Payload:        payload,
+		Active:         true,
+		Description:    secretPayload.Description,
+		Tags:           secretPayload.Tags,
+		ExpiresAt:      secretPayload.ExpiresAt,
+		ContentType:    secretPayload.ContentType,
+		ExpectedSerial: expectedSerial,
 	})
 	if err != nil {
 		log.Errorf("Error creating secret for name: %s, %v", name, err)
-		writer.WriteHeader(http.StatusBadRequest)
+		writer.WriteHeader(errorStatusCode(err))
+	}
+}
+
+// ifMatchSerial returns the serial in the If-Match header of the request, if
+// there is one. The serial may be quoted like an entity tag
+func ifMatchSerial(request *http.Request) (*int64, error) {
+	ifMatch := request.Header.Get("If-Match")
+	if ifMatch == "" {
+		return nil, nil
+	}
+	serial, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil || serial < 0 {
+		return nil, fmt.Errorf("Invalid serial in If-Match header: %s", ifMatch)
+	}
+	return &serial, nil
+}
+
+// readSecretPayload reads the secret to be created from the body of the
+// request. Binary payloads can be posted as is with a content type of
+// application/octet-stream, in which case the content type of the secret can
+// be set with the 'contentType' query parameter
+func readSecretPayload(request *http.Request) (*api.SecretPayload, error) {
+	if !isOctetStream(request.Header.Get("Content-Type")) {
+		var secretPayload api.SecretPayload
+		err := json.NewDecoder(request.Body).Decode(&secretPayload)
+		if err != nil {
+			return nil, err
+		}
+		return &secretPayload, nil
+	}
+
+	// Read one byte more than the limit so that payloads that are too large
+	// are rejected by the store
+	payload, err := ioutil.ReadAll(io.LimitReader(request.Body, store.MaxPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	contentType := request.URL.Query().Get("contentType")
+	if contentType == "" {
+		contentType = api.OctetStreamContentType
 	}
+	return &api.SecretPayload{
+		Payload:     string(payload),
+		ContentType: contentType,
+	}, nil
 }
+
 func (s *server) revokeSecret(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	name := vars["name"]
@@ -135,28 +296,309 @@ func (s *server) revokeSecret(writer http.ResponseWriter, request *http.Request)
 	err := s.secretStore.Revoke(name, serial)
 	if err != nil {
 		log.Errorf("Error revoking secret name %s: %v", name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+}
+
+func (s *server) restoreSecret(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	name := vars["name"]
+	serial := vars["serial"]
+	var restoreRequest api.RestoreRequest
+	if request.Body != nil {
+		decoder := json.NewDecoder(request.Body)
+		err := decoder.Decode(&restoreRequest)
+		if err != nil && err != io.EOF {
+			log.Errorf("Bad data supplied for restoring secret: %s, error: %v", name, err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	log.Debugf("Restoring secret: name: %s, serial: %s", name, serial)
+	err := s.secretStore.Restore(name, serial, restoreRequest.Reason)
+	if err != nil {
+		log.Errorf("Error restoring secret name %s: %v", name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+}
+
+func (s *server) purgeSecret(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	name := vars["name"]
+	serial := vars["serial"]
+	purge, err := purgeRequested(request)
+	if err != nil || !purge {
+		log.Errorf("Purge not set for permanently deleting secret: %s, serial: %s", name, serial)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.purge(writer, name, serial)
+}
+
+func (s *server) purge(writer http.ResponseWriter, name string, serial string) {
+	log.Debugf("Purging secret: name: %s, serial: %s", name, serial)
+	err := s.secretStore.Purge(name, serial)
+	if err != nil {
+		log.Errorf("Error purging secret name %s: %v", name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+}
+
+func (s *server) deleteSecret(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	name := vars["name"]
+	purge, err := purgeRequested(request)
+	if err != nil {
+		log.Errorf("Bad value supplied for purge when deleting secret: %s, error: %v", name, err)
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if purge {
+		s.purge(writer, name, store.AllVersions)
+		return
+	}
+
+	recoveryWindow := store.DefaultRecoveryWindow
+	if value := request.URL.Query().Get("recoveryWindowInDays"); value != "" {
+		days, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Errorf("Bad value supplied for recoveryWindowInDays: %s, error: %v", value, err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		recoveryWindow = time.Duration(days) * 24 * time.Hour
+	}
+
+	log.Debugf("Scheduling deletion of secret: name: %s, recovery window: %v", name, recoveryWindow)
+	purgeAt, err := s.secretStore.Delete(name, recoveryWindow)
+	if err != nil {
+		log.Errorf("Error deleting secret name %s: %v", name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.Encode(&api.DeleteResponse{PurgeAt: purgeAt})
+}
+
+func (s *server) undeleteSecret(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	name := vars["name"]
+	log.Debugf("Undeleting secret: name: %s", name)
+	err := s.secretStore.Undelete(name)
+	if err != nil {
+		log.Errorf("Error undeleting secret name %s: %v", name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+}
+
+// purgeRequested returns true if the 'purge' query parameter of the request
+// asks for secrets to be deleted without a recovery window
+func purgeRequested(request *http.Request) (bool, error) {
+	value := request.URL.Query().Get("purge")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
 }
 
 func (s *server) getSecret(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	name := vars["name"]
 	serial := vars["serial"]
+	if label := request.URL.Query().Get("label"); label != "" {
+		if serial != "" {
+			log.Errorf("Both serial and label supplied for getting secret: %s", name)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := store.ValidateLabel(label); err != nil {
+			log.Errorf("Bad label supplied for getting secret: %s, error: %v", name, err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		serial = label
+	}
 	log.Debugf("Getting secret name: %s, serial: %s", name, serial)
 	secret, err := s.secretStore.Get(name, serial)
 	if err != nil {
 		log.Errorf("getSecret: Error getting secret name: %s, %v", name, err)
-		writer.WriteHeader(http.StatusBadRequest)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+
+	// Only the value of the field is returned when a field of a structured
+	// secret is requested
+	if field := request.URL.Query().Get("field"); field != "" {
+		value, err := secret.Field(field)
+		if err != nil {
+			log.Errorf("getSecret: Error getting field: %s of secret name: %s, %v", field, name, err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(writer, value)
 		return
 	}
 
+	// Clients that accept application/octet-stream are sent the payload as
+	// is, along with the content type it was saved with
+	if secret.Active && acceptsOctetStream(request) {
+		contentType := secret.ContentType
+		if contentType == "" {
+			contentType = api.OctetStreamContentType
+		}
+		writer.Header().Set("Content-Type", contentType)
+		io.WriteString(writer, secret.Payload)
+		return
+	}
+
+	secret.EncodePayload()
 	writer.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(writer)
 	encoder.Encode(&secret)
 }
 
+func (s *server) batchGetSecrets(writer http.ResponseWriter, request *http.Request) {
+	if request.Body == nil {
+		log.Errorf("Bad data supplied for getting secrets")
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	decoder := json.NewDecoder(request.Body)
+	var batchGetRequest api.BatchGetRequest
+	err := decoder.Decode(&batchGetRequest)
+	if err != nil {
+		log.Errorf("Bad data supplied for getting secrets, error: %v", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Debugf("Getting %d secrets", len(batchGetRequest.Secrets))
+	batch, err := s.secretStore.GetMany(batchGetRequest.Secrets)
+	if err != nil {
+		log.Errorf("batchGetSecrets: Error getting secrets: %v", err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+
+	for _, secret := range batch.Secrets {
+		secret.EncodePayload()
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.Encode(batch)
+}
+
+func (s *server) moveLabel(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	name := vars["name"]
+	label := vars["label"]
+	if request.Body == nil {
+		log.Errorf("Bad data supplied for labelling secret: %s", name)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	decoder := json.NewDecoder(request.Body)
+	var labelRequest api.LabelRequest
+	err := decoder.Decode(&labelRequest)
+	if err != nil {
+		log.Errorf("Bad data supplied for labelling secret: %s, error: %v", name, err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Debugf("Moving label: %s of secret: %s to serial: %d", label, name, labelRequest.Serial)
+	err = s.secretStore.MoveLabel(name, label, strconv.FormatInt(labelRequest.Serial, 10))
+	if err != nil {
+		log.Errorf("Error moving label %s of secret name %s: %v", label, name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+}
+
+func (s *server) listSecrets(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	prefix := query.Get("prefix")
+	nextToken := query.Get("nextToken")
+	var maxResults int64
+	if value := query.Get("maxResults"); value != "" {
+		var err error
+		maxResults, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Errorf("Bad value supplied for maxResults: %s, error: %v", value, err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	log.Debugf("Listing secrets, prefix: %s, nextToken: %s", prefix, nextToken)
+	secretList, err := s.secretStore.List(prefix, nextToken, maxResults)
+	if err != nil {
+		log.Errorf("listSecrets: Error listing secrets: %v", err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.Encode(secretList)
+}
+
+func (s *server) listVersions(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	name := vars["name"]
+	log.Debugf("Listing versions of secret name: %s", name)
+	history, err := s.secretStore.ListVersions(name)
+	if err != nil {
+		log.Errorf("listVersions: Error listing versions of secret name: %s, %v", name, err)
+		writer.WriteHeader(errorStatusCode(err))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(writer)
+	encoder.Encode(history)
+}
+
+// errorStatusCode returns the http status code to be used when responding to
+// a request that failed with the error
+func errorStatusCode(err error) int {
+	switch err.(type) {
+	case *store.InvalidRequestError:
+		return http.StatusBadRequest
+	case *store.NotFoundError:
+		return http.StatusNotFound
+	case *store.ConflictError:
+		return http.StatusConflict
+	case *store.PayloadTooLargeError:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// acceptsOctetStream returns true if the client accepts secrets as
+// application/octet-stream rather than as JSON
+func acceptsOctetStream(request *http.Request) bool {
+	for _, accept := range strings.Split(request.Header.Get("Accept"), ",") {
+		if isOctetStream(accept) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOctetStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == api.OctetStreamContentType
+}
+
 func (s *server) version(writer http.ResponseWriter, request *http.Request) {
 	log.Debugf("Returning api version: %s", version.ApiVersion)
 	writer.Header().Set("Content-Type", "application/json")
@@ -21,8 +21,10 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/awslabs/ecs-secrets/modules/api"
+	"github.com/awslabs/ecs-secrets/modules/store"
 	"github.com/awslabs/ecs-secrets/modules/store/mock"
 	"github.com/awslabs/ecs-secrets/modules/version"
 	"github.com/golang/mock/gomock"
@@ -68,83 +70,803 @@ func TestFetchSecretError(t *testing.T) {
 	recorder := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/latest/secrets/foo", nil)
 	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestFetchSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Get("foo", "").Return(&api.SecretRecord{Name: "foo"}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	var response api.SecretRecord
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	expectedResponse := api.SecretRecord{
+		Name: "foo",
+	}
+	if !reflect.DeepEqual(response, expectedResponse) {
+		t.Errorf("Incorrect response. %v != %v", response, expectedResponse)
+	}
+}
+
+func TestFetchSecretBinaryPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Get("foo", "").Return(&api.SecretRecord{
+		Name:        "foo",
+		Payload:     "\xfe\xed\xfe\xed",
+		Active:      true,
+		ContentType: "application/x-java-keystore",
+	}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	var response api.SecretRecord
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	expectedResponse := api.SecretRecord{
+		Name:        "foo",
+		Payload:     "/u3+7Q==",
+		Active:      true,
+		ContentType: "application/x-java-keystore",
+		Encoding:    api.Base64Encoding,
+	}
+	if !reflect.DeepEqual(response, expectedResponse) {
+		t.Errorf("Incorrect response. %v != %v", response, expectedResponse)
+	}
+}
+
+func TestFetchSecretOctetStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Get("foo", "2").Return(&api.SecretRecord{
+		Name:        "foo",
+		Serial:      2,
+		Payload:     "\xfe\xed\xfe\xed",
+		Active:      true,
+		ContentType: "application/x-java-keystore",
+	}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo/2", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/x-java-keystore" {
+		t.Errorf("Incorrect content type: %s", contentType)
+	}
+	if !bytes.Equal(recorder.Body.Bytes(), []byte{0xfe, 0xed, 0xfe, 0xed}) {
+		t.Errorf("Incorrect payload: %v", recorder.Body.Bytes())
+	}
+}
+
+func TestFetchSecretField(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Get("db", "").Return(&api.SecretRecord{
+		Name:        "db",
+		Payload:     `{"password":"hunter2","user":"admin"}`,
+		Active:      true,
+		ContentType: api.StructuredContentType,
+	}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/db?field=password", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	if body := recorder.Body.String(); body != "hunter2" {
+		t.Errorf("Incorrect value of field: %s", body)
+	}
+}
+
+func TestFetchSecretMissingField(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Get("db", "").Return(&api.SecretRecord{
+		Name:        "db",
+		Payload:     `{"user":"admin"}`,
+		Active:      true,
+		ContentType: api.StructuredContentType,
+	}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/db?field=password", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestPathNameRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	name := "prod/payments/db-password"
+	mockStore.EXPECT().Get(name, "").Return(&api.SecretRecord{Name: name}, nil)
+	mockStore.EXPECT().Get(name, "2").Return(&api.SecretRecord{Name: name}, nil)
+	mockStore.EXPECT().Get(name, "latest").Return(&api.SecretRecord{Name: name}, nil)
+	mockStore.EXPECT().ListVersions(name).Return(&api.SecretHistory{Name: name}, nil)
+	mockStore.EXPECT().MoveLabel(name, "current", "2").Return(nil)
+	mockStore.EXPECT().Revoke(name, "2").Return(nil)
+	mockStore.EXPECT().Restore(name, "2", "").Return(nil)
+	mockStore.EXPECT().Purge(name, "all").Return(nil)
+	mockStore.EXPECT().Delete(name, store.DefaultRecoveryWindow).Return(&time.Time{}, nil)
+	mockStore.EXPECT().Undelete(name).Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	for _, request := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/latest/secrets/prod/payments/db-password", ""},
+		{"GET", "/latest/secrets/prod/payments/db-password/2", ""},
+		{"GET", "/latest/secrets/prod/payments/db-password/latest", ""},
+		{"GET", "/latest/secrets/prod/payments/db-password/versions", ""},
+		{"PUT", "/latest/secrets/prod/payments/db-password/labels/current", `{"serial":2}`},
+		{"POST", "/latest/revoke/prod/payments/db-password/2", ""},
+		{"POST", "/latest/restore/prod/payments/db-password/2", ""},
+		{"DELETE", "/latest/secrets/prod/payments/db-password/all?purge=true", ""},
+		{"DELETE", "/latest/secrets/prod/payments/db-password", ""},
+		{"POST", "/latest/undelete/prod/payments/db-password", ""},
+	} {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(request.method, request.path, bytes.NewBufferString(request.body))
+		router.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Incorrect http status for %s %s: %v", request.method, request.path, recorder.Code)
+		}
+	}
+}
+
+func TestBatchGetSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().GetMany([]*api.SecretRequest{
+		{Name: "foo"},
+		{Name: "bar", Serial: 2},
+		{Name: "baz", Label: "current"},
+	}).Return(&api.SecretBatch{
+		Secrets: map[string]*api.SecretRecord{
+			"foo": {Name: "foo", Serial: 1, Payload: "one", Active: true},
+			"bar": {Name: "bar", Serial: 2, Payload: "two", Active: true},
+		},
+		Errors: map[string]string{"baz": "Label 'current' not found for secret 'baz'"},
+	}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets:batchGet",
+		bytes.NewBufferString(`{"secrets":[{"name":"foo"},{"name":"bar","serial":2},{"name":"baz","label":"current"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	var response api.SecretBatch
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(response.Secrets) != 2 || len(response.Errors) != 1 {
+		t.Errorf("Incorrect response: %v", response)
+	}
+}
+
+func TestBatchGetSecretsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().GetMany(gomock.Any()).Return(nil, &store.InvalidRequestError{Reason: "too many"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets:batchGet", bytes.NewBufferString(`{"secrets":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestRevokeSecretsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Revoke("foo", "1").Return(&store.NotFoundError{Name: "foo"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/revoke/foo/1", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestRevokeSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Revoke("foo", "1").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/revoke/foo/1", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestRestoreSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Restore("foo", "1", "revoked by mistake").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/restore/foo/1", bytes.NewBufferString(`{"reason":"revoked by mistake"}`))
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestRestoreSecretWithoutReason(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Restore("foo", "1", "").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/restore/foo/1", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestRestoreSecretBadData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/restore/foo/1", bytes.NewBufferString("reason"))
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestGetSecretByLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Get("foo", "pending").Return(&api.SecretRecord{
+		Name:    "foo",
+		Serial:  3,
+		Payload: "bar",
+		Active:  true,
+	}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo?label=pending", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestGetSecretByLabelAndSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo/3?label=pending", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestGetSecretByInvalidLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo?label=3", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestMoveLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().MoveLabel("foo", "current", "3").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/latest/secrets/foo/labels/current", bytes.NewBufferString(`{"serial":3}`))
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestMoveLabelConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().MoveLabel("foo", "current", "3").Return(&store.ConflictError{Name: "foo", Reason: "busy"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/latest/secrets/foo/labels/current", bytes.NewBufferString(`{"serial":3}`))
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestPurgeSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Purge("foo", "all").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/foo/all?purge=true", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestPurgeSecretWithoutPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/foo/1", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestPurgeSecretError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Purge("foo", "1").Return(&store.NotFoundError{Name: "foo"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/foo/1?purge=true", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestDeleteSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	purgeAt := time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)
+	mockStore.EXPECT().Delete("prod/db-password", store.DefaultRecoveryWindow).Return(&purgeAt, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/prod/db-password", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	var response api.DeleteResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.PurgeAt == nil || !response.PurgeAt.Equal(purgeAt) {
+		t.Errorf("Incorrect purge time. %v != %v", response.PurgeAt, purgeAt)
+	}
+}
+
+func TestDeleteSecretWithRecoveryWindow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	purgeAt := time.Date(2017, 1, 8, 0, 0, 0, 0, time.UTC)
+	mockStore.EXPECT().Delete("foo", 7*24*time.Hour).Return(&purgeAt, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/foo?recoveryWindowInDays=7", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestDeleteSecretBadRecoveryWindow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/foo?recoveryWindowInDays=week", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestDeleteSecretError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Delete("foo", time.Duration(0)).Return(nil, &store.InvalidRequestError{Reason: "window"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/foo?recoveryWindowInDays=0", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestDeleteSecretPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Purge("prod/db-password", "all").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/latest/secrets/prod/db-password?purge=true", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestUndeleteSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Undelete("prod/db-password").Return(nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/undelete/prod/db-password", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestUndeleteSecretError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Undelete("foo").Return(&store.NotFoundError{Name: "foo"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/undelete/foo", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsEmptyPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo", nil)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
 	if recorder.Code != http.StatusBadRequest {
 		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
 }
 
-func TestFetchSecret(t *testing.T) {
+func TestCreateSecretsError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStore := mock_store.NewMockStore(ctrl)
 
-	mockStore.EXPECT().Get("foo", "").Return(&api.SecretRecord{Name: "foo"}, nil)
+	secret := &api.SecretRecord{
+		Name:    "foo",
+		Serial:  int64(1),
+		Payload: "bar",
+		Active:  true,
+	}
+	mockStore.EXPECT().Save(secret).Return(nil, fmt.Errorf("kms is unavailable"))
 	s := NewServer(mockStore)
 	router := s.Router()
 	recorder := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "/latest/secrets/foo", nil)
+	data := api.SecretPayload{
+		Payload: "bar",
+	}
+	dataBytes, _ := json.Marshal(data)
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo", bytes.NewBuffer(dataBytes))
+	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(recorder, req)
-	if recorder.Code != http.StatusOK {
+	if recorder.Code != http.StatusInternalServerError {
 		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
-	var response api.SecretRecord
-	err := json.Unmarshal(recorder.Body.Bytes(), &response)
-	if err != nil {
-		t.Fatalf("Error decoding response: %v", err)
+}
+
+func TestCreateSecretsWithDescriptionAndTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	secret := &api.SecretRecord{
+		Name:        "foo",
+		Serial:      int64(1),
+		Payload:     "bar",
+		Active:      true,
+		Description: "first",
+		Tags:        map[string]string{"team": "payments"},
 	}
-	expectedResponse := api.SecretRecord{
-		Name: "foo",
+	mockStore.EXPECT().Save(secret).Return(secret, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	data := api.SecretPayload{
+		Payload:     "bar",
+		Description: "first",
+		Tags:        map[string]string{"team": "payments"},
 	}
-	if !reflect.DeepEqual(response, expectedResponse) {
-		t.Errorf("Incorrect response. %v != %v", response, expectedResponse)
+	dataBytes, _ := json.Marshal(data)
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo", bytes.NewBuffer(dataBytes))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
 }
 
-func TestRevokeSecretsError(t *testing.T) {
+func TestCreateSecretsWithExpiry(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStore := mock_store.NewMockStore(ctrl)
 
-	mockStore.EXPECT().Revoke("foo", "1").Return(fmt.Errorf("secret not found"))
+	expiresAt := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	mockStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+		if secret.ExpiresAt == nil || !secret.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("Incorrect expiry time: %v", secret.ExpiresAt)
+		}
+	}).Return(&api.SecretRecord{Name: "foo", Serial: 1}, nil)
 	s := NewServer(mockStore)
 	router := s.Router()
 	recorder := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/latest/revoke/foo/1", nil)
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo",
+		bytes.NewBufferString(`{"payload":"bar","expiresAt":"2017-06-01T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsBase64Payload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+		if secret.Payload != "\xfe\xed\xfe\xed" {
+			t.Errorf("Incorrect payload: %q", secret.Payload)
+		}
+		if secret.ContentType != "application/x-pkcs12" {
+			t.Errorf("Incorrect content type: %s", secret.ContentType)
+		}
+	}).Return(&api.SecretRecord{Name: "foo", Serial: 1}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo",
+		bytes.NewBufferString(`{"payload":"/u3+7Q==","encoding":"base64","contentType":"application/x-pkcs12"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsBadEncoding(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo",
+		bytes.NewBufferString(`{"payload":"not base64!","encoding":"base64"}`))
+	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(recorder, req)
 	if recorder.Code != http.StatusBadRequest {
 		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
 }
 
-func TestRevokeSecrets(t *testing.T) {
+func TestCreateSecretsOctetStream(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStore := mock_store.NewMockStore(ctrl)
 
-	mockStore.EXPECT().Revoke("foo", "1").Return(nil)
+	mockStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+		if secret.Payload != "\xfe\xed\xfe\xed" {
+			t.Errorf("Incorrect payload: %q", secret.Payload)
+		}
+		if secret.ContentType != "application/x-java-keystore" {
+			t.Errorf("Incorrect content type: %s", secret.ContentType)
+		}
+	}).Return(&api.SecretRecord{Name: "foo", Serial: 1}, nil)
 	s := NewServer(mockStore)
 	router := s.Router()
 	recorder := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/latest/revoke/foo/1", nil)
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo?contentType=application/x-java-keystore",
+		bytes.NewReader([]byte{0xfe, 0xed, 0xfe, 0xed}))
+	req.Header.Set("Content-Type", "application/octet-stream")
 	router.ServeHTTP(recorder, req)
 	if recorder.Code != http.StatusOK {
 		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
 }
 
-func TestCreateSecretsEmptyPayload(t *testing.T) {
+func TestCreateSecretsPathName(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockStore := mock_store.NewMockStore(ctrl)
 
+	mockStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+		if secret.Name != "prod/payments/db-password" {
+			t.Errorf("Incorrect name: %s", secret.Name)
+		}
+	}).Return(&api.SecretRecord{Name: "prod/payments/db-password", Serial: 1}, nil)
 	s := NewServer(mockStore)
 	router := s.Router()
 	recorder := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/latest/secrets/foo", nil)
+	req, _ := http.NewRequest("POST", "/latest/secrets/prod/payments/db-password", bytes.NewBufferString(`{"payload":"bar"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsInvalidName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/prod/payments/2", bytes.NewBufferString(`{"payload":"bar"}`))
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(recorder, req)
 	if recorder.Code != http.StatusBadRequest {
@@ -152,7 +874,65 @@ func TestCreateSecretsEmptyPayload(t *testing.T) {
 	}
 }
 
-func TestCreateSecretsError(t *testing.T) {
+func TestCreateSecretsIfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Save(gomock.Any()).Do(func(secret *api.SecretRecord) {
+		if secret.ExpectedSerial == nil || *secret.ExpectedSerial != 4 {
+			t.Errorf("Incorrect expected serial: %v", secret.ExpectedSerial)
+		}
+	}).Return(nil, &store.ConflictError{Name: "foo", Reason: "expected latest serial 4, found 5"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo", bytes.NewBufferString(`{"payload":"bar"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"4"`)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsBadIfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo", bytes.NewBufferString(`{"payload":"bar"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsPayloadTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().Save(gomock.Any()).Return(nil, &store.PayloadTooLargeError{Name: "foo", Size: store.MaxPayloadSize + 1, Limit: store.MaxPayloadSize})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/latest/secrets/foo", bytes.NewBufferString(`{"payload":"bar"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestCreateSecretsConflict(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -164,7 +944,7 @@ func TestCreateSecretsError(t *testing.T) {
 		Payload: "bar",
 		Active:  true,
 	}
-	mockStore.EXPECT().Save(secret).Return(nil, fmt.Errorf("secret not found"))
+	mockStore.EXPECT().Save(secret).Return(nil, &store.ConflictError{Name: "foo", Reason: "too busy"})
 	s := NewServer(mockStore)
 	router := s.Router()
 	recorder := httptest.NewRecorder()
@@ -175,7 +955,7 @@ func TestCreateSecretsError(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/latest/secrets/foo", bytes.NewBuffer(dataBytes))
 	req.Header.Set("Content-Type", "application/json")
 	router.ServeHTTP(recorder, req)
-	if recorder.Code != http.StatusBadRequest {
+	if recorder.Code != http.StatusConflict {
 		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
 }
@@ -207,3 +987,128 @@ func TestCreateSecrets(t *testing.T) {
 		t.Fatalf("Incorrect http status: %v", recorder.Code)
 	}
 }
+
+func TestListSecrets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	secretList := &api.SecretList{
+		Secrets:   []*api.SecretSummary{{Name: "foo", Serial: 1, Active: true}},
+		NextToken: "bar",
+	}
+	mockStore.EXPECT().List("", "token", int64(10)).Return(secretList, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets?nextToken=token&maxResults=10", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	var response api.SecretList
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if !reflect.DeepEqual(&response, secretList) {
+		t.Errorf("Incorrect response. %v != %v", response, secretList)
+	}
+}
+
+func TestListSecretsWithPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().List("prod/payments/", "", int64(0)).Return(&api.SecretList{}, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets?prefix=prod/payments/", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestListSecretsBadMaxResults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets?maxResults=lots", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestListSecretsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().List("", "", int64(0)).Return(nil, fmt.Errorf("no secrets here"))
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	history := &api.SecretHistory{
+		Name:     "foo",
+		Versions: []*api.SecretSummary{{Name: "foo", Serial: 1, Active: true}},
+	}
+	mockStore.EXPECT().ListVersions("foo").Return(history, nil)
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo/versions", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+	var response api.SecretHistory
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if !reflect.DeepEqual(&response, history) {
+		t.Errorf("Incorrect response. %v != %v", response, history)
+	}
+}
+
+func TestListVersionsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStore := mock_store.NewMockStore(ctrl)
+
+	mockStore.EXPECT().ListVersions("foo").Return(nil, &store.NotFoundError{Name: "foo"})
+	s := NewServer(mockStore)
+	router := s.Router()
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/secrets/foo/versions", nil)
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Incorrect http status: %v", recorder.Code)
+	}
+}
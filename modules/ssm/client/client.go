@@ -0,0 +1,121 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+)
+
+//go:generate mockgen.sh github.com/awslabs/ecs-secrets/modules/ssm/client Client mock/client_mock.go
+
+// Client defines the subset of the SSM Parameter Store API used to store
+// secrets in parameters
+type Client interface {
+	DeleteParameter(*DeleteParameterInput) (*DeleteParameterOutput, error)
+	GetParameter(*GetParameterInput) (*GetParameterOutput, error)
+	GetParameterHistory(*GetParameterHistoryInput) (*GetParameterHistoryOutput, error)
+	GetParametersByPath(*GetParametersByPathInput) (*GetParametersByPathOutput, error)
+	PutParameter(*PutParameterInput) (*PutParameterOutput, error)
+}
+
+// Error codes returned by Parameter Store
+const (
+	ParameterNotFoundErrorCode        = "ParameterNotFound"
+	ParameterVersionNotFoundErrorCode = "ParameterVersionNotFound"
+	ParameterAlreadyExistsErrorCode   = "ParameterAlreadyExists"
+)
+
+// Types of parameters
+const (
+	StringParameterType       = "String"
+	SecureStringParameterType = "SecureString"
+)
+
+const (
+	serviceName  = "ssm"
+	apiVersion   = "2014-11-06"
+	targetPrefix = "AmazonSSM"
+)
+
+// ssmClient implements the Client interface on top of the JSON protocol of the
+// SDK, as the version of the SDK that is vendored predates Parameter Store
+type ssmClient struct {
+	*client.Client
+}
+
+// NewClient creates a new Parameter Store client
+func NewClient(p client.ConfigProvider, cfgs ...*aws.Config) Client {
+	c := p.ClientConfig(serviceName, cfgs...)
+	svc := &ssmClient{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   serviceName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    apiVersion,
+				JSONVersion:   "1.1",
+				TargetPrefix:  targetPrefix,
+			},
+			c.Handlers,
+		),
+	}
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return svc
+}
+
+// send sends a request for the operation, unmarshalling the response into
+// output
+func (c *ssmClient) send(operation string, input interface{}, output interface{}) error {
+	op := &request.Operation{
+		Name:       operation,
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+	return c.NewRequest(op, input, output).Send()
+}
+
+func (c *ssmClient) DeleteParameter(input *DeleteParameterInput) (*DeleteParameterOutput, error) {
+	output := &DeleteParameterOutput{}
+	return output, c.send("DeleteParameter", input, output)
+}
+
+func (c *ssmClient) GetParameter(input *GetParameterInput) (*GetParameterOutput, error) {
+	output := &GetParameterOutput{}
+	return output, c.send("GetParameter", input, output)
+}
+
+func (c *ssmClient) GetParameterHistory(input *GetParameterHistoryInput) (*GetParameterHistoryOutput, error) {
+	output := &GetParameterHistoryOutput{}
+	return output, c.send("GetParameterHistory", input, output)
+}
+
+func (c *ssmClient) GetParametersByPath(input *GetParametersByPathInput) (*GetParametersByPathOutput, error) {
+	output := &GetParametersByPathOutput{}
+	return output, c.send("GetParametersByPath", input, output)
+}
+
+func (c *ssmClient) PutParameter(input *PutParameterInput) (*PutParameterOutput, error) {
+	output := &PutParameterOutput{}
+	return output, c.send("PutParameter", input, output)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func newTestClient(handler http.HandlerFunc) (Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	return NewClient(session.New(), &aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-west-2"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		DisableSSL:  aws.Bool(true),
+	}), server
+}
+
+func TestPutParameter(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "AmazonSSM.PutParameter" {
+			t.Errorf("Unexpected target: %s", target)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		input := map[string]interface{}{}
+		err := json.Unmarshal(body, &input)
+		if err != nil {
+			t.Fatalf("Error decoding request: %v", err)
+		}
+		expected := map[string]interface{}{
+			"Name":      "/ecs-secrets/myapp/secrets/foo",
+			"Value":     "bar",
+			"Type":      "SecureString",
+			"Overwrite": true,
+		}
+		for key, value := range expected {
+			if input[key] != value {
+				t.Errorf("Unexpected %s in request: %v != %v", key, input[key], value)
+			}
+		}
+		w.Write([]byte(`{"Version": 3}`))
+	})
+	defer server.Close()
+
+	output, err := client.PutParameter(&PutParameterInput{
+		Name:      aws.String("/ecs-secrets/myapp/secrets/foo"),
+		Value:     aws.String("bar"),
+		Type:      aws.String(SecureStringParameterType),
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		t.Fatalf("Error putting parameter: %v", err)
+	}
+	if aws.Int64Value(output.Version) != 3 {
+		t.Errorf("Expected version 3, got %d", aws.Int64Value(output.Version))
+	}
+}
+
+func TestGetParameterHistory(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Parameters": [{"Name": "foo", "Value": "a", "Version": 1}, {"Name": "foo", "Value": "b", "Version": 2}], "NextToken": "token"}`))
+	})
+	defer server.Close()
+
+	output, err := client.GetParameterHistory(&GetParameterHistoryInput{Name: aws.String("foo")})
+	if err != nil {
+		t.Fatalf("Error getting parameter history: %v", err)
+	}
+	if len(output.Parameters) != 2 || aws.StringValue(output.Parameters[1].Value) != "b" || aws.StringValue(output.NextToken) != "token" {
+		t.Errorf("Unexpected parameter history: %v", output)
+	}
+}
+
+func TestGetParameterNotFound(t *testing.T) {
+	client, server := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type": "ParameterNotFound", "message": "foo"}`))
+	})
+	defer server.Close()
+
+	_, err := client.GetParameter(&GetParameterInput{Name: aws.String("foo")})
+	awsErr, ok := err.(awserr.Error)
+	if !ok || awsErr.Code() != ParameterNotFoundErrorCode {
+		t.Errorf("Expected ParameterNotFound error, got: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+// The types below mirror the shapes of the Parameter Store API that are used
+// by Client. Fields are tagged the same way as in the generated service
+// packages of the SDK, so that they are marshalled by its JSON protocol
+
+// Parameter defines the latest version of a parameter
+type Parameter struct {
+	_ struct{} `type:"structure"`
+
+	Name    *string `type:"string"`
+	Type    *string `type:"string"`
+	Value   *string `type:"string"`
+	Version *int64  `type:"long"`
+}
+
+// ParameterHistory defines a version of a parameter
+type ParameterHistory struct {
+	_ struct{} `type:"structure"`
+
+	Name    *string `type:"string"`
+	Type    *string `type:"string"`
+	KeyId   *string `type:"string"`
+	Value   *string `type:"string"`
+	Version *int64  `type:"long"`
+}
+
+type DeleteParameterInput struct {
+	_ struct{} `type:"structure"`
+
+	Name *string `type:"string" required:"true"`
+}
+
+type DeleteParameterOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type GetParameterInput struct {
+	_ struct{} `type:"structure"`
+
+	Name           *string `type:"string" required:"true"`
+	WithDecryption *bool   `type:"boolean"`
+}
+
+type GetParameterOutput struct {
+	_ struct{} `type:"structure"`
+
+	Parameter *Parameter `type:"structure"`
+}
+
+type GetParameterHistoryInput struct {
+	_ struct{} `type:"structure"`
+
+	Name           *string `type:"string" required:"true"`
+	WithDecryption *bool   `type:"boolean"`
+	MaxResults     *int64  `type:"integer"`
+	NextToken      *string `type:"string"`
+}
+
+type GetParameterHistoryOutput struct {
+	_ struct{} `type:"structure"`
+
+	Parameters []*ParameterHistory `type:"list"`
+	NextToken  *string             `type:"string"`
+}
+
+type GetParametersByPathInput struct {
+	_ struct{} `type:"structure"`
+
+	Path           *string `type:"string" required:"true"`
+	Recursive      *bool   `type:"boolean"`
+	WithDecryption *bool   `type:"boolean"`
+	MaxResults     *int64  `type:"integer"`
+	NextToken      *string `type:"string"`
+}
+
+type GetParametersByPathOutput struct {
+	_ struct{} `type:"structure"`
+
+	Parameters []*Parameter `type:"list"`
+	NextToken  *string      `type:"string"`
+}
+
+type PutParameterInput struct {
+	_ struct{} `type:"structure"`
+
+	Name      *string `type:"string" required:"true"`
+	Value     *string `type:"string" required:"true"`
+	Type      *string `type:"string" required:"true"`
+	KeyId     *string `type:"string"`
+	Overwrite *bool   `type:"boolean"`
+}
+
+type PutParameterOutput struct {
+	_ struct{} `type:"structure"`
+
+	Version *int64 `type:"long"`
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package store
+
+import (
+	"fmt"
+
+	"github.com/awslabs/ecs-secrets/modules/api"
+)
+
+// Copy copies a version of a secret from one store to another, such as from
+// the store of one application to that of another. The payload is decrypted
+// by the source store and encrypted again with a data key of the target
+// store, without ever being written to disk. The description, tags, content
+// type and expiry of the version are kept. The latest active version is
+// copied if the serial is empty
+func Copy(from Store, to Store, name string, serial string) (*api.SecretRecord, error) {
+	secret, err := from.Get(name, serial)
+	if err != nil {
+		return nil, err
+	}
+	if !secret.Active {
+		return nil, fmt.Errorf("Version %d of secret '%s' is not active and cannot be copied", secret.Serial, name)
+	}
+
+	return to.Save(&api.SecretRecord{
+		Name:        secret.Name,
+		Serial:      int64(1),
+		Payload:     secret.Payload,
+		Active:      true,
+		Description: secret.Description,
+		Tags:        secret.Tags,
+		ExpiresAt:   secret.ExpiresAt,
+		ContentType: secret.ContentType,
+	})
+}
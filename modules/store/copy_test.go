@@ -0,0 +1,100 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package store
+
+import (
+	"testing"
+
+	"github.com/awslabs/ecs-secrets/modules/crypt/mock"
+	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/dao/mock"
+	"github.com/awslabs/ecs-secrets/modules/identity/mock"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCopy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fromDAO := mock_dao.NewMockDAO(ctrl)
+	fromCrypter := mock_crypt.NewMockCrypter(ctrl)
+	toDAO := mock_dao.NewMockDAO(ctrl)
+	toCrypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	loadedSecret := &dao.SecretRecord{
+		Name:        "foo",
+		Serial:      3,
+		Active:      true,
+		Description: "db password",
+		Tags:        map[string]string{"team": "infra"},
+		ContentType: "text/plain",
+		ExpiresAt:   testTime.Unix() + 3600,
+	}
+	newSecret := &dao.SecretRecord{
+		Name:        "foo",
+		Serial:      1,
+		Active:      true,
+		CreatedAt:   testTime.Unix(),
+		CreatedBy:   testCreatedBy,
+		Description: "db password",
+		Tags:        map[string]string{"team": "infra"},
+		ContentType: "text/plain",
+		ExpiresAt:   testTime.Unix() + 3600,
+	}
+	gomock.InOrder(
+		fromDAO.EXPECT().GetSecretRecord("foo", int64(3)).Return(loadedSecret, nil),
+		fromCrypter.EXPECT().DecryptSecret(loadedSecret).Return([]byte("foobar"), nil),
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		toDAO.EXPECT().GetLatestVersion("foo").Return(nil, nil),
+		toCrypter.EXPECT().EncryptSecret(newSecret, []byte("foobar")).Return(nil, nil),
+		toDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+	)
+	expectNoRetentionPolicy(toDAO, "foo")
+
+	fromStore := newTestStore(fromDAO, fromCrypter, identityProvider)
+	toStore := newTestStore(toDAO, toCrypter, identityProvider)
+	secret, err := Copy(fromStore, toStore, "foo", "3")
+	if err != nil {
+		t.Fatalf("Error copying secret: %v", err)
+	}
+	if secret.Serial != 1 || secret.Payload != "foobar" {
+		t.Errorf("Unexpected copied secret: %v", secret)
+	}
+}
+
+func TestCopyInactiveVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fromDAO := mock_dao.NewMockDAO(ctrl)
+	fromCrypter := mock_crypt.NewMockCrypter(ctrl)
+	toDAO := mock_dao.NewMockDAO(ctrl)
+	toCrypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	loadedSecret := &dao.SecretRecord{
+		Name:   "foo",
+		Serial: 2,
+		Active: false,
+	}
+	fromDAO.EXPECT().GetSecretRecord("foo", int64(2)).Return(loadedSecret, nil)
+
+	fromStore := newTestStore(fromDAO, fromCrypter, identityProvider)
+	toStore := newTestStore(toDAO, toCrypter, identityProvider)
+	_, err := Copy(fromStore, toStore, "foo", "2")
+	if err == nil {
+		t.Error("Expected error when copying an inactive version")
+	}
+}
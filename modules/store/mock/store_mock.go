@@ -18,6 +18,7 @@ package mock_store
 import (
 	api "github.com/awslabs/ecs-secrets/modules/api"
 	gomock "github.com/golang/mock/gomock"
+	time "time"
 )
 
 // Mock of Store interface
@@ -41,6 +42,28 @@ func (_m *MockStore) EXPECT() *_MockStoreRecorder {
 	return _m.recorder
 }
 
+func (_m *MockStore) CollectGarbage() ([]string, error) {
+	ret := _m.ctrl.Call(_m, "CollectGarbage")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockStoreRecorder) CollectGarbage() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CollectGarbage")
+}
+
+func (_m *MockStore) Delete(_param0 string, _param1 time.Duration) (*time.Time, error) {
+	ret := _m.ctrl.Call(_m, "Delete", _param0, _param1)
+	ret0, _ := ret[0].(*time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockStoreRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0, arg1)
+}
+
 func (_m *MockStore) Get(_param0 string, _param1 string) (*api.SecretRecord, error) {
 	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
 	ret0, _ := ret[0].(*api.SecretRecord)
@@ -52,6 +75,80 @@ func (_mr *_MockStoreRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
 }
 
+func (_m *MockStore) GetMany(_param0 []*api.SecretRequest) (*api.SecretBatch, error) {
+	ret := _m.ctrl.Call(_m, "GetMany", _param0)
+	ret0, _ := ret[0].(*api.SecretBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockStoreRecorder) GetMany(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMany", arg0)
+}
+
+func (_m *MockStore) List(_param0 string, _param1 string, _param2 int64) (*api.SecretList, error) {
+	ret := _m.ctrl.Call(_m, "List", _param0, _param1, _param2)
+	ret0, _ := ret[0].(*api.SecretList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockStoreRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "List", arg0, arg1, arg2)
+}
+
+func (_m *MockStore) ListVersions(_param0 string) (*api.SecretHistory, error) {
+	ret := _m.ctrl.Call(_m, "ListVersions", _param0)
+	ret0, _ := ret[0].(*api.SecretHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockStoreRecorder) ListVersions(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListVersions", arg0)
+}
+
+func (_m *MockStore) MoveLabel(_param0 string, _param1 string, _param2 string) error {
+	ret := _m.ctrl.Call(_m, "MoveLabel", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStoreRecorder) MoveLabel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MoveLabel", arg0, arg1, arg2)
+}
+
+func (_m *MockStore) Prune(_param0 string, _param1 bool) ([]*api.SecretSummary, error) {
+	ret := _m.ctrl.Call(_m, "Prune", _param0, _param1)
+	ret0, _ := ret[0].([]*api.SecretSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockStoreRecorder) Prune(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Prune", arg0, arg1)
+}
+
+func (_m *MockStore) Purge(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "Purge", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStoreRecorder) Purge(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Purge", arg0, arg1)
+}
+
+func (_m *MockStore) Restore(_param0 string, _param1 string, _param2 string) error {
+	ret := _m.ctrl.Call(_m, "Restore", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStoreRecorder) Restore(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Restore", arg0, arg1, arg2)
+}
+
 func (_m *MockStore) Revoke(_param0 string, _param1 string) error {
 	ret := _m.ctrl.Call(_m, "Revoke", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -72,3 +169,23 @@ func (_m *MockStore) Save(_param0 *api.SecretRecord) (*api.SecretRecord, error)
 func (_mr *_MockStoreRecorder) Save(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Save", arg0)
 }
+
+func (_m *MockStore) SetRetentionPolicy(_param0 string, _param1 *api.RetentionPolicy) error {
+	ret := _m.ctrl.Call(_m, "SetRetentionPolicy", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStoreRecorder) SetRetentionPolicy(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRetentionPolicy", arg0, arg1)
+}
+
+func (_m *MockStore) Undelete(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "Undelete", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockStoreRecorder) Undelete(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Undelete", arg0)
+}
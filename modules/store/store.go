@@ -15,15 +15,19 @@ package store
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	log "github.com/cihub/seelog"
 
 	"github.com/awslabs/ecs-secrets/modules/api"
 
 	"github.com/awslabs/ecs-secrets/modules/crypt"
 	"github.com/awslabs/ecs-secrets/modules/dao"
+	"github.com/awslabs/ecs-secrets/modules/identity"
 )
 
 //go:generate mockgen.sh github.com/awslabs/ecs-secrets/modules/store Store mock/store_mock.go
@@ -31,35 +35,169 @@ import (
 // Store defines the secret store interface
 type Store interface {
 	Get(string, string) (*api.SecretRecord, error)
+	GetMany([]*api.SecretRequest) (*api.SecretBatch, error)
 	Save(*api.SecretRecord) (*api.SecretRecord, error)
 	Revoke(string, string) error
+	Restore(string, string, string) error
+	MoveLabel(string, string, string) error
+	SetRetentionPolicy(string, *api.RetentionPolicy) error
+	Prune(string, bool) ([]*api.SecretSummary, error)
+	Purge(string, string) error
+	Delete(string, time.Duration) (*time.Time, error)
+	Undelete(string) error
+	CollectGarbage() ([]string, error)
+	List(string, string, int64) (*api.SecretList, error)
+	ListVersions(string) (*api.SecretHistory, error)
 }
 
+// AllVersions can be used in place of a serial to purge every version of a
+// secret
+const AllVersions = "all"
+
+// LatestVersion can be used in place of a serial to get the latest version of
+// a secret, even if it has been revoked or has expired. The latest version
+// that is active is returned if no serial is specified
+const LatestVersion = "latest"
+
+// Well known labels of secrets. Any other label can be used as well
+const (
+	CurrentLabel  = "current"
+	PendingLabel  = "pending"
+	PreviousLabel = "previous"
+)
+
+// reservedLabels cannot be used as labels as they clash with paths of the
+// REST API or with the arguments of the store
+var reservedLabels = map[string]bool{
+	AllVersions:   true,
+	LatestVersion: true,
+	"versions":    true,
+	"labels":      true,
+}
+
+// labelPattern defines the labels that can be used. Labels may not be numeric
+// so that they are never mistaken for serials
+var labelPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// nameSegmentPattern defines the segments that names of secrets are made up
+// of. Names may be paths made up of several segments separated by '/', such
+// as prod/payments/db-password
+var nameSegmentPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// maxNameLength is the length of the longest name a secret can have
+const maxNameLength = 512
+
+// maxBatchSize is the largest number of secrets that can be fetched at once
+const maxBatchSize = 100
+
+// maxConcurrentLookups is the largest number of secrets whose labels or latest
+// versions are looked up at the same time when fetching secrets at once
+const maxConcurrentLookups = 10
+
+// maxSaveAttempts is the number of times saving a secret is attempted when
+// its serial is claimed by another version of the secret being created at the
+// same time
+const maxSaveAttempts = 3
+
+// ConflictError is returned when a secret could not be saved because of other
+// changes being made to the secret at the same time
+type ConflictError struct {
+	Name   string
+	Reason string
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("Conflict saving secret '%s': %s", err.Name, err.Reason)
+}
+
+// NotFoundError is returned when the secret being fetched has no versions
+// matching the request
+type NotFoundError struct {
+	Name string
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("Secret with name '%s' not found", err.Name)
+}
+
+// MaxPayloadSize is the size in bytes of the largest payload that can be saved
+// in the store. Payloads that are too large to be stored in a single DynamoDB
+// item are split into chunks by the DAO
+const MaxPayloadSize = 2 * 1024 * 1024
+
+// PayloadTooLargeError is returned when the payload of a secret being saved is
+// larger than MaxPayloadSize, or than the data store can hold
+type PayloadTooLargeError struct {
+	Name  string
+	Size  int
+	Limit int
+}
+
+func (err *PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("Payload of secret '%s' is %d bytes, which is larger than the limit of %d bytes",
+		err.Name, err.Size, err.Limit)
+}
+
+// InvalidRequestError is returned when the request made to the store is
+// invalid, such as when a name, label or serial is malformed
+type InvalidRequestError struct {
+	Reason string
+}
+
+func (err *InvalidRequestError) Error() string {
+	return err.Reason
+}
+
+// Limits of the recovery window of secrets scheduled for deletion, during which
+// they can be undeleted
+const (
+	MinRecoveryWindow     = 7 * 24 * time.Hour
+	MaxRecoveryWindow     = 30 * 24 * time.Hour
+	DefaultRecoveryWindow = MaxRecoveryWindow
+)
+
+// defaultListLimit is the maximum number of items evaluated per page when
+// listing secrets, if the caller doesn't specify one
+const defaultListLimit = int64(100)
+
 type store struct {
-	dao     dao.DAO
-	crypter crypt.Crypter
+	dao              dao.DAO
+	crypter          crypt.Crypter
+	identityProvider identity.Provider
+	now              func() time.Time
 }
 
 // NewStore creates a new secret store backed by DynamoDB
-func NewStore(appName string, dao dao.DAO, crypter crypt.Crypter) Store {
+func NewStore(appName string, dao dao.DAO, crypter crypt.Crypter, identityProvider identity.Provider) Store {
 	return &store{
-		dao:     dao,
-		crypter: crypter,
+		dao:              dao,
+		crypter:          crypter,
+		identityProvider: identityProvider,
+		now:              time.Now,
 	}
 }
 
-// Get gets a secret from the store
+// Get gets a secret from the store. The latest active version of the secret
+// is returned if the serial is empty, while the latest version is returned if
+// the serial is LatestVersion. The serial may also be the label of a version
+// of the secret
 func (s *store) Get(name string, serial string) (*api.SecretRecord, error) {
 	var loadedSecret *dao.SecretRecord
 	var err error
 	if serial == "" {
+		loadedSecret, err = s.dao.GetLatestActiveVersion(name, s.now().Unix())
+	} else if serial == LatestVersion {
 		loadedSecret, err = s.dao.GetLatestVersion(name)
 	} else {
-		serialInt, err := strconv.Atoi(serial)
+		var serialInt int64
+		serialInt, err = s.resolveSerial(name, serial)
 		if err != nil {
 			return nil, err
 		}
-		loadedSecret, err = s.dao.GetSecretRecord(name, int64(serialInt))
+		loadedSecret, err = s.dao.GetSecretRecord(name, serialInt)
+		if err == dao.ErrSecretRecordNotFound {
+			return nil, &NotFoundError{Name: name}
+		}
 	}
 
 	if err != nil {
@@ -68,60 +206,945 @@ func (s *store) Get(name string, serial string) (*api.SecretRecord, error) {
 	}
 
 	if loadedSecret == nil {
-		return nil, fmt.Errorf("Secret with name '%s' not found", name)
+		return nil, &NotFoundError{Name: name}
+	}
+
+	return s.newSecretRecord(loadedSecret)
+}
+
+// GetMany gets several secrets from the store at once. Versions that are
+// requested by serial or label are read from DynamoDB in batches. The labels
+// and the latest active versions of the other secrets are looked up
+// concurrently, as they can't be read in batches. Secrets that cannot be
+// fetched are reported in the errors of the batch instead of failing the
+// whole request
+func (s *store) GetMany(requests []*api.SecretRequest) (*api.SecretBatch, error) {
+	if len(requests) > maxBatchSize {
+		return nil, &InvalidRequestError{
+			Reason: fmt.Sprintf("Only %d secrets can be fetched at once, got %d", maxBatchSize, len(requests)),
+		}
+	}
+	requested := make(map[string]bool)
+	for _, request := range requests {
+		if requested[request.Name] {
+			return nil, &InvalidRequestError{Reason: fmt.Sprintf("Secret '%s' requested more than once", request.Name)}
+		}
+		requested[request.Name] = true
+	}
+
+	batch := &api.SecretBatch{
+		Secrets: make(map[string]*api.SecretRecord),
+		Errors:  make(map[string]string),
+	}
+	addToBatch := func(name string, loadedSecret *dao.SecretRecord) {
+		secretRecord, err := s.newSecretRecord(loadedSecret)
+		if err != nil {
+			batch.Errors[name] = err.Error()
+			return
+		}
+		batch.Secrets[name] = secretRecord
 	}
 
+	var lookups []*secretLookup
+	for _, request := range requests {
+		if request.Serial != 0 && request.Label != "" {
+			batch.Errors[request.Name] = "Only one of serial or label should be specified"
+			continue
+		}
+		if request.Label != "" {
+			err := ValidateLabel(request.Label)
+			if err != nil {
+				batch.Errors[request.Name] = err.Error()
+				continue
+			}
+		}
+		lookups = append(lookups, &secretLookup{request: request})
+	}
+	s.lookupSecrets(lookups)
+
+	var keys []dao.SecretKey
+	for _, lookup := range lookups {
+		name := lookup.request.Name
+		if lookup.err != nil {
+			batch.Errors[name] = lookup.err.Error()
+			continue
+		}
+		if lookup.serial != 0 {
+			keys = append(keys, dao.SecretKey{Name: name, Serial: lookup.serial})
+			continue
+		}
+		if lookup.latest == nil {
+			batch.Errors[name] = (&NotFoundError{Name: name}).Error()
+			continue
+		}
+		addToBatch(name, lookup.latest)
+	}
+	if len(keys) == 0 {
+		return batch, nil
+	}
+
+	records, err := s.dao.GetSecretRecords(keys)
+	if err != nil {
+		log.Errorf("Error getting secret records: %v", err)
+		for _, key := range keys {
+			batch.Errors[key.Name] = err.Error()
+		}
+		return batch, nil
+	}
+	loadedSecrets := make(map[dao.SecretKey]*dao.SecretRecord)
+	for _, record := range records {
+		loadedSecrets[dao.SecretKey{Name: record.Name, Serial: record.Serial}] = record
+	}
+	for _, key := range keys {
+		loadedSecret, ok := loadedSecrets[key]
+		if !ok {
+			batch.Errors[key.Name] = fmt.Sprintf("Version %d of secret '%s' not found", key.Serial, key.Name)
+			continue
+		}
+		addToBatch(key.Name, loadedSecret)
+	}
+	return batch, nil
+}
+
+// secretLookup holds the outcome of looking up a secret requested from
+// GetMany. The serial is set if the request names a version, either by serial
+// or by label. Otherwise, the latest active version of the secret is set, if
+// it has one
+type secretLookup struct {
+	request *api.SecretRequest
+	serial  int64
+	latest  *dao.SecretRecord
+	err     error
+}
+
+// lookupSecrets resolves the labels and reads the latest active versions of
+// the secrets requested, running up to maxConcurrentLookups lookups at a time
+func (s *store) lookupSecrets(lookups []*secretLookup) {
+	now := s.now().Unix()
+	semaphore := make(chan struct{}, maxConcurrentLookups)
+	var wg sync.WaitGroup
+	for _, lookup := range lookups {
+		if lookup.request.Serial != 0 {
+			lookup.serial = lookup.request.Serial
+			continue
+		}
+		wg.Add(1)
+		go func(lookup *secretLookup) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			name := lookup.request.Name
+			if lookup.request.Label != "" {
+				lookup.serial, lookup.err = s.resolveSerial(name, lookup.request.Label)
+				return
+			}
+			lookup.latest, lookup.err = s.dao.GetLatestActiveVersion(name, now)
+			if lookup.err != nil {
+				log.Errorf("Error getting secret record for: %s, %v", name, lookup.err)
+			}
+		}(lookup)
+	}
+	wg.Wait()
+}
+
+// newSecretRecord converts the secret record loaded from DynamoDB, decrypting
+// its payload unless it is inactive or has expired. Secrets that are scheduled
+// for deletion are not found
+func (s *store) newSecretRecord(loadedSecret *dao.SecretRecord) (*api.SecretRecord, error) {
+	// Secrets scheduled for deletion are hidden until they are undeleted
+	if loadedSecret.PurgeAt != 0 {
+		return nil, &NotFoundError{Name: loadedSecret.Name}
+	}
 	secretRecord := &api.SecretRecord{
-		Name:   loadedSecret.Name,
-		Serial: loadedSecret.Serial,
-		Active: loadedSecret.Active,
+		Name:          loadedSecret.Name,
+		Serial:        loadedSecret.Serial,
+		Active:        loadedSecret.Active,
+		CreatedAt:     createdAtTime(loadedSecret),
+		CreatedBy:     loadedSecret.CreatedBy,
+		Description:   loadedSecret.Description,
+		Tags:          loadedSecret.Tags,
+		RestoredAt:    restoredAtTime(loadedSecret),
+		RestoredBy:    loadedSecret.RestoredBy,
+		RestoreReason: loadedSecret.RestoreReason,
+		ExpiresAt:     expiresAtTime(loadedSecret),
+		ContentType:   loadedSecret.ContentType,
 	}
 	if !loadedSecret.Active {
 		log.Debugf("Returning inactive secret; name: %s, serial: %d", secretRecord.Name, secretRecord.Serial)
 		return secretRecord, nil
 	}
+	// Expired versions are treated like revoked ones until DynamoDB deletes
+	// them
+	if s.expired(loadedSecret) {
+		log.Debugf("Returning expired secret; name: %s, serial: %d", secretRecord.Name, secretRecord.Serial)
+		secretRecord.Active = false
+		return secretRecord, nil
+	}
 
 	decryptedSecret, err := s.crypter.DecryptSecret(loadedSecret)
 	if err != nil {
-		log.Errorf("Error decrypting secret for: %s, %v", name, err)
+		log.Errorf("Error decrypting secret for: %s, %v", loadedSecret.Name, err)
 		return nil, err
 	}
-	secretRecord.Payload = aws.StringValue(decryptedSecret)
+	secretRecord.Payload = string(decryptedSecret)
 
 	return secretRecord, nil
 }
 
 // Revoke revokes the secret from the store
 func (s *store) Revoke(name string, serial string) error {
-	serialInt, err := strconv.Atoi(serial)
+	serialInt, err := parseSerial(serial)
 	if err != nil {
 		return err
 	}
-	return s.dao.RevokeSecretRecord(name, int64(serialInt))
+	err = s.dao.RevokeSecretRecord(name, serialInt)
+	if err == dao.ErrSecretRecordNotFound {
+		return &NotFoundError{Name: name}
+	}
+	return err
 }
 
-// Save saves the secret into the store
-func (s *store) Save(passedSecret *api.SecretRecord) (*api.SecretRecord, error) {
-	// get latest revision, increment serial by 1
-	latestSecret, err := s.dao.GetLatestVersion(passedSecret.Name)
+// Restore reinstates a revoked version of the secret in the store. The
+// identity of the caller and the reason for restoring it are recorded
+func (s *store) Restore(name string, serial string, reason string) error {
+	serialInt, err := parseSerial(serial)
+	if err != nil {
+		return err
+	}
+	restoredBy, err := s.identityProvider.CallerIdentity()
+	if err != nil {
+		log.Errorf("Error getting identity of the caller restoring secret: %s, %v", name, err)
+		return err
+	}
+	err = s.dao.RestoreSecretRecord(name, serialInt, &dao.Restoration{
+		RestoredAt: s.now().UTC().Unix(),
+		RestoredBy: restoredBy,
+		Reason:     reason,
+	})
+	if err == dao.ErrSecretRecordNotFound {
+		return &NotFoundError{Name: name}
+	}
+	return err
+}
+
+// MoveLabel points the label of the secret at a version of the secret. When
+// the current label is moved, the version it pointed at is labelled as the
+// previous version with the same write. Labels are updated atomically; if
+// they are modified concurrently, moving the label is attempted again
+func (s *store) MoveLabel(name string, label string, serial string) error {
+	err := ValidateLabel(label)
+	if err != nil {
+		return err
+	}
+	serialInt, err := parseSerial(serial)
+	if err != nil {
+		return err
+	}
+	// Make sure that the version exists before labelling it
+	_, err = s.dao.GetSecretRecord(name, serialInt)
+	if err == dao.ErrSecretRecordNotFound {
+		return &NotFoundError{Name: name}
+	}
+	if err != nil {
+		log.Errorf("Error getting secret record for: %s, serial: %d, %v", name, serialInt, err)
+		return err
+	}
+
+	for attempt := 1; attempt <= maxSaveAttempts; attempt++ {
+		metadata, err := s.dao.GetSecretLabels(name)
+		if err != nil {
+			return err
+		}
+		previousSerial, ok := metadata.Labels[label]
+		if ok && previousSerial == serialInt {
+			return nil
+		}
+		if label == CurrentLabel && ok {
+			metadata.Labels[PreviousLabel] = previousSerial
+		}
+		metadata.Labels[label] = serialInt
+
+		err = s.dao.PutSecretLabels(metadata)
+		if err != dao.ErrSecretLabelsModified {
+			return err
+		}
+		log.Infof("Metadata of secret %s was modified concurrently, attempt %d of %d",
+			name, attempt, maxSaveAttempts)
+	}
+
+	return &ConflictError{
+		Name:   name,
+		Reason: fmt.Sprintf("label could not be moved after %d attempts", maxSaveAttempts),
+	}
+}
+
+// SetRetentionPolicy sets the retention policy of the secret. The default
+// retention policy of the application is set if the name is empty. Secrets
+// without a retention policy of their own use the default one
+func (s *store) SetRetentionPolicy(name string, policy *api.RetentionPolicy) error {
+	if policy.MaxVersions < 0 || policy.MaxAge < 0 {
+		return &InvalidRequestError{Reason: "Invalid retention policy. Limits of the policy cannot be negative"}
+	}
+	if name == "" {
+		name = dao.ApplicationMetadataName
+	}
+
+	for attempt := 1; attempt <= maxSaveAttempts; attempt++ {
+		metadata, err := s.dao.GetSecretLabels(name)
+		if err != nil {
+			return err
+		}
+		metadata.RetentionMaxVersions = policy.MaxVersions
+		metadata.RetentionMaxAge = int64(policy.MaxAge / time.Second)
+
+		err = s.dao.PutSecretLabels(metadata)
+		if err != dao.ErrSecretLabelsModified {
+			return err
+		}
+		log.Infof("Metadata of secret %s was modified concurrently, attempt %d of %d",
+			name, attempt, maxSaveAttempts)
+	}
+
+	return &ConflictError{
+		Name:   name,
+		Reason: fmt.Sprintf("retention policy could not be set after %d attempts", maxSaveAttempts),
+	}
+}
+
+// Prune revokes the versions of the secret that fall outside its retention
+// policy. The versions are permanently deleted instead if purge is set. The
+// latest version and versions pointed at by labels are always kept. The
+// versions that were pruned are returned
+func (s *store) Prune(name string, purge bool) ([]*api.SecretSummary, error) {
+	metadata, policy, err := s.getRetentionPolicy(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.pruneVersions(name, metadata, policy, purge)
+}
+
+// Purge permanently deletes a version of the secret from the store, along
+// with any labels that point at it. Every version of the secret is deleted if
+// the serial is AllVersions
+func (s *store) Purge(name string, serial string) error {
+	serialInt := int64(0)
+	if serial != AllVersions {
+		var err error
+		serialInt, err = parseSerial(serial)
+		if err != nil {
+			return err
+		}
+	}
+
+	records, err := s.dao.ListVersions(name)
 	if err != nil {
+		log.Errorf("Error listing versions for: %s, %v", name, err)
+		return err
+	}
+	if serial == AllVersions {
+		if len(records) == 0 {
+			return &NotFoundError{Name: name}
+		}
+		return s.purgeVersions(name, records)
+	}
+
+	for _, record := range records {
+		if record.Serial != serialInt {
+			continue
+		}
+		log.Debugf("Purging secret name: %s, serial: %d", name, serialInt)
+		err = s.dao.DeleteSecretRecord(name, serialInt)
+		if err != nil {
+			log.Errorf("Error purging secret name: %s, serial: %d, %v", name, serialInt, err)
+			return err
+		}
+		return s.removeLabels(name, serialInt)
+	}
+	return &NotFoundError{Name: name}
+}
+
+// removeLabels removes the labels of the secret that point at the version
+// with the serial. Labels are updated atomically; if they are modified
+// concurrently, removing them is attempted again
+func (s *store) removeLabels(name string, serial int64) error {
+	for attempt := 1; attempt <= maxSaveAttempts; attempt++ {
+		metadata, err := s.dao.GetSecretLabels(name)
+		if err != nil {
+			return err
+		}
+		removed := false
+		for label, labelSerial := range metadata.Labels {
+			if labelSerial == serial {
+				delete(metadata.Labels, label)
+				removed = true
+			}
+		}
+		if !removed {
+			return nil
+		}
+
+		err = s.dao.PutSecretLabels(metadata)
+		if err != dao.ErrSecretLabelsModified {
+			return err
+		}
+		log.Infof("Metadata of secret %s was modified concurrently, attempt %d of %d",
+			name, attempt, maxSaveAttempts)
+	}
+
+	return &ConflictError{
+		Name:   name,
+		Reason: fmt.Sprintf("labels could not be removed after %d attempts", maxSaveAttempts),
+	}
+}
+
+// purgeVersions permanently deletes the versions of the secret, followed by
+// its metadata
+func (s *store) purgeVersions(name string, records []*dao.SecretRecord) error {
+	for _, record := range records {
+		log.Debugf("Purging secret name: %s, serial: %d", name, record.Serial)
+		err := s.dao.DeleteSecretRecord(name, record.Serial)
+		if err != nil {
+			log.Errorf("Error purging secret name: %s, serial: %d, %v", name, record.Serial, err)
+			return err
+		}
+	}
+	return s.dao.DeleteSecretLabels(name)
+}
+
+// Delete schedules every version of the secret for deletion. The secret is
+// hidden from Get and List straight away and can be undeleted until the
+// recovery window has passed, after which CollectGarbage purges it. The time
+// at which the secret can be purged is returned
+func (s *store) Delete(name string, recoveryWindow time.Duration) (*time.Time, error) {
+	if recoveryWindow < MinRecoveryWindow || recoveryWindow > MaxRecoveryWindow {
+		return nil, &InvalidRequestError{Reason: fmt.Sprintf("Recovery window should be between %v and %v, got %v",
+			MinRecoveryWindow, MaxRecoveryWindow, recoveryWindow)}
+	}
+
+	records, err := s.dao.ListVersions(name)
+	if err != nil {
+		log.Errorf("Error listing versions for: %s, %v", name, err)
 		return nil, err
 	}
-	if latestSecret != nil {
-		passedSecret.Serial = latestSecret.Serial + 1
+	if len(records) == 0 {
+		return nil, &NotFoundError{Name: name}
+	}
+	if records[len(records)-1].PurgeAt != 0 {
+		return nil, &InvalidRequestError{Reason: fmt.Sprintf("Secret '%s' is already scheduled for deletion", name)}
 	}
 
+	deletedBy, err := s.identityProvider.CallerIdentity()
+	if err != nil {
+		log.Errorf("Error getting identity of the caller deleting secret: %s, %v", name, err)
+		return nil, err
+	}
+	deletedAt := s.now().UTC().Truncate(time.Second)
+	purgeAt := deletedAt.Add(recoveryWindow)
+	deletion := &dao.Deletion{
+		DeletedAt: deletedAt.Unix(),
+		DeletedBy: deletedBy,
+		PurgeAt:   purgeAt.Unix(),
+	}
+	// Versions are scheduled in the order of their serials, so the latest
+	// version, which decides whether the secret is listed, is scheduled last.
+	// Versions scheduled by an earlier attempt that failed part way through
+	// are left as they are
+	for _, record := range records {
+		if record.PurgeAt != 0 {
+			continue
+		}
+		err = s.dao.ScheduleSecretRecordDeletion(name, record.Serial, deletion)
+		if err != nil {
+			log.Errorf("Error scheduling deletion of secret name: %s, serial: %d, %v", name, record.Serial, err)
+			return nil, err
+		}
+	}
+	return &purgeAt, nil
+}
+
+// Undelete cancels the scheduled deletion of the secret
+func (s *store) Undelete(name string) error {
+	records, err := s.dao.ListVersions(name)
+	if err != nil {
+		log.Errorf("Error listing versions for: %s, %v", name, err)
+		return err
+	}
+	if len(records) == 0 {
+		return &NotFoundError{Name: name}
+	}
+
+	cancelled := 0
+	for _, record := range records {
+		if record.PurgeAt == 0 {
+			continue
+		}
+		err = s.dao.CancelSecretRecordDeletion(name, record.Serial)
+		if err != nil {
+			log.Errorf("Error cancelling deletion of secret name: %s, serial: %d, %v", name, record.Serial, err)
+			return err
+		}
+		cancelled++
+	}
+	if cancelled == 0 {
+		return &InvalidRequestError{Reason: fmt.Sprintf("Secret '%s' is not scheduled for deletion", name)}
+	}
+	return nil
+}
+
+// CollectGarbage purges the secrets whose recovery window has passed since
+// they were scheduled for deletion. The names of the secrets that were purged
+// are returned
+func (s *store) CollectGarbage() ([]string, error) {
+	now := s.now().Unix()
+	var names []string
+	nextToken := ""
+	for {
+		records, token, err := s.dao.ListSecrets("", nextToken, defaultListLimit)
+		if err != nil {
+			log.Errorf("Error listing secrets: %v", err)
+			return nil, err
+		}
+		for _, record := range records {
+			if record.PurgeAt != 0 && record.PurgeAt <= now {
+				names = append(names, record.Name)
+			}
+		}
+		nextToken = token
+		if nextToken == "" {
+			break
+		}
+	}
+
+	var purged []string
+	for _, name := range names {
+		// The secret may have been undeleted since it was listed
+		records, err := s.dao.ListVersions(name)
+		if err != nil {
+			log.Errorf("Error listing versions for: %s, %v", name, err)
+			return purged, err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if purgeAt := records[len(records)-1].PurgeAt; purgeAt == 0 || purgeAt > now {
+			log.Infof("Secret %s is no longer due to be purged", name)
+			continue
+		}
+		err = s.purgeVersions(name, records)
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, name)
+	}
+	return purged, nil
+}
+
+// List lists the secrets in the store whose names start with the prefix,
+// along with their latest versions. The payloads of the secrets are never
+// returned
+func (s *store) List(prefix string, nextToken string, limit int64) (*api.SecretList, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	records, token, err := s.dao.ListSecrets(prefix, nextToken, limit)
+	if err == dao.ErrInvalidNextToken {
+		return nil, &InvalidRequestError{Reason: err.Error()}
+	}
+	if err != nil {
+		log.Errorf("Error listing secrets: %v", err)
+		return nil, err
+	}
+
+	secretList := &api.SecretList{
+		Secrets:   []*api.SecretSummary{},
+		NextToken: token,
+	}
+	for _, record := range records {
+		// Secrets scheduled for deletion are hidden until they are undeleted
+		if record.PurgeAt != 0 {
+			continue
+		}
+		secretList.Secrets = append(secretList.Secrets, newSecretSummary(record))
+	}
+	return secretList, nil
+}
+
+// ListVersions lists every version of the secret in the store, without
+// decrypting any of them
+func (s *store) ListVersions(name string) (*api.SecretHistory, error) {
+	records, err := s.dao.ListVersions(name)
+	if err != nil {
+		log.Errorf("Error listing versions for: %s, %v", name, err)
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, &NotFoundError{Name: name}
+	}
+
+	metadata, err := s.dao.GetSecretLabels(name)
+	if err != nil {
+		log.Errorf("Error getting metadata for: %s, %v", name, err)
+		return nil, err
+	}
+
+	history := &api.SecretHistory{
+		Name:     name,
+		Versions: []*api.SecretSummary{},
+	}
+	if len(metadata.Labels) > 0 {
+		history.Labels = metadata.Labels
+	}
+	for _, record := range records {
+		history.Versions = append(history.Versions, newSecretSummary(record))
+	}
+	return history, nil
+}
+
+// Save saves the secret into the store. The secret is saved with the serial
+// following that of its latest version. If that serial is claimed by a
+// concurrent save, the next one is tried. A ConflictError is returned if
+// all attempts fail, or if the latest version is not the one the caller
+// expected
+func (s *store) Save(passedSecret *api.SecretRecord) (*api.SecretRecord, error) {
+	// Reject payloads that are too large before calling KMS to encrypt them
+	if len(passedSecret.Payload) > MaxPayloadSize {
+		return nil, &PayloadTooLargeError{Name: passedSecret.Name, Size: len(passedSecret.Payload), Limit: MaxPayloadSize}
+	}
+	if passedSecret.ContentType == api.StructuredContentType {
+		_, err := api.ParseFields(passedSecret.Payload)
+		if err != nil {
+			return nil, &InvalidRequestError{Reason: err.Error()}
+		}
+	}
+
+	createdBy, err := s.identityProvider.CallerIdentity()
+	if err != nil {
+		log.Errorf("Error getting identity of the creator of secret: %s, %v", passedSecret.Name, err)
+		return nil, err
+	}
+	createdAt := s.now().UTC().Truncate(time.Second)
+	passedSecret.CreatedAt = &createdAt
+	passedSecret.CreatedBy = createdBy
+
 	newSecret := &dao.SecretRecord{
+		Name:        passedSecret.Name,
+		Active:      passedSecret.Active,
+		CreatedAt:   createdAt.Unix(),
+		CreatedBy:   createdBy,
+		Description: passedSecret.Description,
+		Tags:        passedSecret.Tags,
+		ContentType: passedSecret.ContentType,
+	}
+	if passedSecret.ExpiresAt != nil {
+		expiresAt := passedSecret.ExpiresAt.UTC().Truncate(time.Second)
+		if !expiresAt.After(createdAt) {
+			return nil, &InvalidRequestError{
+				Reason: fmt.Sprintf("Secret '%s' cannot expire before it is created", passedSecret.Name),
+			}
+		}
+		passedSecret.ExpiresAt = &expiresAt
+		newSecret.ExpiresAt = expiresAt.Unix()
+	}
+	// Some data stores hold smaller records than others, which is also
+	// checked before calling KMS
+	if limiter, ok := s.dao.(dao.SizeLimiter); ok {
+		limit := crypt.MaxPlaintextSize(limiter.MaxEncryptedDataSize(newSecret))
+		if len(passedSecret.Payload) > limit {
+			return nil, &PayloadTooLargeError{Name: passedSecret.Name, Size: len(passedSecret.Payload), Limit: limit}
+		}
+	}
+
+	for attempt := 1; attempt <= maxSaveAttempts; attempt++ {
+		// get latest revision, increment serial by 1
+		latestSecret, err := s.dao.GetLatestVersion(passedSecret.Name)
+		if err != nil {
+			return nil, err
+		}
+		if latestSecret != nil && latestSecret.PurgeAt != 0 {
+			return nil, &ConflictError{
+				Name:   passedSecret.Name,
+				Reason: "secret is scheduled for deletion and must be undeleted first",
+			}
+		}
+		latestSerial := int64(0)
+		if latestSecret != nil {
+			latestSerial = latestSecret.Serial
+			passedSecret.Serial = latestSecret.Serial + 1
+		}
+		// Callers that expect a particular latest version must not save
+		// over changes they haven't seen, so there is no retrying once the
+		// latest version has moved on
+		if expected := passedSecret.ExpectedSerial; expected != nil && *expected != latestSerial {
+			return nil, &ConflictError{
+				Name:   passedSecret.Name,
+				Reason: fmt.Sprintf("expected latest serial %d, found %d", *expected, latestSerial),
+			}
+		}
+		newSecret.Serial = passedSecret.Serial
+
+		// The payload only needs to be encrypted once. The same encrypted
+		// data can be saved with a different serial on subsequent attempts
+		if attempt == 1 {
+			_, err = s.crypter.EncryptSecret(newSecret, []byte(passedSecret.Payload))
+			if err != nil {
+				log.Errorf("Error encrypting secret record for: %s, %v", passedSecret.Name, err)
+				return nil, err
+			}
+		}
+
+		err = s.dao.PutSecretRecord(newSecret)
+		if err == nil {
+			s.enforceRetentionPolicy(passedSecret.Name)
+		}
+		if err != dao.ErrSecretRecordExists {
+			return passedSecret, err
+		}
+		log.Infof("Serial %d of secret %s was created concurrently, attempt %d of %d",
+			newSecret.Serial, passedSecret.Name, attempt, maxSaveAttempts)
+	}
+
+	return nil, &ConflictError{
 		Name:   passedSecret.Name,
-		Serial: passedSecret.Serial,
-		Active: passedSecret.Active,
+		Reason: fmt.Sprintf("serial could not be allocated after %d attempts", maxSaveAttempts),
 	}
+}
 
-	_, err = s.crypter.EncryptSecret(newSecret, passedSecret.Payload)
+// enforceRetentionPolicy revokes the versions of the secret that fall outside
+// its retention policy once a new version has been saved. The new version has
+// already been saved at this point, so errors are logged rather than returned
+func (s *store) enforceRetentionPolicy(name string) {
+	metadata, policy, err := s.getRetentionPolicy(name)
+	if err != nil {
+		log.Errorf("Error getting retention policy for: %s, %v", name, err)
+		return
+	}
+	pruned, err := s.pruneVersions(name, metadata, policy, false)
 	if err != nil {
-		log.Errorf("Error encrypting secret record for: %s, %v", passedSecret.Name, err)
+		log.Errorf("Error enforcing retention policy for: %s, %v", name, err)
+		return
+	}
+	for _, version := range pruned {
+		log.Infof("Revoked secret: %s, version: %d, as per the retention policy", name, version.Serial)
+	}
+}
+
+// getRetentionPolicy returns the metadata of the secret along with the
+// retention policy that applies to it. This is the default retention policy
+// of the application, unless the secret has its own
+func (s *store) getRetentionPolicy(name string) (*dao.SecretLabels, *dao.SecretLabels, error) {
+	metadata, err := s.dao.GetSecretLabels(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hasRetentionPolicy(metadata) {
+		return metadata, metadata, nil
+	}
+	applicationMetadata, err := s.dao.GetSecretLabels(dao.ApplicationMetadataName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return metadata, applicationMetadata, nil
+}
+
+// pruneVersions revokes or purges the versions of the secret that fall outside
+// the retention policy. Versions are listed in the order of their serials, so
+// the last versions in the list are the ones that are kept
+func (s *store) pruneVersions(name string, metadata *dao.SecretLabels, policy *dao.SecretLabels, purge bool) ([]*api.SecretSummary, error) {
+	pruned := []*api.SecretSummary{}
+	if !hasRetentionPolicy(policy) {
+		return pruned, nil
+	}
+
+	records, err := s.dao.ListVersions(name)
+	if err != nil {
+		log.Errorf("Error listing versions for: %s, %v", name, err)
 		return nil, err
 	}
-	err = s.dao.PutSecretRecord(newSecret)
-	return passedSecret, err
+
+	labelled := map[int64]bool{}
+	for _, serial := range metadata.Labels {
+		labelled[serial] = true
+	}
+	now := s.now().UTC().Unix()
+	for i, record := range records {
+		if i == len(records)-1 || labelled[record.Serial] {
+			continue
+		}
+		if policy.RetentionMaxVersions > 0 && int64(len(records)-i) <= policy.RetentionMaxVersions {
+			continue
+		}
+		// Versions created before creation times were tracked are
+		// considered to be older than any maximum age
+		if policy.RetentionMaxAge > 0 && record.CreatedAt != 0 && now-record.CreatedAt < policy.RetentionMaxAge {
+			continue
+		}
+
+		if purge {
+			err = s.dao.DeleteSecretRecord(name, record.Serial)
+		} else if record.Active {
+			err = s.dao.RevokeSecretRecord(name, record.Serial)
+		} else {
+			continue
+		}
+		if err != nil {
+			log.Errorf("Error pruning secret name: %s, serial: %d, %v", name, record.Serial, err)
+			return nil, err
+		}
+		pruned = append(pruned, newSecretSummary(record))
+	}
+	return pruned, nil
+}
+
+// expired returns true if the secret record has an expiry time that has
+// passed
+func (s *store) expired(record *dao.SecretRecord) bool {
+	return record.ExpiresAt != 0 && s.now().Unix() >= record.ExpiresAt
+}
+
+func hasRetentionPolicy(metadata *dao.SecretLabels) bool {
+	return metadata.RetentionMaxVersions > 0 || metadata.RetentionMaxAge > 0
+}
+
+// parseSerial parses the serial of a version of a secret
+func parseSerial(serial string) (int64, error) {
+	serialInt, err := strconv.ParseInt(serial, 10, 64)
+	if err != nil {
+		return 0, &InvalidRequestError{Reason: fmt.Sprintf("Invalid serial '%s'", serial)}
+	}
+	return serialInt, nil
+}
+
+// resolveSerial returns the serial of the version of the secret. The serial
+// passed can either be a number or a label of the secret
+func (s *store) resolveSerial(name string, serial string) (int64, error) {
+	serialInt, err := strconv.ParseInt(serial, 10, 64)
+	if err == nil {
+		return serialInt, nil
+	}
+	if ValidateLabel(serial) != nil {
+		return 0, &InvalidRequestError{Reason: fmt.Sprintf("Invalid serial or label '%s'", serial)}
+	}
+
+	metadata, err := s.dao.GetSecretLabels(name)
+	if err != nil {
+		log.Errorf("Error getting metadata for: %s, %v", name, err)
+		return 0, err
+	}
+	serialInt, ok := metadata.Labels[serial]
+	if !ok {
+		return 0, &InvalidRequestError{Reason: fmt.Sprintf("Label '%s' not found for secret '%s'", serial, name)}
+	}
+	log.Debugf("Label %s of secret %s points at serial %d", serial, name, serialInt)
+	return serialInt, nil
+}
+
+// ValidateLabel returns an error if the label cannot be used
+func ValidateLabel(label string) error {
+	if !labelPattern.MatchString(label) || reservedLabels[label] {
+		return &InvalidRequestError{
+			Reason: fmt.Sprintf("Invalid label '%s'. Labels must start with a letter and may only contain letters, digits, '_', '.' and '-'", label),
+		}
+	}
+	return nil
+}
+
+// ValidateName returns an error if the name cannot be used for a secret. The
+// last segment of a name may not be a number or a reserved label, so that it
+// is never mistaken for a serial in the paths of the REST API
+func ValidateName(name string) error {
+	if name == "" {
+		return &InvalidRequestError{Reason: "Invalid name. Names of secrets cannot be empty"}
+	}
+	if len(name) > maxNameLength {
+		return &InvalidRequestError{
+			Reason: fmt.Sprintf("Invalid name '%s'. Names of secrets cannot be longer than %d characters", name, maxNameLength),
+		}
+	}
+	segments := strings.Split(name, "/")
+	for _, segment := range segments {
+		if !nameSegmentPattern.MatchString(segment) || segment == "." || segment == ".." {
+			return &InvalidRequestError{
+				Reason: fmt.Sprintf("Invalid name '%s'. Names are made up of segments separated by '/', which may only contain letters, digits, '_', '.' and '-'", name),
+			}
+		}
+	}
+	lastSegment := segments[len(segments)-1]
+	if _, err := strconv.ParseInt(lastSegment, 10, 64); err == nil || reservedLabels[lastSegment] {
+		return &InvalidRequestError{
+			Reason: fmt.Sprintf("Invalid name '%s'. The last segment of a name cannot be a number or '%s'", name, lastSegment),
+		}
+	}
+	return nil
+}
+
+func newSecretSummary(record *dao.SecretRecord) *api.SecretSummary {
+	return &api.SecretSummary{
+		Name:          record.Name,
+		Serial:        record.Serial,
+		Active:        record.Active,
+		CreatedAt:     createdAtTime(record),
+		CreatedBy:     record.CreatedBy,
+		Description:   record.Description,
+		Tags:          record.Tags,
+		RestoredAt:    restoredAtTime(record),
+		RestoredBy:    record.RestoredBy,
+		RestoreReason: record.RestoreReason,
+		ExpiresAt:     expiresAtTime(record),
+		ContentType:   record.ContentType,
+		DeletedAt:     deletedAtTime(record),
+		DeletedBy:     record.DeletedBy,
+		PurgeAt:       purgeAtTime(record),
+	}
+}
+
+// createdAtTime returns the creation time of the secret record. Records
+// created before creation times were tracked don't have one
+func createdAtTime(record *dao.SecretRecord) *time.Time {
+	if record.CreatedAt == 0 {
+		return nil
+	}
+	createdAt := time.Unix(record.CreatedAt, 0).UTC()
+	return &createdAt
+}
+
+// restoredAtTime returns the time at which the secret record was last
+// restored, if it has ever been restored
+func restoredAtTime(record *dao.SecretRecord) *time.Time {
+	if record.RestoredAt == 0 {
+		return nil
+	}
+	restoredAt := time.Unix(record.RestoredAt, 0).UTC()
+	return &restoredAt
+}
+
+// expiresAtTime returns the time at which the secret record expires, if it
+// expires at all
+func expiresAtTime(record *dao.SecretRecord) *time.Time {
+	if record.ExpiresAt == 0 {
+		return nil
+	}
+	expiresAt := time.Unix(record.ExpiresAt, 0).UTC()
+	return &expiresAt
+}
+
+// deletedAtTime returns the time at which the secret record was scheduled for
+// deletion, if it has been
+func deletedAtTime(record *dao.SecretRecord) *time.Time {
+	if record.DeletedAt == 0 {
+		return nil
+	}
+	deletedAt := time.Unix(record.DeletedAt, 0).UTC()
+	return &deletedAt
+}
+
+// purgeAtTime returns the time after which the secret record can be purged,
+// if it has been scheduled for deletion
+func purgeAtTime(record *dao.SecretRecord) *time.Time {
+	if record.PurgeAt == 0 {
+		return nil
+	}
+	purgeAt := time.Unix(record.PurgeAt, 0).UTC()
+	return &purgeAt
 }
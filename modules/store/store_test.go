@@ -16,23 +16,51 @@ package store
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/awslabs/ecs-secrets/modules/api"
 
+	"github.com/awslabs/ecs-secrets/modules/crypt"
 	"github.com/awslabs/ecs-secrets/modules/crypt/mock"
 	"github.com/awslabs/ecs-secrets/modules/dao"
 	"github.com/awslabs/ecs-secrets/modules/dao/mock"
+	"github.com/awslabs/ecs-secrets/modules/identity"
+	"github.com/awslabs/ecs-secrets/modules/identity/mock"
 	"github.com/golang/mock/gomock"
 )
 
+const testCreatedBy = "arn:aws:iam::123456789012:user/foo"
+
+var testTime = time.Unix(1500000000, 0).UTC()
+
+// newTestStore creates a store whose clock is fixed at testTime
+func newTestStore(mockDAO dao.DAO, crypter crypt.Crypter, identityProvider identity.Provider) Store {
+	s := NewStore("myapp", mockDAO, crypter, identityProvider).(*store)
+	s.now = func() time.Time {
+		return testTime
+	}
+	return s
+}
+
+// expectNoRetentionPolicy sets up the DAO to return no retention policy for
+// the secret or the application
+func expectNoRetentionPolicy(mockDAO *mock_dao.MockDAO, name string) {
+	mockDAO.EXPECT().GetSecretLabels(name).Return(&dao.SecretLabels{Name: name}, nil)
+	mockDAO.EXPECT().GetSecretLabels(dao.ApplicationMetadataName).Return(&dao.SecretLabels{Name: dao.ApplicationMetadataName}, nil)
+}
+
 func TestGet(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
 	loadedSecret := &dao.SecretRecord{
 		Name:   "bar",
@@ -40,10 +68,10 @@ func TestGet(t *testing.T) {
 	}
 	gomock.InOrder(
 		mockDAO.EXPECT().GetSecretRecord("foo", int64(1)).Return(loadedSecret, nil),
-		crypter.EXPECT().DecryptSecret(loadedSecret).Return(aws.String("foobar"), nil),
+		crypter.EXPECT().DecryptSecret(loadedSecret).Return([]byte("foobar"), nil),
 	)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
 	secret, err := secretStore.Get("foo", "1")
 	if err != nil {
 		t.Fatalf("Error getting secret: %v", err)
@@ -58,23 +86,195 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetBinaryPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	loadedSecret := &dao.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		ContentType: "application/x-java-keystore",
+	}
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretRecord("foo", int64(1)).Return(loadedSecret, nil),
+		crypter.EXPECT().DecryptSecret(loadedSecret).Return([]byte{0xfe, 0xed, 0xfe, 0xed}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", "1")
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
+	}
+	expectedSecret := &api.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		Payload:     "\xfe\xed\xfe\xed",
+		ContentType: "application/x-java-keystore",
+	}
+	if !reflect.DeepEqual(secret, expectedSecret) {
+		t.Errorf("Mismatch between expected and retrieved secret: %v != %v", secret, expectedSecret)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	latestSecret := &dao.SecretRecord{Name: "foo", Serial: 4, Active: true}
+	serialSecret := &dao.SecretRecord{Name: "bar", Serial: 2, Active: true}
+	labelSecret := &dao.SecretRecord{Name: "baz", Serial: 7, Active: true}
+	mockDAO.EXPECT().GetLatestActiveVersion("foo", testTime.Unix()).Return(latestSecret, nil)
+	mockDAO.EXPECT().GetLatestActiveVersion("qux", testTime.Unix()).Return(nil, nil)
+	mockDAO.EXPECT().GetSecretLabels("baz").Return(&dao.SecretLabels{
+		Name:   "baz",
+		Labels: map[string]int64{CurrentLabel: 7},
+	}, nil)
+	mockDAO.EXPECT().GetSecretRecords([]dao.SecretKey{
+		{Name: "bar", Serial: 2},
+		{Name: "baz", Serial: 7},
+		{Name: "quux", Serial: 3},
+	}).Return([]*dao.SecretRecord{labelSecret, serialSecret}, nil)
+	crypter.EXPECT().DecryptSecret(latestSecret).Return([]byte("latest"), nil)
+	crypter.EXPECT().DecryptSecret(serialSecret).Return([]byte("serial"), nil)
+	crypter.EXPECT().DecryptSecret(labelSecret).Return([]byte("label"), nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	batch, err := secretStore.GetMany([]*api.SecretRequest{
+		{Name: "foo"},
+		{Name: "bar", Serial: 2},
+		{Name: "baz", Label: CurrentLabel},
+		{Name: "qux"},
+		{Name: "quux", Serial: 3},
+	})
+	if err != nil {
+		t.Fatalf("Error getting secrets: %v", err)
+	}
+	expectedSecrets := map[string]*api.SecretRecord{
+		"foo": {Name: "foo", Serial: 4, Active: true, Payload: "latest"},
+		"bar": {Name: "bar", Serial: 2, Active: true, Payload: "serial"},
+		"baz": {Name: "baz", Serial: 7, Active: true, Payload: "label"},
+	}
+	if !reflect.DeepEqual(batch.Secrets, expectedSecrets) {
+		t.Errorf("Mismatch between expected and retrieved secrets: %v != %v", batch.Secrets, expectedSecrets)
+	}
+	if len(batch.Errors) != 2 || batch.Errors["qux"] == "" || batch.Errors["quux"] == "" {
+		t.Errorf("Expected errors for the secrets that were not found, got: %v", batch.Errors)
+	}
+}
+
+func TestGetManyLooksUpLatestVersionsConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	// Every lookup waits until all of them have started, which only happens
+	// if they run concurrently
+	numSecrets := 5
+	var started sync.WaitGroup
+	started.Add(numSecrets)
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+	var timedOut int32
+	mockDAO.EXPECT().GetLatestActiveVersion(gomock.Any(), testTime.Unix()).Do(func(name string, now int64) {
+		started.Done()
+		select {
+		case <-allStarted:
+		case <-time.After(5 * time.Second):
+			atomic.StoreInt32(&timedOut, 1)
+		}
+	}).Return(nil, nil).Times(numSecrets)
+
+	var requests []*api.SecretRequest
+	for i := 0; i < numSecrets; i++ {
+		requests = append(requests, &api.SecretRequest{Name: "secret-" + strconv.Itoa(i)})
+	}
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	batch, err := secretStore.GetMany(requests)
+	if err != nil {
+		t.Fatalf("Error getting secrets: %v", err)
+	}
+	if atomic.LoadInt32(&timedOut) != 0 {
+		t.Error("Expected latest versions to be looked up concurrently")
+	}
+	if len(batch.Errors) != numSecrets {
+		t.Errorf("Expected errors for the secrets that were not found, got: %v", batch.Errors)
+	}
+}
+
+func TestGetManyBatchError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().GetSecretRecords(gomock.Any()).Return(nil, fmt.Errorf("throttled"))
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	batch, err := secretStore.GetMany([]*api.SecretRequest{
+		{Name: "foo", Serial: 1},
+		{Name: "bar", Serial: 2},
+		{Name: "baz", Serial: 3, Label: CurrentLabel},
+	})
+	if err != nil {
+		t.Fatalf("Error getting secrets: %v", err)
+	}
+	if len(batch.Secrets) != 0 || len(batch.Errors) != 3 {
+		t.Errorf("Expected every secret to fail, got: %v, %v", batch.Secrets, batch.Errors)
+	}
+}
+
+func TestGetManyDuplicateName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.GetMany([]*api.SecretRequest{
+		{Name: "foo", Serial: 1},
+		{Name: "foo", Serial: 2},
+	})
+	if err == nil {
+		t.Error("Expected error getting the same secret twice")
+	}
+}
+
 func TestGetNoSerial(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
 	loadedSecret := &dao.SecretRecord{
 		Name:   "bar",
 		Active: true,
 	}
 	gomock.InOrder(
-		mockDAO.EXPECT().GetLatestVersion("foo").Return(loadedSecret, nil),
-		crypter.EXPECT().DecryptSecret(loadedSecret).Return(aws.String("foobar"), nil),
+		mockDAO.EXPECT().GetLatestActiveVersion("foo", testTime.Unix()).Return(loadedSecret, nil),
+		crypter.EXPECT().DecryptSecret(loadedSecret).Return([]byte("foobar"), nil),
 	)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
 	secret, err := secretStore.Get("foo", "")
 	if err != nil {
 		t.Fatalf("Error getting secret: %v", err)
@@ -95,6 +295,7 @@ func TestGetInactiveSecret(t *testing.T) {
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
 	loadedSecret := &dao.SecretRecord{
 		Name:          "bar",
@@ -104,8 +305,8 @@ func TestGetInactiveSecret(t *testing.T) {
 
 	mockDAO.EXPECT().GetLatestVersion("foo").Return(loadedSecret, nil)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
-	fetchedSecret, err := secretStore.Get("foo", "")
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	fetchedSecret, err := secretStore.Get("foo", LatestVersion)
 	if err != nil {
 		t.Errorf("Error getting inactive secret: %v", err)
 	}
@@ -120,17 +321,18 @@ func TestGetDecryptSecretError(t *testing.T) {
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
 	loadedSecret := &dao.SecretRecord{
 		Name:   "bar",
 		Active: true,
 	}
 	gomock.InOrder(
-		mockDAO.EXPECT().GetLatestVersion("foo").Return(loadedSecret, nil),
+		mockDAO.EXPECT().GetLatestActiveVersion("foo", testTime.Unix()).Return(loadedSecret, nil),
 		crypter.EXPECT().DecryptSecret(loadedSecret).Return(nil, fmt.Errorf("denied")),
 	)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
 	_, err := secretStore.Get("foo", "")
 	if err == nil {
 		t.Errorf("Expected error getting secret")
@@ -143,10 +345,11 @@ func TestGetSecretDoesNotExist(t *testing.T) {
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
-	mockDAO.EXPECT().GetLatestVersion("foo").Return(nil, nil)
+	mockDAO.EXPECT().GetLatestActiveVersion("foo", testTime.Unix()).Return(nil, nil)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
 	_, err := secretStore.Get("foo", "")
 	if err == nil {
 		t.Error("Expected error getting non existent secret")
@@ -159,10 +362,11 @@ func TestGetDAOError(t *testing.T) {
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
-	mockDAO.EXPECT().GetLatestVersion("foo").Return(nil, fmt.Errorf("come back in a thousand years"))
+	mockDAO.EXPECT().GetLatestActiveVersion("foo", testTime.Unix()).Return(nil, fmt.Errorf("come back in a thousand years"))
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
 	_, err := secretStore.Get("foo", "")
 	if err == nil {
 		t.Error("Expected error getting inactive secret")
@@ -175,118 +379,1194 @@ func TestRevoke(t *testing.T) {
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
 	mockDAO.EXPECT().RevokeSecretRecord("foo", int64(1)).Return(nil)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
 	err := secretStore.Revoke("foo", "1")
 	if err != nil {
 		t.Errorf("Error revoking secret: %v", err)
 	}
 }
 
-func TestSave(t *testing.T) {
+func TestRevokeInvalidSerial(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
-	loadedSecret := &dao.SecretRecord{
-		Name:   "bar",
-		Active: true,
-		Serial: 1,
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Revoke("foo", "one")
+	if _, ok := err.(*InvalidRequestError); !ok {
+		t.Errorf("Expected invalid request error revoking secret with an invalid serial, got: %v", err)
 	}
+}
 
-	newSecret := &dao.SecretRecord{
-		Name:   "bar",
-		Active: true,
-		Serial: 2,
+func TestRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+	mockDAO.EXPECT().RestoreSecretRecord("foo", int64(1), &dao.Restoration{
+		RestoredAt: testTime.Unix(),
+		RestoredBy: testCreatedBy,
+		Reason:     "revoked by mistake",
+	}).Return(nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Restore("foo", "1", "revoked by mistake")
+	if err != nil {
+		t.Errorf("Error restoring secret: %v", err)
+	}
+}
+
+func TestRestoreCallerIdentityError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	identityProvider.EXPECT().CallerIdentity().Return("", fmt.Errorf("who am i"))
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Restore("foo", "1", "")
+	if err == nil {
+		t.Error("Expected error restoring secret when caller identity is unknown")
+	}
+}
+
+func TestGetRestoredSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	record := &dao.SecretRecord{
+		Name:          "foo",
+		Serial:        1,
+		Active:        true,
+		RestoredAt:    testTime.Unix(),
+		RestoredBy:    testCreatedBy,
+		RestoreReason: "revoked by mistake",
+	}
+	mockDAO.EXPECT().GetSecretRecord("foo", int64(1)).Return(record, nil)
+	crypter.EXPECT().DecryptSecret(record).Return([]byte("foobar"), nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", "1")
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
+	}
+	if secret.RestoredAt == nil || !secret.RestoredAt.Equal(testTime) {
+		t.Errorf("Incorrect restore time: %v", secret.RestoredAt)
+	}
+	if secret.RestoredBy != testCreatedBy || secret.RestoreReason != "revoked by mistake" {
+		t.Errorf("Incorrect restore details: %s, %s", secret.RestoredBy, secret.RestoreReason)
+	}
+}
+
+func TestGetLatestVersionReturnsRevokedVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().GetLatestVersion("foo").Return(&dao.SecretRecord{Name: "foo", Serial: 3}, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", LatestVersion)
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
 	}
+	if secret.Serial != 3 || secret.Active {
+		t.Errorf("Expected the revoked latest version to be returned, got: %v", secret)
+	}
+}
+
+func TestGetByLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	record := &dao.SecretRecord{Name: "foo", Serial: 3, Active: true}
 	gomock.InOrder(
-		mockDAO.EXPECT().GetLatestVersion("bar").Return(loadedSecret, nil),
-		crypter.EXPECT().EncryptSecret(newSecret, "foobar").Return(nil, nil),
-		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:   "foo",
+			Labels: map[string]int64{CurrentLabel: 2, PendingLabel: 3},
+		}, nil),
+		mockDAO.EXPECT().GetSecretRecord("foo", int64(3)).Return(record, nil),
+		crypter.EXPECT().DecryptSecret(record).Return([]byte("foobar"), nil),
 	)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", PendingLabel)
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
+	}
+	if secret.Serial != 3 || secret.Payload != "foobar" {
+		t.Errorf("Incorrect secret returned for label: %v", secret)
+	}
+}
+
+func TestGetByLabelNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
 
-	apiSecret := &api.SecretRecord{
-		Name:    "bar",
-		Active:  true,
-		Serial:  1,
-		Payload: "foobar",
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+		Name:   "foo",
+		Labels: map[string]int64{},
+	}, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Get("foo", PendingLabel)
+	if err == nil {
+		t.Error("Expected error getting secret with unknown label")
 	}
-	secret, err := secretStore.Save(apiSecret)
+}
+
+func TestGetInvalidSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Get("foo", "-1x")
+	if err == nil {
+		t.Error("Expected error getting secret with invalid serial")
+	}
+}
+
+func TestMoveLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretRecord("foo", int64(3)).Return(&dao.SecretRecord{Name: "foo", Serial: 3}, nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2, PendingLabel: 3},
+			Revision: 4,
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 3, PendingLabel: 3, PreviousLabel: 2},
+			Revision: 4,
+		}).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.MoveLabel("foo", CurrentLabel, "3")
 	if err != nil {
-		t.Fatalf("Error saving secret: %v", err)
+		t.Errorf("Error moving label: %v", err)
 	}
-	expectedSecret := &api.SecretRecord{
-		Name:    "bar",
-		Active:  true,
-		Payload: "foobar",
-		Serial:  2,
+}
+
+func TestMoveLabelRetriesOnConcurrentModification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretRecord("foo", int64(3)).Return(&dao.SecretRecord{Name: "foo", Serial: 3}, nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:   "foo",
+			Labels: map[string]int64{},
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(gomock.Any()).Return(dao.ErrSecretLabelsModified),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2},
+			Revision: 1,
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2, PendingLabel: 3},
+			Revision: 1,
+		}).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.MoveLabel("foo", PendingLabel, "3")
+	if err != nil {
+		t.Errorf("Error moving label: %v", err)
 	}
-	if !reflect.DeepEqual(secret, expectedSecret) {
-		t.Errorf("Mismatch between expected and saved secret: %v != %v", secret, expectedSecret)
+}
+
+func TestMoveLabelConflict(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().GetSecretRecord("foo", int64(3)).Return(&dao.SecretRecord{Name: "foo", Serial: 3}, nil)
+	for attempt := 1; attempt <= maxSaveAttempts; attempt++ {
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:   "foo",
+			Labels: map[string]int64{},
+		}, nil)
+		mockDAO.EXPECT().PutSecretLabels(gomock.Any()).Return(dao.ErrSecretLabelsModified)
+	}
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.MoveLabel("foo", PendingLabel, "3")
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected conflict error moving label, got: %v", err)
 	}
 }
 
-func TestSaveNoLatestVersion(t *testing.T) {
+func TestMoveLabelVersionDoesNotExist(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
-	newSecret := &dao.SecretRecord{
-		Name:   "bar",
-		Active: true,
-		Serial: 1,
+	mockDAO.EXPECT().GetSecretRecord("foo", int64(3)).Return(nil, dao.ErrSecretRecordNotFound)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.MoveLabel("foo", PendingLabel, "3")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("Expected not found error moving label to a version that does not exist, got: %v", err)
+	}
+}
+
+func TestMoveLabelInvalidLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	for _, label := range []string{"", "1", "all", "versions", "a b"} {
+		err := secretStore.MoveLabel("foo", label, "3")
+		if err == nil {
+			t.Errorf("Expected error moving invalid label: '%s'", label)
+		}
 	}
+}
+
+func TestSaveEnforcesRetentionPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
 	gomock.InOrder(
-		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
-		crypter.EXPECT().EncryptSecret(newSecret, "foobar").Return(nil, nil),
-		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 3}, nil),
+		crypter.EXPECT().EncryptSecret(gomock.Any(), []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Return(nil),
+		mockDAO.EXPECT().GetSecretLabels("bar").Return(&dao.SecretLabels{
+			Name:                 "bar",
+			RetentionMaxVersions: 2,
+		}, nil),
+		mockDAO.EXPECT().ListVersions("bar").Return([]*dao.SecretRecord{
+			{Name: "bar", Serial: 1},
+			{Name: "bar", Serial: 2, Active: true},
+			{Name: "bar", Serial: 3, Active: true},
+			{Name: "bar", Serial: 4, Active: true},
+		}, nil),
+		mockDAO.EXPECT().RevokeSecretRecord("bar", int64(2)).Return(nil),
 	)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
-
-	apiSecret := &api.SecretRecord{
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Save(&api.SecretRecord{
 		Name:    "bar",
 		Active:  true,
 		Serial:  1,
 		Payload: "foobar",
-	}
-	secret, err := secretStore.Save(apiSecret)
+	})
 	if err != nil {
 		t.Fatalf("Error saving secret: %v", err)
 	}
-	if !reflect.DeepEqual(secret, apiSecret) {
-		t.Errorf("Mismatch between expected and saved secret: %v != %v", secret, apiSecret)
+	if secret.Serial != 4 {
+		t.Errorf("Expected secret to be saved with serial 4, got: %d", secret.Serial)
 	}
 }
 
-func TestSaveOnEncryptError(t *testing.T) {
+func TestSaveIgnoresRetentionPolicyErrors(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockDAO := mock_dao.NewMockDAO(ctrl)
 	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
 
-	newSecret := &dao.SecretRecord{
-		Name:   "bar",
-		Active: true,
-		Serial: 1,
-	}
 	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
 		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
-		crypter.EXPECT().EncryptSecret(newSecret, "foobar").Return(nil, fmt.Errorf("what's the point")),
+		crypter.EXPECT().EncryptSecret(gomock.Any(), []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Return(nil),
+		mockDAO.EXPECT().GetSecretLabels("bar").Return(nil, fmt.Errorf("throttled")),
 	)
 
-	secretStore := NewStore("myapp", mockDAO, crypter)
-
-	apiSecret := &api.SecretRecord{
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: "foobar",
+	})
+	if err != nil {
+		t.Errorf("Error saving secret: %v", err)
+	}
+}
+
+func TestPruneUsesApplicationDefault(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	day := int64(24 * 60 * 60)
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:   "foo",
+			Labels: map[string]int64{PreviousLabel: 2},
+		}, nil),
+		mockDAO.EXPECT().GetSecretLabels(dao.ApplicationMetadataName).Return(&dao.SecretLabels{
+			Name:            dao.ApplicationMetadataName,
+			RetentionMaxAge: 30 * day,
+		}, nil),
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1, Active: false},
+			{Name: "foo", Serial: 2, Active: true, CreatedAt: testTime.Unix() - 90*day},
+			{Name: "foo", Serial: 3, Active: true, CreatedAt: testTime.Unix() - 60*day},
+			{Name: "foo", Serial: 4, Active: true, CreatedAt: testTime.Unix() - 10*day},
+			{Name: "foo", Serial: 5, Active: true, CreatedAt: testTime.Unix() - 60*day},
+		}, nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(1)).Return(nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(3)).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	pruned, err := secretStore.Prune("foo", true)
+	if err != nil {
+		t.Fatalf("Error pruning secret: %v", err)
+	}
+	if len(pruned) != 2 || pruned[0].Serial != 1 || pruned[1].Serial != 3 {
+		t.Errorf("Unexpected versions pruned: %v", pruned)
+	}
+}
+
+func TestPruneWithoutRetentionPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	expectNoRetentionPolicy(mockDAO, "foo")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	pruned, err := secretStore.Prune("foo", false)
+	if err != nil {
+		t.Fatalf("Error pruning secret: %v", err)
+	}
+	if len(pruned) != 0 {
+		t.Errorf("Expected no versions to be pruned, got: %v", pruned)
+	}
+}
+
+func TestSetRetentionPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2},
+			Revision: 1,
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{
+			Name:                 "foo",
+			Labels:               map[string]int64{CurrentLabel: 2},
+			RetentionMaxVersions: 5,
+			RetentionMaxAge:      3600,
+			Revision:             1,
+		}).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.SetRetentionPolicy("foo", &api.RetentionPolicy{
+		MaxVersions: 5,
+		MaxAge:      time.Hour,
+	})
+	if err != nil {
+		t.Errorf("Error setting retention policy: %v", err)
+	}
+}
+
+func TestSetApplicationRetentionPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretLabels(dao.ApplicationMetadataName).Return(&dao.SecretLabels{
+			Name: dao.ApplicationMetadataName,
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{
+			Name:                 dao.ApplicationMetadataName,
+			RetentionMaxVersions: 10,
+		}).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.SetRetentionPolicy("", &api.RetentionPolicy{MaxVersions: 10})
+	if err != nil {
+		t.Errorf("Error setting retention policy: %v", err)
+	}
+}
+
+func TestSetRetentionPolicyNegativeLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.SetRetentionPolicy("foo", &api.RetentionPolicy{MaxVersions: -1})
+	if err == nil {
+		t.Error("Expected error setting retention policy with negative limit")
+	}
+}
+
+func TestGetExpiredSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().GetSecretRecord("foo", int64(1)).Return(&dao.SecretRecord{
+		Name:      "foo",
+		Serial:    1,
+		Active:    true,
+		ExpiresAt: testTime.Unix(),
+	}, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", "1")
+	if err != nil {
+		t.Fatalf("Error getting expired secret: %v", err)
+	}
+	if secret.Active || secret.Payload != "" {
+		t.Errorf("Expected expired secret to be inactive without payload: %v", secret)
+	}
+	if secret.ExpiresAt == nil || !secret.ExpiresAt.Equal(testTime) {
+		t.Errorf("Incorrect expiry time: %v", secret.ExpiresAt)
+	}
+}
+
+func TestGetSecretNotYetExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	record := &dao.SecretRecord{
+		Name:      "foo",
+		Serial:    1,
+		Active:    true,
+		ExpiresAt: testTime.Unix() + 1,
+	}
+	mockDAO.EXPECT().GetSecretRecord("foo", int64(1)).Return(record, nil)
+	crypter.EXPECT().DecryptSecret(record).Return([]byte("foobar"), nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", "1")
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
+	}
+	if !secret.Active || secret.Payload != "foobar" {
+		t.Errorf("Expected secret to be active with payload: %v", secret)
+	}
+}
+
+func TestSaveWithExpiry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	expiresAt := testTime.Add(720 * time.Hour)
+	newSecret := &dao.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Serial:    1,
+		CreatedAt: testTime.Unix(),
+		CreatedBy: testCreatedBy,
+		ExpiresAt: expiresAt.Unix(),
+	}
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
+		crypter.EXPECT().EncryptSecret(newSecret, []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	passedExpiresAt := expiresAt.Add(500 * time.Millisecond)
+	secret, err := secretStore.Save(&api.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Serial:    1,
+		Payload:   "foobar",
+		ExpiresAt: &passedExpiresAt,
+	})
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+	if !secret.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Incorrect expiry time: %v", secret.ExpiresAt)
+	}
+}
+
+func TestSaveWithContentType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	newSecret := &dao.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		Serial:      1,
+		CreatedAt:   testTime.Unix(),
+		CreatedBy:   testCreatedBy,
+		ContentType: "application/x-pkcs12",
+	}
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
+		crypter.EXPECT().EncryptSecret(newSecret, []byte{0x30, 0x82}).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		Serial:      1,
+		Payload:     "\x30\x82",
+		ContentType: "application/x-pkcs12",
+	})
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+}
+
+func TestSaveInvalidStructuredSecret(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		Serial:      1,
+		Payload:     "hunter2",
+		ContentType: api.StructuredContentType,
+	})
+	if err == nil {
+		t.Error("Expected error saving structured secret that is not a JSON object")
+	}
+}
+
+func TestSaveExpiryInThePast(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	expiresAt := testTime.Add(-time.Hour)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Serial:    1,
+		Payload:   "foobar",
+		ExpiresAt: &expiresAt,
+	})
+	if err == nil {
+		t.Error("Expected error saving secret that has already expired")
+	}
+}
+
+func TestSavePayloadTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: strings.Repeat("a", MaxPayloadSize+1),
+	})
+	if _, ok := err.(*PayloadTooLargeError); !ok {
+		t.Errorf("Expected payload too large error, got: %v", err)
+	}
+}
+
+// sizeLimitedDAO is a DAO that can store encrypted data of at most
+// maxEncryptedDataSize characters
+type sizeLimitedDAO struct {
+	*mock_dao.MockDAO
+	maxEncryptedDataSize int
+}
+
+func (d *sizeLimitedDAO) MaxEncryptedDataSize(*dao.SecretRecord) int {
+	return d.maxEncryptedDataSize
+}
+
+func TestSavePayloadTooLargeForDAO(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	limit := crypt.MaxPlaintextSize(100)
+	secretStore := newTestStore(&sizeLimitedDAO{MockDAO: mockDAO, maxEncryptedDataSize: 100}, crypter, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Payload: strings.Repeat("a", limit+1),
+	})
+	tooLarge, ok := err.(*PayloadTooLargeError)
+	if !ok {
+		t.Fatalf("Expected payload too large error, got: %v", err)
+	}
+	if tooLarge.Limit != limit {
+		t.Errorf("Expected limit of %d bytes, got %d", limit, tooLarge.Limit)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1},
+			{Name: "foo", Serial: 2},
+		}, nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(1)).Return(nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:   "foo",
+			Labels: map[string]int64{CurrentLabel: 2},
+		}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Purge("foo", "1")
+	if err != nil {
+		t.Errorf("Error purging secret: %v", err)
+	}
+}
+
+func TestPurgeRemovesLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1},
+			{Name: "foo", Serial: 2},
+		}, nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(1)).Return(nil),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2, PreviousLabel: 1, "pending": 1},
+			Revision: 3,
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2},
+			Revision: 3,
+		}).Return(dao.ErrSecretLabelsModified),
+		mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2, PreviousLabel: 1},
+			Revision: 4,
+		}, nil),
+		mockDAO.EXPECT().PutSecretLabels(&dao.SecretLabels{
+			Name:     "foo",
+			Labels:   map[string]int64{CurrentLabel: 2},
+			Revision: 4,
+		}).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Purge("foo", "1")
+	if err != nil {
+		t.Errorf("Error purging secret: %v", err)
+	}
+}
+
+func TestPurgeVersionDoesNotExist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+		{Name: "foo", Serial: 1},
+	}, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Purge("foo", "2")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("Expected not found error purging non existent version, got: %v", err)
+	}
+}
+
+func TestPurgeAllVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1},
+			{Name: "foo", Serial: 2},
+		}, nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(1)).Return(nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(2)).Return(nil),
+		mockDAO.EXPECT().DeleteSecretLabels("foo").Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Purge("foo", AllVersions)
+	if err != nil {
+		t.Errorf("Error purging secret: %v", err)
+	}
+}
+
+func TestPurgeAllVersionsSecretDoesNotExist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().ListVersions("foo").Return(nil, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Purge("foo", AllVersions)
+	if err == nil {
+		t.Error("Expected error purging non existent secret")
+	}
+}
+
+func TestPurgeInvalidSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	err := secretStore.Purge("foo", "one")
+	if err == nil {
+		t.Error("Expected error purging secret with an invalid serial")
+	}
+}
+
+func TestSave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	loadedSecret := &dao.SecretRecord{
+		Name:   "bar",
+		Active: true,
+		Serial: 1,
+	}
+
+	newSecret := &dao.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Serial:    2,
+		CreatedAt: testTime.Unix(),
+		CreatedBy: testCreatedBy,
+	}
+	gomock.InOrder(
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(loadedSecret, nil),
+		crypter.EXPECT().EncryptSecret(newSecret, []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+
+	apiSecret := &api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: "foobar",
+	}
+	secret, err := secretStore.Save(apiSecret)
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+	expectedSecret := &api.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Payload:   "foobar",
+		Serial:    2,
+		CreatedAt: &testTime,
+		CreatedBy: testCreatedBy,
+	}
+	if !reflect.DeepEqual(secret, expectedSecret) {
+		t.Errorf("Mismatch between expected and saved secret: %v != %v", secret, expectedSecret)
+	}
+}
+
+func TestSaveRetriesWhenSerialIsTaken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	var savedSerials []int64
+	gomock.InOrder(
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 1}, nil),
+		crypter.EXPECT().EncryptSecret(gomock.Any(), []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Do(func(record *dao.SecretRecord) {
+			savedSerials = append(savedSerials, record.Serial)
+		}).Return(dao.ErrSecretRecordExists),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 2}, nil),
+		mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Do(func(record *dao.SecretRecord) {
+			savedSerials = append(savedSerials, record.Serial)
+		}).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+
+	apiSecret := &api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: "foobar",
+	}
+	secret, err := secretStore.Save(apiSecret)
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+	if secret.Serial != 3 {
+		t.Errorf("Expected secret to be saved with serial 3, got: %d", secret.Serial)
+	}
+	if !reflect.DeepEqual(savedSerials, []int64{2, 3}) {
+		t.Errorf("Unexpected serials attempted: %v", savedSerials)
+	}
+}
+
+func TestSaveWithExpectedSerial(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 4}, nil),
+		crypter.EXPECT().EncryptSecret(gomock.Any(), []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	expectedSerial := int64(4)
+	secret, err := secretStore.Save(&api.SecretRecord{
+		Name:           "bar",
+		Active:         true,
+		Serial:         1,
+		Payload:        "foobar",
+		ExpectedSerial: &expectedSerial,
+	})
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+	if secret.Serial != 5 {
+		t.Errorf("Expected secret to be saved with serial 5, got: %d", secret.Serial)
+	}
+}
+
+func TestSaveExpectedSerialMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 5}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	expectedSerial := int64(4)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:           "bar",
+		Active:         true,
+		Serial:         1,
+		Payload:        "foobar",
+		ExpectedSerial: &expectedSerial,
+	})
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected conflict error, got: %v", err)
+	}
+}
+
+func TestSaveExpectedSerialSecretExists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 1}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	expectedSerial := int64(0)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:           "bar",
+		Active:         true,
+		Serial:         1,
+		Payload:        "foobar",
+		ExpectedSerial: &expectedSerial,
+	})
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected conflict error, got: %v", err)
+	}
+}
+
+func TestSaveExpectedSerialConcurrentSave(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 4}, nil),
+		crypter.EXPECT().EncryptSecret(gomock.Any(), []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Return(dao.ErrSecretRecordExists),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(&dao.SecretRecord{Name: "bar", Serial: 5}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	expectedSerial := int64(4)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:           "bar",
+		Active:         true,
+		Serial:         1,
+		Payload:        "foobar",
+		ExpectedSerial: &expectedSerial,
+	})
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected conflict error, got: %v", err)
+	}
+}
+
+func TestSaveConflictWhenAttemptsAreExhausted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil).Times(maxSaveAttempts)
+	crypter.EXPECT().EncryptSecret(gomock.Any(), []byte("foobar")).Return(nil, nil)
+	mockDAO.EXPECT().PutSecretRecord(gomock.Any()).Return(dao.ErrSecretRecordExists).Times(maxSaveAttempts)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+
+	apiSecret := &api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: "foobar",
+	}
+	_, err := secretStore.Save(apiSecret)
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected conflict error saving secret, got: %v", err)
+	}
+}
+
+func TestSaveNoLatestVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	newSecret := &dao.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Serial:    1,
+		CreatedAt: testTime.Unix(),
+		CreatedBy: testCreatedBy,
+	}
+	gomock.InOrder(
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
+		crypter.EXPECT().EncryptSecret(newSecret, []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+
+	apiSecret := &api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: "foobar",
+	}
+	secret, err := secretStore.Save(apiSecret)
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+	if !reflect.DeepEqual(secret, apiSecret) {
+		t.Errorf("Mismatch between expected and saved secret: %v != %v", secret, apiSecret)
+	}
+}
+
+func TestSaveOnEncryptError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil)
+
+	newSecret := &dao.SecretRecord{
+		Name:      "bar",
+		Active:    true,
+		Serial:    1,
+		CreatedAt: testTime.Unix(),
+		CreatedBy: testCreatedBy,
+	}
+	gomock.InOrder(
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
+		crypter.EXPECT().EncryptSecret(newSecret, []byte("foobar")).Return(nil, fmt.Errorf("what's the point")),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+
+	apiSecret := &api.SecretRecord{
 		Name:    "bar",
 		Active:  true,
 		Serial:  1,
@@ -297,3 +1577,407 @@ func TestSaveOnEncryptError(t *testing.T) {
 		t.Error("Expected error saving secret")
 	}
 }
+
+func TestList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().ListSecrets("", "", defaultListLimit).Return([]*dao.SecretRecord{
+		{Name: "foo", Serial: 2, Active: true},
+		{Name: "bar", Serial: 1},
+	}, "token", nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secretList, err := secretStore.List("", "", 0)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	expectedList := &api.SecretList{
+		Secrets: []*api.SecretSummary{
+			{Name: "foo", Serial: 2, Active: true},
+			{Name: "bar", Serial: 1},
+		},
+		NextToken: "token",
+	}
+	if !reflect.DeepEqual(secretList, expectedList) {
+		t.Errorf("Mismatch between expected and listed secrets: %v != %v", secretList, expectedList)
+	}
+}
+
+func TestListDAOError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().ListSecrets("", "token", int64(5)).Return(nil, "", fmt.Errorf("lost count"))
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.List("", "token", 5)
+	if err == nil {
+		t.Error("Expected error listing secrets")
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"dbpassword", "com.foo.app1.mysql", "prod/payments/db-password", "prod/2017/key_1"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("Expected name '%s' to be valid: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "*", "/prod/db", "prod/db/", "prod//db", "prod/../db", "prod/db password",
+		"prod/db/2", "prod/db/latest", "prod/db/versions", strings.Repeat("a", maxNameLength+1)} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("Expected name '%s' to be invalid", name)
+		}
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+		{Name: "foo", Serial: 1},
+		{Name: "foo", Serial: 2, Active: true},
+	}, nil)
+	mockDAO.EXPECT().GetSecretLabels("foo").Return(&dao.SecretLabels{
+		Name:   "foo",
+		Labels: map[string]int64{CurrentLabel: 2},
+	}, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	history, err := secretStore.ListVersions("foo")
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	expectedHistory := &api.SecretHistory{
+		Name: "foo",
+		Versions: []*api.SecretSummary{
+			{Name: "foo", Serial: 1},
+			{Name: "foo", Serial: 2, Active: true},
+		},
+		Labels: map[string]int64{CurrentLabel: 2},
+	}
+	if !reflect.DeepEqual(history, expectedHistory) {
+		t.Errorf("Mismatch between expected and listed versions: %v != %v", history, expectedHistory)
+	}
+}
+
+func TestListVersionsSecretDoesNotExist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	mockDAO.EXPECT().ListVersions("foo").Return(nil, nil)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.ListVersions("foo")
+	if err == nil {
+		t.Error("Expected error listing versions of non existent secret")
+	}
+}
+
+func TestGetReturnsMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	loadedSecret := &dao.SecretRecord{
+		Name:        "foo",
+		Serial:      7,
+		Active:      true,
+		CreatedAt:   testTime.Unix(),
+		CreatedBy:   testCreatedBy,
+		Description: "rotated",
+		Tags:        map[string]string{"team": "payments"},
+	}
+	gomock.InOrder(
+		mockDAO.EXPECT().GetSecretRecord("foo", int64(7)).Return(loadedSecret, nil),
+		crypter.EXPECT().DecryptSecret(loadedSecret).Return([]byte("foobar"), nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	secret, err := secretStore.Get("foo", "7")
+	if err != nil {
+		t.Fatalf("Error getting secret: %v", err)
+	}
+	expectedSecret := &api.SecretRecord{
+		Name:        "foo",
+		Serial:      7,
+		Active:      true,
+		Payload:     "foobar",
+		CreatedAt:   &testTime,
+		CreatedBy:   testCreatedBy,
+		Description: "rotated",
+		Tags:        map[string]string{"team": "payments"},
+	}
+	if !reflect.DeepEqual(secret, expectedSecret) {
+		t.Errorf("Mismatch between expected and retrieved secret: %v != %v", secret, expectedSecret)
+	}
+}
+
+func TestSaveWithDescriptionAndTags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	newSecret := &dao.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		Serial:      1,
+		CreatedAt:   testTime.Unix(),
+		CreatedBy:   testCreatedBy,
+		Description: "initial",
+		Tags:        map[string]string{"team": "payments"},
+	}
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("bar").Return(nil, nil),
+		crypter.EXPECT().EncryptSecret(newSecret, []byte("foobar")).Return(nil, nil),
+		mockDAO.EXPECT().PutSecretRecord(newSecret).Return(nil),
+	)
+	expectNoRetentionPolicy(mockDAO, "bar")
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:        "bar",
+		Active:      true,
+		Serial:      1,
+		Payload:     "foobar",
+		Description: "initial",
+		Tags:        map[string]string{"team": "payments"},
+	})
+	if err != nil {
+		t.Fatalf("Error saving secret: %v", err)
+	}
+}
+
+func TestSaveCallerIdentityError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	identityProvider.EXPECT().CallerIdentity().Return("", fmt.Errorf("who am i"))
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{
+		Name:    "bar",
+		Active:  true,
+		Serial:  1,
+		Payload: "foobar",
+	})
+	if err == nil {
+		t.Error("Expected error saving secret")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	crypter := mock_crypt.NewMockCrypter(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+
+	deletion := &dao.Deletion{
+		DeletedAt: testTime.Unix(),
+		DeletedBy: testCreatedBy,
+		PurgeAt:   testTime.Add(7 * 24 * time.Hour).Unix(),
+	}
+	gomock.InOrder(
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1, PurgeAt: testTime.Unix()},
+			{Name: "foo", Serial: 2},
+			{Name: "foo", Serial: 3},
+		}, nil),
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().ScheduleSecretRecordDeletion("foo", int64(2), deletion).Return(nil),
+		mockDAO.EXPECT().ScheduleSecretRecordDeletion("foo", int64(3), deletion).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, crypter, identityProvider)
+	purgeAt, err := secretStore.Delete("foo", MinRecoveryWindow)
+	if err != nil {
+		t.Fatalf("Error deleting secret: %v", err)
+	}
+	if purgeAt.Unix() != deletion.PurgeAt {
+		t.Errorf("Incorrect purge time: %v", purgeAt)
+	}
+}
+
+func TestDeleteInvalidRecoveryWindow(t *testing.T) {
+	secretStore := newTestStore(nil, nil, nil)
+	for _, recoveryWindow := range []time.Duration{0, 6 * 24 * time.Hour, 31 * 24 * time.Hour} {
+		_, err := secretStore.Delete("foo", recoveryWindow)
+		if err == nil {
+			t.Errorf("Expected error deleting secret with recovery window %v", recoveryWindow)
+		}
+	}
+}
+
+func TestDeleteAlreadyScheduled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+		{Name: "foo", Serial: 1, PurgeAt: testTime.Unix()},
+	}, nil)
+
+	secretStore := newTestStore(mockDAO, nil, nil)
+	_, err := secretStore.Delete("foo", DefaultRecoveryWindow)
+	if err == nil {
+		t.Error("Expected error deleting secret that is already scheduled for deletion")
+	}
+}
+
+func TestUndelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	gomock.InOrder(
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1},
+			{Name: "foo", Serial: 2, PurgeAt: testTime.Unix()},
+		}, nil),
+		mockDAO.EXPECT().CancelSecretRecordDeletion("foo", int64(2)).Return(nil),
+	)
+
+	secretStore := newTestStore(mockDAO, nil, nil)
+	err := secretStore.Undelete("foo")
+	if err != nil {
+		t.Errorf("Error undeleting secret: %v", err)
+	}
+}
+
+func TestUndeleteNotScheduled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{{Name: "foo", Serial: 1}}, nil)
+
+	secretStore := newTestStore(mockDAO, nil, nil)
+	err := secretStore.Undelete("foo")
+	if err == nil {
+		t.Error("Expected error undeleting secret that is not scheduled for deletion")
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	due := testTime.Unix() - 1
+	gomock.InOrder(
+		mockDAO.EXPECT().ListSecrets("", "", defaultListLimit).Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 2, PurgeAt: due},
+			{Name: "bar", Serial: 1, PurgeAt: testTime.Unix() + 1},
+		}, "bar", nil),
+		mockDAO.EXPECT().ListSecrets("", "bar", defaultListLimit).Return([]*dao.SecretRecord{
+			{Name: "baz", Serial: 1},
+			{Name: "qux", Serial: 1, PurgeAt: due},
+		}, "", nil),
+		mockDAO.EXPECT().ListVersions("foo").Return([]*dao.SecretRecord{
+			{Name: "foo", Serial: 1, PurgeAt: due},
+			{Name: "foo", Serial: 2, PurgeAt: due},
+		}, nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(1)).Return(nil),
+		mockDAO.EXPECT().DeleteSecretRecord("foo", int64(2)).Return(nil),
+		mockDAO.EXPECT().DeleteSecretLabels("foo").Return(nil),
+		// qux was undeleted after it was listed
+		mockDAO.EXPECT().ListVersions("qux").Return([]*dao.SecretRecord{{Name: "qux", Serial: 1}}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, nil, nil)
+	purged, err := secretStore.CollectGarbage()
+	if err != nil {
+		t.Fatalf("Error collecting garbage: %v", err)
+	}
+	if !reflect.DeepEqual(purged, []string{"foo"}) {
+		t.Errorf("Unexpected secrets purged: %v", purged)
+	}
+}
+
+func TestGetScheduledForDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	mockDAO.EXPECT().GetLatestActiveVersion("foo", testTime.Unix()).Return(&dao.SecretRecord{
+		Name:    "foo",
+		Serial:  1,
+		Active:  true,
+		PurgeAt: testTime.Unix(),
+	}, nil)
+
+	secretStore := newTestStore(mockDAO, nil, nil)
+	_, err := secretStore.Get("foo", "")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("Expected NotFoundError getting secret scheduled for deletion, got %v", err)
+	}
+}
+
+func TestListHidesScheduledForDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	mockDAO.EXPECT().ListSecrets("", "", defaultListLimit).Return([]*dao.SecretRecord{
+		{Name: "foo", Serial: 1, PurgeAt: testTime.Unix()},
+		{Name: "bar", Serial: 1},
+	}, "", nil)
+
+	secretStore := newTestStore(mockDAO, nil, nil)
+	secretList, err := secretStore.List("", "", 0)
+	if err != nil {
+		t.Fatalf("Error listing secrets: %v", err)
+	}
+	if len(secretList.Secrets) != 1 || secretList.Secrets[0].Name != "bar" {
+		t.Errorf("Unexpected secrets listed: %v", secretList.Secrets)
+	}
+}
+
+func TestSaveScheduledForDeletion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDAO := mock_dao.NewMockDAO(ctrl)
+	identityProvider := mock_identity.NewMockProvider(ctrl)
+	gomock.InOrder(
+		identityProvider.EXPECT().CallerIdentity().Return(testCreatedBy, nil),
+		mockDAO.EXPECT().GetLatestVersion("foo").Return(&dao.SecretRecord{Name: "foo", Serial: 1, PurgeAt: testTime.Unix()}, nil),
+	)
+
+	secretStore := newTestStore(mockDAO, nil, identityProvider)
+	_, err := secretStore.Save(&api.SecretRecord{Name: "foo", Active: true, Payload: "bar"})
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected ConflictError saving secret scheduled for deletion, got %v", err)
+	}
+}
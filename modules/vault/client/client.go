@@ -0,0 +1,378 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:generate mockgen.sh github.com/awslabs/ecs-secrets/modules/vault/client Client mock/client_mock.go
+
+// Client defines the subset of the API of the Vault KV version 2 secrets
+// engine used to store secrets. Paths are relative to the mount of the
+// secrets engine. Data keys are generated and decrypted with the transit
+// secrets engine, and the token in use can be looked up to identify callers
+type Client interface {
+	DeleteMetadata(path string) error
+	DeleteVersions(path string, versions []int64) error
+	DestroyVersions(path string, versions []int64) error
+	List(path string) ([]string, error)
+	ReadMetadata(path string) (*Metadata, error)
+	ReadVersion(path string, version int64) (*Version, error)
+	UndeleteVersions(path string, versions []int64) error
+	WriteMetadata(path string, maxVersions int64) error
+	WriteVersion(path string, data interface{}, cas int64) (*VersionMetadata, error)
+	GenerateDataKey(keyName string) (*DataKey, error)
+	Decrypt(keyName string, ciphertext string) ([]byte, error)
+	LookupSelf() (*TokenInfo, error)
+}
+
+const (
+	// DefaultMount is the path the KV version 2 secrets engine is mounted at
+	// by default
+	DefaultMount = "secret"
+	// DefaultAppRoleMount is the path the AppRole auth method is mounted at
+	// by default
+	DefaultAppRoleMount = "approle"
+	// DefaultTransitMount is the path the transit secrets engine is mounted
+	// at by default
+	DefaultTransitMount = "transit"
+	// dataKeyBits is the size of the data keys generated by the transit
+	// secrets engine
+	dataKeyBits = 256
+
+	tokenHeader     = "X-Vault-Token"
+	namespaceHeader = "X-Vault-Namespace"
+	// casMismatchMessage is the error returned by Vault when a check-and-set
+	// write does not match the current version
+	casMismatchMessage = "check-and-set parameter did not match the current version"
+	// tokenRenewWindow is how long before its lease expires that a token
+	// obtained by logging in is replaced
+	tokenRenewWindow = 30 * time.Second
+)
+
+// Error is returned when Vault responds with an error status
+type Error struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("Vault responded with status %d: %s", err.StatusCode, strings.Join(err.Errors, ", "))
+}
+
+// IsNotFound returns true if the error is returned by Vault for a path or a
+// version that does not exist
+func IsNotFound(err error) bool {
+	vaultErr, ok := err.(*Error)
+	return ok && vaultErr.StatusCode == http.StatusNotFound
+}
+
+// IsCheckAndSetMismatch returns true if the error is returned by Vault for a
+// write whose check-and-set version does not match the current version
+func IsCheckAndSetMismatch(err error) bool {
+	vaultErr, ok := err.(*Error)
+	if !ok || vaultErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, message := range vaultErr.Errors {
+		if strings.Contains(message, casMismatchMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config defines how to reach and authenticate with Vault. Requests are
+// authenticated with Token if it is set, otherwise by logging in with the
+// AppRole credentials
+type Config struct {
+	Address      string
+	Mount        string
+	Namespace    string
+	Token        string
+	RoleID       string
+	SecretID     string
+	AppRoleMount string
+	TransitMount string
+	HTTPClient   *http.Client
+}
+
+// vaultClient implements the Client interface over the HTTP API of Vault
+type vaultClient struct {
+	sync.Mutex
+	config      Config
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewClient creates a new Vault client
+func NewClient(config Config) (Client, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("Missing Vault address")
+	}
+	if config.Token == "" && config.RoleID == "" {
+		return nil, fmt.Errorf("Missing Vault token or AppRole role ID")
+	}
+	if config.Mount == "" {
+		config.Mount = DefaultMount
+	}
+	if config.AppRoleMount == "" {
+		config.AppRoleMount = DefaultAppRoleMount
+	}
+	if config.TransitMount == "" {
+		config.TransitMount = DefaultTransitMount
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	return &vaultClient{config: config}, nil
+}
+
+func (c *vaultClient) DeleteMetadata(path string) error {
+	return c.send("DELETE", c.enginePath("metadata", path), nil, nil, nil)
+}
+
+func (c *vaultClient) DeleteVersions(path string, versions []int64) error {
+	return c.send("POST", c.enginePath("delete", path), nil, &versionsInput{Versions: versions}, nil)
+}
+
+func (c *vaultClient) DestroyVersions(path string, versions []int64) error {
+	return c.send("PUT", c.enginePath("destroy", path), nil, &versionsInput{Versions: versions}, nil)
+}
+
+// List lists the keys under the path. Keys ending with '/' are folders
+func (c *vaultClient) List(path string) ([]string, error) {
+	output := &listOutput{}
+	query := url.Values{"list": []string{"true"}}
+	err := c.send("GET", c.enginePath("metadata", path), query, nil, output)
+	return output.Data.Keys, err
+}
+
+func (c *vaultClient) ReadMetadata(path string) (*Metadata, error) {
+	output := &metadataOutput{}
+	err := c.send("GET", c.enginePath("metadata", path), nil, nil, output)
+	return output.Data, err
+}
+
+// ReadVersion reads a version of the secret at the path, or its current
+// version if version is 0
+func (c *vaultClient) ReadVersion(path string, version int64) (*Version, error) {
+	output := &versionOutput{}
+	var query url.Values
+	if version != 0 {
+		query = url.Values{"version": []string{strconv.FormatInt(version, 10)}}
+	}
+	err := c.send("GET", c.enginePath("data", path), query, nil, output)
+	return output.Data, err
+}
+
+func (c *vaultClient) UndeleteVersions(path string, versions []int64) error {
+	return c.send("POST", c.enginePath("undelete", path), nil, &versionsInput{Versions: versions}, nil)
+}
+
+// WriteMetadata sets the number of versions Vault keeps of the secret at the
+// path, creating the secret if it does not exist. Vault destroys the oldest
+// versions beyond that
+func (c *vaultClient) WriteMetadata(path string, maxVersions int64) error {
+	return c.send("POST", c.enginePath("metadata", path), nil, &metadataInput{MaxVersions: maxVersions}, nil)
+}
+
+// WriteVersion writes a new version of the secret at the path, if its current
+// version is cas. A cas of 0 only allows the write if the secret does not
+// exist
+func (c *vaultClient) WriteVersion(path string, data interface{}, cas int64) (*VersionMetadata, error) {
+	output := &writeOutput{}
+	input := &writeInput{
+		Options: writeOptions{CAS: cas},
+		Data:    data,
+	}
+	err := c.send("POST", c.enginePath("data", path), nil, input, output)
+	return output.Data, err
+}
+
+// GenerateDataKey generates a new data key with the transit secrets engine,
+// returning it both in plaintext and encrypted with the named key
+func (c *vaultClient) GenerateDataKey(keyName string) (*DataKey, error) {
+	output := &dataKeyOutput{}
+	path := c.config.TransitMount + "/datakey/plaintext/" + keyName
+	err := c.send("POST", path, nil, &dataKeyInput{Bits: dataKeyBits}, output)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(output.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding data key from Vault: %v", err)
+	}
+	return &DataKey{
+		Plaintext:  plaintext,
+		Ciphertext: output.Data.Ciphertext,
+	}, nil
+}
+
+// Decrypt decrypts the ciphertext with the named key of the transit secrets
+// engine
+func (c *vaultClient) Decrypt(keyName string, ciphertext string) ([]byte, error) {
+	output := &decryptOutput{}
+	path := c.config.TransitMount + "/decrypt/" + keyName
+	err := c.send("POST", path, nil, &decryptInput{Ciphertext: ciphertext}, output)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(output.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding plaintext from Vault: %v", err)
+	}
+	return plaintext, nil
+}
+
+// LookupSelf looks up the token requests are authenticated with
+func (c *vaultClient) LookupSelf() (*TokenInfo, error) {
+	output := &tokenOutput{}
+	err := c.send("GET", "auth/token/lookup-self", nil, nil, output)
+	if err != nil {
+		return nil, err
+	}
+	if output.Data == nil {
+		return nil, fmt.Errorf("Error looking up Vault token: no token returned")
+	}
+	return output.Data, nil
+}
+
+func (c *vaultClient) enginePath(endpoint string, path string) string {
+	return c.config.Mount + "/" + endpoint + "/" + path
+}
+
+// send sends an authenticated request, unmarshalling the response into output.
+// A token obtained by logging in with AppRole is replaced once if Vault
+// refuses it, as it may have been revoked
+func (c *vaultClient) send(method string, path string, query url.Values, input interface{}, output interface{}) error {
+	token, err := c.getToken()
+	if err != nil {
+		return err
+	}
+	err = c.do(method, path, query, token, input, output)
+	if vaultErr, ok := err.(*Error); ok && vaultErr.StatusCode == http.StatusForbidden && c.config.Token == "" {
+		c.resetToken(token)
+		token, err = c.getToken()
+		if err != nil {
+			return err
+		}
+		err = c.do(method, path, query, token, input, output)
+	}
+	return err
+}
+
+func (c *vaultClient) do(method string, path string, query url.Values, token string, input interface{}, output interface{}) error {
+	var body []byte
+	if input != nil {
+		var err error
+		body, err = json.Marshal(input)
+		if err != nil {
+			return err
+		}
+	}
+	requestURL := c.config.Address + "/v1/" + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	request, err := http.NewRequest(method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set(tokenHeader, token)
+	}
+	if c.config.Namespace != "" {
+		request.Header.Set(namespaceHeader, c.config.Namespace)
+	}
+	if input != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.config.HTTPClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("Error calling Vault: %v", err)
+	}
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response from Vault: %v", err)
+	}
+
+	if response.StatusCode >= 400 {
+		vaultErr := &Error{StatusCode: response.StatusCode}
+		// Errors are best effort, not every error response has a body
+		json.Unmarshal(responseBody, vaultErr)
+		return vaultErr
+	}
+	if output == nil || len(responseBody) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(responseBody, output)
+	if err != nil {
+		return fmt.Errorf("Error decoding response from Vault: %v", err)
+	}
+	return nil
+}
+
+// getToken returns the token to authenticate requests with, logging in with
+// AppRole if there is no static token and the last token is about to expire
+func (c *vaultClient) getToken() (string, error) {
+	if c.config.Token != "" {
+		return c.config.Token, nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.token != "" && (c.tokenExpiry.IsZero() || time.Now().Before(c.tokenExpiry)) {
+		return c.token, nil
+	}
+
+	output := &loginOutput{}
+	input := &loginInput{RoleID: c.config.RoleID, SecretID: c.config.SecretID}
+	err := c.do("POST", "auth/"+c.config.AppRoleMount+"/login", nil, "", input, output)
+	if err != nil {
+		return "", fmt.Errorf("Error logging in to Vault with AppRole: %v", err)
+	}
+	if output.Auth == nil || output.Auth.ClientToken == "" {
+		return "", fmt.Errorf("Error logging in to Vault with AppRole: no token returned")
+	}
+	c.token = output.Auth.ClientToken
+	c.tokenExpiry = time.Time{}
+	if output.Auth.LeaseDuration > 0 {
+		c.tokenExpiry = time.Now().Add(time.Duration(output.Auth.LeaseDuration)*time.Second - tokenRenewWindow)
+	}
+	return c.token, nil
+}
+
+// resetToken forgets the token, unless it has already been replaced
+func (c *vaultClient) resetToken(token string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
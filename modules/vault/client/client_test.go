@@ -0,0 +1,247 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestWriteVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/kv/data/ecs-secrets/myapp/secrets/foo" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if token := r.Header.Get(tokenHeader); token != "s.token" {
+			t.Errorf("Unexpected token: %s", token)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		expected := `{"options":{"cas":2},"data":{"foo":"bar"}}`
+		if string(body) != expected {
+			t.Errorf("Unexpected request body: %s != %s", body, expected)
+		}
+		w.Write([]byte(`{"data":{"version":3,"created_time":"2017-01-01T00:00:00Z"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Address: server.URL, Mount: "kv", Token: "s.token"})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	output, err := client.WriteVersion("ecs-secrets/myapp/secrets/foo", map[string]string{"foo": "bar"}, 2)
+	if err != nil {
+		t.Fatalf("Error writing version: %v", err)
+	}
+	if output.Version != 3 {
+		t.Errorf("Expected version 3, got %d", output.Version)
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/secret/metadata/ecs-secrets/myapp/secrets/foo" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		expected := `{"max_versions":100}`
+		if string(body) != expected {
+			t.Errorf("Unexpected request body: %s != %s", body, expected)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL, Token: "s.token"})
+	err := client.WriteMetadata("ecs-secrets/myapp/secrets/foo", 100)
+	if err != nil {
+		t.Errorf("Error writing metadata: %v", err)
+	}
+}
+
+func TestWriteVersionCheckAndSetMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL, Token: "s.token"})
+	_, err := client.WriteVersion("foo", map[string]string{}, 0)
+	if !IsCheckAndSetMismatch(err) {
+		t.Errorf("Expected check-and-set mismatch, got: %v", err)
+	}
+	if IsNotFound(err) {
+		t.Error("Expected check-and-set mismatch not to be reported as not found")
+	}
+}
+
+func TestList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/metadata/ecs-secrets/myapp/secrets/" || r.URL.Query().Get("list") != "true" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Write([]byte(`{"data":{"keys":["db/","token"]}}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL + "/", Token: "s.token"})
+	keys, err := client.List("ecs-secrets/myapp/secrets/")
+	if err != nil {
+		t.Fatalf("Error listing keys: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"db/", "token"}) {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+}
+
+func TestReadVersionNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("version") != "4" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL, Token: "s.token"})
+	_, err := client.ReadVersion("foo", 4)
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+}
+
+func TestAppRoleLogin(t *testing.T) {
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" {
+			input := &loginInput{}
+			json.NewDecoder(r.Body).Decode(input)
+			if input.RoleID != "role" || input.SecretID != "secret" {
+				t.Errorf("Unexpected login: %v", input)
+			}
+			logins++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"auth": map[string]interface{}{
+					"client_token":   "s.login" + strconv.Itoa(logins),
+					"lease_duration": 3600,
+				},
+			})
+			return
+		}
+		// The first token is refused, as if it had been revoked
+		if r.Header.Get(tokenHeader) != "s.login2" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"current_version":1}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Address: server.URL, RoleID: "role", SecretID: "secret"})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		metadata, err := client.ReadMetadata("foo")
+		if err != nil {
+			t.Fatalf("Error reading metadata: %v", err)
+		}
+		if metadata.CurrentVersion != 1 {
+			t.Errorf("Unexpected metadata: %v", metadata)
+		}
+	}
+	if logins != 2 {
+		t.Errorf("Expected to log in again once after the token was refused, logged in %d times", logins)
+	}
+}
+
+func TestGenerateDataKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/transit/datakey/plaintext/ecs-secrets-myapp" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"bits":256}` {
+			t.Errorf("Unexpected request body: %s", body)
+		}
+		w.Write([]byte(`{"data":{"plaintext":"a2V5","ciphertext":"vault:v1:wrapped"}}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL, Token: "s.token"})
+	dataKey, err := client.GenerateDataKey("ecs-secrets-myapp")
+	if err != nil {
+		t.Fatalf("Error generating data key: %v", err)
+	}
+	if string(dataKey.Plaintext) != "key" || dataKey.Ciphertext != "vault:v1:wrapped" {
+		t.Errorf("Unexpected data key: %v", dataKey)
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/encryption/decrypt/ecs-secrets-myapp" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"ciphertext":"vault:v1:wrapped"}` {
+			t.Errorf("Unexpected request body: %s", body)
+		}
+		w.Write([]byte(`{"data":{"plaintext":"a2V5"}}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL, TransitMount: "encryption", Token: "s.token"})
+	plaintext, err := client.Decrypt("ecs-secrets-myapp", "vault:v1:wrapped")
+	if err != nil {
+		t.Fatalf("Error decrypting: %v", err)
+	}
+	if string(plaintext) != "key" {
+		t.Errorf("Unexpected plaintext: %s", plaintext)
+	}
+}
+
+func TestLookupSelf(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/auth/token/lookup-self" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"data":{"display_name":"approle","entity_id":"7d2e3179"}}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Address: server.URL, Token: "s.token"})
+	info, err := client.LookupSelf()
+	if err != nil {
+		t.Fatalf("Error looking up token: %v", err)
+	}
+	expected := &TokenInfo{DisplayName: "approle", EntityID: "7d2e3179"}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("Unexpected token info: %v != %v", info, expected)
+	}
+}
+
+func TestNewClientMissingCredentials(t *testing.T) {
+	_, err := NewClient(Config{Address: "http://127.0.0.1:8200"})
+	if err == nil {
+		t.Error("Expected error creating a client without a token or role ID")
+	}
+}
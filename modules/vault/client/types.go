@@ -0,0 +1,131 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+)
+
+// Metadata defines the metadata of a secret, with the metadata of each of its
+// versions keyed by version. MaxVersions is 0 if the secret keeps as many
+// versions as the secrets engine is configured to keep, which is 10 unless
+// configured otherwise
+type Metadata struct {
+	CurrentVersion int64                      `json:"current_version"`
+	OldestVersion  int64                      `json:"oldest_version"`
+	MaxVersions    int64                      `json:"max_versions"`
+	Versions       map[int64]*VersionMetadata `json:"versions"`
+}
+
+// VersionMetadata defines the metadata of a version of a secret. DeletionTime
+// is set once the version is deleted, and can be in the future if versions of
+// the secret are deleted automatically
+type VersionMetadata struct {
+	Version      int64  `json:"version"`
+	CreatedTime  string `json:"created_time"`
+	DeletionTime string `json:"deletion_time"`
+	Destroyed    bool   `json:"destroyed"`
+}
+
+// Version defines a version of a secret along with its metadata
+type Version struct {
+	Data     json.RawMessage  `json:"data"`
+	Metadata *VersionMetadata `json:"metadata"`
+}
+
+type versionOutput struct {
+	Data *Version `json:"data"`
+}
+
+type metadataOutput struct {
+	Data *Metadata `json:"data"`
+}
+
+type listOutput struct {
+	Data struct {
+		Keys []string `json:"keys"`
+	} `json:"data"`
+}
+
+type metadataInput struct {
+	MaxVersions int64 `json:"max_versions"`
+}
+
+type writeOptions struct {
+	CAS int64 `json:"cas"`
+}
+
+type writeInput struct {
+	Options writeOptions `json:"options"`
+	Data    interface{}  `json:"data"`
+}
+
+type writeOutput struct {
+	Data *VersionMetadata `json:"data"`
+}
+
+// DataKey defines a data key generated by the transit secrets engine. The
+// ciphertext is the data key encrypted with a key of the engine
+type DataKey struct {
+	Plaintext  []byte
+	Ciphertext string
+}
+
+// TokenInfo defines the properties of a token used to identify the caller.
+// The entity ID is empty for tokens that are not tied to an identity entity
+type TokenInfo struct {
+	DisplayName string `json:"display_name"`
+	EntityID    string `json:"entity_id"`
+}
+
+type dataKeyInput struct {
+	Bits int `json:"bits"`
+}
+
+type dataKeyOutput struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+}
+
+type decryptInput struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type decryptOutput struct {
+	Data struct {
+		Plaintext string `json:"plaintext"`
+	} `json:"data"`
+}
+
+type tokenOutput struct {
+	Data *TokenInfo `json:"data"`
+}
+
+type versionsInput struct {
+	Versions []int64 `json:"versions"`
+}
+
+type loginInput struct {
+	RoleID   string `json:"role_id"`
+	SecretID string `json:"secret_id,omitempty"`
+}
+
+type loginOutput struct {
+	Auth *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+	} `json:"auth"`
+}